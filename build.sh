@@ -46,11 +46,14 @@ BUILDFLAGS="-trimpath"  # Remove file system paths from binary
 
 # Set up ldflags
 LDFLAGS="-s -w"  # Strip debug information and symbol tables
+PKG="github.com/Automata-Labs-team/code-sandbox-mcp/installer"
+GIT_COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo "unknown")
+LDFLAGS="$LDFLAGS -X '${PKG}.GitCommit=$GIT_COMMIT'"
 if [ "$RELEASE" = true ]; then
     # Add version information for release builds
-    LDFLAGS="$LDFLAGS -X 'main.Version=$VERSION' -X 'main.BuildMode=release'"
+    LDFLAGS="$LDFLAGS -X '${PKG}.Version=$VERSION' -X '${PKG}.BuildMode=release'"
 else
-    LDFLAGS="$LDFLAGS -X 'main.BuildMode=development'"
+    LDFLAGS="$LDFLAGS -X '${PKG}.BuildMode=development'"
 fi
 
 # Function to build for a specific platform
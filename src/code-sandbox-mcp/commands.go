@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/installer"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+)
+
+// subcommands maps each subcommand to its entry point; serve runs when none is given
+var subcommands = map[string]func(args []string){
+	"serve":      serve,
+	"install":    runInstall,
+	"uninstall":  runUninstall,
+	"doctor":     runDoctor,
+	"completion": runCompletion,
+}
+
+const usage = `Usage: code-sandbox-mcp [command] [flags]
+
+Commands:
+  serve        Run the MCP server (default)
+  install      Add this binary to the Claude Desktop config
+  uninstall    Remove this server from the Claude Desktop config
+  doctor       Check that everything needed to run the server works
+  completion   Print a shell completion script (bash or zsh)
+
+Run 'code-sandbox-mcp serve -h' for the server flags.
+`
+
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	run, ok := subcommands[command]
+	if !ok {
+		if command != "help" {
+			fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", command)
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	run(args)
+}
+
+// configPathUsage describes the --config-path flag of install, uninstall and doctor
+const configPathUsage = "Claude Desktop config file, or the directory holding it, to use instead of the detected one"
+
+func runInstall(args []string) {
+	flags := flag.NewFlagSet("install", flag.ExitOnError)
+	configPath := flags.String("config-path", "", configPathUsage)
+	flags.Parse(args)
+	if err := installer.InstallConfig(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runUninstall(args []string) {
+	flags := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	configPath := flags.String("config-path", "", configPathUsage)
+	flags.Parse(args)
+	if err := installer.UninstallConfig(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runDoctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	image := flags.String("image", tools.DefaultImage, "Image to check for")
+	configPath := flags.String("config-path", "", configPathUsage)
+	flags.Parse(args)
+	os.Exit(installer.RunDoctor(context.Background(), os.Stdout, *image, *configPath))
+}
+
+const bashCompletion = `_code_sandbox_mcp() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "serve install uninstall doctor completion --port --transport --events --events-file --otel-endpoint --confirm-destructive --confirm-timeout --debug-docker --no-update --install" -- "$cur"))
+        return
+    fi
+    case "${COMP_WORDS[1]}" in
+        serve|-*) COMPREPLY=($(compgen -W "--port --transport --events --events-file --otel-endpoint --confirm-destructive --confirm-timeout --debug-docker --no-update --install" -- "$cur")) ;;
+        install|uninstall) COMPREPLY=($(compgen -W "--config-path" -- "$cur")) ;;
+        doctor) COMPREPLY=($(compgen -W "--image --config-path" -- "$cur")) ;;
+        completion) COMPREPLY=($(compgen -W "bash zsh" -- "$cur")) ;;
+    esac
+}
+complete -F _code_sandbox_mcp code-sandbox-mcp
+`
+
+const zshCompletion = `#compdef code-sandbox-mcp
+
+_code_sandbox_mcp() {
+    local -a commands
+    commands=(
+        'serve:Run the MCP server (default)'
+        'install:Add this binary to the Claude Desktop config'
+        'uninstall:Remove this server from the Claude Desktop config'
+        'doctor:Check that everything needed to run the server works'
+        'completion:Print a shell completion script'
+    )
+    if (( CURRENT == 2 )); then
+        _describe 'command' commands
+        return
+    fi
+    case "$words[2]" in
+        serve) _arguments '--port[Port to listen on]:port:' '--transport[Transport to use]:transport:(stdio sse)' '--events[Emit structured events]:format:(jsonl)' '--events-file[Write events to this file]:file:_files' '--otel-endpoint[Export traces over OTLP/HTTP]:url:' '--confirm-destructive[Ask the user to approve destructive operations]' '--confirm-timeout[Deny unapproved operations after]:duration:' '--debug-docker[Record Docker API calls]' '--no-update[Disable auto-update check]' ;;
+        install|uninstall) _arguments '--config-path[Claude Desktop config to use]:file:_files' ;;
+        doctor) _arguments '--image[Image to check for]:image:' '--config-path[Claude Desktop config to use]:file:_files' ;;
+        completion) _values 'shell' bash zsh ;;
+    esac
+}
+
+_code_sandbox_mcp "$@"
+`
+
+func runCompletion(args []string) {
+	flags := flag.NewFlagSet("completion", flag.ExitOnError)
+	flags.Parse(args)
+	switch flags.Arg(0) {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: code-sandbox-mcp completion bash|zsh")
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,119 @@
+package confirm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultTimeout is how long the user has to answer a confirmation before the call is denied. It stays under the
+// call deadline of the watchdog, so a denial arrives before the call is abandoned.
+const DefaultTimeout = 30 * time.Second
+
+// Action describes a destructive call for the user to approve
+type Action struct {
+	Summary string   // what the call does, e.g. "stop and remove container db-1"
+	Reason  string   // why it needs approval
+	Paths   []string // paths it reads or writes, if any
+}
+
+// Describer returns the action of a call that needs approval, or nil for a call that may run unasked
+type Describer func(ctx context.Context, request mcp.CallToolRequest) *Action
+
+// Elicitor sends an elicitation request to the client of the call in ctx; *server.MCPServer is one
+type Elicitor interface {
+	RequestElicitation(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error)
+}
+
+// approvalSchema is the form of the confirmation: a single checkbox
+var approvalSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"approve": map[string]any{
+			"type":        "boolean",
+			"title":       "Approve",
+			"description": "Run this operation",
+		},
+	},
+	"required": []string{"approve"},
+}
+
+// Middleware asks the user, through an elicitation request, to approve each call describe flags, and runs it only
+// when they do. A decline, a cancel or no answer within timeout fails the call with CONFIRMATION_DENIED; a client
+// without elicitation support fails it with CONFIRMATION_UNAVAILABLE.
+func Middleware(elicitor Elicitor, describe Describer, timeout time.Duration) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			action := describe(ctx, request)
+			if action == nil {
+				return next(ctx, request)
+			}
+			tool := request.Params.Name
+			if !clientSupportsElicitation(ctx) {
+				return unavailable(tool), nil
+			}
+
+			askCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			result, err := elicitor.RequestElicitation(askCtx, mcp.ElicitationRequest{
+				Params: mcp.ElicitationParams{
+					Message:         message(tool, action),
+					RequestedSchema: approvalSchema,
+				},
+			})
+			switch {
+			case errors.Is(err, server.ErrElicitationNotSupported) || errors.Is(err, server.ErrNoActiveSession):
+				return unavailable(tool), nil
+			case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+				return mcp.NewToolResultError(fmt.Sprintf("CONFIRMATION_DENIED: %s was not approved within %s: %s", tool, timeout, action.Summary)), nil
+			case err != nil:
+				return mcp.NewToolResultError(fmt.Sprintf("CONFIRMATION_DENIED: %s could not be confirmed: %v", tool, err)), nil
+			case !approved(result):
+				return mcp.NewToolResultError(fmt.Sprintf("CONFIRMATION_DENIED: the user did not approve %s: %s", tool, action.Summary)), nil
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// clientSupportsElicitation reports whether the client of the call declared elicitation when it initialized;
+// sessions that don't record capabilities are given the benefit of the doubt
+func clientSupportsElicitation(ctx context.Context) bool {
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if !ok {
+		return true
+	}
+	return session.GetClientCapabilities().Elicitation != nil
+}
+
+// approved reports whether the user accepted the form with the box checked
+func approved(result *mcp.ElicitationResult) bool {
+	if result == nil || result.Action != mcp.ElicitationResponseActionAccept {
+		return false
+	}
+	content, _ := result.Content.(map[string]any)
+	approve, _ := content["approve"].(bool)
+	return approve
+}
+
+// message describes the action for the user
+func message(tool string, action *Action) string {
+	lines := []string{fmt.Sprintf("%s wants to %s.", tool, action.Summary)}
+	if action.Reason != "" {
+		lines = append(lines, action.Reason)
+	}
+	for _, p := range action.Paths {
+		lines = append(lines, "Path: "+p)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func unavailable(tool string) *mcp.CallToolResult {
+	return mcp.NewToolResultError(fmt.Sprintf("CONFIRMATION_UNAVAILABLE: %s needs the user's approval, but the client doesn't support elicitation; "+
+		"ask the user to restart the server without --confirm-destructive to allow it", tool))
+}
@@ -0,0 +1,148 @@
+package confirm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// basicSession is a client session that records its capabilities but can't be sent requests
+type basicSession struct {
+	capabilities mcp.ClientCapabilities
+}
+
+func (s *basicSession) SessionID() string { return "test-session" }
+func (s *basicSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return make(chan mcp.JSONRPCNotification, 1)
+}
+func (s *basicSession) Initialize()       {}
+func (s *basicSession) Initialized() bool { return true }
+func (s *basicSession) GetClientInfo() mcp.Implementation {
+	return mcp.Implementation{Name: "test-client"}
+}
+func (s *basicSession) SetClientInfo(mcp.Implementation)               {}
+func (s *basicSession) GetClientCapabilities() mcp.ClientCapabilities  { return s.capabilities }
+func (s *basicSession) SetClientCapabilities(c mcp.ClientCapabilities) { s.capabilities = c }
+
+// elicitingSession is a client that answers elicitation requests with answer, or never when answer is nil
+type elicitingSession struct {
+	basicSession
+	answer   *mcp.ElicitationResult
+	requests []mcp.ElicitationRequest
+}
+
+func (s *elicitingSession) RequestElicitation(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+	s.requests = append(s.requests, request)
+	if s.answer == nil {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return s.answer, nil
+}
+
+func newElicitingSession(answer *mcp.ElicitationResult) *elicitingSession {
+	return &elicitingSession{basicSession: basicSession{capabilities: mcp.ClientCapabilities{Elicitation: &struct{}{}}}, answer: answer}
+}
+
+func answer(action mcp.ElicitationResponseAction, content any) *mcp.ElicitationResult {
+	return &mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: action, Content: content}}
+}
+
+// stopDescriber flags sandbox_stop calls only
+func stopDescriber(ctx context.Context, request mcp.CallToolRequest) *Action {
+	if request.Params.Name != "sandbox_stop" {
+		return nil
+	}
+	return &Action{Summary: "stop and remove container db-1", Reason: "It wasn't created by sandbox_initialize.", Paths: []string{"/tmp/out.txt"}}
+}
+
+// call runs a tool call through the middleware in a session, reporting whether the handler ran
+func call(t *testing.T, session server.ClientSession, tool string, timeout time.Duration) (*mcp.CallToolResult, bool) {
+	t.Helper()
+	srv := server.NewMCPServer("test", "1.0.0", server.WithElicitation())
+	ran := false
+	handler := Middleware(srv, stopDescriber, timeout)(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ran = true
+		return mcp.NewToolResultText("done"), nil
+	})
+	request := mcp.CallToolRequest{}
+	request.Params.Name = tool
+	result, err := handler(srv.WithContext(context.Background(), session), request)
+	require.NoError(t, err)
+	return result, ran
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	return result.Content[0].(mcp.TextContent).Text
+}
+
+func TestMiddlewareRunsApprovedCalls(t *testing.T) {
+	session := newElicitingSession(answer(mcp.ElicitationResponseActionAccept, map[string]any{"approve": true}))
+	result, ran := call(t, session, "sandbox_stop", time.Second)
+	assert.True(t, ran)
+	assert.False(t, result.IsError)
+
+	require.Len(t, session.requests, 1)
+	params := session.requests[0].Params
+	assert.Equal(t, "sandbox_stop wants to stop and remove container db-1.\nIt wasn't created by sandbox_initialize.\nPath: /tmp/out.txt", params.Message)
+	assert.Equal(t, approvalSchema, params.RequestedSchema)
+}
+
+func TestMiddlewareDeniesCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer *mcp.ElicitationResult
+	}{
+		{"declined", answer(mcp.ElicitationResponseActionDecline, nil)},
+		{"cancelled", answer(mcp.ElicitationResponseActionCancel, nil)},
+		{"accepted unchecked", answer(mcp.ElicitationResponseActionAccept, map[string]any{"approve": false})},
+		{"accepted without content", answer(mcp.ElicitationResponseActionAccept, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ran := call(t, newElicitingSession(tt.answer), "sandbox_stop", time.Second)
+			assert.False(t, ran)
+			assert.True(t, result.IsError)
+			assert.Equal(t, "CONFIRMATION_DENIED: the user did not approve sandbox_stop: stop and remove container db-1", resultText(result))
+		})
+	}
+}
+
+func TestMiddlewareDeniesUnansweredCalls(t *testing.T) {
+	start := time.Now()
+	result, ran := call(t, newElicitingSession(nil), "sandbox_stop", 50*time.Millisecond)
+	assert.False(t, ran)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "CONFIRMATION_DENIED: sandbox_stop was not approved within 50ms: stop and remove container db-1", resultText(result))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestMiddlewareWithoutElicitationSupport(t *testing.T) {
+	for name, session := range map[string]server.ClientSession{
+		// The client didn't declare elicitation when it initialized
+		"not declared": &elicitingSession{},
+		// The transport can't send requests to the client
+		"not supported": &basicSession{capabilities: mcp.ClientCapabilities{Elicitation: &struct{}{}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, ran := call(t, session, "sandbox_stop", time.Second)
+			assert.False(t, ran)
+			assert.True(t, result.IsError)
+			assert.Contains(t, resultText(result), "CONFIRMATION_UNAVAILABLE: sandbox_stop needs the user's approval, but the client doesn't support elicitation")
+			assert.Contains(t, resultText(result), "without --confirm-destructive")
+		})
+	}
+}
+
+func TestMiddlewarePassesOtherCalls(t *testing.T) {
+	session := newElicitingSession(nil)
+	result, ran := call(t, session, "sandbox_exec", time.Second)
+	assert.True(t, ran)
+	assert.Equal(t, "done", resultText(result))
+	assert.Empty(t, session.requests)
+}
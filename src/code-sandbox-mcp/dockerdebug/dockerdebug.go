@@ -0,0 +1,336 @@
+// Package dockerdebug records the Docker API calls the server makes when --debug-docker is set, so a bug report
+// can say what the daemon was asked and what it answered
+package dockerdebug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// DefaultSize is the number of calls kept, oldest dropped first
+	DefaultSize = 200
+	// maxBodyBytes is how much of each request and response body is kept
+	maxBodyBytes = 2048
+	// attachedEntries is how many of a failed tool call's Docker calls are attached to its result
+	attachedEntries = 5
+)
+
+// redacted replaces scrubbed values
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders carry registry credentials
+var sensitiveHeaders = map[string]bool{
+	"Authorization":     true,
+	"X-Registry-Auth":   true,
+	"X-Registry-Config": true,
+}
+
+// binaryTypes are the content types of streams not worth keeping: archives and raw or multiplexed output
+var binaryTypes = []string{
+	"application/x-tar",
+	"application/octet-stream",
+	"application/vnd.docker.raw-stream",
+	"application/vnd.docker.multiplexed-stream",
+}
+
+// Both also match a value the body was cut in the middle of, which runs to the end without its closing quote
+var (
+	// "PASSWORD=hunter2" in a container's Env
+	envValue = regexp.MustCompile(`"([A-Za-z_][A-Za-z0-9_]*)=[^"]*("|$)`)
+	// "password": "hunter2" in registry auth configs
+	credentialField = regexp.MustCompile(`(?i)"(password|auth|identitytoken|registrytoken)"\s*:\s*"[^"]*("|$)`)
+)
+
+// Entry is one recorded Docker API call
+type Entry struct {
+	Seq          int               `json:"seq"`
+	Time         time.Time         `json:"time"`
+	Method       string            `json:"method"`
+	Path         string            `json:"path"`
+	Status       int               `json:"status,omitempty"`
+	DurationMs   int64             `json:"duration_ms"`
+	Headers      map[string]string `json:"request_headers,omitempty"`
+	RequestBody  string            `json:"request_body,omitempty"`
+	ResponseBody string            `json:"response_body,omitempty"`
+	Error        string            `json:"error,omitempty"`
+
+	call      uint64
+	truncated [2]bool // request and response bodies cut at maxBodyBytes
+}
+
+// Failed reports whether the call failed or the daemon answered it with an error
+func (e Entry) Failed() bool {
+	return e.Error != "" || e.Status >= 400
+}
+
+// Recorder keeps the most recent Docker API calls in a ring buffer
+type Recorder struct {
+	mu      sync.Mutex
+	size    int
+	seq     int
+	entries []*Entry // oldest first
+}
+
+// NewRecorder creates a recorder keeping the last size calls
+func NewRecorder(size int) *Recorder {
+	return &Recorder{size: size}
+}
+
+// active is the recorder of --debug-docker, nil when it isn't set
+var active atomic.Pointer[Recorder]
+
+// Enable starts recording the calls of every Docker client created with ClientOpt in a recorder of size calls
+func Enable(size int) *Recorder {
+	r := NewRecorder(size)
+	active.Store(r)
+	return r
+}
+
+// Enabled reports whether --debug-docker is set
+func Enabled() bool {
+	return active.Load() != nil
+}
+
+// Entries returns the recorded calls, oldest first, with credentials scrubbed; nil when recording is off
+func Entries() []Entry {
+	if r := active.Load(); r != nil {
+		return r.Entries()
+	}
+	return nil
+}
+
+// ClientOpt records the calls of a Docker client while --debug-docker is set and does nothing otherwise. It goes
+// after client.FromEnv, which sets up the transport it wraps.
+func ClientOpt(c *client.Client) error {
+	r := active.Load()
+	if r == nil {
+		return nil
+	}
+	httpClient := c.HTTPClient()
+	if base, ok := httpClient.Transport.(*http.Transport); ok {
+		// Client.Close only closes idle connections of an unwrapped transport, so don't keep any
+		base = base.Clone()
+		base.DisableKeepAlives = true
+		// The client only derives the https scheme from an unwrapped transport either
+		if base.TLSClientConfig != nil {
+			if err := client.WithScheme("https")(c); err != nil {
+				return err
+			}
+		}
+		httpClient.Transport = base
+	}
+	httpClient.Transport = r.Transport(httpClient.Transport)
+	return client.WithHTTPClient(httpClient)(c)
+}
+
+// Transport returns a RoundTripper recording each call made through next
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{recorder: r, next: next}
+}
+
+type transport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := &Entry{Time: time.Now().UTC(), Method: req.Method, Path: req.URL.Path, Headers: map[string]string{}}
+	if req.URL.RawQuery != "" {
+		entry.Path += "?" + req.URL.RawQuery
+	}
+	entry.call, _ = req.Context().Value(callKey{}).(uint64)
+	for name := range req.Header {
+		value := req.Header.Get(name)
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			value = redacted
+		}
+		entry.Headers[name] = value
+	}
+	t.recorder.add(entry)
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if isBinary(req.Header.Get("Content-Type")) {
+			t.recorder.setBody(entry, 0, "[binary stream elided]")
+		} else {
+			// Clone before replacing the body; the request belongs to the caller
+			req = req.Clone(req.Context())
+			req.Body = &capture{ReadCloser: req.Body, recorder: t.recorder, entry: entry, which: 0}
+		}
+	}
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.recorder.finish(entry, resp, err, time.Since(started))
+	if err != nil {
+		return resp, err
+	}
+	if isBinary(resp.Header.Get("Content-Type")) {
+		t.recorder.setBody(entry, 1, "[binary stream elided]")
+	} else if resp.Body != nil {
+		resp.Body = &capture{ReadCloser: resp.Body, recorder: t.recorder, entry: entry, which: 1}
+	}
+	return resp, nil
+}
+
+func isBinary(contentType string) bool {
+	for _, binary := range binaryTypes {
+		if strings.HasPrefix(contentType, binary) {
+			return true
+		}
+	}
+	return false
+}
+
+// capture keeps the start of a body as it's read, without holding the rest
+type capture struct {
+	io.ReadCloser
+	recorder *Recorder
+	entry    *Entry
+	which    int // 0 for the request body, 1 for the response body
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.recorder.appendBody(c.entry, c.which, p[:n])
+	}
+	return n, err
+}
+
+func (r *Recorder) add(entry *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	entry.Seq = r.seq
+	r.entries = append(r.entries, entry)
+	if excess := len(r.entries) - r.size; excess > 0 {
+		r.entries = append([]*Entry(nil), r.entries[excess:]...)
+	}
+}
+
+func (r *Recorder) finish(entry *Entry, resp *http.Response, err error, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.DurationMs = duration.Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Status = resp.StatusCode
+	}
+}
+
+func (r *Recorder) setBody(entry *Entry, which int, body string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if which == 0 {
+		entry.RequestBody = body
+	} else {
+		entry.ResponseBody = body
+	}
+}
+
+func (r *Recorder) appendBody(entry *Entry, which int, p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body := &entry.RequestBody
+	if which == 1 {
+		body = &entry.ResponseBody
+	}
+	if room := maxBodyBytes - len(*body); room < len(p) {
+		p = p[:max(room, 0)]
+		entry.truncated[which] = true
+	}
+	*body += string(p)
+}
+
+// Entries returns copies of the recorded calls, oldest first, with credentials scrubbed from their bodies
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]Entry, len(r.entries))
+	for i, entry := range r.entries {
+		entries[i] = *entry
+		entries[i].Headers = make(map[string]string, len(entry.Headers))
+		for name, value := range entry.Headers {
+			entries[i].Headers[name] = value
+		}
+		entries[i].RequestBody = scrub(entry.RequestBody, entry.truncated[0])
+		entries[i].ResponseBody = scrub(entry.ResponseBody, entry.truncated[1])
+	}
+	return entries
+}
+
+// scrub hides environment variable values and registry credentials in a body, which may be cut short
+func scrub(body string, truncated bool) string {
+	body = envValue.ReplaceAllString(body, `"${1}=`+redacted+`${2}`)
+	body = credentialField.ReplaceAllString(body, `"${1}":"`+redacted+`${2}`)
+	if truncated {
+		body += "...[truncated]"
+	}
+	return body
+}
+
+// callKey marks the context of a tool call, so the Docker calls it makes can be told apart from those of others
+type callKey struct{}
+
+var calls atomic.Uint64
+
+// Middleware tags each tool call, and attaches the last Docker calls it made to the result when it fails after
+// the daemon refused one of them
+func Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		r := active.Load()
+		if r == nil {
+			return next(ctx, request)
+		}
+		call := calls.Add(1)
+		result, err := next(context.WithValue(ctx, callKey{}, call), request)
+		if result == nil || !failed(result) {
+			return result, err
+		}
+
+		var made []Entry
+		anyFailed := false
+		for _, entry := range r.Entries() {
+			if entry.call == call {
+				made = append(made, entry)
+				anyFailed = anyFailed || entry.Failed()
+			}
+		}
+		if !anyFailed {
+			return result, err
+		}
+		made = made[max(len(made)-attachedEntries, 0):]
+		data, jsonErr := json.Marshal(made)
+		if jsonErr == nil {
+			result.Content = append(result.Content, mcp.NewTextContent(
+				fmt.Sprintf("Docker API calls (last %d of this call, from --debug-docker): %s", len(made), data)))
+		}
+		return result, err
+	}
+}
+
+// failed reports whether a result is an error; tools report most failures as "Error: ..." text
+func failed(result *mcp.CallToolResult) bool {
+	if result.IsError {
+		return true
+	}
+	if len(result.Content) == 0 {
+		return false
+	}
+	text, _ := result.Content[0].(mcp.TextContent)
+	return strings.HasPrefix(text.Text, "Error")
+}
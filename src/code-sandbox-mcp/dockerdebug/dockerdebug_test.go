@@ -0,0 +1,209 @@
+package dockerdebug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDaemon answers the few Docker API calls the tests make
+func fakeDaemon(t *testing.T) *httptest.Server {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		switch {
+		case r.URL.Path == "/_ping":
+			w.Header().Set("Api-Version", "1.47")
+			fmt.Fprint(w, "OK")
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"Id":"abc123","Warnings":[]}`)
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"pull access denied for private/app"}`)
+		case strings.HasSuffix(r.URL.Path, "/archive"):
+			w.Header().Set("Content-Type", "application/x-tar")
+			fmt.Fprint(w, strings.Repeat("\x00", 1024))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"big":"`+strings.Repeat("x", 3*maxBodyBytes)+`"}`)
+		}
+	}))
+	t.Cleanup(daemon.Close)
+	return daemon
+}
+
+// enable records calls for the rest of the test
+func enable(t *testing.T, size int) *Recorder {
+	r := Enable(size)
+	t.Cleanup(func() { active.Store(nil) })
+	return r
+}
+
+func newClient(t *testing.T, daemon *httptest.Server) *client.Client {
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+daemon.Listener.Addr().String()), client.WithAPIVersionNegotiation(), ClientOpt)
+	require.NoError(t, err)
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func TestClientOptDisabled(t *testing.T) {
+	cli := newClient(t, fakeDaemon(t))
+	_, err := cli.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, Entries())
+	assert.False(t, Enabled())
+}
+
+func TestRecorderScrubsCredentials(t *testing.T) {
+	r := enable(t, DefaultSize)
+	cli := newClient(t, fakeDaemon(t))
+	ctx := context.Background()
+
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine", Env: []string{"API_TOKEN=s3cret", "DEBUG=1"}}, nil, nil, nil, "")
+	require.NoError(t, err)
+	_, err = cli.ImagePull(ctx, "private/app", image.PullOptions{RegistryAuth: "eyJwYXNzd29yZCI6Imh1bnRlcjIifQ=="})
+	require.Error(t, err)
+
+	entries := r.Entries()
+	require.Len(t, entries, 3, "the ping of the version negotiation, the create and the pull")
+	assert.Equal(t, "/_ping", entries[0].Path)
+
+	create := entries[1]
+	assert.Equal(t, http.MethodPost, create.Method)
+	assert.Equal(t, http.StatusCreated, create.Status)
+	assert.Contains(t, create.RequestBody, `"API_TOKEN=[REDACTED]"`)
+	assert.Contains(t, create.RequestBody, `"DEBUG=[REDACTED]"`)
+	assert.NotContains(t, create.RequestBody, "s3cret")
+	assert.Contains(t, create.RequestBody, `"Image":"alpine"`)
+	assert.Equal(t, `{"Id":"abc123","Warnings":[]}`, create.ResponseBody)
+
+	pull := entries[2]
+	assert.Equal(t, http.StatusNotFound, pull.Status)
+	assert.True(t, pull.Failed())
+	assert.Equal(t, redacted, pull.Headers["X-Registry-Auth"])
+	assert.Contains(t, pull.Path, "fromImage=private%2Fapp")
+	assert.Contains(t, pull.ResponseBody, "pull access denied")
+}
+
+func TestScrubBody(t *testing.T) {
+	body := `{"username":"me","password":"hunter2","Auth":"dXNlcjpwYXNz","IdentityToken": "tok","Env":["HOME=/root"]}`
+	assert.Equal(t, `{"username":"me","password":"[REDACTED]","Auth":"[REDACTED]","IdentityToken":"[REDACTED]","Env":["HOME=[REDACTED]"]}`, scrub(body, false))
+	assert.Equal(t, `{"a":1...[truncated]`, scrub(`{"a":1`, true))
+
+	// A value cut off by maxBodyBytes is scrubbed too
+	assert.Equal(t, `{"Env":["HOME=[REDACTED]","PASSWORD=[REDACTED]...[truncated]`, scrub(`{"Env":["HOME=/root","PASSWORD=hunter2`, true))
+	assert.Equal(t, `{"username":"me","password":"[REDACTED]...[truncated]`, scrub(`{"username":"me","password": "hunt`, true))
+}
+
+func TestRecorderTruncatesAndElides(t *testing.T) {
+	r := enable(t, DefaultSize)
+	daemon := fakeDaemon(t)
+	httpClient := &http.Client{Transport: r.Transport(http.DefaultTransport)}
+
+	resp, err := httpClient.Get(daemon.URL + "/containers/json")
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	resp, err = httpClient.Get(daemon.URL + "/containers/abc/archive?path=/app")
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	resp, err = httpClient.Post(daemon.URL+"/containers/abc/archive", "application/x-tar", strings.NewReader("tar data"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	entries := r.Entries()
+	require.Len(t, entries, 3)
+	assert.Len(t, entries[0].ResponseBody, maxBodyBytes+len("...[truncated]"))
+	assert.True(t, strings.HasSuffix(entries[0].ResponseBody, "...[truncated]"))
+	assert.Equal(t, "/containers/abc/archive?path=/app", entries[1].Path)
+	assert.Equal(t, "[binary stream elided]", entries[1].ResponseBody)
+	assert.Equal(t, "[binary stream elided]", entries[2].RequestBody)
+}
+
+func TestRecorderRingBounds(t *testing.T) {
+	r := enable(t, 3)
+	daemon := fakeDaemon(t)
+	httpClient := &http.Client{Transport: r.Transport(http.DefaultTransport)}
+
+	for i := range 5 {
+		resp, err := httpClient.Get(fmt.Sprintf("%s/_ping?n=%d", daemon.URL, i))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	entries := r.Entries()
+	require.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.Equal(t, i+3, entry.Seq)
+		assert.Equal(t, fmt.Sprintf("/_ping?n=%d", i+2), entry.Path)
+	}
+}
+
+func TestRecorderTransportError(t *testing.T) {
+	r := enable(t, DefaultSize)
+	daemon := fakeDaemon(t)
+	daemon.Close()
+	httpClient := &http.Client{Transport: r.Transport(http.DefaultTransport)}
+
+	_, err := httpClient.Get(daemon.URL + "/_ping")
+	require.Error(t, err)
+	entries := r.Entries()
+	require.Len(t, entries, 1)
+	assert.NotEmpty(t, entries[0].Error)
+	assert.True(t, entries[0].Failed())
+}
+
+func TestMiddleware(t *testing.T) {
+	enable(t, DefaultSize)
+	daemon := fakeDaemon(t)
+	cli := newClient(t, daemon)
+
+	pull := Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, err := cli.ImagePull(ctx, "private/app", image.PullOptions{}); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		return mcp.NewToolResultText("pulled"), nil
+	})
+	ping := Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, err := cli.Ping(ctx); err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText("Error: the daemon answered, but something else went wrong"), nil
+	})
+
+	// A failed call gets the Docker calls it made, and only those
+	_, err := cli.Ping(context.Background())
+	require.NoError(t, err)
+	result, err := pull(context.Background(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	attached := result.Content[1].(mcp.TextContent).Text
+	assert.True(t, strings.HasPrefix(attached, "Docker API calls (last 2 of this call, from --debug-docker): "), attached)
+	var entries []Entry
+	require.NoError(t, json.Unmarshal([]byte(attached[strings.Index(attached, "["):]), &entries))
+	require.Len(t, entries, 2, "the version negotiation and the pull, but not the earlier ping")
+	assert.Equal(t, 2, entries[0].Seq)
+	assert.Equal(t, http.StatusNotFound, entries[1].Status)
+
+	// A failure the daemon had no part in is left alone
+	result, err = ping(context.Background(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.Len(t, result.Content, 1)
+}
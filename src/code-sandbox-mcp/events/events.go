@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Event types
+const (
+	ToolCallStart    = "tool_call_start"
+	ToolCallEnd      = "tool_call_end"
+	ContainerCreated = "container_created"
+	ContainerRemoved = "container_removed"
+	ImagePulled      = "image_pulled"
+	Error            = "error"
+	// Dropped is written on Close when events were dropped because the writer couldn't keep up
+	Dropped = "events_dropped"
+)
+
+// bufferSize is how many events can wait for the writer before new ones are dropped
+const bufferSize = 1024
+
+// Event is one line of the event stream
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Tool       string    `json:"tool,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Container  string    `json:"container,omitempty"`
+	Image      string    `json:"image,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Count      uint64    `json:"count,omitempty"`
+}
+
+// Emitter writes events as JSON Lines from a background goroutine, so emitting never blocks a tool call
+type Emitter struct {
+	events  chan Event
+	dropped atomic.Uint64
+	done    chan struct{}
+	once    sync.Once
+	// mu keeps Close from closing events while an Emit is sending on it
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewEmitter starts writing events to w
+func NewEmitter(w io.Writer) *Emitter {
+	e := &Emitter{events: make(chan Event, bufferSize), done: make(chan struct{})}
+	go func() {
+		defer close(e.done)
+		encoder := json.NewEncoder(w)
+		for event := range e.events {
+			// A broken writer only loses events
+			_ = encoder.Encode(event)
+		}
+		if dropped := e.dropped.Load(); dropped > 0 {
+			_ = encoder.Encode(Event{Time: time.Now().UTC(), Type: Dropped, Count: dropped})
+		}
+	}()
+	return e
+}
+
+// Emit queues an event, dropping it when the buffer is full
+func (e *Emitter) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.events <- event:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many events were dropped so far
+func (e *Emitter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Close writes the queued events and waits for the writer to finish; events emitted afterwards are dropped
+func (e *Emitter) Close() {
+	e.once.Do(func() {
+		if current.Load() == e {
+			current.Store(nil)
+		}
+		e.mu.Lock()
+		e.closed = true
+		close(e.events)
+		e.mu.Unlock()
+	})
+	<-e.done
+}
+
+// current is the emitter Emit sends to, nil when the event stream is off
+var current atomic.Pointer[Emitter]
+
+// SetDefault makes e the emitter used by Emit
+func SetDefault(e *Emitter) {
+	current.Store(e)
+}
+
+// Emit sends an event to the default emitter; it does nothing when the event stream is off
+func Emit(event Event) {
+	if e := current.Load(); e != nil {
+		e.Emit(event)
+	}
+}
+
+// Middleware emits tool_call_start and tool_call_end events around each tool call, and an error event when a
+// handler fails
+func Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		Emit(Event{Type: ToolCallStart, Tool: tool})
+		start := time.Now()
+
+		result, err := next(ctx, request)
+
+		end := Event{Type: ToolCallEnd, Tool: tool, DurationMS: time.Since(start).Milliseconds(), Status: callStatus(result, err)}
+		if err != nil {
+			Emit(Event{Type: Error, Tool: tool, Error: err.Error()})
+		}
+		Emit(end)
+		return result, err
+	}
+}
+
+// callStatus classifies a call as ok or error; tools report most failures as "Error: ..." text rather than as
+// a Go error or an error result
+func callStatus(result *mcp.CallToolResult, err error) string {
+	if err != nil || result == nil || result.IsError {
+		return "error"
+	}
+	if len(result.Content) > 0 {
+		if text, ok := result.Content[0].(mcp.TextContent); ok && strings.HasPrefix(text.Text, "Error") {
+			return "error"
+		}
+	}
+	return "ok"
+}
@@ -0,0 +1,136 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callTool(s *server.MCPServer, name string) {
+	message, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name},
+	})
+	s.HandleMessage(context.Background(), message)
+}
+
+// readEvents decodes the stream strictly line by line
+func readEvents(t *testing.T, stream []byte) []Event {
+	t.Helper()
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(stream))
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "line %q", scanner.Text())
+		assert.False(t, event.Time.IsZero())
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventStreamDuringToolCall(t *testing.T) {
+	var stream bytes.Buffer
+	emitter := NewEmitter(&stream)
+	SetDefault(emitter)
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(Middleware))
+	s.AddTool(mcp.NewTool("sandbox_list"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		Emit(Event{Type: ContainerCreated, Container: "abc123", Image: "alpine:latest"})
+		return mcp.NewToolResultText("[]"), nil
+	})
+	s.AddTool(mcp.NewTool("sandbox_stop"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("Error: no such container: box"), nil
+	})
+	s.AddTool(mcp.NewTool("sandbox_describe"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("DOCKER_CLIENT_ERROR: daemon unreachable")
+	})
+
+	callTool(s, "sandbox_list")
+	callTool(s, "sandbox_stop")
+	callTool(s, "sandbox_describe")
+	emitter.Close()
+
+	events := readEvents(t, stream.Bytes())
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	assert.Equal(t, []string{
+		ToolCallStart, ContainerCreated, ToolCallEnd,
+		ToolCallStart, ToolCallEnd,
+		ToolCallStart, Error, ToolCallEnd,
+	}, types)
+
+	assert.Equal(t, "sandbox_list", events[0].Tool)
+	assert.Equal(t, "alpine:latest", events[1].Image)
+	assert.Equal(t, "ok", events[2].Status)
+	assert.Equal(t, "error", events[4].Status)
+	assert.Equal(t, "DOCKER_CLIENT_ERROR: daemon unreachable", events[6].Error)
+	assert.Equal(t, "error", events[7].Status)
+
+	// Once closed, the default emitter is cleared and Emit is a no-op
+	Emit(Event{Type: Error})
+}
+
+// blockingWriter blocks every write until released
+type blockingWriter struct {
+	release chan struct{}
+	w       io.Writer
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return b.w.Write(p)
+}
+
+func TestEmitDropsWhenWriterIsStuck(t *testing.T) {
+	var stream bytes.Buffer
+	writer := &blockingWriter{release: make(chan struct{}), w: &stream}
+	emitter := NewEmitter(writer)
+
+	// The writer holds one event, the buffer the next bufferSize; everything after that is dropped
+	for i := 0; i < bufferSize+11; i++ {
+		emitter.Emit(Event{Type: ToolCallStart})
+	}
+	assert.GreaterOrEqual(t, emitter.Dropped(), uint64(10))
+
+	close(writer.release)
+	emitter.Close()
+	events := readEvents(t, stream.Bytes())
+	last := events[len(events)-1]
+	assert.Equal(t, Dropped, last.Type)
+	assert.Equal(t, emitter.Dropped(), last.Count)
+	assert.Equal(t, bufferSize+11, len(events)-1+int(last.Count))
+}
+
+func TestEmitDuringClose(t *testing.T) {
+	emitter := NewEmitter(io.Discard)
+
+	// Goroutines still emitting at shutdown, such as a pull or an exit capture, lose their events instead of
+	// sending on a closed channel
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				emitter.Emit(Event{Type: ContainerRemoved})
+			}
+		}()
+	}
+	emitter.Close()
+	wg.Wait()
+	emitter.Emit(Event{Type: ContainerRemoved})
+}
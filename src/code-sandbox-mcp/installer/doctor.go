@@ -0,0 +1,234 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// CheckResult is the outcome of one doctor check
+type CheckResult struct {
+	Name     string
+	Required bool
+	OK       bool
+	Message  string
+	Hint     string
+}
+
+// DockerAPI is the part of the Docker client the doctor checks use
+type DockerAPI interface {
+	ServerVersion(ctx context.Context) (types.Version, error)
+	ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error)
+	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+}
+
+// UpdateChecker reports whether a newer release is available, like CheckForUpdate
+type UpdateChecker func() (bool, string, error)
+
+// CheckDocker verifies that the Docker daemon is reachable
+func CheckDocker(ctx context.Context, docker DockerAPI) CheckResult {
+	result := CheckResult{Name: "Docker daemon reachable", Required: true}
+	version, err := docker.ServerVersion(ctx)
+	if err != nil {
+		result.Message = err.Error()
+		result.Hint = "start Docker, or point DOCKER_HOST at a running daemon"
+		return result
+	}
+	result.OK = true
+	result.Message = fmt.Sprintf("Docker %s (API %s)", version.Version, version.APIVersion)
+	return result
+}
+
+// CheckImage verifies that the default sandbox image is present locally, pulling it if it is not
+func CheckImage(ctx context.Context, docker DockerAPI, ref string) CheckResult {
+	result := CheckResult{Name: "Default image available", Required: true}
+	if _, err := docker.ImageInspect(ctx, ref); err == nil {
+		result.OK = true
+		result.Message = fmt.Sprintf("%s is present", ref)
+		return result
+	}
+
+	reader, err := docker.ImagePull(ctx, ref, image.PullOptions{})
+	if err == nil {
+		// The pull only completes once its progress stream has been read
+		defer reader.Close()
+		_, err = io.Copy(io.Discard, reader)
+	}
+	if err != nil {
+		result.Message = fmt.Sprintf("%s is not present and could not be pulled: %v", ref, err)
+		result.Hint = fmt.Sprintf("check network access to the registry, or run `docker pull %s`", ref)
+		return result
+	}
+	result.OK = true
+	result.Message = fmt.Sprintf("%s pulled", ref)
+	return result
+}
+
+// CheckConfigWritable verifies that the Claude Desktop config file can be created or updated
+func CheckConfigWritable(configPath string) CheckResult {
+	result := CheckResult{Name: "Config file writable"}
+	hint := fmt.Sprintf("fix the permissions of %s", configPath)
+
+	if _, err := os.Stat(configPath); err == nil {
+		f, err := os.OpenFile(configPath, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			result.Message = err.Error()
+			result.Hint = hint
+			return result
+		}
+		f.Close()
+		result.OK = true
+		result.Message = configPath
+		return result
+	}
+
+	// The file doesn't exist yet: check that its directory can hold it
+	dir := filepath.Dir(configPath)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	probe, err := os.CreateTemp(dir, ".code-sandbox-mcp-doctor-*")
+	if err != nil {
+		result.Message = fmt.Sprintf("cannot create %s: %v", configPath, err)
+		result.Hint = fmt.Sprintf("fix the permissions of %s", dir)
+		return result
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	result.OK = true
+	result.Message = fmt.Sprintf("%s (will be created)", configPath)
+	return result
+}
+
+// CheckConfigEntry verifies that the Claude Desktop config registers this server and runs execPath
+func CheckConfigEntry(configPath string, execPath string) CheckResult {
+	result := CheckResult{Name: "Claude Desktop entry", Hint: "run `code-sandbox-mcp install`"}
+
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		result.Message = fmt.Sprintf("%s does not exist", configPath)
+		return result
+	}
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	var config MCPConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		result.Message = fmt.Sprintf("failed to parse %s: %v", configPath, err)
+		result.Hint = "fix the JSON syntax of the config file"
+		return result
+	}
+
+	server, ok := config.MCPServers["code-sandbox-mcp"]
+	if !ok {
+		result.Message = "no code-sandbox-mcp entry in mcpServers"
+		return result
+	}
+	// On Windows the binary is the argument of cmd /c
+	if server.Command != execPath && !slices.Contains(server.Args, execPath) {
+		result.Message = fmt.Sprintf("entry runs %s, not this binary (%s)", server.Command, execPath)
+		result.Hint = "run `code-sandbox-mcp install` from the binary you want to use"
+		return result
+	}
+	result.OK = true
+	result.Message = fmt.Sprintf("points at %s", execPath)
+	return result
+}
+
+// CheckVersion verifies that no newer release is available
+func CheckVersion(checkForUpdate UpdateChecker) CheckResult {
+	result := CheckResult{Name: "Version up to date"}
+	hasUpdate, downloadURL, err := checkForUpdate()
+	switch {
+	case err != nil:
+		result.Message = err.Error()
+		result.Hint = "check network access to api.github.com"
+	case hasUpdate:
+		result.Message = fmt.Sprintf("a newer release is available: %s", downloadURL)
+		result.Hint = "restart without --no-update to update automatically, or download the release"
+	case Version == "dev":
+		result.OK = true
+		result.Message = "development build, update check skipped"
+	default:
+		result.OK = true
+		result.Message = fmt.Sprintf("v%s", Version)
+	}
+	return result
+}
+
+// RunDoctor runs every check against the real environment, prints the results to w and returns the
+// process exit code: 1 if a required check failed, 0 otherwise. configPathOverride is the --config-path flag.
+func RunDoctor(ctx context.Context, w io.Writer, defaultImage string, configPathOverride string) int {
+	var results []CheckResult
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		results = append(results, CheckResult{
+			Name:     "Docker daemon reachable",
+			Required: true,
+			Message:  fmt.Sprintf("failed to create Docker client: %v", err),
+			Hint:     "check the DOCKER_HOST and DOCKER_CERT_PATH environment variables",
+		})
+	} else {
+		defer cli.Close()
+		docker := CheckDocker(ctx, cli)
+		results = append(results, docker)
+		if docker.OK {
+			results = append(results, CheckImage(ctx, cli, defaultImage))
+		}
+	}
+
+	if configPath, err := getConfigPath(configPathOverride); err != nil {
+		results = append(results, CheckResult{Name: "Config file writable", Message: err.Error()})
+	} else {
+		results = append(results, CheckConfigWritable(configPath))
+		if execPath, err := os.Executable(); err == nil {
+			if abs, err := filepath.Abs(execPath); err == nil {
+				execPath = abs
+			}
+			results = append(results, CheckConfigEntry(configPath, execPath))
+		}
+	}
+
+	results = append(results, CheckVersion(CheckForUpdate))
+
+	return PrintResults(w, results)
+}
+
+// PrintResults writes one line per check with a remediation hint under each failure and returns the exit code
+func PrintResults(w io.Writer, results []CheckResult) int {
+	exitCode := 0
+	for _, result := range results {
+		status := "PASS"
+		if !result.OK {
+			status = "WARN"
+			if result.Required {
+				status = "FAIL"
+				exitCode = 1
+			}
+		}
+		fmt.Fprintf(w, "[%s] %s: %s\n", status, result.Name, result.Message)
+		if !result.OK && result.Hint != "" {
+			fmt.Fprintf(w, "       hint: %s\n", result.Hint)
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,164 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDocker struct {
+	versionErr error
+	present    bool
+	pullErr    error
+	pulled     bool
+}
+
+func (f *fakeDocker) ServerVersion(ctx context.Context) (types.Version, error) {
+	return types.Version{Version: "28.0.2", APIVersion: "1.48"}, f.versionErr
+}
+
+func (f *fakeDocker) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	if !f.present {
+		return image.InspectResponse{}, errors.New("No such image")
+	}
+	return image.InspectResponse{}, nil
+}
+
+func (f *fakeDocker) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	if f.pullErr != nil {
+		return nil, f.pullErr
+	}
+	f.pulled = true
+	return io.NopCloser(strings.NewReader(`{"status":"Downloaded"}`)), nil
+}
+
+func TestCheckDocker(t *testing.T) {
+	ok := CheckDocker(context.Background(), &fakeDocker{})
+	assert.True(t, ok.OK)
+	assert.Equal(t, "Docker 28.0.2 (API 1.48)", ok.Message)
+
+	failed := CheckDocker(context.Background(), &fakeDocker{versionErr: errors.New("connection refused")})
+	assert.False(t, failed.OK)
+	assert.True(t, failed.Required)
+	assert.Contains(t, failed.Message, "connection refused")
+	assert.NotEmpty(t, failed.Hint)
+}
+
+func TestCheckImage(t *testing.T) {
+	present := &fakeDocker{present: true}
+	assert.True(t, CheckImage(context.Background(), present, "alpine").OK)
+	assert.False(t, present.pulled)
+
+	missing := &fakeDocker{}
+	result := CheckImage(context.Background(), missing, "alpine")
+	assert.True(t, result.OK)
+	assert.True(t, missing.pulled)
+	assert.Equal(t, "alpine pulled", result.Message)
+
+	unpullable := CheckImage(context.Background(), &fakeDocker{pullErr: errors.New("no route to host")}, "alpine")
+	assert.False(t, unpullable.OK)
+	assert.Contains(t, unpullable.Hint, "docker pull alpine")
+}
+
+func TestCheckConfigWritable(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing file in a missing directory is fine as long as an ancestor is writable
+	result := CheckConfigWritable(filepath.Join(dir, "Claude", "claude_desktop_config.json"))
+	assert.True(t, result.OK, result.Message)
+	assert.Contains(t, result.Message, "will be created")
+
+	existing := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(existing, []byte("{}"), 0644))
+	assert.True(t, CheckConfigWritable(existing).OK)
+
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		require.NoError(t, os.Chmod(existing, 0444))
+		result := CheckConfigWritable(existing)
+		assert.False(t, result.OK)
+		assert.False(t, result.Required)
+	}
+}
+
+func TestCheckConfigEntry(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	result := CheckConfigEntry(configPath, "/usr/local/bin/code-sandbox-mcp")
+	assert.False(t, result.OK)
+	assert.Contains(t, result.Message, "does not exist")
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"mcpServers": {"other": {"command": "x"}}}`), 0644))
+	result = CheckConfigEntry(configPath, "/usr/local/bin/code-sandbox-mcp")
+	assert.False(t, result.OK)
+	assert.Contains(t, result.Message, "no code-sandbox-mcp entry")
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"mcpServers": {"code-sandbox-mcp": {"command": "/old/code-sandbox-mcp"}}}`), 0644))
+	result = CheckConfigEntry(configPath, "/usr/local/bin/code-sandbox-mcp")
+	assert.False(t, result.OK)
+	assert.Contains(t, result.Message, "/old/code-sandbox-mcp")
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"mcpServers": {"code-sandbox-mcp": {"command": "cmd", "args": ["/c", "C:\\bin\\code-sandbox-mcp.exe"]}}}`), 0644))
+	assert.True(t, CheckConfigEntry(configPath, `C:\bin\code-sandbox-mcp.exe`).OK)
+}
+
+func TestCheckVersion(t *testing.T) {
+	update := CheckVersion(func() (bool, string, error) { return true, "https://example.com/release", nil })
+	assert.False(t, update.OK)
+	assert.Contains(t, update.Message, "https://example.com/release")
+
+	offline := CheckVersion(func() (bool, string, error) { return false, "", errors.New("timeout") })
+	assert.False(t, offline.OK)
+	assert.False(t, offline.Required)
+
+	assert.True(t, CheckVersion(func() (bool, string, error) { return false, "", nil }).OK)
+}
+
+func TestPrintResults(t *testing.T) {
+	var out bytes.Buffer
+	code := PrintResults(&out, []CheckResult{
+		{Name: "a", Required: true, OK: true, Message: "fine"},
+		{Name: "b", Message: "meh", Hint: "do b"},
+	})
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[PASS] a: fine\n[WARN] b: meh\n       hint: do b\n", out.String())
+
+	out.Reset()
+	code = PrintResults(&out, []CheckResult{{Name: "c", Required: true, Message: "broken", Hint: "fix c"}})
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "[FAIL] c: broken\n       hint: fix c\n", out.String())
+}
+
+func TestUninstallConfigKeepsOtherServers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("config path is derived from HOME only on linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(ConfigDirEnv, "")
+	configPath, err := getConfigPath("")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"globalShortcut": "x", "mcpServers": {"code-sandbox-mcp": {"command": "a"}, "other": {"command": "b"}}}`), 0644))
+
+	require.NoError(t, UninstallConfig(""))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "code-sandbox-mcp")
+	assert.Contains(t, string(data), `"other"`)
+	assert.Contains(t, string(data), `"globalShortcut"`)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 // MCPConfig represents the Claude Desktop config file structure
@@ -19,8 +20,10 @@ type MCPServer struct {
 	Args    []string          `json:"args"`
 	Env     map[string]string `json:"env"`
 }
-func InstallConfig() error {
-	configPath, err := getConfigPath()
+
+// InstallConfig adds this binary to the Claude Desktop config, preserving every other key in the file
+func InstallConfig(configPathOverride string) error {
+	configPath, err := getConfigPath(configPathOverride)
 	if err != nil {
 		return err
 	}
@@ -41,69 +44,223 @@ func InstallConfig() error {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	var config MCPConfig
-	if _, err := os.Stat(configPath); err == nil {
-		// Read existing config
-		configData, err := os.ReadFile(configPath)
-		if err != nil {
-			return fmt.Errorf("failed to read config file: %w", err)
-		}
-		if err := json.Unmarshal(configData, &config); err != nil {
-			return fmt.Errorf("failed to parse config file: %w", err)
-		}
-	} else {
-		// Create new config
-		config = MCPConfig{
-			MCPServers: make(map[string]MCPServer),
-		}
+	config, servers, _, err := readConfig(configPath)
+	if err != nil {
+		return err
 	}
 
-	// Add or update our server config
-	var command string
+	// Add or update our server config, keeping any other fields of an existing entry such as env
+	var server MCPServer
 	if runtime.GOOS == "windows" {
-		command = "cmd"
-		config.MCPServers["code-sandbox-mcp"] = MCPServer{
-			Command: command,
-			Args:    []string{"/c", execPath},
-			Env:     map[string]string{},
-		}
+		server = MCPServer{Command: "cmd", Args: []string{"/c", execPath}}
 	} else {
-		config.MCPServers["code-sandbox-mcp"] = MCPServer{
-			Command: execPath,
-			Args:    []string{},
-			Env:     map[string]string{},
+		server = MCPServer{Command: execPath, Args: []string{}}
+	}
+	entry, err := mergeServerEntry(servers["code-sandbox-mcp"], server)
+	if err != nil {
+		return err
+	}
+	servers["code-sandbox-mcp"] = entry
+
+	if err := writeConfig(configPath, config, servers); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added code-sandbox-mcp to %s\n", configPath)
+	return nil
+}
+
+// readConfig reads the config file as its raw top-level keys and mcpServers entries, so keys this installer
+// doesn't know about survive a rewrite. A missing file reads as an empty config.
+func readConfig(configPath string) (config map[string]json.RawMessage, servers map[string]json.RawMessage, exists bool, err error) {
+	config = map[string]json.RawMessage{}
+	servers = map[string]json.RawMessage{}
+
+	configData, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return config, servers, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return nil, nil, true, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if raw, ok := config["mcpServers"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &servers); err != nil {
+			return nil, nil, true, fmt.Errorf("failed to parse mcpServers: %w", err)
 		}
 	}
+	return config, servers, true, nil
+}
 
-	// Write the updated config
+// mergeServerEntry sets command and args on an existing server entry, leaving its other fields as they are
+func mergeServerEntry(existing json.RawMessage, server MCPServer) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if len(existing) > 0 {
+		if err := json.Unmarshal(existing, &fields); err != nil {
+			return nil, fmt.Errorf("failed to parse the existing code-sandbox-mcp entry: %w", err)
+		}
+	}
+
+	var err error
+	if fields["command"], err = json.Marshal(server.Command); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if fields["args"], err = json.Marshal(server.Args); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if _, ok := fields["env"]; !ok {
+		fields["env"] = json.RawMessage("{}")
+	}
+	return json.Marshal(fields)
+}
+
+// writeConfig stores servers under mcpServers and replaces the config file atomically, so an interrupted
+// write can't leave a truncated config behind
+func writeConfig(configPath string, config, servers map[string]json.RawMessage) error {
+	serversData, err := json.Marshal(servers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	config["mcpServers"] = serversData
 	configData, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, configData, 0644); err != nil {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(configPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), ".claude_desktop_config-*.json")
+	if err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	defer os.Remove(tmp.Name())
 
-	fmt.Printf("Added code-sandbox-mcp to %s\n", configPath)
+	if _, err := tmp.Write(configData); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), configPath); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
 	return nil
 }
 
-func getConfigPath() (string, error) {
+// configFileName is the name of the Claude Desktop config file in its config directory
+const configFileName = "claude_desktop_config.json"
+
+// ConfigDirEnv names an environment variable that points the installer at the directory of the config file
+const ConfigDirEnv = "CODE_SANDBOX_CONFIG_DIR"
+
+// MultipleConfigsError is returned when several Claude Desktop configs exist and none was chosen
+type MultipleConfigsError struct {
+	Paths []string
+}
+
+func (e *MultipleConfigsError) Error() string {
+	return fmt.Sprintf("found %d Claude Desktop configs: %s; choose one with --config-path or %s",
+		len(e.Paths), strings.Join(e.Paths, ", "), ConfigDirEnv)
+}
+
+// getConfigPath returns the Claude Desktop config file to use. An override given with --config-path wins, as a
+// file or the directory holding it, then CODE_SANDBOX_CONFIG_DIR. Otherwise the platform's locations are
+// probed: on Linux $XDG_CONFIG_HOME/Claude (~/.config/Claude by default) and the config directories of Flatpak
+// apps. The first one is used when none has a config yet; an existing config is used when it's the only one.
+func getConfigPath(override string) (string, error) {
+	if override != "" {
+		if info, err := os.Stat(override); err == nil && info.IsDir() {
+			return filepath.Join(override, configFileName), nil
+		}
+		return override, nil
+	}
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return filepath.Join(dir, configFileName), nil
+	}
+
+	candidates, err := configCandidates()
+	if err != nil {
+		return "", err
+	}
+	var existing []string
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			existing = append(existing, candidate)
+		}
+	}
+	switch len(existing) {
+	case 0:
+		return candidates[0], nil
+	case 1:
+		return existing[0], nil
+	default:
+		return "", &MultipleConfigsError{Paths: existing}
+	}
+}
+
+// configCandidates lists where Claude Desktop keeps its config on this platform, the default location first
+func configCandidates() ([]string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
 	}
 
-	var configDir string
 	switch runtime.GOOS {
 	case "darwin":
-		configDir = filepath.Join(homeDir, "Library", "Application Support", "Claude")
+		return []string{filepath.Join(homeDir, "Library", "Application Support", "Claude", configFileName)}, nil
 	case "windows":
-		configDir = filepath.Join(os.Getenv("APPDATA"), "Claude")
-	default: // linux and others
-		configDir = filepath.Join(homeDir, ".config", "Claude")
+		return []string{filepath.Join(os.Getenv("APPDATA"), "Claude", configFileName)}, nil
 	}
 
-	return filepath.Join(configDir, "claude_desktop_config.json"), nil
-}
\ No newline at end of file
+	// linux and others; relative values of XDG_CONFIG_HOME are invalid and ignored, as the spec says
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	candidates := []string{filepath.Join(configHome, "Claude", configFileName)}
+	// Flatpak apps get a config directory of their own, ~/.var/app/<app ID>/config
+	flatpaks, _ := filepath.Glob(filepath.Join(homeDir, ".var", "app", "*", "config", "Claude", configFileName))
+	return append(candidates, flatpaks...), nil
+}
+
+// UninstallConfig removes this server from the Claude Desktop config, leaving other servers untouched
+func UninstallConfig(configPathOverride string) error {
+	configPath, err := getConfigPath(configPathOverride)
+	if err != nil {
+		return err
+	}
+
+	config, servers, exists, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Printf("%s does not exist, nothing to remove\n", configPath)
+		return nil
+	}
+	if _, ok := servers["code-sandbox-mcp"]; !ok {
+		fmt.Printf("code-sandbox-mcp is not configured in %s\n", configPath)
+		return nil
+	}
+	delete(servers, "code-sandbox-mcp")
+
+	if err := writeConfig(configPath, config, servers); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed code-sandbox-mcp from %s\n", configPath)
+	return nil
+}
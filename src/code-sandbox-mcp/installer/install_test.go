@@ -0,0 +1,186 @@
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupConfig points the config path at a temporary HOME and writes data there unless it is nil
+func setupConfig(t *testing.T, data []byte) string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("config path is derived from HOME only on linux")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(ConfigDirEnv, "")
+	configPath, err := getConfigPath("")
+	require.NoError(t, err)
+	if data != nil {
+		require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+		require.NoError(t, os.WriteFile(configPath, data, 0600))
+	}
+	return configPath
+}
+
+func decodeRaw(t *testing.T, data []byte) map[string]json.RawMessage {
+	t.Helper()
+	var m map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &m))
+	return m
+}
+
+func compact(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, json.Compact(&buf, raw))
+	return buf.String()
+}
+
+func TestInstallConfigPreservesUnknownFields(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/claude_desktop_config.json")
+	require.NoError(t, err)
+	configPath := setupConfig(t, fixture)
+
+	require.NoError(t, InstallConfig(""))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	before, after := decodeRaw(t, fixture), decodeRaw(t, data)
+
+	// Every top-level key other than mcpServers is untouched
+	assert.Len(t, after, len(before))
+	for key, raw := range before {
+		if key != "mcpServers" {
+			assert.Equal(t, compact(t, raw), compact(t, after[key]), key)
+		}
+	}
+
+	beforeServers, afterServers := decodeRaw(t, before["mcpServers"]), decodeRaw(t, after["mcpServers"])
+	assert.Equal(t, compact(t, beforeServers["filesystem"]), compact(t, afterServers["filesystem"]))
+
+	// Our entry points at this binary and keeps its own extra fields
+	entry := decodeRaw(t, afterServers["code-sandbox-mcp"])
+	execPath, err := os.Executable()
+	require.NoError(t, err)
+	execPath, err = filepath.Abs(execPath)
+	require.NoError(t, err)
+	assert.Equal(t, compact(t, mustMarshal(t, execPath)), compact(t, entry["command"]))
+	assert.Equal(t, `{"SANDBOX_RATE_LIMIT":"60/min"}`, compact(t, entry["env"]))
+	assert.Equal(t, `["sandbox_exec"]`, compact(t, entry["autoApprove"]))
+
+	// The file mode survives the atomic replace and no temp file is left behind
+	info, err := os.Stat(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(configPath))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestInstallConfigCreatesFile(t *testing.T) {
+	configPath := setupConfig(t, nil)
+
+	require.NoError(t, InstallConfig(""))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	var config MCPConfig
+	require.NoError(t, json.Unmarshal(data, &config))
+	server, ok := config.MCPServers["code-sandbox-mcp"]
+	require.True(t, ok)
+	assert.NotEmpty(t, server.Command)
+	assert.NotNil(t, server.Env)
+}
+
+func TestInstallConfigRejectsInvalidJSON(t *testing.T) {
+	configPath := setupConfig(t, []byte(`{"mcpServers": `))
+
+	assert.ErrorContains(t, InstallConfig(""), "failed to parse config file")
+
+	// The broken file is left for the user to fix rather than overwritten
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, `{"mcpServers": `, string(data))
+}
+
+func TestGetConfigPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG and Flatpak locations only apply on linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(ConfigDirEnv, "")
+	resolve := func(override string) string {
+		t.Helper()
+		p, err := getConfigPath(override)
+		require.NoError(t, err)
+		return p
+	}
+	writeConfigFile := func(p string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(`{}`), 0600))
+	}
+	defaultPath := filepath.Join(home, ".config", "Claude", "claude_desktop_config.json")
+
+	// Without any config, the default location is used so it can be created
+	assert.Equal(t, defaultPath, resolve(""))
+
+	// XDG_CONFIG_HOME moves the default, unless it's relative
+	xdg := filepath.Join(home, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	assert.Equal(t, filepath.Join(xdg, "Claude", "claude_desktop_config.json"), resolve(""))
+	t.Setenv("XDG_CONFIG_HOME", "relative/config")
+	assert.Equal(t, defaultPath, resolve(""))
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// A Flatpak config is picked up when it's the only one
+	flatpak := filepath.Join(home, ".var", "app", "com.example.Claude", "config", "Claude", "claude_desktop_config.json")
+	writeConfigFile(flatpak)
+	assert.Equal(t, flatpak, resolve(""))
+
+	// With several configs the user has to choose
+	writeConfigFile(defaultPath)
+	_, err := getConfigPath("")
+	var multiple *MultipleConfigsError
+	require.ErrorAs(t, err, &multiple)
+	assert.Equal(t, []string{defaultPath, flatpak}, multiple.Paths)
+	assert.ErrorContains(t, err, "found 2 Claude Desktop configs: ")
+	assert.ErrorContains(t, err, "; choose one with --config-path or CODE_SANDBOX_CONFIG_DIR")
+
+	// CODE_SANDBOX_CONFIG_DIR chooses, and --config-path wins over it, as a file or a directory
+	custom := filepath.Join(home, "custom")
+	t.Setenv(ConfigDirEnv, custom)
+	assert.Equal(t, filepath.Join(custom, "claude_desktop_config.json"), resolve(""))
+	assert.Equal(t, flatpak, resolve(flatpak))
+	assert.Equal(t, defaultPath, resolve(filepath.Dir(defaultPath)))
+}
+
+func TestInstallConfigOverride(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "nested", "claude_desktop_config.json")
+	require.NoError(t, InstallConfig(configPath))
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "code-sandbox-mcp")
+
+	require.NoError(t, UninstallConfig(configPath))
+	data, err = os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "code-sandbox-mcp")
+}
+
+func mustMarshal(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
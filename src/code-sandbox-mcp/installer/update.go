@@ -15,6 +15,7 @@ import (
 var (
 	Version   = "dev"         // Version number (from git tag or specified)
 	BuildMode = "development" // Build mode (development or release)
+	GitCommit = "unknown"     // Git commit the binary was built from
 )
 
 // checkForUpdate checks GitHub releases for a newer version
@@ -0,0 +1,193 @@
+// Package dockertest gates and supports the tests that need a Docker daemon. Tests call Require, which skips
+// them with -short or when no daemon is reachable, and pulls the shared test image once per test binary. Every
+// container a test creates carries Label, and Main removes labeled containers before and after the tests run,
+// so a binary that crashed leaves nothing behind past the next run.
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Label marks the containers created by tests
+const Label = "code-sandbox-mcp.test"
+
+// Image is the small image the tests share, a pinned tag so runs don't drift with alpine:latest.
+// SANDBOX_TEST_IMAGE replaces it, e.g. with a digest mirrored to a local registry.
+var Image = "alpine:3.20"
+
+func init() {
+	if image := os.Getenv("SANDBOX_TEST_IMAGE"); image != "" {
+		Image = image
+	}
+}
+
+// Labels returns the labels of a test container, merged with extra
+func Labels(extra map[string]string) map[string]string {
+	labels := map[string]string{Label: "true"}
+	for key, value := range extra {
+		labels[key] = value
+	}
+	return labels
+}
+
+// Main runs the tests of a package and removes the labeled containers around them, including those left over by
+// an earlier run that crashed. Use it from TestMain.
+func Main(m *testing.M) {
+	removeLabeled()
+	code := m.Run()
+	removeLabeled()
+	os.Exit(code)
+}
+
+// removeLabeled force-removes every test container; without a daemon there is nothing to remove
+func removeLabeled() {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", Label)),
+	})
+	if err != nil {
+		return
+	}
+	for _, c := range containers {
+		_ = cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+	}
+}
+
+var (
+	pullOnce sync.Once
+	pullErr  error
+)
+
+// Require returns a client for a reachable Docker daemon, closed when the test ends, with Image pulled. It skips
+// the test when run with -short or without a daemon, and fails it when the image can't be pulled.
+func Require(t testing.TB) *client.Client {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("needs Docker; skipped with -short")
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err == nil {
+		_, err = cli.Ping(context.Background())
+	}
+	if err != nil {
+		t.Skipf("needs Docker, which is not available: %v", err)
+	}
+	t.Cleanup(func() { cli.Close() })
+
+	pullOnce.Do(func() { pullErr = pull(cli, Image) })
+	if pullErr != nil {
+		t.Fatalf("failed to pull the test image %s: %v", Image, pullErr)
+	}
+	return cli
+}
+
+// pull pulls an image unless it is already present
+func pull(cli *client.Client, ref string) error {
+	ctx := context.Background()
+	if _, err := cli.ImageInspect(ctx, ref); err == nil {
+		return nil
+	}
+	reader, err := cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(io.Discard, reader)
+	return err
+}
+
+// Run runs cmd to completion in a labeled container of Image, removed when the test ends, and returns its ID.
+// The container carries the extra labels as well.
+func Run(t testing.TB, cli *client.Client, name string, extraLabels map[string]string, cmd ...string) string {
+	t.Helper()
+	id := create(t, cli, name, extraLabels, cmd)
+	ctx := context.Background()
+	statusCh, errCh := cli.ContainerWait(ctx, id, container.WaitConditionNextExit)
+	if err := cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		t.Fatalf("failed to start container %s: %v", name, err)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("failed to wait for container %s: %v", name, err)
+	case <-statusCh:
+	}
+	return id
+}
+
+// Start starts a labeled container of Image that keeps running until the test ends, and returns its ID
+func Start(t testing.TB, cli *client.Client, name string, extraLabels map[string]string) string {
+	t.Helper()
+	id := create(t, cli, name, extraLabels, []string{"sleep", "infinity"})
+	if err := cli.ContainerStart(context.Background(), id, container.StartOptions{}); err != nil {
+		t.Fatalf("failed to start container %s: %v", name, err)
+	}
+	return id
+}
+
+func create(t testing.TB, cli *client.Client, name string, extraLabels map[string]string, cmd []string) string {
+	t.Helper()
+	ctx := context.Background()
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:  Image,
+		Cmd:    cmd,
+		Labels: Labels(extraLabels),
+	}, nil, nil, nil, name)
+	if err != nil {
+		t.Fatalf("failed to create container %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		_ = cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+	})
+	return resp.ID
+}
+
+// Exec runs cmd in a running container and returns its combined output and exit code
+func Exec(t testing.TB, cli *client.Client, id string, cmd ...string) (string, int) {
+	t.Helper()
+	ctx := context.Background()
+	exec, err := cli.ContainerExecCreate(ctx, id, container.ExecOptions{Cmd: cmd, AttachStdout: true, AttachStderr: true})
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		t.Fatalf("failed to attach to exec: %v", err)
+	}
+	defer resp.Close()
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, resp.Reader); err != nil {
+		t.Fatalf("failed to read exec output: %v", err)
+	}
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		t.Fatalf("failed to inspect exec: %v", err)
+	}
+	return output.String(), inspect.ExitCode
+}
+
+// AssertExec runs cmd in a running container and fails the test unless it exits 0 with the wanted output
+func AssertExec(t testing.TB, cli *client.Client, id string, want string, cmd ...string) {
+	t.Helper()
+	output, exitCode := Exec(t, cli, id, cmd...)
+	if exitCode != 0 || output != want {
+		t.Errorf("%s: got exit code %d and output %q, want exit code 0 and output %q", fmt.Sprint(cmd), exitCode, output, want)
+	}
+}
@@ -0,0 +1,43 @@
+package dockertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	Main(m)
+}
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, map[string]string{Label: "true"}, Labels(nil))
+	assert.Equal(t, map[string]string{Label: "true", "code-sandbox-mcp.sandbox": "true"}, Labels(map[string]string{"code-sandbox-mcp.sandbox": "true"}))
+}
+
+func TestStartAndExec(t *testing.T) {
+	cli := Require(t)
+	id := Start(t, cli, "mcp-test-harness-exec", nil)
+
+	AssertExec(t, cli, id, "hello\n", "echo", "hello")
+	output, exitCode := Exec(t, cli, id, "sh", "-c", "echo oops >&2; exit 3")
+	assert.Equal(t, "oops\n", output)
+	assert.Equal(t, 3, exitCode)
+}
+
+func TestRemoveLabeled(t *testing.T) {
+	cli := Require(t)
+	Run(t, cli, "mcp-test-harness-leftover", nil, "true")
+
+	removeLabeled()
+	containers, err := cli.ContainerList(context.Background(), container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", Label)),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+}
@@ -2,30 +2,60 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"slices"
+	"time"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/confirm"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/events"
 	"github.com/Automata-Labs-team/code-sandbox-mcp/installer"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/ratelimit"
 	"github.com/Automata-Labs-team/code-sandbox-mcp/resources"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/shutdown"
 	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/watchdog"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-func init() {
-	// Check for --install flag
-	installFlag := flag.Bool("install", false, "Add this binary to Claude Desktop config")
-	noUpdateFlag := flag.Bool("no-update", false, "Disable auto-update check")
-	flag.Parse()
+// seccompProfileDescription documents the seccomp_profile argument of sandbox_initialize and run_command
+const seccompProfileDescription = "Seccomp profile of the container: baseline (the default), a bundled copy of Docker's default profile; paranoid, which also blocks ptrace, " +
+	"perf_event_open, bpf and userfaultfd; default, whatever the engine applies; or the host path of a profile in Docker's JSON format. " +
+	"An engine that rejects the profile runs the container under its own default, with a warning."
+
+// serve runs the MCP server; this is the default subcommand
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	// --install predates the install subcommand and is kept for existing setups
+	installFlag := flags.Bool("install", false, "Add this binary to Claude Desktop config")
+	noUpdateFlag := flags.Bool("no-update", false, "Disable auto-update check")
+	port := flags.String("port", "9520", "Port to listen on")
+	transport := flags.String("transport", "stdio", "Transport to use (stdio, sse)")
+	eventsFormat := flags.String("events", "", "Emit structured events in this format (jsonl)")
+	eventsFile := flags.String("events-file", "", "Write events to this file instead of stderr")
+	otelEndpoint := flags.String("otel-endpoint", "", "Export traces of tool calls over OTLP/HTTP to this URL (e.g. http://localhost:4318)")
+	confirmDestructive := flags.Bool("confirm-destructive", false, "Ask the user through the client to approve destructive operations")
+	confirmTimeout := flags.Duration("confirm-timeout", confirm.DefaultTimeout, "Deny a destructive operation the user hasn't approved within this time")
+	debugDocker := flags.Bool("debug-docker", false, "Record the Docker API calls made, for sandbox_debug_dump and failed tool results")
+	instanceName := flags.String("instance-name", tools.DefaultInstanceName(), "Name of this server instance; it only lists and manages the sandboxes labeled with it")
+	flags.Parse(args)
+
+	if err := tools.SetInstanceName(*instanceName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	if *installFlag {
-		if err := installer.InstallConfig(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		os.Exit(0)
+		runInstall(nil)
+		return
 	}
 
 	// Check for updates unless disabled
@@ -41,13 +71,17 @@ func init() {
 			fmt.Println("Update complete. Restarting...")
 		}
 	}
-}
 
-func main() {
-	port := flag.String("port", "9520", "Port to listen on")
-	transport := flag.String("transport", "stdio", "Transport to use (stdio, sse)")
-	flag.Parse()
-	s := server.NewMCPServer("code-sandbox-mcp", "v1.1.0", server.WithLogging(), server.WithResourceCapabilities(true, true), server.WithPromptCapabilities(false))
+	// Forget the active sandbox of a session when it ends
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		tools.ForgetActiveSandbox(session.SessionID())
+	})
+	serverOptions := []server.ServerOption{server.WithLogging(), server.WithResourceCapabilities(true, true), server.WithPromptCapabilities(false), server.WithHooks(hooks)}
+	if *confirmDestructive {
+		serverOptions = append(serverOptions, server.WithElicitation())
+	}
+	s := server.NewMCPServer("code-sandbox-mcp", installer.Version, serverOptions...)
 	s.AddNotificationHandler("notifications/error", handleNotification)
 	// Register tools
 	// Initialize a new compute environment for code execution
@@ -59,16 +93,165 @@ func main() {
 		),
 		mcp.WithString("image",
 			mcp.Description("Docker image to use as the base environment (e.g., 'python:3.12-slim-bookworm')"),
-			mcp.DefaultString("python:3.12-slim-bookworm"),
+			mcp.DefaultString(tools.DefaultImage),
 		),
 		mcp.WithString("name",
 			mcp.Description("Optional human-readable name for the sandbox container."),
 		),
+		mcp.WithNumber("memory_limit",
+			mcp.Description("Optional memory limit for the container, in MB or as a size such as \"512m\" or \"2g\". Processes exceeding it are killed by the out-of-memory killer."),
+			mcp.Min(0),
+			tools.OrUnitString(),
+		),
+		mcp.WithNumber("cpu_limit",
+			mcp.Description("Optional CPU limit as a number of CPUs, e.g. 1.5. GOMAXPROCS and OMP_NUM_THREADS are set to match, and a memory_limit caps the Node.js heap through NODE_OPTIONS."),
+			mcp.Min(0),
+		),
+		mcp.WithArray("ulimits",
+			mcp.Description("Optional resource limits as {name, soft, hard} objects (e.g. {\"name\": \"nofile\", \"soft\": 4096, \"hard\": 4096}); -1 means unlimited. "+
+				"They override the defaults nofile=1024 and core=0 by name; processes are capped separately by a 256-process PID limit."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{"type": "string"},
+					"soft": map[string]any{"type": "number"},
+					"hard": map[string]any{"type": "number"},
+				},
+				"required": []string{"name", "soft"},
+			}),
+		),
+		mcp.WithArray("packages",
+			mcp.Description("Optional packages to install once the container is running (e.g. [\"requests\", \"numpy==1.26\"]). "+
+				"Initialization fails and the container is removed if installation fails."),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithArray("on_stop_commands",
+			mcp.Description("Optional shell commands sandbox_stop runs in the container before stopping it, e.g. to flush a database or upload results. "+
+				"They run in order within 30 seconds and their output is included in the stop result; a failure is reported as a warning and the stop goes ahead."),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithArray("input_files",
+			mcp.Description("Optional host files to mount read-only at /app/inputs/<basename>, e.g. a CSV too large to pass as a string. "+
+				"Files must not share a basename; the result lists their paths in the container."),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("package_manager",
+			mcp.Description("Package manager for packages: pip, npm, apk or apt. Detected from the image when omitted."),
+			mcp.Enum("pip", "npm", "apk", "apt"),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Optional absolute working directory for the container (default: /app). Relative paths given to the copy, write and compare tools resolve against it."),
+		),
+		mcp.WithString("project_dir",
+			mcp.Description("Optional project directory on the host. When image is omitted, its .tool-versions, .python-version, .nvmrc, .node-version or go.mod "+
+				"picks the official python, node or golang image of the pinned version, and the result names the file it came from."),
+		),
+		mcp.WithString("language",
+			mcp.Description("Optional language the sandbox is for: python, nodejs or go. Its runtime is checked right after the container starts, and an image without it "+
+				"fails with RUNTIME_MISSING instead of later with \"not found\"; the result reports the runtime version. An image picked from project_dir is checked for its runtime."),
+		),
+		mcp.WithBoolean("skip_validation",
+			mcp.Description("Skip the runtime check, e.g. for an image whose entrypoint installs the runtime"),
+		),
+		mcp.WithString("seccomp_profile",
+			mcp.Description(seccompProfileDescription),
+		),
+		mcp.WithBoolean("remove_on_exit",
+			mcp.Description("Remove the sandbox once its main process exits on its own, after its logs are captured into the execution history (default: false)"),
+		),
+		mcp.WithString("env_file",
+			mcp.Description("Optional path to a .env file on the host. Its KEY=VALUE pairs are set in the container environment and their values are redacted from echoed commands and their output."),
+		),
+		mcp.WithString("pip_index_url",
+			mcp.Description("Optional pip index URL (PIP_INDEX_URL) for this sandbox, overriding SANDBOX_PIP_INDEX_URL. Credentials in the URL are redacted from output."),
+		),
+		mcp.WithString("npm_registry",
+			mcp.Description("Optional npm registry URL (npm_config_registry) for this sandbox, overriding SANDBOX_NPM_REGISTRY. Credentials in the URL are redacted from output."),
+		),
+		mcp.WithString("goproxy",
+			mcp.Description("Optional GOPROXY list for this sandbox, overriding SANDBOX_GOPROXY."),
+		),
+		mcp.WithString("template",
+			mcp.Description("Name of a preset from sandbox_templates_list. Arguments given in this call override the preset's values."),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Create the sandbox even when the Docker host is below the free disk or memory threshold"),
+		),
+	)
+
+	// Load the default ulimits and sandbox templates so invalid settings are reported at startup
+	if err := tools.LoadUlimitDefaultsFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadTemplatesFromEnv(initializeTool); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadHostThresholdsFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadCopyFileLimitFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadHostRootFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadPullTimeoutFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadPullLimitFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tools.LoadScaffoldsFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Warn about an outdated Docker Engine now rather than in the middle of a tool call
+	apiCtx, cancelAPICheck := context.WithTimeout(context.Background(), 5*time.Second)
+	if warning := tools.CheckDockerAPI(apiCtx); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	cancelAPICheck()
+
+	// Report the server build, configuration and Docker daemon
+	serverInfoTool := mcp.NewTool("sandbox_server_info",
+		mcp.WithDescription(
+			"Returns the server version, build mode, git commit, transport, active features, configured limits and Docker daemon version. \n"+
+				"The same information is available as the server://info resource.",
+		),
+	)
+
+	// List the configured sandbox templates
+	templatesListTool := mcp.NewTool("sandbox_templates_list",
+		mcp.WithDescription("Lists the sandbox templates available to sandbox_initialize, returning each preset's name, description and arguments."),
 	)
 
 	// List running sandboxes
 	listTool := mcp.NewTool("sandbox_list",
-		mcp.WithDescription("Lists all running sandbox containers, returning their ID, name, image, and status."),
+		mcp.WithDescription("Lists all running sandbox containers, returning their ID, name, image and status, "+
+			"with the machine-readable state, health, exit_code, uptime_seconds and idle_seconds, the time since a tool last used the sandbox."),
+		mcp.WithString("state",
+			mcp.Description("Only list sandboxes in this state, including stopped ones"),
+			mcp.Enum(tools.SandboxStates...),
+		),
+		mcp.WithBoolean("include_stopped",
+			mcp.Description("Also list sandboxes that are not running"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("idle_longer_than",
+			mcp.Description("Only list running sandboxes no tool has used for longer than this duration, such as 30m"),
+		),
+		mcp.WithBoolean("all_instances",
+			mcp.Description("List the sandboxes of every server instance on the Docker host, not only this one's"),
+			mcp.DefaultBool(false),
+		),
 	)
 
 	// Copy a directory to the sandboxed filesystem
@@ -82,12 +265,58 @@ func main() {
 			mcp.Description("ID or name of the container returned from the initialize call"),
 		),
 		mcp.WithString("local_src_dir",
-			mcp.Required(),
-			mcp.Description("Path to a directory in the local file system"),
+			mcp.Description("Path to a directory in the local file system; required unless sources is given"),
 		),
 		mcp.WithString("dest_dir",
 			mcp.Description("Path to save the src directory in the sandbox environment, relative to the container working dir"),
 		),
+		mcp.WithArray("sources",
+			mcp.Description("Several directories to copy in one upload instead of local_src_dir, as {src, dest} objects, e.g. packages/api and packages/shared of a monorepo. "+
+				"The contents of each src land in dest, relative to the container working dir and defaulting to the basename of src; destinations may not overlap."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"src":  map[string]any{"type": "string"},
+					"dest": map[string]any{"type": "string"},
+				},
+				"required": []string{"src"},
+			}),
+		),
+		mcp.WithBoolean("extract_in_container",
+			mcp.Description("Upload the archive to /tmp and extract it with tar inside the container instead of letting the Docker API extract it"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("include_junk",
+			mcp.Description("Also copy OS, cache and editor files such as .DS_Store, Thumbs.db, __pycache__, .pytest_cache and *.swp, which are skipped and counted by default"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("overwrite_policy",
+			mcp.Description("What to do when the project's directory already exists in the sandbox: merge copies over it and leaves files deleted locally in place, replace removes it first (only inside the container working dir), fail_if_exists refuses to copy"),
+			mcp.Enum("merge", "replace", "fail_if_exists"),
+			mcp.DefaultString("merge"),
+		),
+	)
+
+	// Extract a zip or tar archive into the sandboxed filesystem
+	extractArchiveTool := mcp.NewTool("extract_archive_to_sandbox",
+		mcp.WithDescription(
+			"Extract a .zip, .tar, .tar.gz or .tgz archive into the sandboxed filesystem. \n"+
+				"The archive is unpacked on the server and streamed into the container, so the image needs no unzip or tar.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("local_archive_path",
+			mcp.Description("Path to an archive in the local file system"),
+		),
+		mcp.WithString("archive_base64",
+			mcp.Description("Base64-encoded archive contents, for small archives not available on the local file system"),
+		),
+		mcp.WithString("dest_dir",
+			mcp.Description("Directory to extract into, relative to the container working dir"),
+			mcp.Description("Default: ${WORKDIR}"),
+		),
 	)
 
 	// Write a file to the sandboxed filesystem
@@ -108,12 +337,92 @@ func main() {
 			mcp.Required(),
 			mcp.Description("Contents to write to the file"),
 		),
+		mcp.WithString("encoding",
+			mcp.Description("Encoding of file_contents: utf8 (default), base64, or gzip+base64 to send large files compressed"),
+			mcp.Enum(tools.ContentEncodings...),
+		),
 		mcp.WithString("dest_dir",
 			mcp.Description("Directory to create the file in, relative to the container working dir"),
 			mcp.Description("Default: ${WORKDIR}"),
 		),
 	)
 
+	// Write several files with one archive upload
+	writeFilesTool := mcp.NewTool("write_files_sandbox",
+		mcp.WithDescription(
+			"Write several files to the sandboxed filesystem in one call. \n"+
+				"All entries are validated before anything is written; missing parent directories are created. "+
+				"Returns each file's absolute path and size in bytes.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithArray("files",
+			mcp.Required(),
+			mcp.Description("Files as {path, contents, encoding, mode} objects. path is relative to the container working dir; "+
+				"encoding is utf8 (default), base64, or gzip+base64 to send large files compressed; mode is an octal permission such as \"0755\" (default \"0644\")."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path":     map[string]any{"type": "string"},
+					"contents": map[string]any{"type": "string"},
+					"encoding": map[string]any{"type": "string", "enum": tools.ContentEncodings},
+					"mode":     map[string]any{"type": "string"},
+				},
+				"required": []string{"path", "contents"},
+			}),
+		),
+		mcp.WithBoolean("include_junk",
+			mcp.Description("Also write files under OS, cache and editor names such as .DS_Store, __pycache__ and *.swp, which are skipped and counted as skipped_junk by default"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	// Render a file template into the sandboxed filesystem
+	scaffoldTool := mcp.NewTool("scaffold_sandbox",
+		mcp.WithDescription(
+			"Create a project skeleton in the sandboxed filesystem from a named file template, with one upload. \n"+
+				"Read templates://{name} for a template's files and variables. Returns each created file's absolute path and size in bytes.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("template",
+			mcp.Required(),
+			mcp.Description("Name of the file template, e.g. python/fastapi-app"),
+		),
+		mcp.WithString("dest_dir",
+			mcp.Description("Directory to create the files in, relative to the container working dir (default: the working dir)"),
+		),
+		mcp.WithObject("variables",
+			mcp.Description("Values for the template's {{variable}} placeholders, e.g. {\"project_name\": \"api\"}; omitted variables take their defaults"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+	)
+
+	// Apply a unified diff to a file in the sandboxed filesystem
+	applyPatchTool := mcp.NewTool("apply_patch_sandbox",
+		mcp.WithDescription(
+			"Apply a unified diff to a file in the sandboxed filesystem. \n"+
+				"Edits an existing file without resending its full contents. Hunks that moved are found nearby; "+
+				"hunks whose context doesn't match are rejected and the file is left unchanged.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path of the file to patch, relative to the container working dir; must be inside it"),
+		),
+		mcp.WithString("patch",
+			mcp.Required(),
+			mcp.Description("Unified diff for this one file (as produced by diff -u or git diff)"),
+		),
+	)
+
 	// Execute commands in the sandboxed environment
 	execTool := mcp.NewTool("sandbox_exec",
 		mcp.WithDescription(
@@ -127,8 +436,162 @@ func main() {
 		mcp.WithArray("commands",
 			mcp.Required(),
 			mcp.Description("List of command(s) to run in the sandboxed environment"),
-			mcp.Description("Example: [\"apt-get update\", \"pip install numpy\", \"python script.py\"]"),
+			mcp.Description("Example: [\"apt-get update\", \"pip install numpy\", \"python script.py\"]. "+
+				"A command given as {\"command\": \"cat results.json\", \"expect_json\": true} also has its stdout parsed as JSON and returned as structured content, "+
+				"or a JSON_PARSE_FAILED note saying where parsing stopped."),
+			mcp.Items(map[string]any{
+				"type": []string{"string", "object"},
+				"properties": map[string]any{
+					"command":     map[string]any{"type": "string"},
+					"expect_json": map[string]any{"type": "boolean"},
+				},
+				"required": []string{"command"},
+			}),
+			tools.OrString(),
+		),
+		mcp.WithBoolean("merge_output",
+			mcp.Description("Return all output as a single text item instead of one item per command followed by a JSON summary of exit codes"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("keep_ansi",
+			mcp.Description("Keep ANSI escape sequences such as color codes in the output instead of stripping them"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("summarize_output",
+			mcp.Description("Shorten very verbose output to its first and last 20 lines and the error and warning lines between them (Python tracebacks, npm errors, Go panics and test failures, compiler errors), with counts of the elided lines; the full output stays at executions://{execution_id}/output"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("login_shell",
+			mcp.Description("Run each command in a login shell (bash -lc, or sh -lc without bash), so PATH and tools set up by profile scripts such as nvm, pyenv or conda are available"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("env_from_profile",
+			mcp.Description("Source /etc/profile before each command, for images that set their environment there"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	// Run a one-off command in a throwaway container
+	runCommandTool := mcp.NewTool("run_command",
+		mcp.WithDescription(
+			"Run a single command in a fresh container that is removed afterwards. \n"+
+				"Returns JSON with the exit code, stdout and stderr; use sandbox_initialize and sandbox_exec when state must persist between commands.",
+		),
+		mcp.WithString("image",
+			mcp.Required(),
+			mcp.Description("Docker image to run the command in, e.g. \"alpine:latest\""),
+		),
+		mcp.WithArray("command",
+			mcp.Required(),
+			mcp.Description("Program and arguments to run, e.g. [\"ffmpeg\", \"-version\"]; a single string is run with /bin/sh -c"),
 			mcp.Items(map[string]any{"type": "string"}),
+			tools.OrString(),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables to set, e.g. {\"LANG\": \"C.UTF-8\"}"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Working directory for the command (default: /app)"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Kill the command after this many seconds, or a duration such as \"90s\" or \"5m\" (default: 60, max: 600)"),
+			mcp.DefaultNumber(60),
+			mcp.Min(0),
+			tools.OrUnitString(),
+		),
+		mcp.WithString("network",
+			mcp.Description("Network mode of the container; none disables network access"),
+			mcp.Enum("bridge", "none"),
+			mcp.DefaultString("bridge"),
+		),
+		mcp.WithString("seccomp_profile",
+			mcp.Description(seccompProfileDescription),
+		),
+		mcp.WithBoolean("collect_stats",
+			mcp.Description("Sample the container's CPU time and peak memory while the command runs"),
+			mcp.DefaultBool(true),
+		),
+		mcp.WithBoolean("summarize_output",
+			mcp.Description("Shorten very verbose stdout and stderr to their first and last 20 lines and the error and warning lines between them, with counts of the elided lines; the full output stays at executions://{execution_id}/output"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Run even when the Docker host is below the free disk or memory threshold"),
+		),
+	)
+
+	// Repeat a recorded run_command execution from its manifest
+	runFromManifestTool := mcp.NewTool("run_from_manifest",
+		mcp.WithDescription(
+			"Repeat a run_command execution from its manifest, with the image pinned to the recorded digest. \n"+
+				"Give the execution_id of the run, or the manifest read from executions://{id}/manifest. "+
+				"Manifests record environment variable names only; pass their values in env.",
+		),
+		mcp.WithString("execution_id",
+			mcp.Description("Execution ID of the run_command run to repeat"),
+		),
+		mcp.WithObject("manifest",
+			mcp.Description("Manifest of the run to repeat, as returned by executions://{id}/manifest"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Values for the environment variables named in the manifest"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithBoolean("collect_stats",
+			mcp.Description("Sample the container's CPU time and peak memory while the command runs"),
+			mcp.DefaultBool(true),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Run even when the Docker host is below the free disk or memory threshold"),
+		),
+	)
+
+	// Interactive shell sessions for terminal-capable clients
+	shellOpenTool := mcp.NewTool("sandbox_shell_open",
+		mcp.WithDescription(
+			"Open an interactive shell with a TTY in the sandboxed environment. \n"+
+				"Returns a session ID for sandbox_shell_input, sandbox_shell_read and sandbox_shell_close. "+
+				"Sessions are closed when the sandbox is stopped or after 30 minutes without use.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("shell",
+			mcp.Description("Program to run instead of the default shell, e.g. \"python3\" or \"cat\""),
+			mcp.Description("Default: bash if the image has it, otherwise /bin/sh"),
+		),
+	)
+	shellInputTool := mcp.NewTool("sandbox_shell_input",
+		mcp.WithDescription("Send input to a shell session. Text is written as typed, so end commands with \"\\n\" and send control characters such as \"\\u0003\" (Ctrl-C) as is."),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by sandbox_shell_open"),
+		),
+		mcp.WithString("input",
+			mcp.Required(),
+			mcp.Description("Text to send to the shell"),
+		),
+	)
+	shellReadTool := mcp.NewTool("sandbox_shell_read",
+		mcp.WithDescription("Read the output a shell session produced since the last read, including terminal escape sequences. Returns JSON with output and whether the shell has exited."),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by sandbox_shell_open"),
+		),
+		mcp.WithNumber("wait_ms",
+			mcp.Description("When there is no output yet, wait up to this many milliseconds for some, or a duration such as \"5s\" (max 30000)"),
+			mcp.DefaultNumber(0),
+			mcp.Min(0),
+			tools.OrUnitString(),
+		),
+	)
+	shellCloseTool := mcp.NewTool("sandbox_shell_close",
+		mcp.WithDescription("Close a shell session and end the program running in it."),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by sandbox_shell_open"),
 		),
 	)
 
@@ -149,6 +612,11 @@ func main() {
 		mcp.WithString("dest_path",
 			mcp.Description("Path to save the file in the sandbox environment, relative to the container working dir"),
 		),
+		mcp.WithString("overwrite_policy",
+			mcp.Description("What to do when dest_path already exists: merge overwrites it, replace removes it first, also when it is a directory (only inside the container working dir), fail_if_exists refuses to copy"),
+			mcp.Enum("merge", "replace", "fail_if_exists"),
+			mcp.DefaultString("merge"),
+		),
 	)
 
 	// Copy a file from container to local filesystem
@@ -163,7 +631,7 @@ func main() {
 		),
 		mcp.WithString("container_src_path",
 			mcp.Required(),
-			mcp.Description("Path to the file in the container to copy"),
+			mcp.Description("Path to the file in the container to copy, relative to the container working dir"),
 		),
 		mcp.WithString("local_dest_path",
 			mcp.Description("Path where to save the file in the local filesystem"),
@@ -181,37 +649,500 @@ func main() {
 			mcp.Required(),
 			mcp.Description("ID or name of the container to stop and remove"),
 		),
+		mcp.WithBoolean("keep",
+			mcp.Description("Keep the sandbox's checkpoints instead of deleting them with the container"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("skip_hooks",
+			mcp.Description("Stop without running the on_stop_commands the sandbox was created with"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("all_instances",
+			mcp.Description("Also stop a sandbox another server instance created, which is refused with OTHER_INSTANCE otherwise"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	// Choose the sandbox used when container_id_or_name is omitted
+	setActiveSandboxTool := mcp.NewTool("set_active_sandbox",
+		mcp.WithDescription(
+			"Select the default sandbox of this session. \n"+
+				"Tools that take container_id_or_name use it when the argument is omitted, and name it in their result. "+
+				"Stopping the sandbox clears the selection.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("ID or name of a running sandbox, or %q for the most recently created one", tools.LatestSandbox)),
+		),
+	)
+	getActiveSandboxTool := mcp.NewTool("get_active_sandbox",
+		mcp.WithDescription("Return the default sandbox of this session selected with set_active_sandbox, as JSON with container_id_or_name and container_id."),
+	)
+
+	// Checkpoint a sandbox to an image
+	checkpointTool := mcp.NewTool("sandbox_checkpoint",
+		mcp.WithDescription(
+			"Save the current state of a sandbox's filesystem as a checkpoint. \n"+
+				"Returns a checkpoint_id that can be passed to sandbox_rollback to restore this state.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container to checkpoint"),
+		),
+	)
+
+	// Roll a sandbox back to a checkpoint
+	rollbackTool := mcp.NewTool("sandbox_rollback",
+		mcp.WithDescription(
+			"Restore a sandbox to a checkpoint. \n"+
+				"Stops the current container and starts a new one with the same name and limits from the checkpoint. Returns the new container_id.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container to roll back"),
+		),
+		mcp.WithString("checkpoint_id",
+			mcp.Required(),
+			mcp.Description("Checkpoint ID returned by sandbox_checkpoint"),
+		),
+	)
+
+	// List checkpoints
+	checkpointsListTool := mcp.NewTool("sandbox_checkpoints_list",
+		mcp.WithDescription("Lists sandbox checkpoints, newest first, returning their ID, sandbox name, creation time and size."),
+		mcp.WithString("container_id_or_name",
+			mcp.Description("Only list checkpoints of this sandbox"),
+		),
+	)
+
+	// Describe a sandbox container
+	describeTool := mcp.NewTool("sandbox_describe",
+		mcp.WithDescription(
+			"Describe a sandbox container. \n"+
+				"Returns its image and image digests, environment (secret values redacted), working directory, mounts, resource limits, restart count and state.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container to describe"),
+		),
+	)
+
+	// Compare a sandbox directory with another container or a host directory
+	compareTool := mcp.NewTool("sandbox_compare",
+		mcp.WithDescription(
+			"Compare a directory in a sandbox with a directory in another container or on the host. \n"+
+				"Returns the files added in the sandbox, removed from it and changed (by size and sha256), "+
+				"and optionally a unified diff of one file.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the sandbox container"),
+		),
+		mcp.WithString("container_path",
+			mcp.Description("Directory to compare in the sandbox, relative to the container working dir (default: the working dir)"),
+		),
+		mcp.WithString("local_path",
+			mcp.Description("Host directory to compare against; give this or other_container_id_or_name"),
+		),
+		mcp.WithString("other_container_id_or_name",
+			mcp.Description("Container to compare against; give this or local_path"),
+		),
+		mcp.WithString("other_container_path",
+			mcp.Description("Directory to compare in the other container. Default: container_path"),
+		),
+		mcp.WithString("show_content_diff",
+			mcp.Description("Path of one file, relative to the compared directories, to return a unified diff for (capped at 64KB)"),
+		),
+	)
+
+	// Install system packages with the distribution's package manager
+	installSystemPackagesTool := mcp.NewTool("install_system_packages",
+		mcp.WithDescription(
+			"Install system packages in a running sandbox, such as the libraries and build tools a Python or Node package needs. \n"+
+				"Runs apt-get, apk or dnf non-interactively, picked from the image's /etc/os-release. "+
+				"Failed package installs suggest what to install here.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithArray("packages",
+			mcp.Required(),
+			mcp.Description("System package names for the image's distribution, e.g. [\"libpq-dev\", \"build-essential\"]"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+	)
+
+	// Check packages against their registries
+	checkDependenciesTool := mcp.NewTool("check_dependencies",
+		mcp.WithDescription(
+			"Check whether packages exist before installing them in a sandbox. \n"+
+				"Looks each package up in PyPI, the npm registry or the Go module proxy without starting a container, "+
+				"returning whether it was found, its latest version and a suggested name for likely typos.",
+		),
+		mcp.WithString("language",
+			mcp.Required(),
+			mcp.Description("Package ecosystem: python, nodejs or go"),
+		),
+		mcp.WithArray("packages",
+			mcp.Required(),
+			mcp.Description("Package names, optionally with a version specifier (e.g. 'numpy==1.26', 'lodash@4')"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+	)
+
+	// List recent execution outputs
+	executionsListTool := mcp.NewTool("executions_list",
+		mcp.WithDescription(
+			"Lists the recent sandbox_exec and run_command runs kept in memory, newest first, with their execution_id, tool, container, timestamp and exit code. \n"+
+				"Read executions://{execution_id}/output to fetch a run's full output again.",
+		),
+	)
+
+	// Compile or lint a snippet without running it
+	checkCodeTool := mcp.NewTool("check_code",
+		mcp.WithDescription(
+			"Check that code compiles, without running it, in a throwaway container: python -m py_compile for Python, node --check for JavaScript, "+
+				"tsc --noEmit for TypeScript, and go build and go vet for Go. \n"+
+				"Returns ok, the exit code and diagnostics with file, line, column, code and message; the raw output is returned instead when none of it could be parsed.",
+		),
+		mcp.WithString("language",
+			mcp.Required(),
+			mcp.Description("Language of the code"),
+			mcp.Enum("python", "javascript", "typescript", "go"),
+		),
+		mcp.WithString("code",
+			mcp.Required(),
+			mcp.Description("Source of a single file, at most 100KB"),
+		),
+		mcp.WithBoolean("lint",
+			mcp.Description("Also lint Python code with ruff, which is installed for the check"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("image",
+			mcp.Description("Image to check the code in; defaults to python:3.12-slim-bookworm, node:22-slim or golang:1.24-bookworm"),
+		),
+	)
+
+	// Write very large logs to a host file instead of returning them
+	logsExportTool := mcp.NewTool("sandbox_logs_export",
+		mcp.WithDescription(
+			"Stream a container's logs into a host file instead of returning them, for logs too large to read through MCP. \n"+
+				"Returns the paths written and their sizes in bytes.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container to export the logs of"),
+		),
+		mcp.WithString("local_dest_path",
+			mcp.Required(),
+			mcp.Description("Host file to write the logs to; an existing file is replaced"),
+		),
+		mcp.WithBoolean("split_streams",
+			mcp.Description("Write stdout and stderr to separate files, e.g. app.stdout.log and app.stderr.log for app.log; containers with a TTY have a single stream"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("since",
+			mcp.Description("Only export logs since this time, as an RFC 3339 timestamp or a duration such as 10m"),
+		),
+		mcp.WithNumber("tail",
+			mcp.Description("Only export this many lines from the end of the logs; 0 exports them all"),
+			mcp.Min(0),
+		),
+	)
+
+	// Run a command in a sandbox later, e.g. to check on something a minute from now
+	scheduleExecTool := mcp.NewTool("schedule_exec",
+		mcp.WithDescription(
+			"Schedule a command to run in a sandbox after a delay, without waiting for it. \n"+
+				"The output is stored like a sandbox_exec run, with the scheduled and actual fire times; executions_list shows it once it has run, "+
+				"and a notifications/resources/updated message is sent for its executions://{execution_id}/output URI. "+
+				"Pending jobs are lost when the sandbox is stopped or the server exits.",
+		),
+		mcp.WithString("container_id_or_name",
+			mcp.Required(),
+			mcp.Description("ID or name of the container to run the command in"),
+		),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Command to run with sh -c"),
+		),
+		mcp.WithNumber("delay_seconds",
+			mcp.Required(),
+			mcp.Description("Seconds to wait before running the command, or a duration such as \"10m\", from 1 second to 1 hour"),
+			mcp.Min(1),
+			mcp.Max(3600),
+			tools.OrUnitString(),
+		),
+	)
+	scheduledListTool := mcp.NewTool("scheduled_list",
+		mcp.WithDescription("Lists the schedule_exec jobs that haven't run yet, soonest first, with their schedule_id, container, command and due time."),
+	)
+	scheduleCancelTool := mcp.NewTool("schedule_cancel",
+		mcp.WithDescription("Cancel a schedule_exec job that hasn't run yet."),
+		mcp.WithString("schedule_id",
+			mcp.Required(),
+			mcp.Description("schedule_id returned by schedule_exec"),
+		),
+	)
+
+	// Dump the recorded Docker API calls; only registered with --debug-docker
+	debugDumpTool := mcp.NewTool("sandbox_debug_dump",
+		mcp.WithDescription(
+			"Returns the most recent Docker API calls the server made, oldest first, with method, path, status, duration and the start of the request and response bodies. \n"+
+				"Registry credentials and environment variable values are redacted, so the output can be attached to a bug report.",
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Number of calls to return, most recent last (default 50)"),
+			mcp.Min(1),
+		),
 	)
 
 	// Register dynamic resource for container logs
 	// Dynamic resource example - Container Logs by ID
 	containerLogsTemplate := mcp.NewResourceTemplate(
-		"containers://{id}/logs",
+		"containers://{id}/logs{?keep_ansi}",
 		"Container Logs",
-		mcp.WithTemplateDescription("Returns all container logs from the specified container. Logs are returned as a single text resource."),
+		mcp.WithTemplateDescription("Returns all container logs from the specified container. Logs are returned as a single text resource. "+
+			"{id} accepts a container ID, unique ID prefix or percent-encoded name; the contents carry the canonical URI keyed by the 12-character ID, with the name in _meta. Exited containers are prefixed with a line giving the exit code and time. "+
+			"ANSI escape sequences are stripped unless ?keep_ansi=true is given."),
 		mcp.WithTemplateMIMEType("text/plain"),
 		mcp.WithTemplateAnnotations([]mcp.Role{mcp.RoleAssistant, mcp.RoleUser}, 0.5),
 	)
 
 	s.AddResourceTemplate(containerLogsTemplate, resources.GetContainerLogs)
-	s.AddTool(initializeTool, tools.InitializeEnvironment)
-	s.AddTool(listTool, tools.ListSandboxes)
-	s.AddTool(copyProjectTool, tools.CopyProject)
-	s.AddTool(writeFileTool, tools.WriteFile)
-	s.AddTool(execTool, tools.Exec)
-	s.AddTool(copyFileTool, tools.CopyFile)
-	s.AddTool(copyFileFromContainerTool, tools.CopyFileFromContainer)
-	s.AddTool(stopContainerTool, tools.StopContainer)
+	s.AddResourceTemplate(mcp.NewResourceTemplate(
+		"executions://{id}/output",
+		"Execution Output",
+		mcp.WithTemplateDescription("Returns the output of a previous sandbox_exec run. {id} is the execution_id included in the tool result. "+
+			"Only the most recent runs are kept; see executions_list."),
+		mcp.WithTemplateMIMEType("text/plain"),
+	), resources.GetExecutionOutput)
+	s.AddResourceTemplate(mcp.NewResourceTemplate(
+		"executions://{id}/manifest",
+		"Execution Manifest",
+		mcp.WithTemplateDescription("Returns the reproducibility manifest of a previous run_command run: image digest, command, "+
+			"environment variable names, network, timeout and limits. Pass it to run_from_manifest to run it again."),
+		mcp.WithTemplateMIMEType("application/json"),
+	), resources.GetExecutionManifest)
+	s.AddResource(mcp.NewResource(resources.ServerInfoURI, "Server Info",
+		mcp.WithResourceDescription("Server version, build mode, transport, active features, configured limits and Docker daemon version."),
+		mcp.WithMIMEType("application/json"),
+	), resources.GetServerInfo)
+	s.AddResource(mcp.NewResource(tools.SandboxEventsURI, "Sandbox Events",
+		mcp.WithResourceDescription("The recent start, stop, die and oom events of sandbox containers, oldest first. "+
+			"A notifications/resources/updated message is sent for this URI after each new event."),
+		mcp.WithMIMEType("application/json"),
+	), resources.GetSandboxEvents)
+	// Watch the daemon for sandbox lifecycle events so clients learn about crashed sandboxes without polling
+	sandboxEventsCtx, stopSandboxEvents := context.WithCancel(context.Background())
+	defer stopSandboxEvents()
+	// A sandbox whose main process exits on its own has its logs captured; clients hear where they are kept
+	tools.SetSandboxExitNotifier(func(exit tools.SandboxExit) {
+		level := mcp.LoggingLevelInfo
+		if exit.ExitCode != 0 {
+			level = mcp.LoggingLevelWarning
+		}
+		s.SendNotificationToAllClients("notifications/message", map[string]any{"level": level, "logger": "sandbox", "data": exit.String()})
+		s.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": "executions://" + exit.ExecutionID + "/output"})
+		s.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": "containers://" + exit.ContainerID + "/logs"})
+	})
+	tools.StartSandboxEvents(sandboxEventsCtx, func(uri string) {
+		s.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+	})
+	// Scheduled runs announce their output as it's recorded
+	tools.SetScheduleNotifier(func(uri string) {
+		s.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+	})
+	// Every file template is listed as a resource of its own
+	for _, name := range tools.ScaffoldNames() {
+		scaffold, _ := tools.LookupScaffold(name)
+		s.AddResource(mcp.NewResource(tools.ScaffoldURIPrefix+name, "File template "+name,
+			mcp.WithResourceDescription(scaffold.Description+". Render it with scaffold_sandbox."),
+			mcp.WithMIMEType("application/json"),
+		), resources.GetScaffoldTemplate)
+	}
+	serverTools := []server.ServerTool{
+		{Tool: initializeTool, Handler: tools.InitializeEnvironment},
+		{Tool: listTool, Handler: tools.ListSandboxes},
+		{Tool: describeTool, Handler: tools.DescribeSandbox},
+		{Tool: compareTool, Handler: tools.CompareSandbox},
+		{Tool: templatesListTool, Handler: tools.ListTemplates},
+		{Tool: serverInfoTool, Handler: tools.GetServerInfo},
+		{Tool: copyProjectTool, Handler: tools.CopyProject},
+		{Tool: extractArchiveTool, Handler: tools.ExtractArchive},
+		{Tool: writeFileTool, Handler: tools.WriteFile},
+		{Tool: writeFilesTool, Handler: tools.WriteFiles},
+		{Tool: scaffoldTool, Handler: tools.ScaffoldSandbox},
+		{Tool: applyPatchTool, Handler: tools.ApplyPatch},
+		{Tool: execTool, Handler: tools.Exec},
+		{Tool: runCommandTool, Handler: tools.RunCommand},
+		{Tool: runFromManifestTool, Handler: tools.RunFromManifest},
+		{Tool: shellOpenTool, Handler: tools.ShellOpen},
+		{Tool: shellInputTool, Handler: tools.ShellInput},
+		{Tool: shellReadTool, Handler: tools.ShellRead},
+		{Tool: shellCloseTool, Handler: tools.ShellClose},
+		{Tool: copyFileTool, Handler: tools.CopyFile},
+		{Tool: copyFileFromContainerTool, Handler: tools.CopyFileFromContainer},
+		{Tool: stopContainerTool, Handler: tools.StopContainer},
+		{Tool: setActiveSandboxTool, Handler: tools.SetActiveSandbox},
+		{Tool: getActiveSandboxTool, Handler: tools.GetActiveSandbox},
+		{Tool: checkpointTool, Handler: tools.CheckpointSandbox},
+		{Tool: rollbackTool, Handler: tools.RollbackSandbox},
+		{Tool: checkpointsListTool, Handler: tools.ListCheckpoints},
+		{Tool: executionsListTool, Handler: tools.ListExecutions},
+		{Tool: checkCodeTool, Handler: tools.CheckCode},
+		{Tool: logsExportTool, Handler: tools.ExportLogs},
+		{Tool: scheduleExecTool, Handler: tools.ScheduleExec},
+		{Tool: scheduledListTool, Handler: tools.ListScheduled},
+		{Tool: scheduleCancelTool, Handler: tools.CancelScheduled},
+		{Tool: checkDependenciesTool, Handler: tools.CheckDependencies},
+		{Tool: installSystemPackagesTool, Handler: tools.InstallSystemPackages},
+	}
+	if *debugDocker {
+		dockerdebug.Enable(dockerdebug.DefaultSize)
+		serverTools = append(serverTools, server.ServerTool{Tool: debugDumpTool, Handler: tools.DebugDump})
+	}
+
+	if err := tools.ConfigureExecutionHistoryFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Trace tool calls when --otel-endpoint is set; outermost, so a call's span covers all of its handling
+	if *otelEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(context.Background(), *otelEndpoint, installer.Version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.Printf("Failed to flush traces: %v", err)
+			}
+		}()
+		server.WithToolHandlerMiddleware(tracing.Middleware)(s)
+	}
+
+	// Stream events for supervisors when --events is set; registered before the other middlewares so rejected calls are seen too
+	if *eventsFormat != "" {
+		emitter, err := startEvents(*eventsFormat, *eventsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer emitter.Close()
+		server.WithToolHandlerMiddleware(events.Middleware)(s)
+	}
+
+	// Track in-flight tool calls so shutdown doesn't kill them mid-container-creation
+	drainTimeout, err := shutdown.DrainTimeoutFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tracker := shutdown.NewTracker()
+	server.WithToolHandlerMiddleware(tracker.Middleware)(s)
+
+	// Attach a failed call's Docker API calls to its result when --debug-docker is set
+	if *debugDocker {
+		server.WithToolHandlerMiddleware(dockerdebug.Middleware)(s)
+	}
+
+	// Rate limit tool calls when SANDBOX_RATE_LIMIT or SANDBOX_RATE_LIMIT_<TOOL> is set
+	toolNames := make([]string, 0, len(serverTools))
+	for _, t := range serverTools {
+		toolNames = append(toolNames, t.Tool.Name)
+	}
+	limiter, err := ratelimit.FromEnv(toolNames)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var limits map[string]string
+	if limiter != nil {
+		server.WithToolHandlerMiddleware(limiter.Middleware)(s)
+		limits = limiter.Limits()
+	}
+
+	// Give every call a deadline so a client that gives up still gets the output captured so far; tools with a
+	// timeout of their own get that instead when it is longer
+	callTimeout, err := watchdog.TimeoutFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	server.WithToolHandlerMiddleware(watchdog.Middleware(callTimeout, tools.CallTimeouts()))(s)
+
+	// Check arguments against the tool schemas, as they stand once container_id_or_name is optional
+	implicitTools := allowImplicitSandbox(serverTools)
+	schemas := make(map[string]mcp.ToolInputSchema, len(serverTools))
+	for _, t := range serverTools {
+		schemas[t.Tool.Name] = t.Tool.InputSchema
+	}
+	server.WithToolHandlerMiddleware(tools.ArgumentValidationMiddleware(schemas))(s)
+
+	// Resolve "@latest" or an omitted sandbox reference; inside the watchdog, so the resolution counts towards the deadline
+	server.WithToolHandlerMiddleware(tools.ImplicitSandboxMiddleware(implicitTools))(s)
+
+	// Ask the user to approve destructive calls once their target is resolved
+	if *confirmDestructive {
+		server.WithToolHandlerMiddleware(confirm.Middleware(s, tools.DestructiveAction, *confirmTimeout))(s)
+	}
+
+	// Serialize the calls that change a container; after confirmation, so waiting for the user holds no lock
+	server.WithToolHandlerMiddleware(tools.ContainerLockMiddleware)(s)
+
+	tools.SetServerConfig(*transport, map[string]bool{
+		"rate_limiting":       limiter != nil,
+		"call_watchdog":       callTimeout > 0,
+		"templates":           os.Getenv("SANDBOX_TEMPLATES") != "",
+		"tracing":             *otelEndpoint != "",
+		"confirm_destructive": *confirmDestructive,
+		"debug_docker":        *debugDocker,
+	}, limits)
+	s.AddTools(serverTools...)
+
 	switch *transport {
 	case "stdio":
-		if err := server.ServeStdio(s); err != nil {
+		// Unlike server.ServeStdio, a signal lets the current call finish and write its result before exiting.
+		// Listen also waits for the calls in progress when stdin closes.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		tracker.OnSignal(drainTimeout, nil, func(drained bool) {
+			logDrain(drained, drainTimeout)
+			cancel()
+		})
+		if err := server.NewStdioServer(s).Listen(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
 			s.SendNotificationToClient(context.Background(), "notifications/error", map[string]interface{}{
 				"message": fmt.Sprintf("Failed to start stdio server: %v", err),
 			})
 		}
 	case "sse":
-		sseServer := server.NewSSEServer(s)
-		if err := sseServer.Start(fmt.Sprintf(":%s", *port)); err != nil {
+		httpServer := &http.Server{}
+		sseServer := server.NewSSEServer(s, server.WithHTTPServer(httpServer))
+		httpServer.Handler = sseServer
+
+		stopped := make(chan struct{})
+		tracker.OnSignal(drainTimeout, func() {
+			// Closing the listener refuses new connections; open SSE streams stay up to deliver results
+			go httpServer.Shutdown(context.Background())
+		}, func(drained bool) {
+			defer close(stopped)
+			logDrain(drained, drainTimeout)
+			// Results are queued on the SSE stream after their handler returns; let them flush
+			time.Sleep(sseFlushDelay)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			sseServer.Shutdown(ctx)
+		})
+
+		err := sseServer.Start(fmt.Sprintf(":%s", *port))
+		if errors.Is(err, http.ErrServerClosed) {
+			<-stopped
+		} else if err != nil {
 			s.SendNotificationToClient(context.Background(), "notifications/error", map[string]interface{}{
 				"message": fmt.Sprintf("Failed to start SSE server: %v", err),
 			})
@@ -223,6 +1154,54 @@ func main() {
 	}
 }
 
+// startEvents starts the event stream in the given format, writing to path or to stderr when path is empty
+func startEvents(format string, path string) (*events.Emitter, error) {
+	if format != "jsonl" {
+		return nil, fmt.Errorf("unsupported --events format %q: use jsonl", format)
+	}
+	var w io.Writer = os.Stderr
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open events file: %w", err)
+		}
+		w = f
+	}
+	emitter := events.NewEmitter(w)
+	events.SetDefault(emitter)
+	return emitter, nil
+}
+
+// allowImplicitSandbox makes container_id_or_name optional in the tools that require it, so they accept "@latest"
+// or no reference, and returns their names
+func allowImplicitSandbox(serverTools []server.ServerTool) []string {
+	var names []string
+	for i := range serverTools {
+		schema := &serverTools[i].Tool.InputSchema
+		// Selecting the active sandbox takes an explicit choice
+		if !slices.Contains(schema.Required, "container_id_or_name") || serverTools[i].Tool.Name == "set_active_sandbox" {
+			continue
+		}
+		schema.Required = slices.DeleteFunc(slices.Clone(schema.Required), func(r string) bool { return r == "container_id_or_name" })
+		if prop, ok := schema.Properties["container_id_or_name"].(map[string]any); ok {
+			prop["description"] = fmt.Sprint(prop["description"]) + tools.ImplicitSandboxDescription
+		}
+		names = append(names, serverTools[i].Tool.Name)
+	}
+	return names
+}
+
+// sseFlushDelay is how long shutdown waits for drained results to be written to their SSE streams
+const sseFlushDelay = 250 * time.Millisecond
+
+func logDrain(drained bool, timeout time.Duration) {
+	if drained {
+		log.Printf("Shutting down: all in-flight tool calls finished")
+	} else {
+		log.Printf("Shutting down: in-flight tool calls still running after %s were abandoned", timeout)
+	}
+}
+
 func handleNotification(
 	ctx context.Context,
 	notification mcp.JSONRPCNotification,
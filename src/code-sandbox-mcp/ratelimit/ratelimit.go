@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Rate is a number of calls allowed per period
+type Rate struct {
+	Calls  int
+	Period time.Duration
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Calls, r.Period)
+}
+
+// ParseRate parses rates such as "60/min", "10/s", "100/hour" or "5/30s"
+func ParseRate(s string) (Rate, error) {
+	callsStr, periodStr, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		return Rate{}, fmt.Errorf("invalid rate %q: expected <calls>/<period>, e.g. 60/min", s)
+	}
+
+	calls, err := strconv.Atoi(strings.TrimSpace(callsStr))
+	if err != nil || calls <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: calls must be a positive integer", s)
+	}
+
+	var period time.Duration
+	switch strings.ToLower(strings.TrimSpace(periodStr)) {
+	case "s", "sec", "second":
+		period = time.Second
+	case "m", "min", "minute":
+		period = time.Minute
+	case "h", "hr", "hour":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(strings.TrimSpace(periodStr))
+		if err != nil || period <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate %q: period must be s, min, hour or a duration like 30s", s)
+		}
+	}
+
+	return Rate{Calls: calls, Period: period}, nil
+}
+
+// bucket is a token bucket refilled continuously at Calls per Period
+type bucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate Rate, now time.Time) *bucket {
+	return &bucket{rate: rate, tokens: float64(rate.Calls), last: now}
+}
+
+// refill adds the tokens accumulated since the last call
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += elapsed.Seconds() * float64(b.rate.Calls) / b.rate.Period.Seconds()
+	if b.tokens > float64(b.rate.Calls) {
+		b.tokens = float64(b.rate.Calls)
+	}
+	b.last = now
+}
+
+// wait returns how long until a token is available, zero if one is available now
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	missing := 1 - b.tokens
+	return time.Duration(missing * float64(b.rate.Period) / float64(b.rate.Calls))
+}
+
+// Limiter enforces a global rate across all tools plus optional per-tool rates.
+// It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	global  *bucket
+	perTool map[string]*bucket
+}
+
+// NewLimiter creates a limiter. A nil global rate leaves only the per-tool rates in effect.
+func NewLimiter(global *Rate, perTool map[string]Rate, now func() time.Time) *Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	l := &Limiter{now: now, perTool: make(map[string]*bucket)}
+	start := now()
+	if global != nil {
+		l.global = newBucket(*global, start)
+	}
+	for tool, rate := range perTool {
+		l.perTool[tool] = newBucket(rate, start)
+	}
+	return l
+}
+
+// Allow consumes a token for the tool, or reports which limit was hit and how long to wait
+func (l *Limiter) Allow(tool string) (ok bool, limit string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	toolBucket := l.perTool[tool]
+
+	// Check every applicable bucket before consuming from any of them
+	if toolBucket != nil {
+		toolBucket.refill(now)
+		if wait := toolBucket.wait(); wait > 0 {
+			return false, fmt.Sprintf("%s limit of %s", tool, toolBucket.rate), wait
+		}
+	}
+	if l.global != nil {
+		l.global.refill(now)
+		if wait := l.global.wait(); wait > 0 {
+			return false, fmt.Sprintf("global limit of %s", l.global.rate), wait
+		}
+	}
+
+	if toolBucket != nil {
+		toolBucket.tokens--
+	}
+	if l.global != nil {
+		l.global.tokens--
+	}
+	return true, "", 0
+}
+
+// Middleware rejects tool calls over the limit with a RATE_LIMITED error instead of running the handler
+func (l *Limiter) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ok, limit, retryAfter := l.Allow(request.Params.Name)
+		if !ok {
+			retryAfter = retryAfter.Round(time.Millisecond)
+			return mcp.NewToolResultError(fmt.Sprintf(
+				"RATE_LIMITED: %s exceeded; retry after %s (retry_after_seconds=%.3f). Back off instead of retrying immediately.",
+				limit, retryAfter, retryAfter.Seconds(),
+			)), nil
+		}
+		return next(ctx, request)
+	}
+}
+
+// FromEnv builds a limiter from SANDBOX_RATE_LIMIT (global) and SANDBOX_RATE_LIMIT_<TOOL> (per tool,
+// tool name upper-cased) for the given tools. It returns nil when no limit is configured.
+func FromEnv(toolNames []string) (*Limiter, error) {
+	var global *Rate
+	if value := os.Getenv("SANDBOX_RATE_LIMIT"); value != "" {
+		rate, err := ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("SANDBOX_RATE_LIMIT: %w", err)
+		}
+		global = &rate
+	}
+
+	perTool := make(map[string]Rate)
+	for _, name := range toolNames {
+		key := "SANDBOX_RATE_LIMIT_" + strings.ToUpper(name)
+		if value := os.Getenv(key); value != "" {
+			rate, err := ParseRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			perTool[name] = rate
+		}
+	}
+
+	if global == nil && len(perTool) == 0 {
+		return nil, nil
+	}
+	return NewLimiter(global, perTool, nil), nil
+}
+
+// Limits describes the configured rates keyed by "global" or tool name
+func (l *Limiter) Limits() map[string]string {
+	limits := make(map[string]string, len(l.perTool)+1)
+	if l.global != nil {
+		limits["global"] = l.global.rate.String()
+	}
+	for tool, b := range l.perTool {
+		limits[tool] = b.rate.String()
+	}
+	return limits
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "60/min", want: Rate{Calls: 60, Period: time.Minute}},
+		{in: "10/s", want: Rate{Calls: 10, Period: time.Second}},
+		{in: " 100 / hour ", want: Rate{Calls: 100, Period: time.Hour}},
+		{in: "5/30s", want: Rate{Calls: 5, Period: 30 * time.Second}},
+		{in: "60", wantErr: true},
+		{in: "0/min", wantErr: true},
+		{in: "ten/min", wantErr: true},
+		{in: "10/fortnight", wantErr: true},
+		{in: "10/-5s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLimiterGlobalRefill(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLimiter(&Rate{Calls: 2, Period: time.Minute}, nil, clock.Now)
+
+	ok, _, _ := l.Allow("sandbox_exec")
+	assert.True(t, ok)
+	ok, _, _ = l.Allow("sandbox_list")
+	assert.True(t, ok)
+
+	ok, limit, retryAfter := l.Allow("sandbox_exec")
+	assert.False(t, ok)
+	assert.Contains(t, limit, "global")
+	assert.Equal(t, 30*time.Second, retryAfter)
+
+	clock.Advance(29 * time.Second)
+	ok, _, retryAfter = l.Allow("sandbox_exec")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retryAfter.Round(time.Millisecond))
+
+	clock.Advance(time.Second)
+	ok, _, _ = l.Allow("sandbox_exec")
+	assert.True(t, ok)
+}
+
+func TestLimiterPerToolOverride(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLimiter(&Rate{Calls: 60, Period: time.Minute}, map[string]Rate{
+		"sandbox_initialize": {Calls: 1, Period: time.Minute},
+	}, clock.Now)
+
+	ok, _, _ := l.Allow("sandbox_initialize")
+	assert.True(t, ok)
+	ok, limit, retryAfter := l.Allow("sandbox_initialize")
+	assert.False(t, ok)
+	assert.Contains(t, limit, "sandbox_initialize")
+	assert.Equal(t, time.Minute, retryAfter)
+
+	// Other tools only see the global limit, which the rejected call did not consume
+	for i := 0; i < 59; i++ {
+		ok, _, _ = l.Allow("sandbox_exec")
+		require.True(t, ok, "call %d", i)
+	}
+	ok, limit, _ = l.Allow("sandbox_exec")
+	assert.False(t, ok)
+	assert.Contains(t, limit, "global")
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLimiter(&Rate{Calls: 50, Period: time.Minute}, nil, clock.Now)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _, _ := l.Allow("sandbox_exec"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, allowed)
+}
+
+func TestMiddlewareReturnsRateLimited(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLimiter(&Rate{Calls: 1, Period: 10 * time.Second}, nil, clock.Now)
+
+	calls := 0
+	handler := l.Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		calls++
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	request := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "sandbox_exec"}}
+	result, err := handler(context.Background(), request)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	result, err = handler(context.Background(), request)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	text := result.Content[0].(mcp.TextContent).Text
+	assert.Contains(t, text, "RATE_LIMITED")
+	assert.Contains(t, text, "retry_after_seconds=10.000")
+	assert.Equal(t, 1, calls)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("SANDBOX_RATE_LIMIT", "")
+	l, err := FromEnv([]string{"sandbox_exec"})
+	require.NoError(t, err)
+	assert.Nil(t, l)
+
+	t.Setenv("SANDBOX_RATE_LIMIT", "60/min")
+	t.Setenv("SANDBOX_RATE_LIMIT_SANDBOX_INITIALIZE", "10/min")
+	l, err = FromEnv([]string{"sandbox_exec", "sandbox_initialize"})
+	require.NoError(t, err)
+	require.NotNil(t, l)
+	assert.Equal(t, Rate{Calls: 60, Period: time.Minute}, l.global.rate)
+	assert.Equal(t, Rate{Calls: 10, Period: time.Minute}, l.perTool["sandbox_initialize"].rate)
+
+	t.Setenv("SANDBOX_RATE_LIMIT", "lots")
+	_, err = FromEnv(nil)
+	assert.ErrorContains(t, err, "SANDBOX_RATE_LIMIT")
+}
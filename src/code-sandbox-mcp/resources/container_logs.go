@@ -2,29 +2,61 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/pkg/stdcopy"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// GetContainerLogs returns the logs of a container. The {id} segment of the URI accepts a container ID, ID prefix
+// or name, resolved like the container_id_or_name of the tools; the contents are always keyed by the canonical
+// containers://{12-char ID}/logs URI, with the full ID and name in their _meta, so every reference to a container
+// reads back as the same resource.
 func GetContainerLogs(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 	defer cli.Close()
 
-	containerIDPath, found := strings.CutPrefix(request.Params.URI, "containers://") // Extract ID from the full URI
-	if !found {
-		return nil, fmt.Errorf("invalid URI: %s", request.Params.URI)
+	containerID, keepANSI, err := parseLogsURI(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	// A reference matching no container is left for the inspect below to report with the known sandboxes
+	resolved, err := tools.ResolveContainer(ctx, containerID)
+	var ambiguous *tools.AmbiguousReferenceError
+	switch {
+	case errors.As(err, &ambiguous):
+		return nil, err
+	case err == nil:
+		containerID = resolved
+	}
+
+	// Inspect first so unknown containers get a useful answer instead of a raw 404
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			// A sandbox that exited on its own had its logs captured before it was removed
+			if exit, record, ok := tools.LookupSandboxExit(containerID); ok {
+				return capturedLogs(exit, record, keepANSI), nil
+			}
+			return nil, fmt.Errorf("%s", notFoundMessage(containerID, knownSandboxIDs(ctx, cli)))
+		}
+		return nil, fmt.Errorf("error inspecting container: %w", err)
 	}
-	containerID := strings.TrimSuffix(containerIDPath, "/logs")
 
 	// Set default ContainerLogsOptions
 	logOpts := container.LogsOptions{
@@ -40,18 +72,125 @@ func GetContainerLogs(ctx context.Context, request mcp.ReadResourceRequest) ([]m
 	defer reader.Close()
 
 	var b strings.Builder
-	if _, err := stdcopy.StdCopy(&b, &b, reader); err != nil {
+	var warning string
+	if inspect.Config != nil && inspect.Config.Tty {
+		// TTY containers don't multiplex their output
+		if _, err := io.Copy(&b, reader); err != nil {
+			return nil, fmt.Errorf("error copying container logs: %w", err)
+		}
+	} else if warning, err = tools.DemuxOutput(&b, &b, reader); err != nil {
 		return nil, fmt.Errorf("error copying container logs: %w", err)
 	}
 
 	// Combine them. You could also return them separately if you prefer.
-	combined := b.String()
+	combined, sanitized := tools.SanitizeOutput(b.String(), keepANSI)
+	if sanitized.Changed() {
+		combined = fmt.Sprintf("[output sanitized: %s]\n", sanitized) + combined
+	}
+	if warning != "" {
+		combined = fmt.Sprintf("[warning: %s]\n", warning) + combined
+	}
+	if inspect.State != nil {
+		combined = stateHeader(inspect.State) + combined
+	}
 
 	return []mcp.ResourceContents{
 		mcp.TextResourceContents{
-			URI:      fmt.Sprintf("containers://%s/logs", containerID),
+			Meta: &mcp.Meta{AdditionalFields: map[string]any{
+				"container_id": inspect.ID,
+				"name":         strings.TrimPrefix(inspect.Name, "/"),
+			}},
+			URI:      logsURI(inspect.ID, keepANSI),
 			MIMEType: "text/plain",
 			Text:     combined,
 		},
 	}, nil
 }
+
+// capturedLogs serves the logs captured when a removed sandbox exited, under the same URI and _meta as while it
+// existed. They were stored with ANSI escape sequences stripped, whatever keep_ansi asks for.
+func capturedLogs(exit tools.SandboxExit, record tools.ExecutionRecord, keepANSI bool) []mcp.ResourceContents {
+	text := fmt.Sprintf("[container exited with code %d at %s]\n[container removed; these logs were captured when it exited, as executions://%s/output]\n",
+		exit.ExitCode, exit.FinishedAt.Format(time.RFC3339), exit.ExecutionID)
+	if record.Truncated {
+		text += "[only the end of the logs was kept]\n"
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			Meta: &mcp.Meta{AdditionalFields: map[string]any{
+				"container_id": exit.FullID(),
+				"name":         exit.Name,
+				"execution_id": exit.ExecutionID,
+			}},
+			URI:      logsURI(exit.FullID(), keepANSI),
+			MIMEType: "text/plain",
+			Text:     text + record.Output,
+		},
+	}
+}
+
+// logsURI returns the canonical logs URI of a container, keyed by its short ID
+func logsURI(containerID string, keepANSI bool) string {
+	uri := fmt.Sprintf("containers://%s/logs", url.PathEscape(containerID[:min(len(containerID), 12)]))
+	if keepANSI {
+		uri += "?keep_ansi=true"
+	}
+	return uri
+}
+
+// parseLogsURI extracts the percent-decoded container reference and the keep_ansi flag from
+// containers://{id}/logs{?keep_ansi}
+func parseLogsURI(uri string) (string, bool, error) {
+	containerIDPath, found := strings.CutPrefix(uri, "containers://") // Extract ID from the full URI
+	if !found {
+		return "", false, fmt.Errorf("invalid URI: %s", uri)
+	}
+	containerIDPath, rawQuery, _ := strings.Cut(containerIDPath, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid URI query: %s", uri)
+	}
+	keepANSI, _ := strconv.ParseBool(query.Get("keep_ansi"))
+	ref, err := url.PathUnescape(strings.TrimSuffix(containerIDPath, "/logs"))
+	if err != nil {
+		return "", false, fmt.Errorf("invalid URI: %s: %w", uri, err)
+	}
+	return ref, keepANSI, nil
+}
+
+// stateHeader describes a container that is no longer running; running containers get no header
+func stateHeader(state *container.State) string {
+	if state.Running || state.Status == "created" {
+		return ""
+	}
+	finishedAt := state.FinishedAt
+	if t, err := time.Parse(time.RFC3339Nano, finishedAt); err == nil {
+		finishedAt = t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("[container exited with code %d at %s]\n", state.ExitCode, finishedAt)
+}
+
+// knownSandboxIDs lists the short IDs of all sandbox containers, ignoring errors since it only decorates another error
+func knownSandboxIDs(ctx context.Context, cli *client.Client) []string {
+	containers, err := tools.ListSandboxContainers(ctx, cli, true, false)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(containers))
+	for _, c := range containers {
+		id := c.ID[:12]
+		if name := tools.ContainerName(c); name != "" {
+			id += " (" + name + ")"
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// notFoundMessage builds the error returned for an unknown container reference
+func notFoundMessage(ref string, known []string) string {
+	if len(known) == 0 {
+		return fmt.Sprintf("container %s not found; there are no sandbox containers, create one with sandbox_initialize", ref)
+	}
+	return fmt.Sprintf("container %s not found; known sandboxes: %s", ref, strings.Join(known, ", "))
+}
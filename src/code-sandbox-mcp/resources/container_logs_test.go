@@ -0,0 +1,127 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/internal/dockertest"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+)
+
+func newReadResourceRequest(uri string) mcp.ReadResourceRequest {
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+	return request
+}
+
+func TestMain(m *testing.M) {
+	dockertest.Main(m)
+}
+
+// runSandboxContainer runs cmd to completion in a container labeled as a sandbox
+func runSandboxContainer(t *testing.T, cli *client.Client, name string, cmd ...string) string {
+	t.Helper()
+	return dockertest.Run(t, cli, name, map[string]string{tools.SandboxLabel: "true"}, cmd...)
+}
+
+func TestStateHeader(t *testing.T) {
+	assert.Empty(t, stateHeader(&container.State{Running: true, Status: "running"}))
+	assert.Empty(t, stateHeader(&container.State{Status: "created"}))
+	assert.Equal(t,
+		"[container exited with code 1 at 2024-06-01T12:00:00Z]\n",
+		stateHeader(&container.State{Status: "exited", ExitCode: 1, FinishedAt: "2024-06-01T12:00:00.123456789Z"}),
+	)
+}
+
+func TestNotFoundMessage(t *testing.T) {
+	assert.Contains(t, notFoundMessage("nope", nil), "sandbox_initialize")
+	msg := notFoundMessage("nope", []string{"0123456789ab (one)", "ba9876543210 (two)"})
+	assert.Contains(t, msg, "container nope not found")
+	assert.Contains(t, msg, "0123456789ab (one), ba9876543210 (two)")
+}
+
+func TestGetContainerLogsUnknownContainer(t *testing.T) {
+	dockertest.Require(t)
+
+	_, err := GetContainerLogs(context.Background(), newReadResourceRequest("containers://doesnotexist/logs"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "container doesnotexist not found")
+}
+
+func TestGetContainerLogsExitedContainer(t *testing.T) {
+	cli := dockertest.Require(t)
+	id := runSandboxContainer(t, cli, "mcp-test-logs-exited", "sh", "-c", "echo goodbye; exit 1")
+
+	contents, err := GetContainerLogs(context.Background(), newReadResourceRequest("containers://"+id+"/logs"))
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	text := contents[0].(mcp.TextResourceContents).Text
+	assert.True(t, strings.HasPrefix(text, "[container exited with code 1 at "), text)
+	assert.Contains(t, text, "goodbye")
+}
+
+func TestGetContainerLogsByName(t *testing.T) {
+	cli := dockertest.Require(t)
+	runSandboxContainer(t, cli, "mcp-test-logs-by-name", "echo", "hello by name")
+
+	contents, err := GetContainerLogs(context.Background(), newReadResourceRequest("containers://mcp-test-logs-by-name/logs"))
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Contains(t, contents[0].(mcp.TextResourceContents).Text, "hello by name")
+}
+
+func TestGetContainerLogsCanonicalURI(t *testing.T) {
+	cli := dockertest.Require(t)
+	id := runSandboxContainer(t, cli, "mcp-test-logs.canonical_uri", "echo", "hello canonical")
+
+	for _, ref := range []string{"mcp-test-logs.canonical_uri", "mcp-test-logs%2Ecanonical%5Furi", id[:12], id} {
+		t.Run(ref, func(t *testing.T) {
+			contents, err := GetContainerLogs(context.Background(), newReadResourceRequest("containers://"+ref+"/logs"))
+			require.NoError(t, err)
+			require.Len(t, contents, 1)
+			logs := contents[0].(mcp.TextResourceContents)
+			assert.Equal(t, "containers://"+id[:12]+"/logs", logs.URI)
+			assert.Contains(t, logs.Text, "hello canonical")
+			require.NotNil(t, logs.Meta)
+			assert.Equal(t, map[string]any{"container_id": id, "name": "mcp-test-logs.canonical_uri"}, logs.Meta.AdditionalFields)
+		})
+	}
+
+	contents, err := GetContainerLogs(context.Background(), newReadResourceRequest("containers://"+id[:12]+"/logs?keep_ansi=true"))
+	require.NoError(t, err)
+	assert.Equal(t, "containers://"+id[:12]+"/logs?keep_ansi=true", contents[0].(mcp.TextResourceContents).URI)
+}
+
+func TestLogsURI(t *testing.T) {
+	assert.Equal(t, "containers://0123456789ab/logs", logsURI("0123456789abcdef0123456789abcdef", false))
+	assert.Equal(t, "containers://0123456789ab/logs?keep_ansi=true", logsURI("0123456789abcdef0123456789abcdef", true))
+}
+
+func TestParseLogsURI(t *testing.T) {
+	id, keepANSI, err := parseLogsURI("containers://my-box/logs")
+	require.NoError(t, err)
+	assert.Equal(t, "my-box", id)
+	assert.False(t, keepANSI)
+
+	id, keepANSI, err = parseLogsURI("containers://abc123/logs?keep_ansi=true")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", id)
+	assert.True(t, keepANSI)
+
+	id, _, err = parseLogsURI("containers://my%5Fbox.v2/logs")
+	require.NoError(t, err)
+	assert.Equal(t, "my_box.v2", id)
+
+	_, _, err = parseLogsURI("containers://my%zzbox/logs")
+	assert.Error(t, err)
+
+	_, _, err = parseLogsURI("files://abc123/logs")
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// GetExecutionOutput returns the stored output of a previous tool run. The {id} segment of the URI is the
+// execution_id returned in the tool result.
+func GetExecutionOutput(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	idPath, found := strings.CutPrefix(request.Params.URI, "executions://")
+	if !found {
+		return nil, fmt.Errorf("invalid URI: %s", request.Params.URI)
+	}
+	id := strings.TrimSuffix(idPath, "/output")
+
+	record, ok := tools.LookupExecution(id)
+	if !ok {
+		return nil, fmt.Errorf("execution %s not found; it may have been evicted, use executions_list to see the stored executions", id)
+	}
+
+	text := record.Output
+	if record.Truncated {
+		text = "[output truncated to its most recent part]\n" + text
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      fmt.Sprintf("executions://%s/output", id),
+			MIMEType: "text/plain",
+			Text:     text,
+		},
+	}, nil
+}
+
+// GetExecutionManifest returns the reproducibility manifest of a previous run_command run as JSON
+func GetExecutionManifest(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	idPath, found := strings.CutPrefix(request.Params.URI, "executions://")
+	if !found {
+		return nil, fmt.Errorf("invalid URI: %s", request.Params.URI)
+	}
+	id := strings.TrimSuffix(idPath, "/manifest")
+
+	record, ok := tools.LookupExecution(id)
+	if !ok {
+		return nil, fmt.Errorf("execution %s not found; it may have been evicted, use executions_list to see the stored executions", id)
+	}
+	if record.Manifest == nil {
+		return nil, fmt.Errorf("execution %s has no manifest; manifests are recorded for run_command runs", id)
+	}
+
+	jsonData, err := json.MarshalIndent(record.Manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize manifest: %v", err)
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      fmt.Sprintf("executions://%s/manifest", id),
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
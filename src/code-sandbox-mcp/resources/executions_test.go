@@ -0,0 +1,26 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExecutionOutputUnknownID(t *testing.T) {
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = "executions://exec-does-not-exist/output"
+
+	_, err := GetExecutionOutput(context.Background(), request)
+	assert.ErrorContains(t, err, "execution exec-does-not-exist not found")
+	assert.ErrorContains(t, err, "executions_list")
+}
+
+func TestGetExecutionManifestUnknownID(t *testing.T) {
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = "executions://exec-does-not-exist/manifest"
+
+	_, err := GetExecutionManifest(context.Background(), request)
+	assert.ErrorContains(t, err, "execution exec-does-not-exist not found")
+}
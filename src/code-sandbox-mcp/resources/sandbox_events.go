@@ -0,0 +1,26 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// GetSandboxEvents returns the recent start, stop, die and oom events of sandbox containers as JSON, oldest first
+func GetSandboxEvents(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	jsonData, err := json.MarshalIndent(tools.SandboxEvents(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize sandbox events: %v", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      tools.SandboxEventsURI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// GetScaffoldTemplate returns a file template of scaffold_sandbox: its description and variables as JSON,
+// followed by each file with its {{variable}} placeholders unrendered
+func GetScaffoldTemplate(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	name, found := strings.CutPrefix(request.Params.URI, tools.ScaffoldURIPrefix)
+	if !found {
+		return nil, fmt.Errorf("invalid URI: %s", request.Params.URI)
+	}
+	scaffold, err := tools.LookupScaffold(name)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(scaffold.Files))
+	for _, f := range scaffold.Files {
+		paths = append(paths, f.Path)
+	}
+	jsonData, err := json.MarshalIndent(map[string]interface{}{
+		"name":        scaffold.Name,
+		"description": scaffold.Description,
+		"variables":   scaffold.Variables,
+		"files":       paths,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize template: %v", err)
+	}
+
+	contents := []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}
+	for _, f := range scaffold.Files {
+		contents = append(contents, mcp.TextResourceContents{
+			URI:      request.Params.URI + "/" + f.Path,
+			MIMEType: "text/plain",
+			Text:     f.Contents,
+		})
+	}
+	return contents, nil
+}
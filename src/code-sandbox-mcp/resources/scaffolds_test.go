@@ -0,0 +1,28 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetScaffoldTemplate(t *testing.T) {
+	contents, err := GetScaffoldTemplate(context.Background(), newReadResourceRequest("templates://python/fastapi-app"))
+	require.NoError(t, err)
+	require.Greater(t, len(contents), 1)
+	summary := contents[0].(mcp.TextResourceContents)
+	assert.Equal(t, "application/json", summary.MIMEType)
+	assert.Contains(t, summary.Text, `"project_name": "app"`)
+
+	var uris []string
+	for _, c := range contents[1:] {
+		uris = append(uris, c.(mcp.TextResourceContents).URI)
+	}
+	assert.Contains(t, uris, "templates://python/fastapi-app/main.py")
+
+	_, err = GetScaffoldTemplate(context.Background(), newReadResourceRequest("templates://python/unknown"))
+	assert.ErrorContains(t, err, `unknown template "python/unknown"`)
+}
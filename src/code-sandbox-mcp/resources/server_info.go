@@ -0,0 +1,29 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ServerInfoURI is the static resource exposing the same data as the sandbox_server_info tool
+const ServerInfoURI = "server://info"
+
+// GetServerInfo returns the server build, configuration and Docker daemon details as JSON
+func GetServerInfo(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	jsonData, err := json.MarshalIndent(tools.CurrentServerInfo(ctx), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize server info: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      ServerInfoURI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
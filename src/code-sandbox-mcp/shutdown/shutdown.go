@@ -0,0 +1,119 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultDrainTimeout is how long shutdown waits for in-flight tool calls unless SANDBOX_SHUTDOWN_TIMEOUT is set
+const DefaultDrainTimeout = 30 * time.Second
+
+// Tracker counts in-flight tool calls so shutdown can wait for them, and rejects calls once shutdown has begun
+type Tracker struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	// idle is closed when the last in-flight call returns during a drain
+	idle chan struct{}
+}
+
+// NewTracker creates a tracker with no calls in flight
+func NewTracker() *Tracker {
+	return &Tracker{idle: make(chan struct{})}
+}
+
+// Middleware tracks each tool call until its handler returns; calls arriving during shutdown get a
+// SHUTTING_DOWN error instead of running
+func (t *Tracker) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t.mu.Lock()
+		if t.draining {
+			t.mu.Unlock()
+			return mcp.NewToolResultError("SHUTTING_DOWN: the server is shutting down and no longer accepts tool calls"), nil
+		}
+		t.inFlight++
+		t.mu.Unlock()
+		defer t.done()
+
+		return next(ctx, request)
+	}
+}
+
+func (t *Tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	if t.draining && t.inFlight == 0 {
+		close(t.idle)
+	}
+}
+
+// Drain stops accepting tool calls and waits up to timeout for the in-flight ones to return. It reports
+// whether all of them finished in time.
+func (t *Tracker) Drain(timeout time.Duration) bool {
+	t.mu.Lock()
+	if !t.draining {
+		t.draining = true
+		if t.inFlight == 0 {
+			close(t.idle)
+		}
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.idle:
+		return true
+	default:
+	}
+	select {
+	case <-t.idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// OnSignal handles the first SIGINT or SIGTERM by calling begin (if not nil), draining the tracker and then
+// calling stop with whether every in-flight call finished. A second signal calls stop immediately.
+func (t *Tracker) OnSignal(timeout time.Duration, begin func(), stop func(drained bool)) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		if begin != nil {
+			begin()
+		}
+		drained := make(chan bool, 1)
+		go func() { drained <- t.Drain(timeout) }()
+
+		select {
+		case ok := <-drained:
+			stop(ok)
+		case <-signals:
+			stop(false)
+		}
+		signal.Stop(signals)
+	}()
+}
+
+// DrainTimeoutFromEnv reads SANDBOX_SHUTDOWN_TIMEOUT, a duration such as 30s or 2m
+func DrainTimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("SANDBOX_SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return DefaultDrainTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("SANDBOX_SHUTDOWN_TIMEOUT: invalid duration %q", value)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,78 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSlowServer returns a server with a "slow_exec" tool that signals started and then takes delay to finish
+func newSlowServer(tracker *Tracker, delay time.Duration, started chan<- struct{}) *server.MCPServer {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(tracker.Middleware))
+	s.AddTool(mcp.NewTool("slow_exec"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		close(started)
+		time.Sleep(delay)
+		return mcp.NewToolResultText("exec finished"), nil
+	})
+	return s
+}
+
+func callTool(s *server.MCPServer, id int, name string) mcp.JSONRPCMessage {
+	message, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name},
+	})
+	return s.HandleMessage(context.Background(), message)
+}
+
+func resultText(t *testing.T, message mcp.JSONRPCMessage) (string, bool) {
+	t.Helper()
+	response, ok := message.(mcp.JSONRPCResponse)
+	require.True(t, ok, "expected a result, got %#v", message)
+	result, ok := response.Result.(mcp.CallToolResult)
+	require.True(t, ok)
+	return result.Content[0].(mcp.TextContent).Text, result.IsError
+}
+
+func TestDrainTimeout(t *testing.T) {
+	tracker := NewTracker()
+	started := make(chan struct{})
+	s := newSlowServer(tracker, time.Second, started)
+
+	go callTool(s, 1, "slow_exec")
+	<-started
+
+	begin := time.Now()
+	assert.False(t, tracker.Drain(50*time.Millisecond))
+	assert.Less(t, time.Since(begin), time.Second)
+}
+
+func TestDrainWithNothingInFlight(t *testing.T) {
+	assert.True(t, NewTracker().Drain(0))
+}
+
+func TestDrainTimeoutFromEnv(t *testing.T) {
+	t.Setenv("SANDBOX_SHUTDOWN_TIMEOUT", "")
+	timeout, err := DrainTimeoutFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDrainTimeout, timeout)
+
+	t.Setenv("SANDBOX_SHUTDOWN_TIMEOUT", "2m")
+	timeout, err = DrainTimeoutFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, timeout)
+
+	for _, bad := range []string{"soon", "-5s"} {
+		t.Setenv("SANDBOX_SHUTDOWN_TIMEOUT", bad)
+		_, err = DrainTimeoutFromEnv()
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package shutdown
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalDrainsInFlightCall(t *testing.T) {
+	tracker := NewTracker()
+	started := make(chan struct{})
+	s := newSlowServer(tracker, 300*time.Millisecond, started)
+
+	stopped := make(chan bool, 1)
+	began := make(chan struct{})
+	tracker.OnSignal(5*time.Second, func() { close(began) }, func(drained bool) { stopped <- drained })
+
+	delivered := make(chan mcp.JSONRPCMessage, 1)
+	go func() { delivered <- callTool(s, 1, "slow_exec") }()
+	<-started
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	<-began
+
+	// Calls arriving during shutdown are rejected without running
+	text, isError := resultText(t, callTool(s, 2, "slow_exec"))
+	assert.True(t, isError)
+	assert.Contains(t, text, "SHUTTING_DOWN")
+
+	select {
+	case drained := <-stopped:
+		assert.True(t, drained)
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not finish")
+	}
+
+	// The in-flight call still delivered its result
+	text, isError = resultText(t, <-delivered)
+	assert.False(t, isError)
+	assert.Equal(t, "exec finished", text)
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// activeSandbox is the default sandbox of an MCP session
+type activeSandbox struct {
+	Ref         string `json:"container_id_or_name"` // as ResolveContainer returns it
+	ContainerID string `json:"container_id"`
+	Stopped     bool   `json:"-"` // stopped since it was set, so omitted references fail until another is set
+}
+
+// activeSandboxes holds the default sandbox of each session, by session ID
+var activeSandboxes = struct {
+	sync.Mutex
+	sessions map[string]activeSandbox
+}{sessions: map[string]activeSandbox{}}
+
+// NoActiveSandboxError is returned for an omitted reference after the session's active sandbox was stopped
+type NoActiveSandboxError struct {
+	Stopped string
+}
+
+func (e *NoActiveSandboxError) Error() string {
+	return fmt.Sprintf("NO_ACTIVE_SANDBOX: the active sandbox %s was stopped; create one with sandbox_initialize and select it with set_active_sandbox, or pass container_id_or_name", e.Stopped)
+}
+
+// sessionID identifies the session of a call; calls outside a session share the empty ID
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// lookupActiveSandbox returns the active sandbox of the calling session
+func lookupActiveSandbox(ctx context.Context) (activeSandbox, bool) {
+	activeSandboxes.Lock()
+	defer activeSandboxes.Unlock()
+	active, ok := activeSandboxes.sessions[sessionID(ctx)]
+	return active, ok
+}
+
+// clearActiveSandbox marks the sandbox stopped in every session that has it active
+func clearActiveSandbox(ref string) {
+	activeSandboxes.Lock()
+	defer activeSandboxes.Unlock()
+	for id, active := range activeSandboxes.sessions {
+		if active.Ref == ref {
+			active.Stopped = true
+			activeSandboxes.sessions[id] = active
+		}
+	}
+}
+
+// ForgetActiveSandbox drops the active sandbox of a session that ended
+func ForgetActiveSandbox(sessionID string) {
+	activeSandboxes.Lock()
+	defer activeSandboxes.Unlock()
+	delete(activeSandboxes.sessions, sessionID)
+}
+
+// SetActiveSandbox makes a running sandbox the default of the calling session, used when container_id_or_name
+// is omitted
+func SetActiveSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ref, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("Error: container_id_or_name is required"), nil
+	}
+	sandboxes, err := listImplicitSandboxes(ctx)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to list sandboxes: %v", err)), nil
+	}
+	if ref == LatestSandbox {
+		latest, err := pickImplicitSandbox(sandboxes, LatestSandbox)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		ref = latest.ID
+	}
+	resolved, err := matchContainer(sandboxes, ref)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v; set_active_sandbox only selects a running sandbox", err)), nil
+	}
+	active := activeSandbox{Ref: resolved}
+	for _, c := range sandboxes {
+		if ContainerName(c) == resolved || c.ID == resolved {
+			active.ContainerID = c.ID[:12]
+		}
+	}
+
+	activeSandboxes.Lock()
+	activeSandboxes.sessions[sessionID(ctx)] = active
+	activeSandboxes.Unlock()
+	return mcp.NewToolResultText(fmt.Sprintf("Active sandbox is now %s (container ID %s); tools use it when container_id_or_name is omitted", active.Ref, active.ContainerID)), nil
+}
+
+// GetActiveSandbox returns the default sandbox of the calling session as JSON
+func GetActiveSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	active, ok := lookupActiveSandbox(ctx)
+	if !ok {
+		return mcp.NewToolResultText("No active sandbox; select one with set_active_sandbox"), nil
+	}
+	if active.Stopped {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", &NoActiveSandboxError{Stopped: active.Ref})), nil
+	}
+	jsonData, err := json.Marshal(active)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize active sandbox: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSession is an MCP client session with a fixed ID
+type fakeSession struct{ id string }
+
+func (s *fakeSession) Initialize()       {}
+func (s *fakeSession) Initialized() bool { return true }
+func (s *fakeSession) SessionID() string { return s.id }
+func (s *fakeSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return make(chan mcp.JSONRPCNotification, 1)
+}
+
+// sessionContext returns a context for calls made in the named session
+func sessionContext(t *testing.T, id string) context.Context {
+	t.Helper()
+	t.Cleanup(func() { ForgetActiveSandbox(id) })
+	return server.NewMCPServer("test", "0").WithContext(context.Background(), &fakeSession{id: id})
+}
+
+func TestActiveSandbox(t *testing.T) {
+	withImplicitSandboxes(t, olderSandbox, newerSandbox)
+	alice, bob := sessionContext(t, "alice"), sessionContext(t, "bob")
+
+	var seen string
+	exec := ImplicitSandboxMiddleware([]string{"sandbox_exec"})(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		seen = request.GetString("container_id_or_name", "")
+		return mcp.NewToolResultText("ok"), nil
+	})
+	call := func(ctx context.Context, args map[string]interface{}) *mcp.CallToolResult {
+		t.Helper()
+		seen = ""
+		result, err := exec(ctx, newMockCallToolRequest("sandbox_exec", args))
+		require.NoError(t, err)
+		return result
+	}
+
+	result, err := GetActiveSandbox(alice, newMockCallToolRequest("get_active_sandbox", nil))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "No active sandbox")
+
+	result, err = SetActiveSandbox(alice, newMockCallToolRequest("set_active_sandbox", map[string]interface{}{"container_id_or_name": "aaaa1111"}))
+	require.NoError(t, err)
+	assert.Equal(t, "Active sandbox is now mcp-older (container ID aaaa11112222); tools use it when container_id_or_name is omitted", resultText(t, result))
+
+	result, err = GetActiveSandbox(alice, newMockCallToolRequest("get_active_sandbox", nil))
+	require.NoError(t, err)
+	var active map[string]string
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &active))
+	assert.Equal(t, map[string]string{"container_id_or_name": "mcp-older", "container_id": "aaaa11112222"}, active)
+
+	// With two sandboxes running, the active one settles an omitted reference, and the result names it
+	result = call(alice, map[string]interface{}{"commands": []interface{}{"ls"}})
+	assert.Equal(t, "mcp-older", seen)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, "Used sandbox mcp-older (container ID aaaa11112222), the active sandbox", result.Content[1].(mcp.TextContent).Text)
+
+	// "@latest" and explicit references still win, and other sessions are unaffected
+	call(alice, map[string]interface{}{"container_id_or_name": LatestSandbox})
+	assert.Equal(t, "mcp-newer", seen)
+	call(alice, map[string]interface{}{"container_id_or_name": "mcp-newer"})
+	assert.Equal(t, "mcp-newer", seen)
+	result = call(bob, map[string]interface{}{})
+	assert.Contains(t, resultText(t, result), "AMBIGUOUS_REFERENCE")
+
+	// Stopping the active sandbox clears it until another is selected
+	clearActiveSandbox("mcp-older")
+	result = call(alice, map[string]interface{}{})
+	assert.Equal(t, "", seen)
+	assert.Contains(t, resultText(t, result), "NO_ACTIVE_SANDBOX: the active sandbox mcp-older was stopped")
+	result, err = GetActiveSandbox(alice, newMockCallToolRequest("get_active_sandbox", nil))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "NO_ACTIVE_SANDBOX")
+
+	_, err = SetActiveSandbox(alice, newMockCallToolRequest("set_active_sandbox", map[string]interface{}{"container_id_or_name": LatestSandbox}))
+	require.NoError(t, err)
+	call(alice, map[string]interface{}{})
+	assert.Equal(t, "mcp-newer", seen)
+}
+
+func TestSetActiveSandboxRequiresRunningSandbox(t *testing.T) {
+	withImplicitSandboxes(t, olderSandbox)
+	ctx := sessionContext(t, "carol")
+
+	result, err := SetActiveSandbox(ctx, newMockCallToolRequest("set_active_sandbox", map[string]interface{}{"container_id_or_name": "mcp-gone"}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "set_active_sandbox only selects a running sandbox")
+	_, ok := lookupActiveSandbox(ctx)
+	assert.False(t, ok)
+}
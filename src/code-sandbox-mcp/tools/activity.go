@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"sync"
+	"time"
+)
+
+// activityLog remembers when a tool last used each container, keyed by the reference ResolveContainer returns
+type activityLog struct {
+	sync.Mutex
+	last map[string]time.Time
+	now  func() time.Time // replaced in tests
+}
+
+// sandboxActivity records the use of containers by exec, shell, copy, write and logs calls. It is kept in memory,
+// so after a restart a sandbox counts as idle since it started.
+var sandboxActivity = &activityLog{last: map[string]time.Time{}, now: time.Now}
+
+// Touch records that a container is being used now
+func (a *activityLog) Touch(ref string) {
+	a.Lock()
+	defer a.Unlock()
+	a.last[ref] = a.now()
+}
+
+// LastUsed returns the latest use of a container known by any of refs
+func (a *activityLog) LastUsed(refs ...string) (time.Time, bool) {
+	a.Lock()
+	defer a.Unlock()
+	var last time.Time
+	for _, ref := range refs {
+		if t, ok := a.last[ref]; ok && t.After(last) {
+			last = t
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// Forget drops the activity of a removed container, known by any of refs
+func (a *activityLog) Forget(refs ...string) {
+	a.Lock()
+	defer a.Unlock()
+	for _, ref := range refs {
+		delete(a.last, ref)
+	}
+}
+
+// idleSeconds is how long a running container has gone unused: since a tool last used it, or since it started
+// when none has since. It is nil when the start time is unknown.
+func idleSeconds(startedAt string, now time.Time, refs ...string) *int64 {
+	started, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil || started.IsZero() {
+		return nil
+	}
+	since := started
+	if last, ok := sandboxActivity.LastUsed(refs...); ok && last.After(since) {
+		since = last
+	}
+	idle := max(int64(now.Sub(since).Seconds()), 0)
+	return &idle
+}
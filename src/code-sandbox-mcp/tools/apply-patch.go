@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ApplyPatch applies a unified diff to a file in the container's filesystem
+func ApplyPatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters using new API
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultText("file_path is required"), nil
+	}
+
+	diff, err := request.RequireString("patch")
+	if err != nil {
+		return mcp.NewToolResultText("patch is required"), nil
+	}
+
+	patch, err := parsePatch(diff)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error parsing patch: %v", err)), nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create Docker client: %v", err)), nil
+	}
+	defer cli.Close()
+
+	workDir, err := containerWorkDir(ctx, cli, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// The target and any path named in the diff headers must stay inside the working directory
+	fullPath, err := resolveInWorkDir(workDir, filePath)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	for _, headerPath := range []string{patch.OldPath, patch.NewPath} {
+		if headerPath == "" || headerPath == "/dev/null" {
+			continue
+		}
+		if _, err := resolveInWorkDir(workDir, headerPath); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: patch header %v", err)), nil
+		}
+	}
+
+	// A patch from /dev/null creates the file
+	content, mode := "", int64(0644)
+	if patch.OldPath != "/dev/null" {
+		data, header, err := readFileFromContainer(ctx, cli, containerIDOrName, fullPath)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error reading file: %v", err)), nil
+		}
+		content, mode = string(data), header.Mode
+	}
+
+	result, err := applyPatch(content, patch)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error applying patch to %s: %v", fullPath, err)), nil
+	}
+
+	if err := putFileInContainer(ctx, cli, containerIDOrName, fullPath, []byte(result.Content), mode); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error writing file: %v", err)), nil
+	}
+
+	message := fmt.Sprintf("Successfully patched %s in container %s: %d hunks applied, new size %d bytes", fullPath, containerIDOrName, result.Hunks, len(result.Content))
+	for i, offset := range result.Offsets {
+		if offset != 0 {
+			message += fmt.Sprintf("\nHunk %d applied with offset %+d lines", i+1, offset)
+		}
+	}
+	return mcp.NewToolResultText(message), nil
+}
+
+// resolveInWorkDir resolves a path against the working directory and rejects anything outside it
+func resolveInWorkDir(workDir, filePath string) (string, error) {
+	fullPath := filePath
+	if !path.IsAbs(fullPath) {
+		fullPath = path.Join(workDir, fullPath)
+	}
+	fullPath = path.Clean(fullPath)
+	if fullPath == workDir || !strings.HasPrefix(fullPath, strings.TrimSuffix(workDir, "/")+"/") {
+		return "", fmt.Errorf("path %s is outside the working directory %s", filePath, workDir)
+	}
+	return fullPath, nil
+}
+
+// readFileFromContainer reads a regular file from the container into memory
+func readFileFromContainer(ctx context.Context, cli *client.Client, containerIDOrName string, filePath string) ([]byte, *tar.Header, error) {
+	reader, stat, err := cli.CopyFromContainer(ctx, containerIDOrName, filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer reader.Close()
+
+	if stat.Mode.IsDir() {
+		return nil, nil, fmt.Errorf("%s is a directory, only files are supported", filePath)
+	}
+
+	// Docker sends files in tar format
+	tr := tar.NewReader(reader)
+	header, err := tr.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read tar header: %w", err)
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil, nil, fmt.Errorf("%s is not a regular file", filePath)
+	}
+
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+	return data, header, nil
+}
+
+// putFileInContainer writes a file through the Docker archive API, which works without a shell in the image
+func putFileInContainer(ctx context.Context, cli *client.Client, containerIDOrName string, filePath string, data []byte, mode int64) error {
+	buf, err := fileArchive(filePath, data, mode)
+	if err != nil {
+		return err
+	}
+
+	if err := cli.CopyToContainer(ctx, containerIDOrName, "/", buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	return nil
+}
+
+// fileArchive packs a single file in a tar rooted at the container's filesystem root, so CopyToContainer creates
+// the directories a new file goes in
+func fileArchive(filePath string, data []byte, mode int64) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	header := &tar.Header{
+		Name:    strings.TrimPrefix(path.Clean(filePath), "/"),
+		Mode:    mode,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return nil, fmt.Errorf("failed to write tar header: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write tar content: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	return buf, nil
+}
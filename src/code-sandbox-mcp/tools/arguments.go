@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// InvalidArgumentError is returned for an argument that doesn't match the tool's input schema
+type InvalidArgumentError struct {
+	Field    string // e.g. "timeout_seconds" or "files[0].path"
+	Expected string // e.g. "a number" or "at least 0"
+	Got      any    // the value sent; nil when the field is missing
+	Missing  bool
+}
+
+func (e *InvalidArgumentError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("INVALID_ARGUMENT: %s is required and must be %s", e.Field, e.Expected)
+	}
+	return fmt.Sprintf("INVALID_ARGUMENT: %s must be %s, got %s", e.Field, e.Expected, describeJSONValue(e.Got))
+}
+
+// OrString lets an array property also take a single string, as the command arguments of sandbox_exec and
+// run_command do. Its schema type becomes ["array", "string"].
+func OrString() mcp.PropertyOption {
+	return func(schema map[string]any) {
+		schema["type"] = []string{"array", "string"}
+	}
+}
+
+// ArgumentValidationMiddleware checks the arguments of every call against the input schema of its tool before
+// the handler runs, so a missing field or a wrong type fails with one INVALID_ARGUMENT error instead of whatever
+// the handler makes of it. Arguments the schema doesn't declare are passed through.
+func ArgumentValidationMiddleware(schemas map[string]mcp.ToolInputSchema) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			schema, ok := schemas[request.Params.Name]
+			if !ok {
+				return next(ctx, request)
+			}
+			if err := validateArguments(schema, request.GetArguments()); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// validateArguments checks the arguments of a call against a tool's input schema. A null argument counts as
+// omitted.
+func validateArguments(schema mcp.ToolInputSchema, args map[string]any) error {
+	return validateObject("", schema.Properties, schema.Required, args)
+}
+
+func validateObject(prefix string, properties map[string]any, required []string, values map[string]any) error {
+	for _, name := range required {
+		if values[name] == nil {
+			prop, _ := properties[name].(map[string]any)
+			return &InvalidArgumentError{Field: prefix + name, Expected: expectedType(prop), Missing: true}
+		}
+	}
+	for _, name := range sortedKeys(values) {
+		prop, ok := properties[name].(map[string]any)
+		if !ok || values[name] == nil {
+			continue
+		}
+		if err := validateValue(prefix+name, prop, values[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(field string, schema map[string]any, value any) error {
+	invalid := func(expected string) error {
+		return &InvalidArgumentError{Field: field, Expected: expected, Got: value}
+	}
+	types := schemaTypes(schema)
+	if len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasJSONType(value, t) }) {
+		return invalid(expectedType(schema))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && v < minimum {
+			return invalid(fmt.Sprintf("at least %v", minimum))
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && v > maximum {
+			return invalid(fmt.Sprintf("at most %v", maximum))
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateValue(fmt.Sprintf("%s[%d]", field, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]string)
+		if err := validateObject(field+".", properties, required, v); err != nil {
+			return err
+		}
+		if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+			for _, key := range sortedKeys(v) {
+				if _, declared := properties[key]; !declared && v[key] != nil {
+					if err := validateValue(field+"."+key, additional, v[key]); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]string); ok {
+		if s, isString := value.(string); isString && !slices.Contains(enum, s) {
+			return invalid(fmt.Sprintf("one of %v", enum))
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of an object in order, so an error names the same field every time
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// schemaTypes returns the JSON types a property allows, from its "type" keyword
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// hasJSONType reports whether a decoded JSON value is of a JSON schema type
+func hasJSONType(value any, jsonType string) bool {
+	switch jsonType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// expectedType describes the types a property allows, e.g. "an array of strings or a string"
+func expectedType(schema map[string]any) string {
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return "a value"
+	}
+	descriptions := make([]string, len(types))
+	for i, t := range types {
+		descriptions[i] = describeType(t)
+		if items, ok := schema["items"].(map[string]any); ok && t == "array" {
+			if itemTypes := schemaTypes(items); len(itemTypes) == 1 {
+				descriptions[i] = "an array of " + itemTypes[0] + "s"
+			}
+		}
+	}
+	last := len(descriptions) - 1
+	if last == 0 {
+		return descriptions[0]
+	}
+	return strings.Join(descriptions[:last], ", ") + " or " + descriptions[last]
+}
+
+func describeType(jsonType string) string {
+	switch jsonType {
+	case "array", "integer", "object":
+		return "an " + jsonType
+	}
+	return "a " + jsonType
+}
+
+// describeJSONValue names the JSON type of a value for an error message, quoting short strings
+func describeJSONValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		if len(v) <= 32 {
+			return fmt.Sprintf("the string %q", v)
+		}
+		return "a string"
+	case float64:
+		return fmt.Sprintf("the number %v", v)
+	case bool:
+		return fmt.Sprintf("%v", v)
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
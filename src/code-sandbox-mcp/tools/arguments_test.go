@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validationTools declare their arguments the way main.go does, covering every kind of property it uses
+var validationTools = []mcp.Tool{
+	mcp.NewTool("sandbox_initialize",
+		mcp.WithString("image", mcp.DefaultString(DefaultImage)),
+		mcp.WithNumber("memory_limit", mcp.Min(0), OrUnitString()),
+		mcp.WithNumber("cpu_limit", mcp.Min(0)),
+		mcp.WithArray("ulimits", mcp.Items(map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name": map[string]any{"type": "string"},
+				"soft": map[string]any{"type": "number"},
+				"hard": map[string]any{"type": "number"},
+			},
+			"required": []string{"name", "soft"},
+		})),
+		mcp.WithArray("packages", mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("package_manager", mcp.Enum("pip", "npm", "apk", "apt")),
+		mcp.WithBoolean("force"),
+	),
+	mcp.NewTool("sandbox_exec",
+		mcp.WithString("container_id_or_name", mcp.Required()),
+		mcp.WithArray("commands", mcp.Required(), mcp.Items(map[string]any{"type": "string"}), OrString()),
+		mcp.WithBoolean("merge_output"),
+	),
+	mcp.NewTool("run_command",
+		mcp.WithString("image", mcp.Required()),
+		mcp.WithArray("command", mcp.Required(), mcp.Items(map[string]any{"type": "string"}), OrString()),
+		mcp.WithObject("env", mcp.AdditionalProperties(map[string]any{"type": "string"})),
+		mcp.WithNumber("timeout_seconds", mcp.DefaultNumber(60), mcp.Min(0), OrUnitString()),
+		mcp.WithString("network", mcp.Enum("bridge", "none")),
+	),
+	mcp.NewTool("sandbox_shell_read",
+		mcp.WithString("session_id", mcp.Required()),
+		mcp.WithNumber("wait_ms", mcp.DefaultNumber(0), mcp.Min(0), OrUnitString()),
+	),
+}
+
+// validSample returns a value a property accepts
+func validSample(prop map[string]any) any {
+	if enum, ok := prop["enum"].([]string); ok {
+		return enum[0]
+	}
+	switch schemaTypes(prop)[0] {
+	case "string":
+		return "value"
+	case "number":
+		return float64(1)
+	case "boolean":
+		return true
+	case "array":
+		return []any{}
+	case "object":
+		return map[string]any{}
+	}
+	return nil
+}
+
+// wrongSample returns a value of a type the property doesn't accept
+func wrongSample(prop map[string]any) any {
+	if slices.Contains(schemaTypes(prop), "string") {
+		return true
+	}
+	return "1"
+}
+
+func TestValidateArgumentsRejectsWrongTypes(t *testing.T) {
+	for _, tool := range validationTools {
+		valid := map[string]any{}
+		for name, prop := range tool.InputSchema.Properties {
+			valid[name] = validSample(prop.(map[string]any))
+		}
+		require.NoError(t, validateArguments(tool.InputSchema, valid), tool.Name)
+
+		for name, prop := range tool.InputSchema.Properties {
+			args := make(map[string]any, len(valid))
+			for key, value := range valid {
+				args[key] = value
+			}
+			args[name] = wrongSample(prop.(map[string]any))
+			err := validateArguments(tool.InputSchema, args)
+			var invalid *InvalidArgumentError
+			if assert.ErrorAs(t, err, &invalid, "%s.%s", tool.Name, name) {
+				assert.Equal(t, name, invalid.Field)
+				assert.Contains(t, err.Error(), "INVALID_ARGUMENT: "+name+" must be ")
+			}
+		}
+
+		for _, name := range tool.InputSchema.Required {
+			args := make(map[string]any, len(valid))
+			for key, value := range valid {
+				args[key] = value
+			}
+			delete(args, name)
+			err := validateArguments(tool.InputSchema, args)
+			var invalid *InvalidArgumentError
+			if assert.ErrorAs(t, err, &invalid, "%s.%s", tool.Name, name) {
+				assert.True(t, invalid.Missing)
+				assert.Equal(t, name, invalid.Field)
+			}
+		}
+	}
+}
+
+func TestValidateArgumentsMessages(t *testing.T) {
+	schemas := map[string]mcp.ToolInputSchema{}
+	for _, tool := range validationTools {
+		schemas[tool.Name] = tool.InputSchema
+	}
+
+	tests := []struct {
+		name string
+		tool string
+		args map[string]any
+		want string
+	}{
+		{
+			name: "number sent as a string",
+			tool: "sandbox_initialize",
+			args: map[string]any{"cpu_limit": "1.5"},
+			want: `INVALID_ARGUMENT: cpu_limit must be a number, got the string "1.5"`,
+		},
+		{
+			name: "duration of the wrong type",
+			tool: "run_command",
+			args: map[string]any{"image": "alpine", "command": "ls", "timeout_seconds": true},
+			want: "INVALID_ARGUMENT: timeout_seconds must be a number or a string, got true",
+		},
+		{
+			name: "below the minimum",
+			tool: "sandbox_shell_read",
+			args: map[string]any{"session_id": "s1", "wait_ms": float64(-5)},
+			want: "INVALID_ARGUMENT: wait_ms must be at least 0, got the number -5",
+		},
+		{
+			name: "missing field",
+			tool: "sandbox_exec",
+			args: map[string]any{"container_id_or_name": "box"},
+			want: "INVALID_ARGUMENT: commands is required and must be an array of strings or a string",
+		},
+		{
+			name: "null counts as missing",
+			tool: "sandbox_exec",
+			args: map[string]any{"container_id_or_name": "box", "commands": nil},
+			want: "INVALID_ARGUMENT: commands is required and must be an array of strings or a string",
+		},
+		{
+			name: "wrong array item",
+			tool: "sandbox_exec",
+			args: map[string]any{"container_id_or_name": "box", "commands": []any{"ls", float64(3)}},
+			want: "INVALID_ARGUMENT: commands[1] must be a string, got the number 3",
+		},
+		{
+			name: "wrong object value",
+			tool: "run_command",
+			args: map[string]any{"image": "alpine", "command": []any{"env"}, "env": map[string]any{"DEBUG": true}},
+			want: "INVALID_ARGUMENT: env.DEBUG must be a string, got true",
+		},
+		{
+			name: "missing field of an array item",
+			tool: "sandbox_initialize",
+			args: map[string]any{"ulimits": []any{map[string]any{"name": "nofile"}}},
+			want: "INVALID_ARGUMENT: ulimits[0].soft is required and must be a number",
+		},
+		{
+			name: "value outside the enum",
+			tool: "run_command",
+			args: map[string]any{"image": "alpine", "command": "ls", "network": "host"},
+			want: `INVALID_ARGUMENT: network must be one of [bridge none], got the string "host"`,
+		},
+		{
+			name: "string for a boolean",
+			tool: "sandbox_initialize",
+			args: map[string]any{"force": "true"},
+			want: `INVALID_ARGUMENT: force must be a boolean, got the string "true"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, validateArguments(schemas[tt.tool], tt.args), tt.want)
+		})
+	}
+
+	// A single string where an array or a string is declared, and arguments the schema doesn't know
+	assert.NoError(t, validateArguments(schemas["sandbox_exec"], map[string]any{"container_id_or_name": "box", "commands": "ls", "extra": 1}))
+}
+
+func TestArgumentValidationMiddleware(t *testing.T) {
+	schemas := map[string]mcp.ToolInputSchema{}
+	for _, tool := range validationTools {
+		schemas[tool.Name] = tool.InputSchema
+	}
+	called := 0
+	handler := ArgumentValidationMiddleware(schemas)(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called++
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	// An invalid call fails without reaching the handler
+	result, err := handler(context.Background(), newMockCallToolRequest("sandbox_shell_read", map[string]any{"wait_ms": "100"}))
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "INVALID_ARGUMENT: session_id is required and must be a string", resultText(t, result))
+	assert.Zero(t, called)
+
+	// Valid calls and tools without a schema go through
+	for _, request := range []mcp.CallToolRequest{
+		newMockCallToolRequest("sandbox_shell_read", map[string]any{"session_id": "s1", "wait_ms": float64(100)}),
+		newMockCallToolRequest("unknown_tool", map[string]any{"anything": "1"}),
+	} {
+		result, err := handler(context.Background(), request)
+		require.NoError(t, err)
+		assert.False(t, result.IsError)
+	}
+	assert.Equal(t, 2, called)
+}
@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// checkCodeTimeout bounds a check, including installing ruff or fetching tsc
+	checkCodeTimeout = 3 * time.Minute
+	// maxCheckCode caps the code, which goes into the container through an environment variable
+	maxCheckCode = 100 * 1024
+	// checkCodeDir is where the code is written in the container
+	checkCodeDir = "/check"
+)
+
+// codeChecker is how check_code checks the code of a language
+type codeChecker struct {
+	Image  string // used when the call names no image
+	File   string // the file the code is written to, in checkCodeDir
+	Script string // checks File; $status is the exit code
+	Parse  func(output string) []Diagnostic
+}
+
+// codeCheckers are keyed by the normalized language argument
+var codeCheckers = map[string]codeChecker{
+	"python": {
+		Image:  DefaultImage,
+		File:   "main.py",
+		Script: `python3 -m py_compile main.py || status=1`,
+		Parse:  func(output string) []Diagnostic { return append(parsePyCompile(output), parseRuff(output)...) },
+	},
+	"javascript": {
+		Image:  "node:22-slim",
+		File:   "main.js",
+		Script: `node --check main.js || status=1`,
+		Parse:  parseNodeCheck,
+	},
+	"typescript": {
+		Image:  "node:22-slim",
+		File:   "main.ts",
+		Script: `npx --yes --package typescript@5 -- tsc --noEmit --pretty false --strict main.ts || status=1`,
+		Parse:  parseTSC,
+	},
+	"go": {
+		Image: "golang:1.24-bookworm",
+		File:  "main.go",
+		Script: `go mod init check >/dev/null 2>&1; go mod tidy >/dev/null 2>&1
+go build -o /dev/null . && go vet . || status=1`,
+		Parse: parseGoDiagnostics,
+	},
+}
+
+// ruffScript runs ruff after the Python syntax check when check_code is asked to lint
+const ruffScript = `
+if pip install --quiet --disable-pip-version-check --root-user-action=ignore ruff >/dev/null 2>&1; then
+  ruff check --no-cache --output-format=concise main.py || status=1
+else
+  echo "ruff could not be installed" >&2; status=1
+fi`
+
+// Diagnostic is a problem a static check found, located as precisely as the tool's output allows
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// codeCheckResult is the result of check_code. Output is the checker's raw output, returned when none of it
+// could be parsed into diagnostics.
+type codeCheckResult struct {
+	Language    string       `json:"language"`
+	Image       string       `json:"image"`
+	OK          bool         `json:"ok"`
+	ExitCode    int          `json:"exit_code"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Output      string       `json:"output,omitempty"`
+	TimedOut    bool         `json:"timed_out,omitempty"`
+}
+
+// CheckCode compiles or lints a snippet in a throwaway container without running it
+func CheckCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := requireAPIFeature("run_command"); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	language, err := codeCheckLanguage(request.GetString("language", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	code, err := request.RequireString("code")
+	if err != nil {
+		return mcp.NewToolResultText("code is required"), nil
+	}
+	if len(code) > maxCheckCode {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: code is %d bytes; check_code takes at most %d", len(code), maxCheckCode)), nil
+	}
+	lint := request.GetBool("lint", false)
+	if lint && language != "python" {
+		return mcp.NewToolResultText("Error: lint is only supported for python, with ruff"), nil
+	}
+
+	checker := codeCheckers[language]
+	image := request.GetString("image", checker.Image)
+	script := checker.Script
+	if lint {
+		script += ruffScript
+	}
+
+	mirrors := packageMirrorsFromEnv()
+	config := sandboxContainerConfig(image)
+	config.Cmd = []string{"/bin/sh", "-c", checkCodeCommand(checker.File, script)}
+	config.Env = append(mirrors.Env(), "SANDBOX_CHECK_CODE="+code)
+	config.WorkingDir = checkCodeDir
+	config.Tty = false
+	config.OpenStdin = false
+	hostConfig := sandboxHostConfig(0)
+	hostConfig.Resources.Ulimits = defaultUlimits
+	hostConfig.NetworkMode = container.NetworkMode("bridge")
+
+	progress := newProgressReporter(ctx, request, phasePull, phaseSetup, phaseExecute, phaseCollect)
+	run, err := runOnce(ctx, progress, config, hostConfig, checkCodeTimeout, false)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	progress.Done(fmt.Sprintf("Check exited with code %d", run.ExitCode))
+
+	output := redactValues(run.Stdout+run.Stderr, mirrors.Credentials())
+	result := codeCheckResult{
+		Language:    language,
+		Image:       image,
+		OK:          run.ExitCode == 0,
+		ExitCode:    run.ExitCode,
+		Diagnostics: checker.Parse(output),
+		TimedOut:    run.TimedOut,
+	}
+	if result.Diagnostics == nil {
+		result.Diagnostics = []Diagnostic{}
+	}
+	if len(result.Diagnostics) == 0 {
+		result.Output = output
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize check result: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// codeCheckLanguage normalizes the language argument of check_code, which unlike the other tools tells
+// JavaScript and TypeScript apart
+func codeCheckLanguage(language string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "python", "py":
+		return "python", nil
+	case "javascript", "js", "nodejs", "node":
+		return "javascript", nil
+	case "typescript", "ts":
+		return "typescript", nil
+	case "go", "golang":
+		return "go", nil
+	default:
+		return "", fmt.Errorf("unsupported language %q: supported languages are python, javascript, typescript, go", language)
+	}
+}
+
+// checkCodeCommand writes the code from the environment to file and runs the check script, exiting with its status
+func checkCodeCommand(file, script string) string {
+	return fmt.Sprintf("printf '%%s' \"$SANDBOX_CHECK_CODE\" > %s && unset SANDBOX_CHECK_CODE\nstatus=0\n%s\nexit $status", file, script)
+}
+
+var (
+	// File "main.py", line 3
+	pyCompileLocation = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+)`)
+	// SyntaxError: '(' was never closed, as Python and Node.js print it
+	errorLine = regexp.MustCompile(`^(\w*(?:Error|Warning)): (.+)$`)
+	// main.py:1:8: F401 [*] `os` imported but unused
+	ruffDiagnostic = regexp.MustCompile(`^([^:\s]+\.py):(\d+):(\d+): ([A-Z]+[0-9]+) (?:\[\*\] )?(.+)$`)
+	// /check/main.js:2
+	nodeLocation = regexp.MustCompile(`^(\S+\.[cm]?js):(\d+)$`)
+	// main.ts(1,7): error TS2322: Type 'string' is not assignable to type 'number'.
+	tscDiagnostic = regexp.MustCompile(`^(\S+\.tsx?)\((\d+),(\d+)\): (?:error|warning) (TS\d+): (.+)$`)
+	// ./main.go:5:2: "os" imported and not used
+	goDiagnostic = regexp.MustCompile(`^(?:vet: )?(\S+\.go):(\d+):(\d+): (.+)$`)
+)
+
+// parsePyCompile reads the traceback py_compile prints for a syntax error
+func parsePyCompile(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	var current *Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		if m := pyCompileLocation.FindStringSubmatch(line); m != nil {
+			current = &Diagnostic{File: checkedFile(m[1]), Line: atoi(m[2])}
+			continue
+		}
+		if m := errorLine.FindStringSubmatch(line); m != nil && current != nil {
+			current.Code, current.Message = m[1], m[2]
+			diagnostics = append(diagnostics, *current)
+			current = nil
+		}
+	}
+	return diagnostics
+}
+
+// parseRuff reads ruff's concise output format
+func parseRuff(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		if m := ruffDiagnostic.FindStringSubmatch(line); m != nil {
+			diagnostics = append(diagnostics, Diagnostic{File: checkedFile(m[1]), Line: atoi(m[2]), Column: atoi(m[3]), Code: m[4], Message: m[5]})
+		}
+	}
+	return diagnostics
+}
+
+// parseNodeCheck reads the error node --check prints: the location, the source line with a caret, then the error
+func parseNodeCheck(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	var current *Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		if m := nodeLocation.FindStringSubmatch(line); m != nil {
+			current = &Diagnostic{File: checkedFile(m[1]), Line: atoi(m[2])}
+			continue
+		}
+		if m := errorLine.FindStringSubmatch(line); m != nil && current != nil {
+			current.Code, current.Message = m[1], m[2]
+			diagnostics = append(diagnostics, *current)
+			current = nil
+		}
+	}
+	return diagnostics
+}
+
+// parseTSC reads the output of tsc --pretty false
+func parseTSC(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		if m := tscDiagnostic.FindStringSubmatch(line); m != nil {
+			diagnostics = append(diagnostics, Diagnostic{File: checkedFile(m[1]), Line: atoi(m[2]), Column: atoi(m[3]), Code: m[4], Message: m[5]})
+		}
+	}
+	return diagnostics
+}
+
+// parseGoDiagnostics reads the file:line:column lines of go build and go vet
+func parseGoDiagnostics(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		if m := goDiagnostic.FindStringSubmatch(line); m != nil {
+			diagnostics = append(diagnostics, Diagnostic{File: checkedFile(m[1]), Line: atoi(m[2]), Column: atoi(m[3]), Message: m[4]})
+		}
+	}
+	return diagnostics
+}
+
+// checkedFile reports a path from a checker's output relative to checkCodeDir
+func checkedFile(p string) string {
+	p = strings.TrimPrefix(p, checkCodeDir+"/")
+	return strings.TrimPrefix(p, "./")
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeCheckLanguage(t *testing.T) {
+	for input, want := range map[string]string{"Python": "python", "js": "javascript", "node": "javascript", "ts": "typescript", "golang": "go"} {
+		got, err := codeCheckLanguage(input)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, input)
+	}
+	_, err := codeCheckLanguage("ruby")
+	assert.EqualError(t, err, `unsupported language "ruby": supported languages are python, javascript, typescript, go`)
+}
+
+func TestCheckCodeCommand(t *testing.T) {
+	assert.Equal(t, "printf '%s' \"$SANDBOX_CHECK_CODE\" > main.py && unset SANDBOX_CHECK_CODE\nstatus=0\npython3 -m py_compile main.py || status=1\nexit $status",
+		checkCodeCommand("main.py", codeCheckers["python"].Script))
+}
+
+func TestParsePyCompile(t *testing.T) {
+	output := "  File \"main.py\", line 2\n    def f(:\n          ^\nSyntaxError: invalid syntax\n"
+	assert.Equal(t, []Diagnostic{{File: "main.py", Line: 2, Code: "SyntaxError", Message: "invalid syntax"}}, parsePyCompile(output))
+
+	output = "  File \"/check/main.py\", line 1\n    x = (\n        ^\nSyntaxError: '(' was never closed\n"
+	assert.Equal(t, []Diagnostic{{File: "main.py", Line: 1, Code: "SyntaxError", Message: "'(' was never closed"}}, parsePyCompile(output))
+
+	assert.Empty(t, parsePyCompile("Traceback (most recent call last):\nsomething else went wrong\n"))
+}
+
+func TestParseRuff(t *testing.T) {
+	output := "main.py:1:8: F401 [*] `os` imported but unused\nmain.py:4:5: F841 Local variable `x` is assigned to but never used\nFound 2 errors.\n[*] 1 fixable with the `--fix` option.\n"
+	assert.Equal(t, []Diagnostic{
+		{File: "main.py", Line: 1, Column: 8, Code: "F401", Message: "`os` imported but unused"},
+		{File: "main.py", Line: 4, Column: 5, Code: "F841", Message: "Local variable `x` is assigned to but never used"},
+	}, parseRuff(output))
+}
+
+func TestParseNodeCheck(t *testing.T) {
+	output := "/check/main.js:2\n\n\n\nSyntaxError: Unexpected end of input\n    at wrapSafe (node:internal/modules/cjs/loader:1464:18)\n    at checkSyntax (node:internal/main/check_syntax:78:3)\n\nNode.js v20.19.5\n"
+	assert.Equal(t, []Diagnostic{{File: "main.js", Line: 2, Code: "SyntaxError", Message: "Unexpected end of input"}}, parseNodeCheck(output))
+}
+
+func TestParseTSC(t *testing.T) {
+	output := "main.ts(1,7): error TS2322: Type 'string' is not assignable to type 'number'.\nmain.ts(3,1): error TS2304: Cannot find name 'foo'.\n"
+	assert.Equal(t, []Diagnostic{
+		{File: "main.ts", Line: 1, Column: 7, Code: "TS2322", Message: "Type 'string' is not assignable to type 'number'."},
+		{File: "main.ts", Line: 3, Column: 1, Code: "TS2304", Message: "Cannot find name 'foo'."},
+	}, parseTSC(output))
+	assert.Empty(t, parseTSC("npm error could not determine executable to run\n"))
+}
+
+func TestParseGoDiagnostics(t *testing.T) {
+	output := "# check\n./main.go:5:2: \"os\" imported and not used\n"
+	assert.Equal(t, []Diagnostic{{File: "main.go", Line: 5, Column: 2, Message: `"os" imported and not used`}}, parseGoDiagnostics(output))
+
+	output = "# check\nmain.go:8:14: fmt.Printf format %d has arg \"x\" of wrong type string\nvet: ./main.go:3:8: could not import foo\n"
+	assert.Equal(t, []Diagnostic{
+		{File: "main.go", Line: 8, Column: 14, Message: `fmt.Printf format %d has arg "x" of wrong type string`},
+		{File: "main.go", Line: 3, Column: 8, Message: "could not import foo"},
+	}, parseGoDiagnostics(output))
+}
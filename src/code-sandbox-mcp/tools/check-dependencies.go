@@ -0,0 +1,288 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DependencyCheck is the result of looking up one package in its registry
+type DependencyCheck struct {
+	Package       string `json:"package"`
+	Found         bool   `json:"found"`
+	LatestVersion string `json:"latest_version,omitempty"`
+	Suggestion    string `json:"suggestion,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// packageRegistries holds the registry endpoints, replaced in tests
+type packageRegistries struct {
+	Client  *http.Client
+	PyPI    string
+	NPM     string
+	GoProxy string
+}
+
+var registries = packageRegistries{
+	Client:  &http.Client{Timeout: 10 * time.Second},
+	PyPI:    "https://pypi.org",
+	NPM:     "https://registry.npmjs.org",
+	GoProxy: "https://proxy.golang.org",
+}
+
+// pythonPackageAliases maps import names that differ from their PyPI distribution name
+var pythonPackageAliases = map[string]string{
+	"sklearn":  "scikit-learn",
+	"cv2":      "opencv-python",
+	"pil":      "pillow",
+	"yaml":     "pyyaml",
+	"bs4":      "beautifulsoup4",
+	"dateutil": "python-dateutil",
+	"dotenv":   "python-dotenv",
+	"jwt":      "pyjwt",
+	"magic":    "python-magic",
+	"serial":   "pyserial",
+	"attr":     "attrs",
+	"skimage":  "scikit-image",
+}
+
+// popularPackages are the names misspellings are compared against
+var popularPackages = map[string][]string{
+	"python": {
+		"numpy", "pandas", "requests", "matplotlib", "scipy", "scikit-learn", "flask", "django", "fastapi",
+		"pytest", "pillow", "pyyaml", "beautifulsoup4", "sqlalchemy", "boto3", "torch", "tensorflow", "seaborn",
+		"httpx", "pydantic", "uvicorn", "aiohttp", "click", "rich", "openai", "transformers", "plotly",
+	},
+	"nodejs": {
+		"express", "react", "react-dom", "lodash", "axios", "typescript", "next", "vue", "moment", "dayjs",
+		"chalk", "commander", "dotenv", "jest", "mocha", "webpack", "vite", "eslint", "prettier", "zod",
+		"uuid", "ws", "socket.io", "mongoose", "prisma", "tsx",
+	},
+	"go": {
+		"github.com/gin-gonic/gin", "github.com/gorilla/mux", "github.com/spf13/cobra", "github.com/spf13/viper",
+		"github.com/stretchr/testify", "github.com/sirupsen/logrus", "go.uber.org/zap", "github.com/google/uuid",
+		"github.com/labstack/echo/v4", "github.com/gofiber/fiber/v2", "gopkg.in/yaml.v3", "github.com/go-chi/chi/v5",
+	},
+}
+
+// CheckDependencies looks up packages in their language's registry without starting a container
+func CheckDependencies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	language, err := request.RequireString("language")
+	if err != nil {
+		return mcp.NewToolResultText("language is required"), nil
+	}
+	language, err = registryLanguage(language)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	var packages []string
+	if pkgs, ok := request.GetArguments()["packages"].([]interface{}); ok {
+		for _, pkg := range pkgs {
+			pkgStr, ok := pkg.(string)
+			if !ok {
+				return mcp.NewToolResultText("Each package must be a string"), nil
+			}
+			packages = append(packages, pkgStr)
+		}
+	}
+	if len(packages) == 0 {
+		return mcp.NewToolResultText("packages must be a non-empty array of strings"), nil
+	}
+
+	results := make([]DependencyCheck, 0, len(packages))
+	for _, pkg := range packages {
+		results = append(results, checkPackage(ctx, language, pkg))
+	}
+
+	jsonData, err := json.Marshal(results)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize dependency check: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// registryLanguage normalizes the language argument to a registry key
+func registryLanguage(language string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "python", "py":
+		return "python", nil
+	case "nodejs", "node", "javascript", "js", "typescript", "ts":
+		return "nodejs", nil
+	case "go", "golang":
+		return "go", nil
+	default:
+		return "", fmt.Errorf("unsupported language %q: supported languages are python, nodejs, go", language)
+	}
+}
+
+// checkPackage looks up one package and, when it is missing, suggests a likely intended name
+func checkPackage(ctx context.Context, language, spec string) DependencyCheck {
+	name := packageName(language, spec)
+	result := DependencyCheck{Package: name}
+	if name == "" {
+		result.Error = fmt.Sprintf("invalid package %q", spec)
+		return result
+	}
+
+	var version string
+	var found bool
+	var err error
+	switch language {
+	case "python":
+		version, found, err = lookupPyPI(ctx, name)
+	case "nodejs":
+		version, found, err = lookupNPM(ctx, name)
+	case "go":
+		version, found, err = lookupGoModule(ctx, name)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Found = found
+	result.LatestVersion = version
+	if !found {
+		result.Suggestion = suggestPackage(language, name)
+	}
+	return result
+}
+
+// packageName strips version specifiers such as numpy==1.26, lodash@4 or example.com/mod@v1.2.0
+func packageName(language, spec string) string {
+	spec = strings.TrimSpace(spec)
+	switch language {
+	case "python":
+		if i := strings.IndexAny(spec, "=<>!~;[ "); i >= 0 {
+			spec = spec[:i]
+		}
+	case "nodejs":
+		// Scoped packages start with @, so look for a version @ after the first character
+		if i := strings.LastIndex(spec, "@"); i > 0 {
+			spec = spec[:i]
+		}
+	case "go":
+		if i := strings.Index(spec, "@"); i >= 0 {
+			spec = spec[:i]
+		}
+	}
+	return strings.TrimSpace(spec)
+}
+
+// lookupPyPI queries the PyPI JSON API
+func lookupPyPI(ctx context.Context, name string) (string, bool, error) {
+	var body struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	found, err := getRegistryJSON(ctx, registries.PyPI+"/pypi/"+url.PathEscape(name)+"/json", &body)
+	return body.Info.Version, found, err
+}
+
+// lookupNPM queries the npm registry; the slash of a scoped name must be escaped
+func lookupNPM(ctx context.Context, name string) (string, bool, error) {
+	var body struct {
+		DistTags struct {
+			Latest string `json:"latest"`
+		} `json:"dist-tags"`
+	}
+	found, err := getRegistryJSON(ctx, registries.NPM+"/"+url.PathEscape(name), &body)
+	return body.DistTags.Latest, found, err
+}
+
+// lookupGoModule queries the Go module proxy's @latest endpoint
+func lookupGoModule(ctx context.Context, module string) (string, bool, error) {
+	var body struct {
+		Version string `json:"Version"`
+	}
+	found, err := getRegistryJSON(ctx, registries.GoProxy+"/"+escapeModulePath(module)+"/@latest", &body)
+	return body.Version, found, err
+}
+
+// escapeModulePath applies the module proxy's case encoding: each upper-case letter becomes ! and its lower case
+func escapeModulePath(module string) string {
+	var b strings.Builder
+	for _, r := range module {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// getRegistryJSON fetches and decodes a registry document; 404 and 410 mean the package does not exist
+func getRegistryJSON(ctx context.Context, endpoint string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := registries.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("registry unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("registry returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("failed to decode registry response: %w", err)
+	}
+	return true, nil
+}
+
+// suggestPackage proposes the distribution name for a known import alias, or the closest popular package
+// within two edits
+func suggestPackage(language, name string) string {
+	lower := strings.ToLower(name)
+	if language == "python" {
+		if alias, ok := pythonPackageAliases[lower]; ok {
+			return alias
+		}
+	}
+
+	best, bestDistance := "", 3
+	for _, candidate := range popularPackages[language] {
+		if d := editDistance(lower, candidate); d < bestDistance && d > 0 {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
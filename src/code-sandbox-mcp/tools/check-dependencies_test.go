@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withMockRegistries points the registry lookups at a fake server for the duration of the test
+func withMockRegistries(t *testing.T) {
+	t.Helper()
+	responses := map[string]string{
+		"/pypi/numpy/json":   `{"info": {"version": "2.1.0"}}`,
+		"/npm/lodash":        `{"dist-tags": {"latest": "4.17.21"}}`,
+		"/npm/@types%2Fnode": `{"dist-tags": {"latest": "22.7.0"}}`,
+		"/goproxy/github.com/!burnt!sushi/toml/@latest": `{"Version": "v1.4.0"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := r.URL.EscapedPath(); path {
+		case "/goproxy/github.com/gone/mod/@latest":
+			http.Error(w, "gone", http.StatusGone)
+		case "/pypi/broken/json":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			body, ok := responses[path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(body))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previous := registries
+	registries = packageRegistries{
+		Client:  server.Client(),
+		PyPI:    server.URL,
+		NPM:     server.URL + "/npm",
+		GoProxy: server.URL + "/goproxy",
+	}
+	t.Cleanup(func() { registries = previous })
+}
+
+func checkDependencies(t *testing.T, language string, packages ...interface{}) []DependencyCheck {
+	t.Helper()
+	result, err := CheckDependencies(context.Background(), newMockCallToolRequest("check_dependencies", map[string]interface{}{
+		"language": language,
+		"packages": packages,
+	}))
+	require.NoError(t, err)
+	var checks []DependencyCheck
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &checks), resultText(t, result))
+	return checks
+}
+
+func TestCheckDependenciesPython(t *testing.T) {
+	withMockRegistries(t)
+
+	checks := checkDependencies(t, "Python", "numpy==1.26.0", "nunpy", "sklearn", "broken")
+	require.Len(t, checks, 4)
+
+	assert.Equal(t, DependencyCheck{Package: "numpy", Found: true, LatestVersion: "2.1.0"}, checks[0])
+	assert.Equal(t, DependencyCheck{Package: "nunpy", Suggestion: "numpy"}, checks[1])
+	assert.Equal(t, DependencyCheck{Package: "sklearn", Suggestion: "scikit-learn"}, checks[2])
+	assert.False(t, checks[3].Found)
+	assert.Contains(t, checks[3].Error, "500")
+}
+
+func TestCheckDependenciesNode(t *testing.T) {
+	withMockRegistries(t)
+
+	checks := checkDependencies(t, "js", "lodash@4", "@types/node", "expres")
+	require.Len(t, checks, 3)
+
+	assert.Equal(t, DependencyCheck{Package: "lodash", Found: true, LatestVersion: "4.17.21"}, checks[0])
+	assert.Equal(t, DependencyCheck{Package: "@types/node", Found: true, LatestVersion: "22.7.0"}, checks[1])
+	assert.Equal(t, DependencyCheck{Package: "expres", Suggestion: "express"}, checks[2])
+}
+
+func TestCheckDependenciesGo(t *testing.T) {
+	withMockRegistries(t)
+
+	checks := checkDependencies(t, "golang", "github.com/BurntSushi/toml@v1.3.0", "github.com/gone/mod")
+	require.Len(t, checks, 2)
+
+	assert.Equal(t, DependencyCheck{Package: "github.com/BurntSushi/toml", Found: true, LatestVersion: "v1.4.0"}, checks[0])
+	assert.Equal(t, DependencyCheck{Package: "github.com/gone/mod"}, checks[1])
+}
+
+func TestCheckDependenciesUnsupportedLanguage(t *testing.T) {
+	result, err := CheckDependencies(context.Background(), newMockCallToolRequest("check_dependencies", map[string]interface{}{
+		"language": "cobol",
+		"packages": []interface{}{"x"},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "supported languages are python, nodejs, go")
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("numpy", "numpy"))
+	assert.Equal(t, 1, editDistance("nunpy", "numpy"))
+	assert.Equal(t, 2, editDistance("pnadas", "pandas"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+}
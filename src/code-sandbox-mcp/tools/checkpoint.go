@@ -0,0 +1,302 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerImage "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Labels applied to checkpoint images
+const (
+	CheckpointLabel        = "code-sandbox-mcp.checkpoint"
+	checkpointSandboxLabel = "code-sandbox-mcp.checkpoint.sandbox"
+	checkpointMetaLabel    = "code-sandbox-mcp.checkpoint.meta"
+)
+
+// checkpointMeta is the part of the sandbox's host configuration restored on rollback
+type checkpointMeta struct {
+	Memory     int64               `json:"memory,omitempty"`
+	MemorySwap int64               `json:"memory_swap,omitempty"`
+	NanoCPUs   int64               `json:"nano_cpus,omitempty"`
+	Binds      []string            `json:"binds,omitempty"`
+	Mounts     []mount.Mount       `json:"mounts,omitempty"`
+	Ulimits    []*container.Ulimit `json:"ulimits,omitempty"`
+	// SecurityOpt carries the sandbox's seccomp profile, so a rollback runs under the same restrictions
+	SecurityOpt []string `json:"security_opt,omitempty"`
+}
+
+// CheckpointInfo describes a checkpoint image
+type CheckpointInfo struct {
+	CheckpointID string `json:"checkpoint_id"`
+	Sandbox      string `json:"sandbox"`
+	Created      string `json:"created"`
+	Size         int64  `json:"size"`
+}
+
+// CheckpointSandbox commits a sandbox to an unnamed, labeled image and returns the checkpoint ID
+func CheckpointSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	checkpointID, err := checkpointContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("checkpoint_id: %s", checkpointID)), nil
+}
+
+// RollbackSandbox replaces a sandbox with a new container started from one of its checkpoints
+func RollbackSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = lookupContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	checkpointID, err := request.RequireString("checkpoint_id")
+	if err != nil {
+		return mcp.NewToolResultText("checkpoint_id is required"), nil
+	}
+
+	containerID, seccompWarning, err := rollbackContainer(ctx, containerIDOrName, checkpointID)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	message := fmt.Sprintf("container_id: %s", containerID)
+	if seccompWarning != "" {
+		message += fmt.Sprintf("\nWarning: %s", seccompWarning)
+	}
+	return mcp.NewToolResultText(message), nil
+}
+
+// ListCheckpoints lists checkpoint images, optionally only those of one sandbox
+func ListCheckpoints(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return nil, fmt.Errorf("DOCKER_CLIENT_ERROR: failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	sandbox := ""
+	if containerIDOrName := request.GetString("container_id_or_name", ""); containerIDOrName != "" {
+		sandbox, err = sandboxName(ctx, cli, containerIDOrName)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+
+	checkpoints, err := listCheckpoints(ctx, cli, sandbox)
+	if err != nil {
+		return nil, fmt.Errorf("CHECKPOINT_LIST_ERROR: failed to list checkpoints: %v", err)
+	}
+
+	jsonData, err := json.Marshal(checkpoints)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize checkpoint list: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// checkpointContainer commits the container, recording the sandbox name and host configuration as image labels
+func checkpointContainer(ctx context.Context, containerIDOrName string) (string, error) {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	var meta checkpointMeta
+	if inspect.HostConfig != nil {
+		meta = checkpointMeta{
+			Memory:      inspect.HostConfig.Memory,
+			MemorySwap:  inspect.HostConfig.MemorySwap,
+			NanoCPUs:    inspect.HostConfig.NanoCPUs,
+			Binds:       inspect.HostConfig.Binds,
+			Mounts:      inspect.HostConfig.Mounts,
+			Ulimits:     inspect.HostConfig.Ulimits,
+			SecurityOpt: inspect.HostConfig.SecurityOpt,
+		}
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize checkpoint metadata: %w", err)
+	}
+
+	// The daemon merges these labels into the container's own config
+	resp, err := cli.ContainerCommit(ctx, inspect.ID, container.CommitOptions{
+		Comment: fmt.Sprintf("code-sandbox-mcp checkpoint of %s", strings.TrimPrefix(inspect.Name, "/")),
+		Pause:   true,
+		Config: &container.Config{
+			Labels: map[string]string{
+				CheckpointLabel:        "true",
+				checkpointSandboxLabel: strings.TrimPrefix(inspect.Name, "/"),
+				checkpointMetaLabel:    string(metaJSON),
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit container: %w", err)
+	}
+
+	return shortImageID(resp.ID), nil
+}
+
+// rollbackContainer starts a new container from the checkpoint and, once it runs, replaces the sandbox with it
+// under the same name. The warning is set when the engine rejected the sandbox's seccomp profile.
+func rollbackContainer(ctx context.Context, containerIDOrName string, checkpointID string) (string, string, error) {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	name := strings.TrimPrefix(inspect.Name, "/")
+
+	checkpoint, err := cli.ImageInspect(ctx, checkpointID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to inspect checkpoint %s: %w", checkpointID, err)
+	}
+	if checkpoint.Config == nil || checkpoint.Config.Labels[CheckpointLabel] != "true" {
+		return "", "", fmt.Errorf("%s is not a sandbox checkpoint", checkpointID)
+	}
+	if owner := checkpoint.Config.Labels[checkpointSandboxLabel]; owner != name {
+		return "", "", fmt.Errorf("checkpoint %s belongs to sandbox %s, not %s", checkpointID, owner, name)
+	}
+
+	var meta checkpointMeta
+	if err := json.Unmarshal([]byte(checkpoint.Config.Labels[checkpointMetaLabel]), &meta); err != nil {
+		return "", "", fmt.Errorf("failed to read checkpoint metadata: %w", err)
+	}
+
+	config := sandboxContainerConfig(checkpoint.ID)
+	if checkpoint.Config.WorkingDir != "" {
+		config.WorkingDir = checkpoint.Config.WorkingDir
+	}
+	hostConfig := sandboxHostConfig(meta.Memory)
+	hostConfig.Resources.MemorySwap = meta.MemorySwap
+	hostConfig.Binds = meta.Binds
+	hostConfig.Mounts = meta.Mounts
+	hostConfig.Resources.Ulimits = meta.Ulimits
+	hostConfig.Resources.NanoCPUs = meta.NanoCPUs
+	hostConfig.SecurityOpt = meta.SecurityOpt
+
+	// The replacement starts under a name of its own, so a checkpoint that fails to start leaves the sandbox as
+	// it was; only then is the sandbox removed and the name handed over
+	replacement := fmt.Sprintf("%s-rollback-%d", name, time.Now().UnixNano())
+	id, seccompWarning, err := startWithSeccompFallback(ctx, cli, config, hostConfig, replacement)
+	if err != nil {
+		if id != "" {
+			_ = removeContainer(ctx, cli, id)
+		}
+		return "", "", fmt.Errorf("failed to start the checkpoint, the sandbox is unchanged: %w", err)
+	}
+	// The replaced container's exit must not be captured, nor forget what is kept under the name it hands over
+	if err := stopRequestedContainer(ctx, inspect.ID, containerIDOrName); err != nil {
+		_ = removeContainer(ctx, cli, id)
+		return "", "", err
+	}
+	if err := cli.ContainerRename(ctx, id, name); err != nil {
+		return "", "", fmt.Errorf("the sandbox was rolled back but its container %s could not be renamed to %s: %w", replacement, name, err)
+	}
+	return id, seccompWarning, nil
+}
+
+// listCheckpoints returns checkpoint images, newest first, optionally only those of one sandbox
+func listCheckpoints(ctx context.Context, cli *client.Client, sandbox string) ([]CheckpointInfo, error) {
+	args := filters.NewArgs(filters.Arg("label", CheckpointLabel+"=true"))
+	if sandbox != "" {
+		args.Add("label", checkpointSandboxLabel+"="+sandbox)
+	}
+
+	images, err := cli.ImageList(ctx, dockerImage.ListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Created > images[j].Created })
+
+	checkpoints := make([]CheckpointInfo, 0, len(images))
+	for _, img := range images {
+		checkpoints = append(checkpoints, CheckpointInfo{
+			CheckpointID: shortImageID(img.ID),
+			Sandbox:      img.Labels[checkpointSandboxLabel],
+			Created:      time.Unix(img.Created, 0).UTC().Format(time.RFC3339),
+			Size:         img.Size,
+		})
+	}
+	return checkpoints, nil
+}
+
+// removeCheckpoints deletes all checkpoint images of a sandbox, newest first so children go before parents
+func removeCheckpoints(ctx context.Context, cli *client.Client, sandbox string) (int, error) {
+	checkpoints, err := listCheckpoints(ctx, cli, sandbox)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	removed := 0
+	for _, checkpoint := range checkpoints {
+		if _, err := cli.ImageRemove(ctx, checkpoint.CheckpointID, dockerImage.RemoveOptions{Force: true, PruneChildren: true}); err != nil {
+			return removed, fmt.Errorf("failed to remove checkpoint %s: %w", checkpoint.CheckpointID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// sandboxName resolves a container reference to its name without the leading slash
+func sandboxName(ctx context.Context, cli *client.Client, containerIDOrName string) (string, error) {
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return strings.TrimPrefix(inspect.Name, "/"), nil
+}
+
+// shortImageID trims the digest algorithm and shortens an image ID to 12 characters
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
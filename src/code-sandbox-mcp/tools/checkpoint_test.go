@@ -0,0 +1,13 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortImageID(t *testing.T) {
+	assert.Equal(t, "0123456789ab", shortImageID("sha256:0123456789abcdef0123456789abcdef"))
+	assert.Equal(t, "0123456789ab", shortImageID("0123456789abcdef"))
+	assert.Equal(t, "abc", shortImageID("abc"))
+}
@@ -0,0 +1,317 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxContentDiffBytes caps the unified diff returned by show_content_diff
+const maxContentDiffBytes = 64 * 1024
+
+// fileEntry is the size and sha256 of one regular file
+type fileEntry struct {
+	Size   int64
+	SHA256 string
+}
+
+// fileManifest maps slash-separated paths relative to the compared directory to their entries
+type fileManifest map[string]fileEntry
+
+// FileChange is one file that differs between the two sides of a comparison
+type FileChange struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	OtherSize *int64 `json:"other_size,omitempty"`
+}
+
+// Comparison describes the sandbox directory relative to the other side: added files exist only in the
+// sandbox, removed files exist only on the other side
+type Comparison struct {
+	Sandbox       string       `json:"sandbox"`
+	Other         string       `json:"other"`
+	Added         []FileChange `json:"added"`
+	Removed       []FileChange `json:"removed"`
+	Changed       []FileChange `json:"changed"`
+	Unchanged     int          `json:"unchanged"`
+	Diff          string       `json:"diff,omitempty"`
+	DiffTruncated bool         `json:"diff_truncated,omitempty"`
+}
+
+// CompareSandbox compares a directory in a container against a directory in another container or on the host
+func CompareSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters using new API
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = lookupContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	containerPath := inWorkDir(workDir, request.GetString("container_path", ""))
+
+	localPath := request.GetString("local_path", "")
+	otherContainer := request.GetString("other_container_id_or_name", "")
+	if (localPath == "") == (otherContainer == "") {
+		return mcp.NewToolResultText("Exactly one of local_path or other_container_id_or_name is required"), nil
+	}
+	if otherContainer != "" {
+		if otherContainer, err = lookupContainer(ctx, otherContainer); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+	otherPath := containerPath
+	if p := request.GetString("other_container_path", ""); p != "" && otherContainer != "" {
+		otherWorkDir, err := lookupWorkDir(ctx, otherContainer)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		otherPath = inWorkDir(otherWorkDir, p)
+	}
+
+	diffPath := request.GetString("show_content_diff", "")
+	if diffPath != "" {
+		if diffPath, err = cleanRelativePath(diffPath); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: show_content_diff: %v", err)), nil
+		}
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create Docker client: %v", err)), nil
+	}
+	defer cli.Close()
+
+	sandbox, err := containerManifest(ctx, cli, containerIDOrName, containerPath)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error listing %s in container %s: %v", containerPath, containerIDOrName, err)), nil
+	}
+
+	// readOther reads one file of the other side for the content diff
+	var other fileManifest
+	var otherLabel string
+	var readOther func(rel string) ([]byte, error)
+	if localPath != "" {
+		localPath = filepath.Clean(localPath)
+		otherLabel = localPath
+		if other, err = dirManifest(localPath); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error listing %s: %v", localPath, err)), nil
+		}
+		readOther = func(rel string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(localPath, filepath.FromSlash(rel)))
+		}
+	} else {
+		otherLabel = otherContainer + ":" + otherPath
+		if other, err = containerManifest(ctx, cli, otherContainer, otherPath); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error listing %s in container %s: %v", otherPath, otherContainer, err)), nil
+		}
+		readOther = func(rel string) ([]byte, error) {
+			data, _, err := readFileFromContainer(ctx, cli, otherContainer, path.Join(otherPath, rel))
+			return data, err
+		}
+	}
+
+	comparison := compareManifests(sandbox, other)
+	comparison.Sandbox = containerIDOrName + ":" + containerPath
+	comparison.Other = otherLabel
+
+	if diffPath != "" {
+		_, inSandbox := sandbox[diffPath]
+		_, inOther := other[diffPath]
+		if !inSandbox && !inOther {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: show_content_diff: %s does not exist on either side", diffPath)), nil
+		}
+
+		// A file missing on one side diffs against empty content
+		var sandboxData, otherData []byte
+		if inSandbox {
+			if sandboxData, _, err = readFileFromContainer(ctx, cli, containerIDOrName, path.Join(containerPath, diffPath)); err != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("Error reading %s from container %s: %v", diffPath, containerIDOrName, err)), nil
+			}
+		}
+		if inOther {
+			if otherData, err = readOther(diffPath); err != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("Error reading %s from %s: %v", diffPath, otherLabel, err)), nil
+			}
+		}
+		comparison.Diff, comparison.DiffTruncated = contentDiff(diffPath, otherData, sandboxData)
+	}
+
+	jsonData, err := json.Marshal(comparison)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize comparison: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// cleanRelativePath normalizes a path relative to the compared directories, rejecting ones that escape them
+func cleanRelativePath(p string) (string, error) {
+	cleaned := path.Clean(filepath.ToSlash(p))
+	if path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%q must be a file path relative to the compared directories", p)
+	}
+	return cleaned, nil
+}
+
+// containerManifest hashes every regular file under dir in the container; the files are streamed through the
+// Docker archive API, so the image needs neither a shell nor sha256sum
+func containerManifest(ctx context.Context, cli *client.Client, containerIDOrName string, dir string) (fileManifest, error) {
+	reader, stat, err := cli.CopyFromContainer(ctx, containerIDOrName, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer reader.Close()
+
+	if !stat.Mode.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return tarManifest(reader)
+}
+
+// tarManifest hashes the regular files of a CopyFromContainer archive, whose entries are rooted at the
+// base name of the copied directory
+func tarManifest(r io.Reader) (fileManifest, error) {
+	manifest := fileManifest{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return manifest, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		_, rel, found := strings.Cut(strings.TrimPrefix(header.Name, "./"), "/")
+		if !found || rel == "" {
+			continue
+		}
+		entry, err := hashFile(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		manifest[rel] = entry
+	}
+}
+
+// dirManifest hashes every regular file under a host directory
+func dirManifest(dir string) (fileManifest, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	manifest := fileManifest{}
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		entry, err := hashFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		manifest[filepath.ToSlash(rel)] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func hashFile(r io.Reader) (fileEntry, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	return fileEntry{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// compareManifests classifies the sandbox files against the other side's, sorted by path
+func compareManifests(sandbox, other fileManifest) Comparison {
+	comparison := Comparison{Added: []FileChange{}, Removed: []FileChange{}, Changed: []FileChange{}}
+	for p, entry := range sandbox {
+		otherEntry, ok := other[p]
+		switch {
+		case !ok:
+			comparison.Added = append(comparison.Added, FileChange{Path: p, Size: entry.Size})
+		case otherEntry.SHA256 != entry.SHA256:
+			otherSize := otherEntry.Size
+			comparison.Changed = append(comparison.Changed, FileChange{Path: p, Size: entry.Size, OtherSize: &otherSize})
+		default:
+			comparison.Unchanged++
+		}
+	}
+	for p, entry := range other {
+		if _, ok := sandbox[p]; !ok {
+			comparison.Removed = append(comparison.Removed, FileChange{Path: p, Size: entry.Size})
+		}
+	}
+
+	for _, changes := range [][]FileChange{comparison.Added, comparison.Removed, comparison.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	}
+	return comparison
+}
+
+// contentDiff renders the unified diff from the other side's file to the sandbox's, capped at maxContentDiffBytes
+func contentDiff(rel string, otherData, sandboxData []byte) (string, bool) {
+	if bytes.IndexByte(otherData, 0) >= 0 || bytes.IndexByte(sandboxData, 0) >= 0 {
+		if bytes.Equal(otherData, sandboxData) {
+			return "", false
+		}
+		return fmt.Sprintf("Binary files a/%s and b/%s differ\n", rel, rel), false
+	}
+
+	diff, err := unifiedDiff("a/"+rel, "b/"+rel, string(otherData), string(sandboxData), 3)
+	if err != nil {
+		return fmt.Sprintf("Cannot diff %s: %v\n", rel, err), false
+	}
+	if len(diff) <= maxContentDiffBytes {
+		return diff, false
+	}
+	// Cut at a line boundary so the diff stays readable
+	cut := strings.LastIndexByte(diff[:maxContentDiffBytes], '\n') + 1
+	return diff[:cut], true
+}
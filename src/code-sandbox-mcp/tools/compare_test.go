@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// containerArchive builds a CopyFromContainer-style archive rooted at the directory's base name
+func containerArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "app/" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "app/link", Typeflag: tar.TypeSymlink, Linkname: "main.py"}))
+	require.NoError(t, tw.Close())
+	return buf
+}
+
+func TestCompareManifests(t *testing.T) {
+	host := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(host, "pkg"), 0755))
+	for name, content := range map[string]string{
+		"main.py":      "print('hi')\n",
+		"pkg/util.py":  "x = 1\n",
+		"removed.txt":  "gone\n",
+		"pkg/same.txt": "same\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(host, filepath.FromSlash(name)), []byte(content), 0644))
+	}
+	other, err := dirManifest(host)
+	require.NoError(t, err)
+	assert.Len(t, other, 4)
+
+	sandbox, err := tarManifest(containerArchive(t, map[string]string{
+		"main.py":      "print('hi')\n",
+		"pkg/util.py":  "x = 22\n",
+		"pkg/same.txt": "same\n",
+		"new.txt":      "new\n",
+	}))
+	require.NoError(t, err)
+	assert.NotContains(t, sandbox, "link")
+	assert.Equal(t, other["main.py"], sandbox["main.py"])
+
+	comparison := compareManifests(sandbox, other)
+	assert.Equal(t, []FileChange{{Path: "new.txt", Size: 4}}, comparison.Added)
+	assert.Equal(t, []FileChange{{Path: "removed.txt", Size: 5}}, comparison.Removed)
+	require.Len(t, comparison.Changed, 1)
+	assert.Equal(t, "pkg/util.py", comparison.Changed[0].Path)
+	assert.Equal(t, int64(7), comparison.Changed[0].Size)
+	assert.Equal(t, int64(6), *comparison.Changed[0].OtherSize)
+	assert.Equal(t, 2, comparison.Unchanged)
+}
+
+func TestContentDiff(t *testing.T) {
+	diff, truncated := contentDiff("pkg/util.py", []byte("x = 1\n"), []byte("x = 22\n"))
+	assert.False(t, truncated)
+	assert.Equal(t, "--- a/pkg/util.py\n+++ b/pkg/util.py\n@@ -1,1 +1,1 @@\n-x = 1\n+x = 22\n", diff)
+
+	diff, _ = contentDiff("blob", []byte("a\x00b"), []byte("a\x00c"))
+	assert.Equal(t, "Binary files a/blob and b/blob differ\n", diff)
+
+	large := strings.Repeat("line\n", 30000)
+	diff, truncated = contentDiff("big.txt", nil, []byte(large))
+	assert.True(t, truncated)
+	assert.LessOrEqual(t, len(diff), maxContentDiffBytes)
+	assert.True(t, strings.HasSuffix(diff, "\n"))
+}
+
+func TestCleanRelativePath(t *testing.T) {
+	p, err := cleanRelativePath("./pkg//util.py")
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/util.py", p)
+
+	for _, bad := range []string{"/etc/passwd", "../secret", ".", "pkg/../../x"} {
+		_, err := cleanRelativePath(bad)
+		assert.Error(t, err, bad)
+	}
+}
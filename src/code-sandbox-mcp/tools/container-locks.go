@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// lockKind says which other calls a call on a container conflicts with
+type lockKind int
+
+const (
+	// lockUse runs commands in a container; it only conflicts with lockLifecycle
+	lockUse lockKind = iota + 1
+	// lockWrite changes a container's files; writes are serialized with each other
+	lockWrite
+	// lockLifecycle stops or replaces a container, so it waits for every other call
+	lockLifecycle
+)
+
+// containerLockKinds are the tools that lock the container they're called on; the read-only tools, such as
+// sandbox_describe and sandbox_list, and the logs resource don't lock anything
+var containerLockKinds = map[string]lockKind{
+	"sandbox_exec":               lockUse,
+	"sandbox_shell_open":         lockUse,
+	"sandbox_shell_input":        lockUse,
+	"check_dependencies":         lockUse,
+	"copy_file_from_sandbox":     lockUse,
+	"copy_project":               lockWrite,
+	"copy_file":                  lockWrite,
+	"extract_archive_to_sandbox": lockWrite,
+	"write_file_sandbox":         lockWrite,
+	"write_files_sandbox":        lockWrite,
+	"scaffold_sandbox":           lockWrite,
+	"apply_patch_sandbox":        lockWrite,
+	"install_system_packages":    lockWrite,
+	"sandbox_checkpoint":         lockWrite,
+	"sandbox_stop":               lockLifecycle,
+	"sandbox_rollback":           lockLifecycle,
+}
+
+// conflicts reports whether calls of kinds a and b can't run on the same container at once
+func (a lockKind) conflicts(b lockKind) bool {
+	return a == lockLifecycle || b == lockLifecycle || (a == lockWrite && b == lockWrite)
+}
+
+// containerLockWait is how long a call waits for a conflicting call before failing with CONTAINER_BUSY;
+// replaced in tests
+var containerLockWait = 2 * time.Second
+
+// resolveLockKey turns a container reference into the key its lock is held under; replaced in tests
+var resolveLockKey = lookupContainer
+
+// ContainerBusyError is returned when a conflicting call still holds a container's lock after containerLockWait
+type ContainerBusyError struct {
+	Container string
+	Operation string
+	Running   time.Duration
+}
+
+func (e *ContainerBusyError) Error() string {
+	return fmt.Sprintf("CONTAINER_BUSY: container %s is busy with %s (running for %s); retry once it finishes",
+		e.Container, e.Operation, e.Running.Round(100*time.Millisecond))
+}
+
+// lockHolder is a call holding a container's lock
+type lockHolder struct {
+	tool    string
+	kind    lockKind
+	started time.Time
+}
+
+// containerLock is the state of one container's lock. It's only kept while it has holders or waiters, so the
+// map doesn't grow with every container ever touched.
+type containerLock struct {
+	holders []*lockHolder
+	waiters int
+	// released is closed, and replaced, whenever a holder lets go
+	released chan struct{}
+}
+
+// containerLocks is a map of advisory locks keyed by resolved container reference
+type containerLocks struct {
+	mu    sync.Mutex
+	locks map[string]*containerLock
+}
+
+func newContainerLocks() *containerLocks {
+	return &containerLocks{locks: make(map[string]*containerLock)}
+}
+
+// sandboxLocks are the locks the middleware takes
+var sandboxLocks = newContainerLocks()
+
+// acquire takes the lock on key for a call of tool, waiting up to wait for conflicting calls to finish. It
+// returns a func that releases the lock, or a *ContainerBusyError naming the call that still has it.
+func (l *containerLocks) acquire(ctx context.Context, key, tool string, kind lockKind, wait time.Duration) (func(), error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock := l.locks[key]
+	if lock == nil {
+		lock = &containerLock{released: make(chan struct{})}
+		l.locks[key] = lock
+	}
+	for {
+		conflict := lock.conflict(kind)
+		if conflict == nil {
+			holder := &lockHolder{tool: tool, kind: kind, started: time.Now()}
+			lock.holders = append(lock.holders, holder)
+			return func() { l.release(key, lock, holder) }, nil
+		}
+
+		lock.waiters++
+		released := lock.released
+		l.mu.Unlock()
+		var err error
+		select {
+		case <-released:
+		case <-timer.C:
+			err = &ContainerBusyError{Container: key, Operation: conflict.tool, Running: time.Since(conflict.started)}
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		l.mu.Lock()
+		lock.waiters--
+		if err != nil {
+			l.forget(key, lock)
+			return nil, err
+		}
+	}
+}
+
+// conflict returns the longest-running holder a call of kind has to wait for, or nil if it can go ahead
+func (c *containerLock) conflict(kind lockKind) *lockHolder {
+	for _, holder := range c.holders {
+		if holder.kind.conflicts(kind) {
+			return holder
+		}
+	}
+	return nil
+}
+
+func (l *containerLocks) release(key string, lock *containerLock, holder *lockHolder) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, h := range lock.holders {
+		if h == holder {
+			lock.holders = append(lock.holders[:i], lock.holders[i+1:]...)
+			close(lock.released)
+			lock.released = make(chan struct{})
+			break
+		}
+	}
+	l.forget(key, lock)
+}
+
+// forget drops a lock nobody holds or waits for; l.mu must be held
+func (l *containerLocks) forget(key string, lock *containerLock) {
+	if len(lock.holders) == 0 && lock.waiters == 0 && l.locks[key] == lock {
+		delete(l.locks, key)
+	}
+}
+
+// size is the number of locks in the map
+func (l *containerLocks) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.locks)
+}
+
+// ContainerLockMiddleware serializes the calls that change a container: writes wait for each other, and
+// sandbox_stop and sandbox_rollback wait for every other call on the container, including running commands. A
+// call that still can't go ahead after a short wait fails with a CONTAINER_BUSY error naming the call in its
+// way. References that don't resolve are left for the tool to reject.
+func ContainerLockMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		kind, ok := containerLockKinds[request.Params.Name]
+		ref := request.GetString("container_id_or_name", "")
+		if !ok || ref == "" {
+			return next(ctx, request)
+		}
+		key, err := resolveLockKey(ctx, ref)
+		if err != nil {
+			return next(ctx, request)
+		}
+
+		release, err := sandboxLocks.acquire(ctx, key, request.Params.Name, kind, containerLockWait)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerLocks(t *testing.T) {
+	locks := newContainerLocks()
+	ctx := context.Background()
+
+	// Commands run side by side, and so do writes to different containers
+	releaseExec, err := locks.acquire(ctx, "box", "sandbox_exec", lockUse, 0)
+	require.NoError(t, err)
+	releaseShell, err := locks.acquire(ctx, "box", "sandbox_shell_input", lockUse, 0)
+	require.NoError(t, err)
+	releaseWrite, err := locks.acquire(ctx, "box", "write_file_sandbox", lockWrite, 0)
+	require.NoError(t, err)
+	releaseOther, err := locks.acquire(ctx, "other", "write_file_sandbox", lockWrite, 0)
+	require.NoError(t, err)
+
+	// A second write and a stop have to wait
+	_, err = locks.acquire(ctx, "box", "copy_file", lockWrite, 10*time.Millisecond)
+	var busy *ContainerBusyError
+	require.ErrorAs(t, err, &busy)
+	assert.Equal(t, "write_file_sandbox", busy.Operation)
+	_, err = locks.acquire(ctx, "box", "sandbox_stop", lockLifecycle, 10*time.Millisecond)
+	require.ErrorAs(t, err, &busy)
+	assert.Equal(t, "sandbox_exec", busy.Operation, "the longest-running call is named")
+	assert.Regexp(t, `^CONTAINER_BUSY: container box is busy with sandbox_exec \(running for .+\); retry once it finishes$`, err.Error())
+
+	// A waiting stop goes ahead once the calls in its way finish
+	done := make(chan error)
+	go func() {
+		release, err := locks.acquire(ctx, "box", "sandbox_stop", lockLifecycle, 5*time.Second)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	releaseExec()
+	releaseShell()
+	releaseWrite()
+	require.NoError(t, <-done)
+
+	// A cancelled call stops waiting
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = locks.acquire(cancelled, "other", "copy_file", lockWrite, 5*time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing is left behind once the locks are released
+	releaseOther()
+	assert.Equal(t, 0, locks.size())
+}
+
+func TestContainerLockMiddleware(t *testing.T) {
+	savedResolve, savedWait := resolveLockKey, containerLockWait
+	resolveLockKey = func(ctx context.Context, ref string) (string, error) { return "mcp-box", nil }
+	containerLockWait = 50 * time.Millisecond
+	t.Cleanup(func() { resolveLockKey, containerLockWait = savedResolve, savedWait })
+
+	// The exec blocks until the test lets it finish
+	execStarted, finishExec := make(chan struct{}), make(chan struct{})
+	stopped := false
+	handler := ContainerLockMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		switch request.Params.Name {
+		case "sandbox_exec":
+			close(execStarted)
+			<-finishExec
+		case "sandbox_stop":
+			stopped = true
+		}
+		return mcp.NewToolResultText("ok"), nil
+	})
+	call := func(tool string) *mcp.CallToolResult {
+		request := newMockCallToolRequest(tool, map[string]interface{}{"container_id_or_name": "box"})
+		result, err := handler(context.Background(), request)
+		require.NoError(t, err)
+		return result
+	}
+
+	execDone := make(chan *mcp.CallToolResult)
+	go func() { execDone <- call("sandbox_exec") }()
+	<-execStarted
+
+	// A stop racing the exec is refused and never reaches the handler
+	result := call("sandbox_stop")
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(t, result), "CONTAINER_BUSY: container mcp-box is busy with sandbox_exec")
+	assert.False(t, stopped)
+
+	// Read-only tools go ahead
+	assert.False(t, call("sandbox_describe").IsError)
+
+	// Once the exec finishes, the stop goes through
+	close(finishExec)
+	assert.False(t, (<-execDone).IsError)
+	result = call("sandbox_stop")
+	assert.False(t, result.IsError)
+	assert.True(t, stopped)
+	assert.Equal(t, 0, sandboxLocks.size())
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// ContentEncodings are the encodings of file contents sent to write_file_sandbox and write_files_sandbox
+var ContentEncodings = []string{"utf8", "base64", "gzip+base64"}
+
+const (
+	// maxDecompressedSize caps what gzip+base64 contents may expand to
+	maxDecompressedSize = 50 << 20
+	// maxCompressionRatio caps how many times its compressed size gzip+base64 contents may expand to, once past
+	// compressionRatioFloor; generated source seldom compresses beyond 20:1, a zip bomb compresses beyond 1000:1
+	maxCompressionRatio   = 200
+	compressionRatioFloor = 1 << 20
+)
+
+// decodeContents decodes file contents sent with an encoding. field names the contents argument and
+// encodingField the encoding argument in errors.
+func decodeContents(field, encodingField, contents, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "utf8", "utf-8":
+		return []byte(contents), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(contents)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not valid base64: %v", field, err)
+		}
+		return data, nil
+	case "gzip+base64":
+		compressed, err := base64.StdEncoding.DecodeString(contents)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not valid base64: %v", field, err)
+		}
+		return gunzipContents(field, compressed)
+	default:
+		return nil, fmt.Errorf("%s must be utf8, base64 or gzip+base64, got %q", encodingField, encoding)
+	}
+}
+
+// gunzipContents decompresses gzip contents, stopping as soon as they expand past the size or ratio limit, so a
+// decompression bomb costs no more memory than the limit
+func gunzipContents(field string, compressed []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a gzip stream: %v", field, err)
+	}
+	defer reader.Close()
+
+	limit := int64(maxDecompressedSize)
+	byRatio := int64(len(compressed)) * maxCompressionRatio
+	if byRatio < compressionRatioFloor {
+		byRatio = compressionRatioFloor
+	}
+	if byRatio < limit {
+		limit = byRatio
+	}
+
+	var data bytes.Buffer
+	n, err := io.Copy(&data, io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s is a corrupt gzip stream: %v", field, err)
+	}
+	if n > limit {
+		if limit == maxDecompressedSize {
+			return nil, fmt.Errorf("DECOMPRESSION_BOMB: %s expands past the %dMB limit", field, maxDecompressedSize>>20)
+		}
+		return nil, fmt.Errorf("DECOMPRESSION_BOMB: %s expands past %d times its compressed size of %d bytes", field, maxCompressionRatio, len(compressed))
+	}
+	return data.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gzipBase64 compresses data the way a client sends gzip+base64 contents
+func gzipBase64(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeContentsRoundTrip(t *testing.T) {
+	binary := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(binary)
+	for name, data := range map[string][]byte{
+		"source": []byte(strings.Repeat("def handler(event):\n    return {'status': 200}\n\n", 2000)),
+		"binary": binary,
+		"empty":  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := decodeContents("file_contents", "encoding", gzipBase64(t, data), "gzip+base64")
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data, decoded), "contents differ after the round trip")
+		})
+	}
+
+	decoded, err := decodeContents("file_contents", "encoding", "aGk=", "base64")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hi"), decoded)
+	decoded, err = decodeContents("file_contents", "encoding", "aGk=", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("aGk="), decoded)
+}
+
+func TestDecodeContentsRejectsBombs(t *testing.T) {
+	// 64MB of zeros compress to about 64KB: past both the size cap and the ratio limit
+	bomb := gzipBase64(t, make([]byte, 64<<20))
+	_, err := decodeContents("files[0].contents", "files[0].encoding", bomb, "gzip+base64")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DECOMPRESSION_BOMB: files[0].contents expands past 200 times its compressed size")
+
+	// A small but highly compressible file is fine under the ratio floor
+	_, err = decodeContents("file_contents", "encoding", gzipBase64(t, make([]byte, 512<<10)), "gzip+base64")
+	assert.NoError(t, err)
+}
+
+func TestDecodeContentsRejectsCorruptStreams(t *testing.T) {
+	stream, err := base64.StdEncoding.DecodeString(gzipBase64(t, []byte(strings.Repeat("payload ", 100))))
+	require.NoError(t, err)
+	truncated := base64.StdEncoding.EncodeToString(stream[:len(stream)/2])
+	corrupted := bytes.Clone(stream)
+	corrupted[len(corrupted)-5] ^= 0xff // the CRC-32 of the trailer
+
+	tests := []struct {
+		name     string
+		contents string
+		encoding string
+		want     string
+	}{
+		{"not base64", "%%%", "gzip+base64", "file_contents is not valid base64: illegal base64 data at input byte 0"},
+		{"not gzip", base64.StdEncoding.EncodeToString([]byte("plain text")), "gzip+base64", "file_contents is not a gzip stream: gzip: invalid header"},
+		{"truncated", truncated, "gzip+base64", "file_contents is a corrupt gzip stream: unexpected EOF"},
+		{"bad checksum", base64.StdEncoding.EncodeToString(corrupted), "gzip+base64", "file_contents is a corrupt gzip stream: gzip: invalid checksum"},
+		{"unknown encoding", "x", "zstd", `encoding must be utf8, base64 or gzip+base64, got "zstd"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeContents("file_contents", "encoding", tt.contents, tt.encoding)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
@@ -7,8 +7,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -20,16 +20,22 @@ func CopyFileFromContainer(ctx context.Context, request mcp.CallToolRequest) (*m
 	if err != nil {
 		return mcp.NewToolResultText("container_id_or_name is required"), nil
 	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 
 	containerSrcPath, err := request.RequireString("container_src_path")
 	if err != nil {
 		return mcp.NewToolResultText("container_src_path is required"), nil
 	}
 
-	// If container path doesn't start with /, prepend /app/
-	if !strings.HasPrefix(containerSrcPath, "/") {
-		containerSrcPath = filepath.Join("/app", containerSrcPath)
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
 	}
+	containerSrcPath = inWorkDir(workDir, containerSrcPath)
 
 	// Get the local destination path (optional parameter)
 	localDestPath := request.GetString("local_dest_path", "")
@@ -38,25 +44,32 @@ func CopyFileFromContainer(ctx context.Context, request mcp.CallToolRequest) (*m
 		localDestPath = filepath.Base(containerSrcPath)
 	}
 
-	// Clean and create the destination directory if it doesn't exist
-	localDestPath = filepath.Clean(localDestPath)
+	// Clean and create the destination directory if it doesn't exist, mapping a host path to where the server
+	// can write it
+	unmapped := unmappedInContainer(localDestPath)
+	localDestPath = translateHostPath(localDestPath)
 	if err := os.MkdirAll(filepath.Dir(localDestPath), 0755); err != nil {
 		return mcp.NewToolResultText(fmt.Sprintf("Error creating destination directory: %v", err)), nil
 	}
 
 	// Copy the file from the container
-	if err := copySingleFileFromContainer(ctx, containerIDOrName, containerSrcPath, localDestPath); err != nil {
+	if err := copySingleFileFromContainer(ctx, containerIDOrName, containerSrcPath, localDestPath, newProgressReporter(ctx, request)); err != nil {
 		return mcp.NewToolResultText(fmt.Sprintf("Error copying file from container: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s from container %s to %s", containerSrcPath, containerIDOrName, localDestPath)), nil
+	message := fmt.Sprintf("Successfully copied %s from container %s to %s (working directory %s)", containerSrcPath, containerIDOrName, localDestPath, workDir)
+	if unmapped {
+		message += "\nWarning: the server runs in a container and no SANDBOX_HOST_ROOT mapping covers this path, so the file was written inside the server's container rather than on the Docker host"
+	}
+	return mcp.NewToolResultText(message), nil
 }
 
 // copySingleFileFromContainer copies a single file from the container to the local filesystem
-func copySingleFileFromContainer(ctx context.Context, containerIDOrName string, srcPath string, destPath string) error {
+func copySingleFileFromContainer(ctx context.Context, containerIDOrName string, srcPath string, destPath string, progress *progressReporter) error {
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -96,16 +109,26 @@ func copySingleFileFromContainer(ctx context.Context, containerIDOrName string,
 	}
 	defer destFile.Close()
 
-	// Copy the content
-	_, err = io.Copy(destFile, tr)
-	if err != nil {
+	// Stream the content from the tar reader straight into the file
+	content := &countingReader{r: tr, step: copyProgressStep(header.Size), onProgress: func(n int64) {
+		progress.ReportBytes(n, header.Size, fmt.Sprintf("Copied %s of %s", formatBytes(uint64(n)), formatBytes(uint64(header.Size))))
+	}}
+	if _, err := io.Copy(destFile, content); err != nil {
 		return fmt.Errorf("failed to write file content: %w", err)
 	}
 
 	// Set file permissions from tar header
-	if err := os.Chmod(destPath, os.FileMode(header.Mode)); err != nil {
+	if err := destFile.Chmod(os.FileMode(header.Mode)); err != nil {
 		return fmt.Errorf("failed to set file permissions: %w", err)
 	}
 
+	// Make sure the file is on disk before reporting success
+	if err := destFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync destination file: %w", err)
+	}
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf("failed to close destination file: %w", err)
+	}
+
 	return nil
 }
@@ -2,19 +2,48 @@ package tools
 
 import (
 	"archive/tar"
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// defaultMaxCopyFileBytes is the largest file copy_file uploads unless SANDBOX_MAX_COPY_FILE_MB is set
+const defaultMaxCopyFileBytes = 5 * 1024 * 1024 * 1024
+
+// maxCopyFileBytes is the copy_file size limit in bytes; 0 disables it
+var maxCopyFileBytes int64 = defaultMaxCopyFileBytes
+
+// LoadCopyFileLimitFromEnv reads SANDBOX_MAX_COPY_FILE_MB; 0 disables the limit
+func LoadCopyFileLimitFromEnv() error {
+	value := os.Getenv("SANDBOX_MAX_COPY_FILE_MB")
+	if value == "" {
+		return nil
+	}
+	mb, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || mb < 0 {
+		return fmt.Errorf("invalid SANDBOX_MAX_COPY_FILE_MB %q: must be a number of megabytes", value)
+	}
+	maxCopyFileBytes = mb * 1024 * 1024
+	return nil
+}
+
+// checkCopyFileSize returns a FILE_TOO_LARGE error for a file over the copy_file limit
+func checkCopyFileSize(path string, size int64) error {
+	if maxCopyFileBytes > 0 && size > maxCopyFileBytes {
+		return fmt.Errorf("FILE_TOO_LARGE: %s is %s, above the copy_file limit of %s; raise SANDBOX_MAX_COPY_FILE_MB to copy it",
+			path, formatBytes(uint64(size)), formatBytes(uint64(maxCopyFileBytes)))
+	}
+	return nil
+}
+
 // CopyFile copies a single local file to a container's filesystem
 func CopyFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Extract parameters using new API
@@ -22,33 +51,50 @@ func CopyFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolRe
 	if err != nil {
 		return mcp.NewToolResultText("container_id_or_name is required"), nil
 	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 
 	localSrcFile, err := request.RequireString("local_src_file")
 	if err != nil {
 		return mcp.NewToolResultText("local_src_file is required"), nil
 	}
 
-	// Clean and validate the source path
-	localSrcFile = filepath.Clean(localSrcFile)
+	// Clean and validate the source path, mapping a host path to where the server can read it
+	localSrcFile = translateHostPath(localSrcFile)
+	if err := checkHostPath(localSrcFile); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 	info, err := os.Stat(localSrcFile)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error accessing source file: %v", err)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Error accessing source file: %v", localPathError(err))), nil
 	}
 
 	if info.IsDir() {
 		return mcp.NewToolResultText("local_src_file must be a file, not a directory"), nil
 	}
+	if err := checkCopyFileSize(localSrcFile, info.Size()); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 
-	// Get the destination path (optional parameter)
-	destPath := request.GetString("dest_path", "")
-	if destPath == "" {
-		// Default: use the name of the source file
-		destPath = filepath.Join("/app", filepath.Base(localSrcFile))
-	} else {
-		// If provided but doesn't start with /, prepend /app/
-		if !strings.HasPrefix(destPath, "/") {
-			destPath = filepath.Join("/app", destPath)
-		}
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Get the destination path (optional parameter), defaulting to the name of the source file
+	destPath := inWorkDir(workDir, request.GetString("dest_path", filepath.Base(localSrcFile)))
+
+	// An existing file at the destination is overwritten unless the caller asks otherwise
+	policy, err := parseOverwritePolicy(request.GetString("overwrite_policy", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	prepared, err := prepareDestination(ctx, containerIDOrName, destPath, workDir, policy)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
 	}
 
 	// Create destination directory in container if it doesn't exist
@@ -58,11 +104,11 @@ func CopyFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolRe
 	}
 
 	// Copy the file to the container
-	if err := copyFileToContainer(ctx, containerIDOrName, localSrcFile, destPath); err != nil {
+	if err := copyFileToContainer(ctx, containerIDOrName, localSrcFile, destPath, newProgressReporter(ctx, request)); err != nil {
 		return mcp.NewToolResultText(fmt.Sprintf("Error copying file to container: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s to %s in container %s", localSrcFile, destPath, containerIDOrName)), nil
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s to %s in container %s (working directory %s)\n%s", localSrcFile, destPath, containerIDOrName, workDir, prepared)), nil
 }
 
 // createDirectoryInContainer creates a directory in the container if it doesn't exist
@@ -70,6 +116,7 @@ func createDirectoryInContainer(ctx context.Context, containerIDOrName string, d
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -93,11 +140,13 @@ func createDirectoryInContainer(ctx context.Context, containerIDOrName string, d
 	return nil
 }
 
-// copyFileToContainer copies a single file to the container
-func copyFileToContainer(ctx context.Context, containerIDOrName string, srcPath string, destPath string) error {
+// copyFileToContainer streams a single file to the container as a one-entry tar archive, so memory use stays
+// flat however large the file is
+func copyFileToContainer(ctx context.Context, containerIDOrName string, srcPath string, destPath string, progress *progressReporter) error {
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -117,40 +166,51 @@ func copyFileToContainer(ctx context.Context, containerIDOrName string, srcPath
 		return fmt.Errorf("failed to stat source file: %w", err)
 	}
 
-	// Create a buffer to write our archive to
-	var buf bytes.Buffer
-
-	// Create a new tar archive
-	tw := tar.NewWriter(&buf)
-
-	// Create tar header
 	header := &tar.Header{
 		Name:    filepath.Base(destPath),
 		Size:    srcInfo.Size(),
 		Mode:    int64(srcInfo.Mode()),
 		ModTime: srcInfo.ModTime(),
 	}
-
-	// Write header
-	if err := tw.WriteHeader(header); err != nil {
-		return fmt.Errorf("failed to write tar header: %w", err)
-	}
-
-	// Copy file content to tar archive
-	if _, err := io.Copy(tw, srcFile); err != nil {
-		return fmt.Errorf("failed to write file content to tar: %w", err)
-	}
-
-	// Close tar writer
-	if err := tw.Close(); err != nil {
-		return fmt.Errorf("failed to close tar writer: %w", err)
-	}
+	content := &countingReader{r: srcFile, step: copyProgressStep(srcInfo.Size()), onProgress: func(n int64) {
+		progress.ReportBytes(n, srcInfo.Size(), fmt.Sprintf("Copied %s of %s", formatBytes(uint64(n)), formatBytes(uint64(srcInfo.Size()))))
+	}}
+	archive := tarFileStream(header, content)
+	// Closing the read side stops the tar writer if the upload fails partway
+	defer archive.Close()
 
 	// Copy the tar archive to the container
-	err = cli.CopyToContainer(ctx, containerIDOrName, filepath.Dir(destPath), &buf, container.CopyToContainerOptions{})
+	err = cli.CopyToContainer(ctx, containerIDOrName, filepath.Dir(destPath), archive, container.CopyToContainerOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to copy to container: %w", err)
 	}
 
 	return nil
 }
+
+// tarFileStream writes a one-entry tar archive of content through a pipe, for the reader to consume as it is
+// produced. A failure reading content is returned to the reader.
+func tarFileStream(header *tar.Header, content io.Reader) *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(header)
+		if err == nil {
+			var n int64
+			n, err = io.Copy(tw, content)
+			if err == nil && n != header.Size {
+				err = fmt.Errorf("file changed size while being copied: read %d of %d bytes", n, header.Size)
+			}
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// copyProgressStep reports transfer progress about every 5% of the file, and not more often than every 1MB
+func copyProgressStep(size int64) int64 {
+	return max(size/20, 1024*1024)
+}
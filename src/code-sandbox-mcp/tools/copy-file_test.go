@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarFileStreamBoundedMemory(t *testing.T) {
+	// A sparse 100MB file takes no disk space but reads back as 100MB of zeros
+	const size = 100 * 1024 * 1024
+	path := filepath.Join(t.TempDir(), "weights.bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(size))
+	t.Cleanup(func() { f.Close() })
+
+	var reports []int64
+	content := &countingReader{r: f, step: copyProgressStep(size), onProgress: func(n int64) { reports = append(reports, n) }}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	tr := tar.NewReader(tarFileStream(&tar.Header{Name: "weights.bin", Size: size, Mode: 0644}, content))
+	header, err := tr.Next()
+	require.NoError(t, err)
+	n, err := io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	runtime.ReadMemStats(&after)
+	assert.Equal(t, "weights.bin", header.Name)
+	assert.Equal(t, int64(size), n)
+	// Buffering the file would allocate at least its size; streaming needs a few copy buffers
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(8*1024*1024))
+
+	require.Len(t, reports, 20)
+	assert.Equal(t, int64(size), reports[len(reports)-1])
+}
+
+func TestTarFileStreamSizeChanged(t *testing.T) {
+	tr := tar.NewReader(tarFileStream(&tar.Header{Name: "log.txt", Size: 10, Mode: 0644}, bytes.NewReader([]byte("short"))))
+	_, err := tr.Next()
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, tr)
+	assert.ErrorContains(t, err, "file changed size while being copied: read 5 of 10 bytes")
+}
+
+func TestCountingReaderSmallFile(t *testing.T) {
+	var reports []int64
+	r := &countingReader{r: bytes.NewReader(make([]byte, 1000)), step: copyProgressStep(1000), onProgress: func(n int64) { reports = append(reports, n) }}
+	_, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	// Steps are at least 1MB, so a small file reports once when it is done
+	assert.Equal(t, []int64{1000}, reports)
+}
+
+func TestCheckCopyFileSize(t *testing.T) {
+	saved := maxCopyFileBytes
+	t.Cleanup(func() { maxCopyFileBytes = saved })
+
+	t.Setenv("SANDBOX_MAX_COPY_FILE_MB", "1024")
+	require.NoError(t, LoadCopyFileLimitFromEnv())
+	assert.NoError(t, checkCopyFileSize("model.bin", 1024*1024*1024))
+	assert.EqualError(t, checkCopyFileSize("model.bin", 3*1024*1024*1024),
+		"FILE_TOO_LARGE: model.bin is 3.0GB, above the copy_file limit of 1.0GB; raise SANDBOX_MAX_COPY_FILE_MB to copy it")
+
+	t.Setenv("SANDBOX_MAX_COPY_FILE_MB", "0")
+	require.NoError(t, LoadCopyFileLimitFromEnv())
+	assert.NoError(t, checkCopyFileSize("model.bin", 100*1024*1024*1024))
+
+	t.Setenv("SANDBOX_MAX_COPY_FILE_MB", "lots")
+	assert.ErrorContains(t, LoadCopyFileLimitFromEnv(), `invalid SANDBOX_MAX_COPY_FILE_MB "lots"`)
+}
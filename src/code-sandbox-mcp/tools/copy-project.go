@@ -7,70 +7,144 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
-	"time"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-// CopyProject copies a local directory to a container's filesystem
+// CopyProject copies a local directory to a container's filesystem, or several of them given as sources
 func CopyProject(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Extract parameters using new API
 	containerIDOrName, err := request.RequireString("container_id_or_name")
 	if err != nil {
 		return mcp.NewToolResultText("container_id_or_name is required"), nil
 	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Several roots, such as two packages of a monorepo, go up together in one archive
+	if sources, ok := request.GetArguments()["sources"]; ok && sources != nil {
+		if request.GetString("local_src_dir", "") != "" {
+			return mcp.NewToolResultText("Error: give either local_src_dir or sources, not both"), nil
+		}
+		return copySources(ctx, request, containerIDOrName, sources), nil
+	}
 
 	localSrcDir, err := request.RequireString("local_src_dir")
 	if err != nil {
-		return mcp.NewToolResultText("local_src_dir is required"), nil
+		return mcp.NewToolResultText("local_src_dir or sources is required"), nil
 	}
 
-	// Clean and validate the source path
-	localSrcDir = filepath.Clean(localSrcDir)
+	// Clean and validate the source path, mapping a host path to where the server can read it
+	localSrcDir = translateHostPath(localSrcDir)
+	if err := checkHostPath(localSrcDir); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 	info, err := os.Stat(localSrcDir)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error accessing source directory: %v", err)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Error accessing source directory: %v", localPathError(err))), nil
 	}
 
 	if !info.IsDir() {
 		return mcp.NewToolResultText("local_src_dir must be a directory"), nil
 	}
 
-	// Get the destination path (optional parameter)
-	destDir := request.GetString("dest_dir", "")
-	if destDir == "" {
-		// Default: use the name of the source directory
-		destDir = filepath.Join("/app", filepath.Base(localSrcDir))
-	} else {
-		// If provided but doesn't start with /, prepend /app/
-		if !strings.HasPrefix(destDir, "/") {
-			destDir = filepath.Join("/app", destDir)
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Get the destination path (optional parameter), defaulting to the name of the source directory
+	destDir := inWorkDir(workDir, request.GetString("dest_dir", filepath.Base(localSrcDir)))
+
+	// OS, cache and editor junk is left out unless asked for
+	includeJunk := request.GetBool("include_junk", false)
+
+	// The project lands in destDir/<basename>; re-copying merges into it unless the caller wants a clean copy
+	policy, err := parseOverwritePolicy(request.GetString("overwrite_policy", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	prepared, err := prepareDestination(ctx, containerIDOrName, path.Join(destDir, filepath.Base(localSrcDir)), workDir, policy)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// The archive is uploaded once and extracted by the Docker API; the old upload-to-/tmp
+	// and `tar -xf` path is kept for callers that explicitly ask for it
+	if request.GetBool("extract_in_container", false) {
+		progress := newProgressReporter(ctx, request, phaseArchive, phaseUpload, phaseExtract)
+		hasTar, err := containerHasCommand(ctx, containerIDOrName, "tar")
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error checking for tar in container: %v", err)), nil
+		}
+		if hasTar {
+			skipped, err := copyProjectViaExtract(ctx, progress, containerIDOrName, localSrcDir, destDir, includeJunk)
+			if err != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+			}
+			progress.Done("Project copied")
+			return copyProjectResult(fmt.Sprintf("Successfully copied %s to %s in container %s (working directory %s)", localSrcDir, destDir, containerIDOrName, workDir), skipped, prepared), nil
 		}
+		// Images without tar can't extract in the container, so let the Docker API do it
+		skipped, err := copyProjectDirect(ctx, progress, containerIDOrName, localSrcDir, destDir, includeJunk)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err)), nil
+		}
+		progress.Done("Project copied")
+		return copyProjectResult(fmt.Sprintf("Successfully copied %s to %s in container %s (working directory %s; tar not available, extracted via the Docker API)", localSrcDir, destDir, containerIDOrName, workDir), skipped, prepared), nil
+	}
+
+	progress := newProgressReporter(ctx, request, phaseArchive, phaseUpload)
+	skipped, err := copyProjectDirect(ctx, progress, containerIDOrName, localSrcDir, destDir, includeJunk)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err)), nil
+	}
+	progress.Done("Project copied")
+
+	return copyProjectResult(fmt.Sprintf("Successfully copied %s to %s in container %s (working directory %s)", localSrcDir, destDir, containerIDOrName, workDir), skipped, prepared), nil
+}
+
+// copyProjectResult is the copy_project result text, noting the junk entries left out and the overwrite policy
+func copyProjectResult(message string, skipped int, prepared preparedDestination) *mcp.CallToolResult {
+	if note := junkSkippedNote(skipped); note != "" {
+		message += "\n" + note
 	}
+	message += "\n" + prepared.String()
+	return mcp.NewToolResultText(message)
+}
 
+// copyProjectViaExtract uploads the archive to /tmp, extracts it with tar inside the container and removes the
+// tarball. It returns the number of junk entries left out.
+func copyProjectViaExtract(ctx context.Context, progress *progressReporter, containerIDOrName string, srcPath string, destDir string, includeJunk bool) (int, error) {
 	// Create tar archive of the source directory
-	tarBuffer, err := createTarArchive(localSrcDir)
+	progress.Phase(phaseArchive, fmt.Sprintf("Archiving %s", srcPath))
+	tarBuffer, skipped, err := createTarArchive(srcPath, filepath.Base(srcPath), includeJunk)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error creating tar archive: %v", err)), nil
+		return 0, fmt.Errorf("failed to create tar archive: %w", err)
 	}
 
 	// Create a temporary file name for the tar archive in the container
-	tarFileName := filepath.Join("/tmp", fmt.Sprintf("project_%s.tar", filepath.Base(localSrcDir)))
+	tarFileName := filepath.Join("/tmp", fmt.Sprintf("project_%s.tar", filepath.Base(srcPath)))
 
 	// Copy the tar archive to the container's temp directory
-	err = copyTarToContainer(ctx, containerIDOrName, "/tmp", tarBuffer)
-	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err)), nil
+	progress.Phase(phaseUpload, "Uploading the archive")
+	if err := copyTarToContainer(ctx, containerIDOrName, "/tmp", tarBuffer); err != nil {
+		return 0, fmt.Errorf("failed to copy to container: %w", err)
 	}
 
 	// Extract the tar archive in the container
-	err = extractTarInContainer(ctx, containerIDOrName, tarFileName, destDir)
-	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error extracting archive in container: %v", err)), nil
+	progress.Phase(phaseExtract, fmt.Sprintf("Extracting into %s", destDir))
+	if err := extractTarInContainer(ctx, containerIDOrName, tarFileName, destDir); err != nil {
+		return 0, fmt.Errorf("failed to extract archive in container: %w", err)
 	}
 
 	// Clean up the temporary tar file
@@ -80,23 +154,73 @@ func CopyProject(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 		fmt.Printf("Warning: Failed to clean up temporary tar file: %v\n", err)
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s to %s in container %s", localSrcDir, destDir, containerIDOrName)), nil
+	return skipped, nil
+}
+
+// copyProjectDirect uploads the project with entries rooted at the container's filesystem root,
+// so CopyToContainer extracts it (creating missing parent directories) without running anything in the container.
+// The resulting layout matches the in-container extraction: destDir/<basename>/... It returns the number of junk
+// entries left out.
+func copyProjectDirect(ctx context.Context, progress *progressReporter, containerIDOrName string, srcPath string, destDir string, includeJunk bool) (int, error) {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	progress.Phase(phaseArchive, fmt.Sprintf("Archiving %s", srcPath))
+	prefix := strings.TrimPrefix(path.Join(filepath.ToSlash(destDir), filepath.Base(srcPath)), "/")
+	tarArchive, skipped, err := createTarArchive(srcPath, prefix, includeJunk)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tar archive: %w", err)
+	}
+
+	progress.Phase(phaseUpload, "Uploading the archive")
+	if err := cli.CopyToContainer(ctx, containerIDOrName, "/", tarArchive, container.CopyToContainerOptions{}); err != nil {
+		return 0, fmt.Errorf("failed to copy to container: %w", err)
+	}
+
+	return skipped, nil
 }
 
-// createTarArchive creates a tar archive of the specified source path
-func createTarArchive(srcPath string) (io.Reader, error) {
+// createTarArchive creates a tar archive of the specified source path with every entry placed under prefix.
+// Unless includeJunk is set, entries named in junkNames are left out, directories with their contents; the
+// number left out is returned.
+func createTarArchive(srcPath string, prefix string, includeJunk bool) (io.Reader, int, error) {
 	buf := new(bytes.Buffer)
 	tw := tar.NewWriter(buf)
 	defer tw.Close()
 
+	_, skipped, err := writeTarTree(tw, srcPath, prefix, includeJunk)
+	if err != nil {
+		return nil, 0, err
+	}
+	return buf, skipped, nil
+}
+
+// writeTarTree adds the entries of srcPath to an archive under prefix, like createTarArchive, and returns the
+// number of regular files written and of junk entries left out
+func writeTarTree(tw *tar.Writer, srcPath string, prefix string, includeJunk bool) (int, int, error) {
 	srcPath = filepath.Clean(srcPath)
-	baseDir := filepath.Base(srcPath)
 
+	files, skipped := 0, 0
 	err := filepath.Walk(srcPath, func(file string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if !includeJunk && file != srcPath && isJunkName(fi.Name()) {
+			skipped++
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Create tar header
 		header, err := tar.FileInfoHeader(fi, fi.Name())
 		if err != nil {
@@ -114,7 +238,7 @@ func createTarArchive(srcPath string) (io.Reader, error) {
 			return nil
 		}
 
-		header.Name = filepath.Join(baseDir, relPath)
+		header.Name = path.Join(prefix, filepath.ToSlash(relPath))
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -131,15 +255,16 @@ func createTarArchive(srcPath string) (io.Reader, error) {
 			if _, err := io.Copy(tw, f); err != nil {
 				return err
 			}
+			files++
 		}
 		return nil
 	})
 
 	if err != nil {
-		return nil, err
+		return 0, 0, err
 	}
 
-	return buf, nil
+	return files, skipped, nil
 }
 
 // copyTarToContainer copies a tar archive to a container
@@ -147,6 +272,7 @@ func copyTarToContainer(ctx context.Context, containerIDOrName string, destPath
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -196,6 +322,7 @@ func executeCommandAndWait(ctx context.Context, containerIDOrName string, cmd []
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -219,20 +346,5 @@ func executeCommandAndWait(ctx context.Context, containerIDOrName string, cmd []
 	}
 
 	// Wait for the command to complete
-	for {
-		inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
-		if err != nil {
-			return fmt.Errorf("failed to inspect exec: %w", err)
-		}
-		if !inspect.Running {
-			if inspect.ExitCode != 0 {
-				return fmt.Errorf("command exited with code %d", inspect.ExitCode)
-			}
-			break
-		}
-		// Small sleep to avoid hammering the Docker API
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	return nil
+	return waitForExec(ctx, cli, exec.ID)
 }
@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readTarEntries returns the headers of an archive keyed by entry name
+func readTarEntries(t *testing.T, r io.Reader) map[string]*tar.Header {
+	t.Helper()
+	entries := map[string]*tar.Header{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		entries[header.Name] = header
+	}
+	return entries
+}
+
+func TestCreateTarArchiveDirectLayout(t *testing.T) {
+	project := writeProjectFixture(t)
+
+	archive, skipped, err := createTarArchive(project, "app/proj/proj", false)
+	require.NoError(t, err)
+	assert.Zero(t, skipped)
+	entries := readTarEntries(t, archive)
+
+	// Same layout the in-container `tar -xf -C /app/proj` produced: /app/proj/proj/...
+	require.Contains(t, entries, "app/proj/proj/main.py")
+	require.Contains(t, entries, "app/proj/proj/pkg")
+	require.Contains(t, entries, "app/proj/proj/pkg/sub")
+	require.Contains(t, entries, "app/proj/proj/pkg/sub/run.sh")
+	assert.NotContains(t, entries, "app/proj/proj", "the source root itself is not an entry")
+
+	assert.Equal(t, byte(tar.TypeDir), entries["app/proj/proj/pkg/sub"].Typeflag)
+	assert.Equal(t, int64(0755), entries["app/proj/proj/pkg/sub/run.sh"].Mode&0777)
+	assert.Equal(t, int64(0644), entries["app/proj/proj/main.py"].Mode&0777)
+}
+
+func TestCreateTarArchiveExtractLayout(t *testing.T) {
+	project := writeProjectFixture(t)
+
+	archive, _, err := createTarArchive(project, "proj", false)
+	require.NoError(t, err)
+	entries := readTarEntries(t, archive)
+
+	require.Contains(t, entries, "proj/main.py")
+	require.Contains(t, entries, "proj/pkg/sub/run.sh")
+}
+
+func TestCreateTarArchiveSkipsJunk(t *testing.T) {
+	project := writeProjectFixture(t)
+	junk := []string{
+		".DS_Store",
+		"pkg/._main.py",
+		"Thumbs.db",
+		"pkg/desktop.ini",
+		"pkg/__pycache__/util.cpython-312.pyc",
+		"pkg/__pycache__/nested/deeper.pyc",
+		"legacy.pyc",
+		".pytest_cache/v/cache/lastfailed",
+		".mypy_cache/3.12/main.meta.json",
+		".ruff_cache/CACHEDIR.TAG",
+		".main.py.swp",
+		"pkg/sub/.run.sh.swo",
+		"main.py~",
+		".#main.py",
+	}
+	for _, name := range junk {
+		p := filepath.Join(project, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte("junk"), 0644))
+	}
+	// Only whole segments match
+	require.NoError(t, os.WriteFile(filepath.Join(project, "pkg", "not__pycache__.py"), []byte("keep"), 0644))
+
+	archive, skipped, err := createTarArchive(project, "proj", false)
+	require.NoError(t, err)
+	entries := readTarEntries(t, archive)
+	for name := range entries {
+		assert.False(t, isJunkPath(name), "junk entry %s was archived", name)
+	}
+	assert.Contains(t, entries, "proj/main.py")
+	assert.Contains(t, entries, "proj/pkg/sub/run.sh")
+	assert.Contains(t, entries, "proj/pkg/not__pycache__.py")
+	assert.NotContains(t, entries, "proj/pkg/__pycache__")
+	// Each skipped directory counts once, whatever it holds
+	assert.Equal(t, len(junk)-1, skipped)
+
+	archive, skipped, err = createTarArchive(project, "proj", true)
+	require.NoError(t, err)
+	entries = readTarEntries(t, archive)
+	assert.Zero(t, skipped)
+	for _, name := range junk {
+		assert.Contains(t, entries, "proj/"+name)
+	}
+}
+
+// writeSecondRoot adds a second fixture root next to the one writeProjectFixture made
+func writeSecondRoot(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "shared")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lib", "util.py"), []byte("def util(): pass\n"), 0644))
+	return root
+}
+
+func TestParseSourcesArgument(t *testing.T) {
+	project := writeProjectFixture(t)
+	shared := writeSecondRoot(t)
+
+	sources, err := parseSourcesArgument([]any{
+		map[string]any{"src": project, "dest": "packages/api"},
+		map[string]any{"src": shared},
+		map[string]any{"src": shared, "dest": "/opt/shared"},
+	}, "/app")
+	require.NoError(t, err)
+	require.Len(t, sources, 3)
+	assert.Equal(t, copySource{Src: project, Dest: "/app/packages/api"}, sources[0])
+	assert.Equal(t, "/app/shared", sources[1].Dest, "dest defaults to the basename of src")
+	assert.Equal(t, "/opt/shared", sources[2].Dest)
+
+	file := filepath.Join(project, "main.py")
+	for _, tc := range []struct {
+		value any
+		want  string
+	}{
+		{nil, "non-empty array"},
+		{[]any{}, "non-empty array"},
+		{[]any{"proj"}, "sources[0] must be an object"},
+		{[]any{map[string]any{"dest": "x"}}, "sources[0].src must name a directory"},
+		{[]any{map[string]any{"src": file}}, "must be a directory"},
+		{[]any{map[string]any{"src": filepath.Join(project, "missing")}}, "sources[0].src"},
+		{[]any{map[string]any{"src": project, "dest": "/"}}, "below /"},
+		{[]any{map[string]any{"src": project, "dest": "app"}, map[string]any{"src": shared, "dest": "app"}}, "DESTINATION_OVERLAP: sources[0].dest /app/app and sources[1].dest /app/app overlap"},
+		{[]any{map[string]any{"src": project, "dest": "packages"}, map[string]any{"src": shared, "dest": "packages/shared"}}, "DESTINATION_OVERLAP"},
+		{[]any{map[string]any{"src": project, "dest": "packages/api"}, map[string]any{"src": shared, "dest": "packages"}}, "DESTINATION_OVERLAP"},
+	} {
+		_, err := parseSourcesArgument(tc.value, "/app")
+		assert.ErrorContains(t, err, tc.want, "%v", tc.value)
+	}
+
+	// Siblings sharing a prefix don't overlap
+	_, err = parseSourcesArgument([]any{
+		map[string]any{"src": project, "dest": "pkg"},
+		map[string]any{"src": shared, "dest": "pkg2"},
+	}, "/app")
+	assert.NoError(t, err)
+}
+
+func TestParseSourcesArgumentForbidden(t *testing.T) {
+	home := withForbiddenHome(t)
+	_, err := parseSourcesArgument([]any{
+		map[string]any{"src": filepath.Join(home, "project")},
+		map[string]any{"src": filepath.Join(home, ".claude")},
+	}, "/app")
+	var forbidden *ForbiddenPathError
+	assert.True(t, errors.As(err, &forbidden), "expected FORBIDDEN_PATH, got %v", err)
+	assert.ErrorContains(t, err, "sources[1].src")
+}
+
+func TestCreateSourcesArchive(t *testing.T) {
+	project := writeProjectFixture(t)
+	shared := writeSecondRoot(t)
+	sources := []copySource{
+		{Src: project, Dest: "/app/packages/api"},
+		{Src: shared, Dest: "/app/packages/shared"},
+	}
+
+	archive, skipped, err := createSourcesArchive(sources, false)
+	require.NoError(t, err)
+	assert.Zero(t, skipped)
+	assert.Equal(t, 2, sources[0].Files)
+	assert.Equal(t, 1, sources[1].Files)
+
+	entries := readTarEntries(t, archive)
+	assert.Contains(t, entries, "app/packages/api/main.py")
+	assert.Contains(t, entries, "app/packages/api/pkg/sub/run.sh")
+	assert.Contains(t, entries, "app/packages/shared/lib/util.py")
+	// Each root lands in its own destination and nowhere else
+	assert.NotContains(t, entries, "app/packages/api/lib/util.py")
+	assert.NotContains(t, entries, "app/packages/shared/main.py")
+	for name := range entries {
+		assert.Regexp(t, `^app/packages/(api|shared)(/|$)`, name)
+	}
+
+	assert.Equal(t, "Successfully copied 2 directories to container mcp-x (working directory /app):\n"+
+		"- "+project+" -> /app/packages/api (2 files)\n"+
+		"- "+shared+" -> /app/packages/shared (1 file)", copySourcesMessage(sources, "mcp-x", "/app"))
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// copySource is one root of a copy_project call with sources: a directory on the host and the directory of the
+// container its contents land in
+type copySource struct {
+	Src  string
+	Dest string
+	// Files counts the regular files the archive holds for this root
+	Files int
+}
+
+// parseSourcesArgument parses the sources argument of copy_project, an array of {src, dest} objects. Each src is
+// checked on its own like local_src_dir; dest defaults to the basename of src and is relative to the working
+// directory. Destinations that are the same or nest inside one another are refused before anything is read.
+func parseSourcesArgument(value any, workDir string) ([]copySource, error) {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("sources must be a non-empty array of {src, dest} objects")
+	}
+
+	sources := make([]copySource, 0, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("sources[%d] must be an object with src and an optional dest", i)
+		}
+		src, _ := obj["src"].(string)
+		if src == "" {
+			return nil, fmt.Errorf("sources[%d].src must name a directory", i)
+		}
+		src = translateHostPath(src)
+		if err := checkHostPath(src); err != nil {
+			return nil, fmt.Errorf("sources[%d].src: %w", i, err)
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d].src: %v", i, localPathError(err))
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("sources[%d].src %s must be a directory", i, src)
+		}
+
+		dest, _ := obj["dest"].(string)
+		if dest == "" {
+			dest = filepath.Base(src)
+		}
+		dest = inWorkDir(workDir, dest)
+		if dest == "/" {
+			return nil, fmt.Errorf("sources[%d].dest must name a directory below /", i)
+		}
+		for j, other := range sources {
+			if destinationsOverlap(dest, other.Dest) {
+				return nil, fmt.Errorf("DESTINATION_OVERLAP: sources[%d].dest %s and sources[%d].dest %s overlap; give each root a destination of its own", j, other.Dest, i, dest)
+			}
+		}
+		sources = append(sources, copySource{Src: src, Dest: dest})
+	}
+	return sources, nil
+}
+
+// destinationsOverlap reports whether two container directories are the same or one lies inside the other
+func destinationsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// createSourcesArchive puts every root in one archive rooted at the container's filesystem root, each under its
+// destination, and fills in the file count of each. It returns the number of junk entries left out.
+func createSourcesArchive(sources []copySource, includeJunk bool) (io.Reader, int, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	skipped := 0
+	for i := range sources {
+		files, rootSkipped, err := writeTarTree(tw, sources[i].Src, strings.TrimPrefix(sources[i].Dest, "/"), includeJunk)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", sources[i].Src, err)
+		}
+		sources[i].Files = files
+		skipped += rootSkipped
+	}
+	if err := tw.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf, skipped, nil
+}
+
+// copySources is copy_project with sources: the roots are checked, their destinations prepared under the
+// overwrite policy, and all of them uploaded in a single CopyToContainer call
+func copySources(ctx context.Context, request mcp.CallToolRequest, containerIDOrName string, value any) *mcp.CallToolResult {
+	if request.GetBool("extract_in_container", false) {
+		return mcp.NewToolResultText("Error: extract_in_container only applies to local_src_dir; sources are always extracted by the Docker API")
+	}
+	if request.GetString("dest_dir", "") != "" {
+		return mcp.NewToolResultText("Error: dest_dir only applies to local_src_dir; give each of the sources its own dest")
+	}
+
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err))
+	}
+	sources, err := parseSourcesArgument(value, workDir)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err))
+	}
+
+	// Check every destination before the first one is touched, so replace doesn't clear some roots and then
+	// refuse another
+	policy, err := parseOverwritePolicy(request.GetString("overwrite_policy", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err))
+	}
+	if policy == overwriteReplace {
+		for i, source := range sources {
+			if err := removableDestination(source.Dest, workDir); err != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("Error: sources[%d]: %v", i, err))
+			}
+		}
+	}
+	prepared := preparedDestination{Policy: policy}
+	for _, source := range sources {
+		root, err := prepareDestination(ctx, containerIDOrName, source.Dest, workDir, policy)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err))
+		}
+		prepared.Removed += root.Removed
+	}
+
+	progress := newProgressReporter(ctx, request, phaseArchive, phaseUpload)
+	progress.Phase(phaseArchive, fmt.Sprintf("Archiving %d directories", len(sources)))
+	archive, skipped, err := createSourcesArchive(sources, request.GetBool("include_junk", false))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create tar archive: %v", err))
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create Docker client: %v", err))
+	}
+	defer cli.Close()
+	progress.Phase(phaseUpload, "Uploading the archive")
+	if err := cli.CopyToContainer(ctx, containerIDOrName, "/", archive, container.CopyToContainerOptions{}); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err))
+	}
+	progress.Done("Project copied")
+
+	return copyProjectResult(copySourcesMessage(sources, containerIDOrName, workDir), skipped, prepared)
+}
+
+// copySourcesMessage lists each root with where it went and its file count
+func copySourcesMessage(sources []copySource, containerIDOrName, workDir string) string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "Successfully copied %d directories to container %s (working directory %s):", len(sources), containerIDOrName, workDir)
+	for _, source := range sources {
+		files := "files"
+		if source.Files == 1 {
+			files = "file"
+		}
+		fmt.Fprintf(&message, "\n- %s -> %s (%d %s)", source.Src, source.Dest, source.Files, files)
+	}
+	return message.String()
+}
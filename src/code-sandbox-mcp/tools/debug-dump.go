@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// debugDumpResult is the result of sandbox_debug_dump
+type debugDumpResult struct {
+	Count   int                 `json:"count"`
+	Entries []dockerdebug.Entry `json:"entries"`
+}
+
+// DebugDump returns the Docker API calls recorded by --debug-docker, oldest first, for attaching to a bug report
+func DebugDump(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !dockerdebug.Enabled() {
+		return mcp.NewToolResultText("Error: Docker API calls are only recorded when the server runs with --debug-docker"), nil
+	}
+	limit := request.GetInt("limit", 50)
+	if limit < 1 {
+		return mcp.NewToolResultText("Error: limit must be at least 1"), nil
+	}
+
+	entries := dockerdebug.Entries()
+	entries = entries[max(len(entries)-limit, 0):]
+	jsonData, err := json.Marshal(debugDumpResult{Count: len(entries), Entries: entries})
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize Docker API calls: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// frameHeaderLen is the length of the header Docker puts before each frame of a multiplexed stream
+const frameHeaderLen = 8
+
+// DemuxOutput splits a multiplexed Docker stream into stdout and stderr with stdcopy. A stream that turns out
+// not to be multiplexed, such as the output of a TTY or bytes left over from a read cut short, makes stdcopy fail
+// with "Unrecognized input header"; the bytes it hadn't split are then copied to stdout unchanged, and the
+// returned warning says so instead of the output being lost. Other errors, such as an error the daemon sent in
+// the stream, are returned.
+func DemuxOutput(stdout, stderr io.Writer, stream io.Reader) (warning string, err error) {
+	raw := &rawRecorder{src: stream}
+	_, err = stdcopy.StdCopy(frameCounter{raw, stdout}, frameCounter{raw, stderr}, raw)
+	switch {
+	case err != nil && strings.HasPrefix(err.Error(), "Unrecognized input header"):
+		n, copyErr := io.Copy(stdout, io.MultiReader(&raw.pending, stream))
+		if copyErr != nil {
+			return "", fmt.Errorf("failed to read output: %w", copyErr)
+		}
+		return fmt.Sprintf("output could not be split into stdout and stderr (%v); %d bytes are shown unsplit on stdout", err, n), nil
+	case err != nil:
+		return "", err
+	case raw.pending.Len() > frameHeaderLen:
+		// The stream ended in the middle of a frame, which stdcopy drops
+		partial := raw.pending.Bytes()
+		out := stdout
+		if stdcopy.StdType(partial[0]) == stdcopy.Stderr {
+			out = stderr
+		}
+		n, _ := out.Write(partial[frameHeaderLen:])
+		return fmt.Sprintf("output ended in the middle of a frame; its last %d bytes are shown as received", n), nil
+	}
+	return "", nil
+}
+
+// rawRecorder keeps the bytes stdcopy has read but not yet written as a frame
+type rawRecorder struct {
+	src     io.Reader
+	pending bytes.Buffer
+}
+
+func (r *rawRecorder) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	r.pending.Write(p[:n])
+	return n, err
+}
+
+// frameCounter is a demultiplexed side of the stream; stdcopy writes each frame in a single Write, after which
+// the frame's bytes are no longer pending
+type frameCounter struct {
+	raw *rawRecorder
+	out io.Writer
+}
+
+func (w frameCounter) Write(p []byte) (int, error) {
+	w.raw.pending.Next(frameHeaderLen + len(p))
+	return w.out.Write(p)
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multiplexed writes frames the way the Docker daemon does for a non-TTY container
+func multiplexed(t *testing.T, frames ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, f := range frames {
+		stream := stdcopy.Stdout
+		if f[0] == "stderr" {
+			stream = stdcopy.Stderr
+		}
+		_, err := stdcopy.NewStdWriter(&buf, stream).Write([]byte(f[1]))
+		require.NoError(t, err)
+	}
+	return buf.Bytes()
+}
+
+// oneByteReader returns a byte per Read, so frames arrive split across reads
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+func TestDemuxOutput(t *testing.T) {
+	stream := multiplexed(t, [2]string{"stdout", "hello\n"}, [2]string{"stderr", "oops\n"}, [2]string{"stdout", ""}, [2]string{"stdout", "bye\n"})
+	for name, reader := range map[string]io.Reader{
+		"whole":        bytes.NewReader(stream),
+		"byte by byte": oneByteReader{bytes.NewReader(stream)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			warning, err := DemuxOutput(&stdout, &stderr, reader)
+			require.NoError(t, err)
+			assert.Empty(t, warning)
+			assert.Equal(t, "hello\nbye\n", stdout.String())
+			assert.Equal(t, "oops\n", stderr.String())
+		})
+	}
+}
+
+func TestDemuxOutputFallsBackToRawStream(t *testing.T) {
+	// A TTY stream has no frame headers at all
+	var stdout, stderr bytes.Buffer
+	raw := "\x1b[32mok\x1b[0m\r\nplain output\r\n"
+	warning, err := DemuxOutput(&stdout, &stderr, oneByteReader{strings.NewReader(raw)})
+	require.NoError(t, err)
+	assert.Equal(t, raw, stdout.String())
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, "output could not be split into stdout and stderr (Unrecognized input header: 27); 27 bytes are shown unsplit on stdout", warning)
+
+	// Frames that were split are kept; the bytes from the unrecognized header on are shown as they are
+	stdout.Reset()
+	stream := append(multiplexed(t, [2]string{"stdout", "first\n"}, [2]string{"stderr", "warn\n"}), "garbage after a cut\n"...)
+	warning, err = DemuxOutput(&stdout, &stderr, bytes.NewReader(stream))
+	require.NoError(t, err)
+	assert.Equal(t, "first\ngarbage after a cut\n", stdout.String())
+	assert.Equal(t, "warn\n", stderr.String())
+	assert.Contains(t, warning, "20 bytes are shown unsplit on stdout")
+}
+
+func TestDemuxOutputKeepsTruncatedFrame(t *testing.T) {
+	stream := multiplexed(t, [2]string{"stdout", "done\n"}, [2]string{"stderr", "Traceback (most recent call last):\n"})
+	var stdout, stderr bytes.Buffer
+	warning, err := DemuxOutput(&stdout, &stderr, bytes.NewReader(stream[:len(stream)-10]))
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", stdout.String())
+	assert.Equal(t, "Traceback (most recent ca", stderr.String())
+	assert.Equal(t, "output ended in the middle of a frame; its last 25 bytes are shown as received", warning)
+}
+
+func TestDemuxOutputReturnsDaemonErrors(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := stdcopy.NewStdWriter(&buf, stdcopy.Systemerr).Write([]byte("container gone"))
+	require.NoError(t, err)
+	_, err = DemuxOutput(io.Discard, io.Discard, &buf)
+	assert.EqualError(t, err, "error from daemon in stream: container gone")
+
+	_, err = DemuxOutput(io.Discard, io.Discard, io.MultiReader(bytes.NewReader(multiplexed(t, [2]string{"stdout", "x"})), errReader{}))
+	assert.EqualError(t, err, "connection reset")
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
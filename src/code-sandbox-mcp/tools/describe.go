@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// secretEnvPattern matches variable names whose values are redacted even when they didn't come from an env_file
+var secretEnvPattern = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|PRIVATE_?KEY|CREDENTIAL)`)
+
+// SandboxDescription is the curated subset of a container's inspect data returned by sandbox_describe
+type SandboxDescription struct {
+	ContainerID  string            `json:"container_id"`
+	Name         string            `json:"name"`
+	Image        string            `json:"image"`
+	ImageID      string            `json:"image_id"`
+	ImageDigests []string          `json:"image_digests,omitempty"`
+	Created      string            `json:"created"`
+	State        SandboxState      `json:"state"`
+	WorkingDir   string            `json:"working_dir"`
+	Env          []string          `json:"env"`
+	Mounts       []SandboxMount    `json:"mounts"`
+	Resources    SandboxResources  `json:"resources"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// SandboxState is the run state of a sandbox container
+type SandboxState struct {
+	Status       string `json:"status"`
+	Running      bool   `json:"running"`
+	ExitCode     int    `json:"exit_code"`
+	OOMKilled    bool   `json:"oom_killed"`
+	StartedAt    string `json:"started_at,omitempty"`
+	IdleSeconds  *int64 `json:"idle_seconds,omitempty"` // since a tool last used it; only while running
+	FinishedAt   string `json:"finished_at,omitempty"`
+	RestartCount int    `json:"restart_count"`
+	Error        string `json:"error,omitempty"`
+}
+
+// SandboxMount is one volume or bind mount of a sandbox
+type SandboxMount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadWrite   bool   `json:"read_write"`
+}
+
+// SandboxResources are the resource limits of a sandbox; zero means unlimited
+type SandboxResources struct {
+	MemoryBytes     int64               `json:"memory_bytes"`
+	MemorySwapBytes int64               `json:"memory_swap_bytes"`
+	NanoCPUs        int64               `json:"nano_cpus"`
+	PidsLimit       int64               `json:"pids_limit"`
+	Ulimits         []*container.Ulimit `json:"ulimits,omitempty"`
+}
+
+// DescribeSandbox returns the image digest, environment, working directory, mounts, limits and state of a container
+func DescribeSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = lookupContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return nil, fmt.Errorf("DOCKER_CLIENT_ERROR: failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to inspect container: %v", err)), nil
+	}
+
+	// The digests pin the exact image for a reproducible sandbox; locally built images have none
+	var digests []string
+	if img, err := cli.ImageInspect(ctx, inspect.Image); err == nil {
+		digests = img.RepoDigests
+	}
+
+	jsonData, err := json.Marshal(describeContainer(inspect, digests, time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize sandbox description: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// describeContainer builds the sandbox description from inspect data, redacting secret env values
+func describeContainer(inspect container.InspectResponse, digests []string, now time.Time) SandboxDescription {
+	desc := SandboxDescription{
+		ImageDigests: digests,
+		Env:          []string{},
+		Mounts:       []SandboxMount{},
+	}
+	if inspect.ContainerJSONBase != nil {
+		desc.ContainerID = inspect.ID
+		desc.Name = strings.TrimPrefix(inspect.Name, "/")
+		desc.ImageID = inspect.Image
+		desc.Created = inspect.Created
+		desc.State.RestartCount = inspect.RestartCount
+		if state := inspect.State; state != nil {
+			desc.State.Status = state.Status
+			desc.State.Running = state.Running
+			desc.State.ExitCode = state.ExitCode
+			desc.State.OOMKilled = state.OOMKilled
+			desc.State.StartedAt = state.StartedAt
+			desc.State.Error = state.Error
+			if state.Running {
+				desc.State.IdleSeconds = idleSeconds(state.StartedAt, now, desc.Name, desc.ContainerID)
+			} else {
+				desc.State.FinishedAt = state.FinishedAt
+			}
+		}
+		if hostConfig := inspect.HostConfig; hostConfig != nil {
+			desc.Resources = SandboxResources{
+				MemoryBytes:     hostConfig.Memory,
+				MemorySwapBytes: hostConfig.MemorySwap,
+				NanoCPUs:        hostConfig.NanoCPUs,
+				Ulimits:         hostConfig.Ulimits,
+			}
+			if hostConfig.PidsLimit != nil {
+				desc.Resources.PidsLimit = *hostConfig.PidsLimit
+			}
+		}
+	}
+
+	if config := inspect.Config; config != nil {
+		desc.Image = config.Image
+		desc.WorkingDir = config.WorkingDir
+		desc.Labels = config.Labels
+		for _, kv := range config.Env {
+			desc.Env = append(desc.Env, redactEnv(desc.ContainerID, kv))
+		}
+	}
+
+	for _, m := range inspect.Mounts {
+		desc.Mounts = append(desc.Mounts, SandboxMount{
+			Type:        string(m.Type),
+			Source:      m.Source,
+			Destination: m.Destination,
+			ReadWrite:   m.RW,
+		})
+	}
+
+	return desc
+}
+
+// redactEnv masks the value of a KEY=VALUE pair that came from an env_file or whose name looks secret
+func redactEnv(containerID string, kv string) string {
+	key, value, found := strings.Cut(kv, "=")
+	if !found || value == "" {
+		return kv
+	}
+	if secretEnvPattern.MatchString(key) || isSecretKey(containerID, key) || redactSecrets(containerID, value) != value {
+		return key + "=[REDACTED]"
+	}
+	return kv
+}
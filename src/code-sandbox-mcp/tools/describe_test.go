@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeContainer(t *testing.T) {
+	id := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	registerSecrets(id, "described", []string{"DB_URL", "PIN"}, []string{"from-env-file", "1234"})
+	t.Cleanup(func() { forgetSecrets(id) })
+
+	pids := int64(128)
+	inspect := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:           id,
+			Name:         "/described",
+			Image:        "sha256:abc",
+			Created:      "2026-01-02T03:04:05Z",
+			RestartCount: 2,
+			State:        &container.State{Status: "running", Running: true, StartedAt: "2026-01-02T03:04:06Z", FinishedAt: "0001-01-01T00:00:00Z"},
+			HostConfig: &container.HostConfig{Resources: container.Resources{
+				Memory:     64 * 1024 * 1024,
+				MemorySwap: 64 * 1024 * 1024,
+				PidsLimit:  &pids,
+			}},
+		},
+		Config: &container.Config{
+			Image:      "alpine:latest",
+			WorkingDir: "/app",
+			Env:        []string{"PATH=/usr/bin", "DB_URL=from-env-file", "PIN=1234", "GITHUB_TOKEN=ghp_x", "EMPTY_SECRET="},
+			Labels:     map[string]string{SandboxLabel: "true"},
+		},
+		Mounts: []container.MountPoint{{Type: mount.TypeBind, Source: "/host/data", Destination: "/data", RW: false}},
+	}
+
+	desc := describeContainer(inspect, []string{"alpine@sha256:def"}, time.Date(2026, 1, 2, 3, 5, 6, 0, time.UTC))
+	assert.Equal(t, id, desc.ContainerID)
+	assert.Equal(t, "described", desc.Name)
+	assert.Equal(t, "alpine:latest", desc.Image)
+	assert.Equal(t, []string{"alpine@sha256:def"}, desc.ImageDigests)
+	assert.Equal(t, "/app", desc.WorkingDir)
+	assert.Equal(t, []string{"PATH=/usr/bin", "DB_URL=[REDACTED]", "PIN=[REDACTED]", "GITHUB_TOKEN=[REDACTED]", "EMPTY_SECRET="}, desc.Env)
+	assert.Equal(t, []SandboxMount{{Type: "bind", Source: "/host/data", Destination: "/data", ReadWrite: false}}, desc.Mounts)
+	assert.Equal(t, int64(64*1024*1024), desc.Resources.MemoryBytes)
+	assert.Equal(t, int64(128), desc.Resources.PidsLimit)
+	idle := int64(60)
+	assert.Equal(t, SandboxState{Status: "running", Running: true, StartedAt: "2026-01-02T03:04:06Z", IdleSeconds: &idle, RestartCount: 2}, desc.State)
+}
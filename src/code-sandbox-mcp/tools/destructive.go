@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/confirm"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// createdBySandboxInitialize reports whether a container carries SandboxLabel; replaced in tests
+var createdBySandboxInitialize = func(ctx context.Context, containerIDOrName string) (bool, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return false, err
+	}
+	return inspect.Config != nil && inspect.Config.Labels[SandboxLabel] == "true", nil
+}
+
+// DestructiveAction describes the calls --confirm-destructive asks the user about: copying a file or exporting
+// logs out of a sandbox onto the host, and stopping a container that sandbox_initialize didn't create. Calls whose arguments
+// don't resolve are left for the tool to reject.
+func DestructiveAction(ctx context.Context, request mcp.CallToolRequest) *confirm.Action {
+	containerIDOrName := request.GetString("container_id_or_name", "")
+	if containerIDOrName == "" {
+		return nil
+	}
+	switch request.Params.Name {
+	case "copy_file_from_sandbox":
+		src := request.GetString("container_src_path", "")
+		dest := request.GetString("local_dest_path", "")
+		if dest == "" {
+			dest = filepath.Base(src)
+		}
+		return &confirm.Action{
+			Summary: fmt.Sprintf("copy %s from container %s onto the host", src, containerIDOrName),
+			Reason:  "Files from the sandbox are written to the host and replace any file already at the destination.",
+			Paths:   []string{filepath.Clean(dest)},
+		}
+	case "sandbox_logs_export":
+		dest := request.GetString("local_dest_path", "")
+		if dest == "" {
+			return nil
+		}
+		dest = filepath.Clean(dest)
+		// A TTY container is exported to dest even when the streams were to be split
+		paths := []string{dest}
+		if request.GetBool("split_streams", false) {
+			for _, f := range splitLogPaths(dest) {
+				paths = append(paths, f.Path)
+			}
+		}
+		return &confirm.Action{
+			Summary: fmt.Sprintf("export the logs of container %s onto the host", containerIDOrName),
+			Reason:  "The logs are written to the host and replace any file already at the destination.",
+			Paths:   paths,
+		}
+	case "sandbox_stop":
+		created, err := createdBySandboxInitialize(ctx, containerIDOrName)
+		if err != nil || created {
+			return nil
+		}
+		return &confirm.Action{
+			Summary: fmt.Sprintf("stop and remove container %s", containerIDOrName),
+			Reason:  "The container was not created by sandbox_initialize, so it may belong to something else on the Docker host.",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/confirm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestructiveAction(t *testing.T) {
+	original := createdBySandboxInitialize
+	t.Cleanup(func() { createdBySandboxInitialize = original })
+	createdBySandboxInitialize = func(ctx context.Context, containerIDOrName string) (bool, error) {
+		switch containerIDOrName {
+		case "sandbox-1":
+			return true, nil
+		case "postgres":
+			return false, nil
+		}
+		return false, errors.New("no such container")
+	}
+
+	ctx := context.Background()
+	assert.Equal(t, &confirm.Action{
+		Summary: "stop and remove container postgres",
+		Reason:  "The container was not created by sandbox_initialize, so it may belong to something else on the Docker host.",
+	}, DestructiveAction(ctx, newMockCallToolRequest("sandbox_stop", map[string]any{"container_id_or_name": "postgres"})))
+	assert.Equal(t, &confirm.Action{
+		Summary: "copy /app/out/report.csv from container sandbox-1 onto the host",
+		Reason:  "Files from the sandbox are written to the host and replace any file already at the destination.",
+		Paths:   []string{"report.csv"},
+	}, DestructiveAction(ctx, newMockCallToolRequest("copy_file_from_sandbox", map[string]any{"container_id_or_name": "sandbox-1", "container_src_path": "/app/out/report.csv"})))
+
+	assert.Equal(t, &confirm.Action{
+		Summary: "export the logs of container sandbox-1 onto the host",
+		Reason:  "The logs are written to the host and replace any file already at the destination.",
+		Paths:   []string{"/tmp/app.log"},
+	}, DestructiveAction(ctx, newMockCallToolRequest("sandbox_logs_export", map[string]any{"container_id_or_name": "sandbox-1", "local_dest_path": "/tmp/app.log"})))
+	assert.Equal(t, []string{"/tmp/app.log", "/tmp/app.stdout.log", "/tmp/app.stderr.log"},
+		DestructiveAction(ctx, newMockCallToolRequest("sandbox_logs_export", map[string]any{"container_id_or_name": "sandbox-1", "local_dest_path": "/tmp/./app.log", "split_streams": true})).Paths)
+
+	// Sandboxes, missing containers and other tools run unasked
+	assert.Nil(t, DestructiveAction(ctx, newMockCallToolRequest("sandbox_stop", map[string]any{"container_id_or_name": "sandbox-1"})))
+	assert.Nil(t, DestructiveAction(ctx, newMockCallToolRequest("sandbox_stop", map[string]any{"container_id_or_name": "gone"})))
+	assert.Nil(t, DestructiveAction(ctx, newMockCallToolRequest("sandbox_exec", map[string]any{"container_id_or_name": "postgres"})))
+}
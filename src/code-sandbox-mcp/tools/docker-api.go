@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+)
+
+// MinDockerAPIVersion is the oldest Docker Engine API (Docker 20.10) the server is tested against
+const MinDockerAPIVersion = "1.41"
+
+// apiFeatures maps the features that depend on newer API fields to the API version that introduced them.
+// On an older daemon they are refused up front instead of failing halfway through a call.
+var apiFeatures = map[string]string{
+	"cpu_limit":   "1.25", // HostConfig.NanoCPUs
+	"run_command": "1.30", // waiting for the next exit before the container starts
+}
+
+// dockerAPIPinger is the part of the Docker client used to negotiate the API version
+type dockerAPIPinger interface {
+	Ping(ctx context.Context) (types.Ping, error)
+	NegotiateAPIVersionPing(ping types.Ping)
+	ClientVersion() string
+}
+
+// DockerAPIStatus is the outcome of negotiating the API version with the daemon
+type DockerAPIStatus struct {
+	Version          string   `json:"negotiated_api_version,omitempty"`
+	MinVersion       string   `json:"min_api_version"`
+	Supported        bool     `json:"supported"`
+	DisabledFeatures []string `json:"disabled_features,omitempty"`
+}
+
+// dockerAPI holds the last negotiated status; an empty version means the daemon hasn't been reached yet
+var dockerAPI = struct {
+	sync.Mutex
+	status DockerAPIStatus
+}{status: DockerAPIStatus{MinVersion: MinDockerAPIVersion, Supported: true}}
+
+// newDockerAPIStatus works out what a daemon speaking the given API version supports
+func newDockerAPIStatus(version string) DockerAPIStatus {
+	status := DockerAPIStatus{
+		Version:    version,
+		MinVersion: MinDockerAPIVersion,
+		Supported:  !versions.LessThan(version, MinDockerAPIVersion),
+	}
+	for feature, minVersion := range apiFeatures {
+		if versions.LessThan(version, minVersion) {
+			status.DisabledFeatures = append(status.DisabledFeatures, feature)
+		}
+	}
+	sort.Strings(status.DisabledFeatures)
+	return status
+}
+
+// negotiateDockerAPI pings the daemon, negotiates the API version and records the result for the feature gates
+func negotiateDockerAPI(ctx context.Context, cli dockerAPIPinger) (DockerAPIStatus, error) {
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		return DockerAPIStatus{}, fmt.Errorf("failed to reach Docker daemon: %w", err)
+	}
+	cli.NegotiateAPIVersionPing(ping)
+	status := newDockerAPIStatus(cli.ClientVersion())
+
+	dockerAPI.Lock()
+	dockerAPI.status = status
+	dockerAPI.Unlock()
+	return status, nil
+}
+
+// CheckDockerAPI negotiates the API version with the daemon at startup and returns a warning when it is older
+// than the supported minimum; an unreachable daemon is reported by the tools once they are called
+func CheckDockerAPI(ctx context.Context) string {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return ""
+	}
+	defer cli.Close()
+
+	status, err := negotiateDockerAPI(ctx, cli)
+	if err != nil || status.Supported {
+		return ""
+	}
+	warning := fmt.Sprintf("Docker API %s is older than the minimum supported %s (Docker Engine 20.10); upgrade Docker Engine", status.Version, status.MinVersion)
+	if len(status.DisabledFeatures) > 0 {
+		warning += fmt.Sprintf(". Disabled: %s", strings.Join(status.DisabledFeatures, ", "))
+	}
+	return warning
+}
+
+// DockerAPIVersion returns the negotiated Docker API version, or "" before the daemon has been reached
+func DockerAPIVersion() string {
+	dockerAPI.Lock()
+	defer dockerAPI.Unlock()
+	return dockerAPI.status.Version
+}
+
+// requireAPIFeature returns an UNSUPPORTED_DOCKER_API error when the daemon is too old for the feature.
+// Until a version has been negotiated every feature is allowed, and the daemon has the final say.
+func requireAPIFeature(feature string) error {
+	version := DockerAPIVersion()
+	minVersion, gated := apiFeatures[feature]
+	if version == "" || !gated || !versions.LessThan(version, minVersion) {
+		return nil
+	}
+	return fmt.Errorf("UNSUPPORTED_DOCKER_API: %s needs Docker API %s, but the daemon speaks %s; upgrade Docker Engine", feature, minVersion, version)
+}
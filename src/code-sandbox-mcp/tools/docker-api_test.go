@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePinger negotiates like the Docker client: the lower of the daemon's and the client's API version
+type fakePinger struct {
+	daemonVersion string
+	err           error
+	version       string
+}
+
+func (f *fakePinger) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{APIVersion: f.daemonVersion}, f.err
+}
+
+func (f *fakePinger) NegotiateAPIVersionPing(ping types.Ping) {
+	f.version = "1.48"
+	if versions.LessThan(ping.APIVersion, f.version) {
+		f.version = ping.APIVersion
+	}
+}
+
+func (f *fakePinger) ClientVersion() string {
+	return f.version
+}
+
+// resetDockerAPI forgets the negotiated version once the test ends
+func resetDockerAPI(t *testing.T) {
+	t.Cleanup(func() {
+		dockerAPI.Lock()
+		dockerAPI.status = DockerAPIStatus{MinVersion: MinDockerAPIVersion, Supported: true}
+		dockerAPI.Unlock()
+	})
+}
+
+func TestNegotiateDockerAPI(t *testing.T) {
+	tests := []struct {
+		daemon    string
+		version   string
+		supported bool
+		disabled  []string
+	}{
+		{"1.47", "1.47", true, nil},
+		{"1.52", "1.48", true, nil},
+		{"1.41", "1.41", true, nil},
+		{"1.40", "1.40", false, nil},
+		{"1.29", "1.29", false, []string{"run_command"}},
+		{"1.24", "1.24", false, []string{"cpu_limit", "run_command"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.daemon, func(t *testing.T) {
+			resetDockerAPI(t)
+			status, err := negotiateDockerAPI(context.Background(), &fakePinger{daemonVersion: tt.daemon})
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, status.Version)
+			assert.Equal(t, tt.supported, status.Supported)
+			assert.Equal(t, tt.disabled, status.DisabledFeatures)
+			assert.Equal(t, tt.version, DockerAPIVersion())
+
+			for feature := range apiFeatures {
+				err := requireAPIFeature(feature)
+				if slices.Contains(tt.disabled, feature) {
+					require.Error(t, err, feature)
+					assert.Contains(t, err.Error(), "UNSUPPORTED_DOCKER_API: "+feature+" needs Docker API "+apiFeatures[feature])
+				} else {
+					assert.NoError(t, err, feature)
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiateDockerAPIUnreachable(t *testing.T) {
+	resetDockerAPI(t)
+	_, err := negotiateDockerAPI(context.Background(), &fakePinger{err: errors.New("connection refused")})
+	require.Error(t, err)
+	// Without a negotiated version the gates leave the decision to the daemon
+	assert.Empty(t, DockerAPIVersion())
+	assert.NoError(t, requireAPIFeature("run_command"))
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultPullTimeout bounds an image pull when SANDBOX_PULL_TIMEOUT isn't set
+const defaultPullTimeout = 10 * time.Minute
+
+// Deadlines of single Docker calls, so a stalled daemon socket fails the call instead of hanging it. They apply
+// within the deadline of the tool call, which still ends every call first.
+var (
+	containerCreateTimeout = 30 * time.Second
+	execAttachTimeout      = 10 * time.Second
+	pullTimeout            = defaultPullTimeout
+)
+
+// execPollInterval is how often a detached exec is inspected while waiting for it to exit
+var execPollInterval = 100 * time.Millisecond
+
+// LoadPullTimeoutFromEnv reads SANDBOX_PULL_TIMEOUT as a duration; 0 disables the timeout
+func LoadPullTimeoutFromEnv() error {
+	value := os.Getenv("SANDBOX_PULL_TIMEOUT")
+	if value == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return fmt.Errorf("invalid SANDBOX_PULL_TIMEOUT %q: must be a duration such as 5m", value)
+	}
+	pullTimeout = timeout
+	return nil
+}
+
+// CallTimeouts are the longest calls of the tools that pull an image or run a command with a timeout of their own
+// may take, for the call watchdog: the pull, the container setup and the command. A disabled pull timeout leaves
+// these calls without a deadline.
+func CallTimeouts() map[string]func(request mcp.CallToolRequest) time.Duration {
+	afterPull := func(d time.Duration) time.Duration {
+		if pullTimeout <= 0 {
+			return 0
+		}
+		return pullTimeout + containerCreateTimeout + d
+	}
+	return map[string]func(request mcp.CallToolRequest) time.Duration{
+		"sandbox_initialize": func(mcp.CallToolRequest) time.Duration {
+			return afterPull(0)
+		},
+		"run_command": func(request mcp.CallToolRequest) time.Duration {
+			// An invalid timeout_seconds is refused at once
+			timeout, _ := runCommandTimeout(request)
+			return afterPull(timeout)
+		},
+		"run_from_manifest": func(request mcp.CallToolRequest) time.Duration {
+			manifest, _ := parseManifestArgument(request)
+			return afterPull(manifestTimeout(manifest))
+		},
+		"check_code": func(mcp.CallToolRequest) time.Duration {
+			return afterPull(checkCodeTimeout)
+		},
+		"install_system_packages": func(mcp.CallToolRequest) time.Duration {
+			return systemPackagesTimeout
+		},
+	}
+}
+
+// withDockerTimeout derives the context of a single Docker call from the request context; a timeout of 0 leaves
+// it unbounded
+func withDockerTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// dockerTimeoutError names the operation that ran out of time when its own deadline, rather than the request's,
+// ended it
+func dockerTimeoutError(parent context.Context, op string, timeout time.Duration, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		return fmt.Errorf("%s did not finish within %s; the Docker daemon may be stalled: %w", op, timeout, err)
+	}
+	return err
+}
+
+// execInspector is the part of the Docker client used to wait for an exec
+type execInspector interface {
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+}
+
+// waitForExec polls a started exec until it exits, returning an error for a non-zero exit code. It gives up
+// as soon as ctx ends.
+func waitForExec(ctx context.Context, cli execInspector, execID string) error {
+	ticker := time.NewTicker(execPollInterval)
+	defer ticker.Stop()
+	for {
+		inspect, err := cli.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect exec: %w", err)
+		}
+		if !inspect.Running {
+			if inspect.ExitCode != 0 {
+				return fmt.Errorf("command exited with code %d", inspect.ExitCode)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
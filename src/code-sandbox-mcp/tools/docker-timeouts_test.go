@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// runningExec is an exec that never exits
+type runningExec struct{ inspections int }
+
+func (e *runningExec) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	e.inspections++
+	return container.ExecInspect{ExecID: execID, Running: true}, nil
+}
+
+func TestWaitForExecStopsWhenCancelled(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	defer func(interval time.Duration) { execPollInterval = interval }(execPollInterval)
+	execPollInterval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	exec := &runningExec{}
+	err := waitForExec(ctx, exec, "exec-1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, exec.inspections, 1)
+}
+
+func TestLoadPullTimeoutFromEnv(t *testing.T) {
+	defer func(timeout time.Duration) { pullTimeout = timeout }(pullTimeout)
+
+	t.Setenv("SANDBOX_PULL_TIMEOUT", "30m")
+	require.NoError(t, LoadPullTimeoutFromEnv())
+	assert.Equal(t, 30*time.Minute, pullTimeout)
+
+	t.Setenv("SANDBOX_PULL_TIMEOUT", "0")
+	require.NoError(t, LoadPullTimeoutFromEnv())
+	ctx, cancel := withDockerTimeout(context.Background(), pullTimeout)
+	defer cancel()
+	_, hasDeadline := ctx.Deadline()
+	assert.False(t, hasDeadline)
+
+	t.Setenv("SANDBOX_PULL_TIMEOUT", "soon")
+	assert.ErrorContains(t, LoadPullTimeoutFromEnv(), "invalid SANDBOX_PULL_TIMEOUT")
+}
+
+func TestDockerTimeoutError(t *testing.T) {
+	// The operation's own deadline is named
+	err := dockerTimeoutError(context.Background(), "creating the container", 30*time.Second, context.DeadlineExceeded)
+	assert.EqualError(t, err, "creating the container did not finish within 30s; the Docker daemon may be stalled: context deadline exceeded")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The request's deadline, or any other error, passes through
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, dockerTimeoutError(expired, "creating the container", 30*time.Second, context.DeadlineExceeded))
+	other := errors.New("no such image")
+	assert.Equal(t, other, dockerTimeoutError(context.Background(), "pulling the image", time.Minute, other))
+}
+
+func TestCallTimeouts(t *testing.T) {
+	defer func(timeout time.Duration) { pullTimeout = timeout }(pullTimeout)
+	pullTimeout = 10 * time.Minute
+	timeouts := CallTimeouts()
+	setup := pullTimeout + containerCreateTimeout
+
+	assert.Equal(t, setup, timeouts["sandbox_initialize"](newMockCallToolRequest("sandbox_initialize", nil)))
+	assert.Equal(t, setup+defaultRunCommandTimeout, timeouts["run_command"](newMockCallToolRequest("run_command", nil)))
+	assert.Equal(t, setup+5*time.Minute, timeouts["run_command"](newMockCallToolRequest("run_command", map[string]interface{}{"timeout_seconds": "5m"})))
+	assert.Equal(t, setup+maxRunCommandTimeout, timeouts["run_command"](newMockCallToolRequest("run_command", map[string]interface{}{"timeout_seconds": float64(3600)})))
+	assert.Equal(t, setup+2*time.Minute, timeouts["run_from_manifest"](newMockCallToolRequest("run_from_manifest", map[string]interface{}{
+		"manifest": map[string]interface{}{"image_digest": "python@sha256:0123", "command": []interface{}{"true"}, "network": "none", "timeout_seconds": float64(120)},
+	})))
+
+	assert.Equal(t, setup+checkCodeTimeout, timeouts["check_code"](newMockCallToolRequest("check_code", map[string]interface{}{"language": "typescript"})))
+	assert.Equal(t, systemPackagesTimeout, timeouts["install_system_packages"](newMockCallToolRequest("install_system_packages", nil)))
+
+	// Without a pull timeout a pull may take any time, so neither may the call
+	pullTimeout = 0
+	assert.Zero(t, timeouts["run_command"](newMockCallToolRequest("run_command", nil)))
+}
@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var dotenvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// dotenvVar is one KEY=VALUE assignment read from a .env file
+type dotenvVar struct {
+	Key   string
+	Value string
+}
+
+// parseDotenv parses a .env file: KEY=VALUE lines with an optional `export ` prefix, # comments,
+// single-quoted literal values and double-quoted values with \n, \t, \" and \\ escapes.
+// Malformed lines are skipped and reported as warnings with their line number.
+func parseDotenv(data string) ([]dotenvVar, []string) {
+	var vars []dotenvVar
+	var warnings []string
+
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		key, rest, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("line %d: expected KEY=VALUE", lineNo))
+			continue
+		}
+		if !dotenvKeyPattern.MatchString(key) {
+			warnings = append(warnings, fmt.Sprintf("line %d: invalid variable name %q", lineNo, key))
+			continue
+		}
+
+		rest = strings.TrimSpace(rest)
+		var value string
+		switch {
+		case strings.HasPrefix(rest, "'") || strings.HasPrefix(rest, `"`):
+			// Quoted values may span lines until the closing quote
+			quote := rest[0]
+			body := rest[1:]
+			end := closingQuote(body, quote)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				body += "\n" + lines[i]
+				end = closingQuote(body, quote)
+			}
+			if end < 0 {
+				warnings = append(warnings, fmt.Sprintf("line %d: unterminated %c quote", lineNo, quote))
+				continue
+			}
+			if trailing := strings.TrimSpace(body[end+1:]); trailing != "" && !strings.HasPrefix(trailing, "#") {
+				warnings = append(warnings, fmt.Sprintf("line %d: unexpected text after closing quote", lineNo))
+				continue
+			}
+			value = body[:end]
+			if quote == '"' {
+				value = unescapeDotenv(value)
+			}
+		default:
+			// Unquoted values end at a comment preceded by whitespace
+			if j := strings.Index(rest, " #"); j >= 0 {
+				rest = rest[:j]
+			}
+			value = strings.TrimSpace(rest)
+		}
+
+		vars = append(vars, dotenvVar{Key: key, Value: value})
+	}
+
+	return vars, warnings
+}
+
+// closingQuote returns the index of the unescaped closing quote in s, or -1
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(s)
+}
+
+// dotenvEnv converts parsed variables to the KEY=VALUE form used by the container config; later
+// assignments of the same key win
+func dotenvEnv(vars []dotenvVar) []string {
+	env := make([]string, 0, len(vars))
+	index := map[string]int{}
+	for _, v := range vars {
+		if i, ok := index[v.Key]; ok {
+			env[i] = v.Key + "=" + v.Value
+			continue
+		}
+		index[v.Key] = len(env)
+		env = append(env, v.Key+"="+v.Value)
+	}
+	return env
+}
+
+// minRedactedLength skips very short values, which would otherwise mangle unrelated text
+const minRedactedLength = 4
+
+// sandboxSecret is the set of env_file variables of one sandbox
+type sandboxSecret struct {
+	ID   string
+	Name string
+	// Keys are the names of all env_file variables, whatever the length of their values
+	Keys   []string
+	Values []string
+}
+
+// matches reports whether a container reference (full or short ID, or name) names this sandbox
+func (s sandboxSecret) matches(ref string) bool {
+	return ref != "" && (ref == s.Name || strings.HasPrefix(s.ID, ref))
+}
+
+// sandboxSecrets holds the env_file values of each sandbox so they can be masked wherever the server
+// echoes commands
+var sandboxSecrets = struct {
+	sync.RWMutex
+	entries []sandboxSecret
+}{}
+
+// registerSecrets remembers the env_file variable names and the values to redact for a sandbox
+func registerSecrets(containerID, name string, keys, values []string) {
+	var secrets []string
+	for _, value := range values {
+		if len(value) >= minRedactedLength {
+			secrets = append(secrets, value)
+		}
+	}
+	if len(keys) == 0 && len(secrets) == 0 {
+		return
+	}
+	// Longest first so a value containing another is masked as a whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	sandboxSecrets.Lock()
+	defer sandboxSecrets.Unlock()
+	sandboxSecrets.entries = append(sandboxSecrets.entries, sandboxSecret{ID: containerID, Name: name, Keys: keys, Values: secrets})
+}
+
+// forgetSecrets drops the values registered for a sandbox
+func forgetSecrets(containerIDOrName string) {
+	sandboxSecrets.Lock()
+	defer sandboxSecrets.Unlock()
+	kept := sandboxSecrets.entries[:0]
+	for _, entry := range sandboxSecrets.entries {
+		if !entry.matches(containerIDOrName) {
+			kept = append(kept, entry)
+		}
+	}
+	sandboxSecrets.entries = kept
+}
+
+// redactSecrets masks the sandbox's env_file values in s
+func redactSecrets(containerIDOrName string, s string) string {
+	sandboxSecrets.RLock()
+	defer sandboxSecrets.RUnlock()
+	for _, entry := range sandboxSecrets.entries {
+		if !entry.matches(containerIDOrName) {
+			continue
+		}
+		for _, secret := range entry.Values {
+			s = strings.ReplaceAll(s, secret, "[REDACTED]")
+		}
+	}
+	return s
+}
+
+// isSecretKey reports whether the variable came from the sandbox's env_file
+func isSecretKey(containerIDOrName string, key string) bool {
+	sandboxSecrets.RLock()
+	defer sandboxSecrets.RUnlock()
+	for _, entry := range sandboxSecrets.entries {
+		if entry.matches(containerIDOrName) && slices.Contains(entry.Keys, key) {
+			return true
+		}
+	}
+	return false
+}
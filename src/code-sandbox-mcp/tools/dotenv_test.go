@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenvFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.env")
+	require.NoError(t, err)
+
+	vars, warnings := parseDotenv(string(data))
+	got := map[string]string{}
+	for _, v := range vars {
+		got[v.Key] = v.Value
+	}
+
+	assert.Equal(t, map[string]string{
+		"DB_HOST":       "localhost",
+		"DB_PORT":       "5432",
+		"API_KEY":       "sk-export-1234",
+		"SINGLE":        `literal $HOME \n stays`,
+		"DOUBLE":        "line one\nline two \"quoted\"",
+		"MULTILINE":     "first\nsecond",
+		"EMPTY":         "",
+		"HASH_IN_VALUE": "abc#def",
+	}, got)
+	assert.Equal(t, []string{
+		"line 12: expected KEY=VALUE",
+		`line 13: invalid variable name "1BAD"`,
+		`line 14: unterminated " quote`,
+	}, warnings)
+}
+
+func TestDotenvEnvLaterAssignmentWins(t *testing.T) {
+	vars, warnings := parseDotenv("A=1\nB=2\nA=3\n")
+	require.Empty(t, warnings)
+	assert.Equal(t, []string{"A=3", "B=2"}, dotenvEnv(vars))
+}
+
+func TestRedactSecrets(t *testing.T) {
+	id := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	registerSecrets(id, "secret-box", nil, []string{"sk-export-1234", "abc", "hunter22"})
+	t.Cleanup(func() { forgetSecrets(id) })
+
+	cmd := "curl -H 'Authorization: sk-export-1234' -u abc:hunter22 host"
+	want := "curl -H 'Authorization: [REDACTED]' -u abc:[REDACTED] host"
+	assert.Equal(t, want, redactSecrets(id, cmd))
+	assert.Equal(t, want, redactSecrets(id[:12], cmd))
+	assert.Equal(t, want, redactSecrets("secret-box", cmd))
+	assert.Equal(t, cmd, redactSecrets("other-box", cmd))
+
+	forgetSecrets("secret-box")
+	assert.Equal(t, cmd, redactSecrets(id, cmd))
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxJSONOutput caps the stdout an expect_json command may print to be parsed; larger output is only returned
+// as text
+const maxJSONOutput = 1 << 20
+
+// execCommand is one entry of the sandbox_exec commands, given as a string or as
+// {"command": "...", "expect_json": true}
+type execCommand struct {
+	Command    string
+	ExpectJSON bool
+}
+
+// parseExecCommands validates the commands argument of sandbox_exec
+func parseExecCommands(value any) ([]execCommand, error) {
+	if command, ok := value.(string); ok {
+		return []execCommand{{Command: command}}, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("commands must be a string or an array of strings")
+	}
+	commands := make([]execCommand, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			commands = append(commands, execCommand{Command: item})
+		case map[string]any:
+			command, ok := item["command"].(string)
+			if !ok {
+				return nil, errors.New("Each command object must have a command string")
+			}
+			expectJSON, _ := item["expect_json"].(bool)
+			commands = append(commands, execCommand{Command: command, ExpectJSON: expectJSON})
+		default:
+			return nil, errors.New("Each command must be a string")
+		}
+	}
+	return commands, nil
+}
+
+// execJSON is the parsed stdout of an expect_json command
+type execJSON struct {
+	Command int `json:"command"` // index of the command in commands
+	Value   any `json:"value"`
+}
+
+// parseJSONOutput parses the stdout of an expect_json command as a single JSON value. When it isn't one, the
+// returned note starts with JSON_PARSE_FAILED and says where parsing stopped.
+func parseJSONOutput(stdout string) (value any, note string) {
+	if len(stdout) > maxJSONOutput {
+		return nil, fmt.Sprintf("JSON_PARSE_FAILED: stdout is %d bytes, over the %d bytes expect_json parses", len(stdout), maxJSONOutput)
+	}
+	if strings.TrimSpace(stdout) == "" {
+		return nil, "JSON_PARSE_FAILED: stdout is empty"
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	// Keep integers such as IDs exact instead of rounding them to float64
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		var syntaxErr *json.SyntaxError
+		offset := len(stdout)
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = int(syntaxErr.Offset) - 1
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			err = errors.New("unexpected end of JSON input")
+		}
+		return nil, fmt.Sprintf("JSON_PARSE_FAILED: %v at %s", err, textPosition(stdout, offset))
+	}
+	end := int(decoder.InputOffset())
+	if rest := strings.TrimLeft(stdout[end:], " \t\r\n"); rest != "" {
+		offset := len(stdout) - len(rest)
+		return nil, fmt.Sprintf("JSON_PARSE_FAILED: unexpected %q after the JSON value at %s", firstLine(rest[:min(len(rest), 20)]), textPosition(stdout, offset))
+	}
+	return value, ""
+}
+
+// textPosition describes a byte offset of s as a 1-based line and column
+func textPosition(s string, offset int) string {
+	offset = max(0, min(offset, len(s)))
+	line := strings.Count(s[:offset], "\n") + 1
+	column := offset - strings.LastIndex(s[:offset], "\n")
+	return fmt.Sprintf("line %d, column %d (byte %d)", line, column, offset)
+}
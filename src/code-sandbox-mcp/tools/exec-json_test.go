@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExecCommands(t *testing.T) {
+	commands, err := parseExecCommands("ls")
+	require.NoError(t, err)
+	assert.Equal(t, []execCommand{{Command: "ls"}}, commands)
+
+	commands, err = parseExecCommands([]any{"ls", map[string]any{"command": "cat results.json", "expect_json": true}, map[string]any{"command": "pwd"}})
+	require.NoError(t, err)
+	assert.Equal(t, []execCommand{{Command: "ls"}, {Command: "cat results.json", ExpectJSON: true}, {Command: "pwd"}}, commands)
+
+	_, err = parseExecCommands([]any{"ls", 3.0})
+	assert.EqualError(t, err, "Each command must be a string")
+	_, err = parseExecCommands([]any{map[string]any{"expect_json": true}})
+	assert.EqualError(t, err, "Each command object must have a command string")
+	_, err = parseExecCommands(nil)
+	assert.EqualError(t, err, "commands must be a string or an array of strings")
+}
+
+func TestParseJSONOutput(t *testing.T) {
+	value, note := parseJSONOutput("{\"items\": [1, 2], \"id\": 9007199254740993}\n")
+	assert.Empty(t, note)
+	encoded, err := json.Marshal(value)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"items": [1, 2], "id": 9007199254740993}`, string(encoded))
+	assert.Contains(t, string(encoded), "9007199254740993", "large integers stay exact")
+
+	for name, tc := range map[string]struct {
+		stdout string
+		want   string
+	}{
+		"trailing garbage": {"{\"ok\": true}\nDone in 3s\n", `JSON_PARSE_FAILED: unexpected "Done in 3s" after the JSON value at line 2, column 1 (byte 13)`},
+		"two values":       {"[1]\n[2]\n", `JSON_PARSE_FAILED: unexpected "[2]" after the JSON value at line 2, column 1 (byte 4)`},
+		"syntax error":     {"{\n  \"a\": 1,\n  b: 2\n}", "JSON_PARSE_FAILED: invalid character 'b' looking for beginning of object key string at line 3, column 3 (byte 14)"},
+		"truncated":        {"{\"a\": [1, 2", "JSON_PARSE_FAILED: unexpected end of JSON input at line 1, column 12 (byte 11)"},
+		"empty":            {" \n", "JSON_PARSE_FAILED: stdout is empty"},
+		"oversized":        {"[" + strings.Repeat("1,", maxJSONOutput/2) + "1]", "JSON_PARSE_FAILED: stdout is 1048579 bytes, over the 1048576 bytes expect_json parses"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			value, note := parseJSONOutput(tc.stdout)
+			assert.Nil(t, value)
+			assert.Equal(t, tc.want, note)
+		})
+	}
+}
+
+func TestExecResultStructuredContent(t *testing.T) {
+	summary := execSummary{ExitCodes: []int{0}, ExecutionID: "exec-1", JSON: []execJSON{{Command: 0, Value: map[string]any{"ok": true}}}}
+	for _, merge := range []bool{false, true} {
+		result, err := execResult([]string{"$ cat results.json\n{\"ok\": true}\n"}, summary, OutputSanitization{}, merge)
+		require.NoError(t, err)
+		structured, err := json.Marshal(result.StructuredContent)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"exit_codes": [0], "execution_id": "exec-1", "json": [{"command": 0, "value": {"ok": true}}]}`, string(structured))
+	}
+
+	result, err := execResult([]string{"$ ls\n"}, execSummary{ExitCodes: []int{0}, ExecutionID: "exec-2"}, OutputSanitization{}, false)
+	require.NoError(t, err)
+	assert.Nil(t, result.StructuredContent)
+	assert.Equal(t, `{"exit_codes":[0],"execution_id":"exec-2"}`, result.Content[1].(mcp.TextContent).Text)
+}
@@ -2,13 +2,19 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
+	"sync"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
 	"github.com/docker/docker/api/types/container"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
 )
 
 // Exec executes commands in a container
@@ -18,111 +24,369 @@ func Exec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult
 	if err != nil {
 		return mcp.NewToolResultText("container_id_or_name is required"), nil
 	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	ctx, timing := tracing.WithTiming(ctx)
 
-	// Commands can be a single string or an array of strings
-	var commands []string
-	args := request.GetArguments()
-	if cmdsArr, ok := args["commands"].([]interface{}); ok {
-		// It's an array of commands
-		for _, cmd := range cmdsArr {
-			if cmdStr, ok := cmd.(string); ok {
-				commands = append(commands, cmdStr)
-			} else {
-				return mcp.NewToolResultText("Each command must be a string"), nil
-			}
-		}
-	} else if cmdStr, ok := args["commands"].(string); ok {
-		// It's a single command string
-		commands = []string{cmdStr}
-	} else {
-		return mcp.NewToolResultText("commands must be a string or an array of strings"), nil
+	// Commands can be a single string or an array of strings and {"command", "expect_json"} objects
+	commands, err := parseExecCommands(request.GetArguments()["commands"])
+	if err != nil {
+		return mcp.NewToolResultText(err.Error()), nil
 	}
 
 	if len(commands) == 0 {
 		return mcp.NewToolResultText("at least one command is required"), nil
 	}
 
-	// Execute each command and collect output
-	var outputBuilder strings.Builder
-	for i, cmd := range commands {
+	// Return everything as one text block instead of one item per command
+	mergeOutput := request.GetBool("merge_output", false)
+	// Color codes are stripped unless the caller renders them
+	keepANSI := request.GetBool("keep_ansi", false)
+	// Very verbose output can be cut down to its ends and its error lines; the record keeps all of it
+	summarize := request.GetBool("summarize_output", false)
+
+	// Commands that rely on nvm, pyenv or conda set up in profile scripts need those scripts sourced
+	var loginShell string
+	loginMode := shellMode{Login: request.GetBool("login_shell", false), FromProfile: request.GetBool("env_from_profile", false)}
+	if loginMode.Login {
+		loginShell = containerShell(ctx, containerIDOrName)
+	}
+
+	// Execute each command and collect one output section per command
+	var sections []string
+	var exitCodes []int
+	var sanitized OutputSanitization
+	var running *execStillRunningError
+	var runningHeader string
+	var parsed []execJSON
+	for i, command := range commands {
+		cmd := command.Command
 		// Format the command nicely in the output
-		if i > 0 {
-			outputBuilder.WriteString("\n\n")
-		}
-		outputBuilder.WriteString(fmt.Sprintf("$ %s\n", cmd))
+		header := fmt.Sprintf("$ %s\n", redactSecrets(containerIDOrName, cmd))
 
 		// Execute the command
-		stdout, stderr, exitCode, err := executeCommandWithOutput(ctx, containerIDOrName, cmd)
+		stdout, stderr, exitCode, err := executeArgvWithProgress(ctx, containerIDOrName, loginMode.argv(loginShell, cmd), nil)
+		if errors.As(err, &running) {
+			// The call ran out of time; return what the command printed so far
+			section, report := execSection(ctx, containerIDOrName, header, stdout, stderr, 0, keepANSI)
+			sanitized.Add(report)
+			sections = append(sections, section)
+			exitCodes = append(exitCodes, -1)
+			runningHeader = header
+			break
+		}
 		if err != nil {
-			return mcp.NewToolResultText(fmt.Sprintf("Error executing command: %v", err)), nil
+			section := header + fmt.Sprintf("Error executing command: %v\n", err)
+			if reason := explainExecFailure(ctx, containerIDOrName, -1); reason != "" {
+				section += reason + "\n"
+			}
+			sections = append(sections, section)
+			exitCodes = append(exitCodes, -1)
+			break
 		}
 
-		// Add the command output to the collector
-		if stdout != "" {
-			outputBuilder.WriteString(stdout)
-			if !strings.HasSuffix(stdout, "\n") {
-				outputBuilder.WriteString("\n")
+		section, report := execSection(ctx, containerIDOrName, header, stdout, stderr, exitCode, keepANSI)
+		sanitized.Add(report)
+		// The stdout of an expect_json command is also returned parsed, or with why it couldn't be
+		if command.ExpectJSON && exitCode == 0 {
+			cleaned, _ := SanitizeOutput(redactSecrets(containerIDOrName, stdout), false)
+			if value, note := parseJSONOutput(cleaned); note != "" {
+				section += note + "; stdout is shown as text above\n"
+			} else {
+				parsed = append(parsed, execJSON{Command: i, Value: value})
 			}
 		}
-		if stderr != "" {
-			outputBuilder.WriteString("Error: ")
-			outputBuilder.WriteString(stderr)
-			if !strings.HasSuffix(stderr, "\n") {
-				outputBuilder.WriteString("\n")
-			}
+		sections = append(sections, section)
+		exitCodes = append(exitCodes, exitCode)
+		if exitCode != 0 {
+			break
 		}
+	}
 
-		// If the command failed, add the exit code and stop processing subsequent commands
+	if running != nil {
+		// The command keeps running in the container; its record is completed once it exits
+		output := strings.Join(sections, "\n")
+		id := startExecution("sandbox_exec", containerIDOrName, output)
+		go finishExecution(id, containerIDOrName, slices.Clone(sections[:len(sections)-1]), runningHeader, running, keepANSI)
+		sections[len(sections)-1] += fmt.Sprintf("Watchdog: the tool call reached its deadline while this command was still running. "+
+			"It keeps running in the container; read executions://%s/output for the complete output once executions_list no longer shows it as running.\n", id)
+		summary := execSummary{ExitCodes: exitCodes, ExecutionID: id, Running: true, Limits: containerLimits(context.WithoutCancel(ctx), containerIDOrName), JSON: parsed, Timing: timing.Milliseconds()}
+		if summarize {
+			summary.Summarized = summarizeSections(sections)
+		}
+		return execResult(sections, summary, sanitized, mergeOutput)
+	}
+
+	// Keep the output so it can be re-read through executions://{id}/output
+	output := strings.Join(sections, "\n")
+	id := recordExecution("sandbox_exec", containerIDOrName, exitCodes[len(exitCodes)-1], output)
+	summary := execSummary{ExitCodes: exitCodes, ExecutionID: id, Limits: containerLimits(ctx, containerIDOrName), JSON: parsed, Timing: timing.Milliseconds()}
+	if summarize {
+		summary.Summarized = summarizeSections(sections)
+	}
+	return execResult(sections, summary, sanitized, mergeOutput)
+}
+
+// execResult builds the sandbox_exec result from the command sections and the summary. When expect_json
+// commands were parsed, the summary with their values is also the result's structured content.
+func execResult(sections []string, summary execSummary, sanitized OutputSanitization, mergeOutput bool) (*mcp.CallToolResult, error) {
+	if sanitized.Changed() {
+		summary.Sanitized = &sanitized
+	}
+	if mergeOutput {
+		merged := fmt.Sprintf("%s\nexecution_id: %s", strings.Join(sections, "\n"), summary.ExecutionID)
+		if sanitized.Changed() {
+			merged += "\nsanitized: " + sanitized.String()
+		}
+		if summary.Limits != nil {
+			merged += "\nlimits: " + summary.Limits.String()
+		}
+		if summary.Summarized != nil {
+			merged += fmt.Sprintf("\nsummarized: %s; the full output is at executions://%s/output", summary.Summarized, summary.ExecutionID)
+		}
+		result := mcp.NewToolResultText(merged)
+		if len(summary.JSON) > 0 {
+			result.StructuredContent = summary
+		}
+		return result, nil
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize exec summary: %v", err)
+	}
+	result := &mcp.CallToolResult{}
+	for _, section := range sections {
+		result.Content = append(result.Content, mcp.NewTextContent(section))
+	}
+	result.Content = append(result.Content, mcp.NewTextContent(string(summaryJSON)))
+	if len(summary.JSON) > 0 {
+		result.StructuredContent = summary
+	}
+	return result, nil
+}
+
+// execSection formats the output of one command below its $ line: stdout, then stderr, then for a failed
+// command its exit code and why it stopped
+func execSection(ctx context.Context, containerIDOrName string, header string, stdout string, stderr string, exitCode int, keepANSI bool) (string, OutputSanitization) {
+	var section strings.Builder
+	section.WriteString(header)
+
+	var sanitized OutputSanitization
+	stdout, stdoutReport := SanitizeOutput(redactSecrets(containerIDOrName, stdout), keepANSI)
+	stderr, stderrReport := SanitizeOutput(redactSecrets(containerIDOrName, stderr), keepANSI)
+	sanitized.Add(stdoutReport)
+	sanitized.Add(stderrReport)
+
+	// Add the command output to the section
+	if stdout != "" {
+		section.WriteString(stdout)
+		if !strings.HasSuffix(stdout, "\n") {
+			section.WriteString("\n")
+		}
+	}
+	if stderr != "" {
+		// Tools like pip, npm and git report progress on stderr, so only a failed command's stderr reads as an error
 		if exitCode != 0 {
-			outputBuilder.WriteString(fmt.Sprintf("Command exited with code %d\n", exitCode))
-			break
+			section.WriteString("Error: ")
+		} else {
+			section.WriteString("stderr: ")
+		}
+		section.WriteString(stderr)
+		if !strings.HasSuffix(stderr, "\n") {
+			section.WriteString("\n")
 		}
 	}
 
-	return mcp.NewToolResultText(outputBuilder.String()), nil
+	// If the command failed, add the exit code
+	if exitCode != 0 {
+		section.WriteString(fmt.Sprintf("Command exited with code %d\n", exitCode))
+		if reason := explainExecFailure(ctx, containerIDOrName, exitCode); reason != "" {
+			section.WriteString(reason + "\n")
+		}
+	}
+	return section.String(), sanitized
+}
+
+// finishExecution waits for a command that outlived its sandbox_exec call and completes its execution record
+// with the full output
+func finishExecution(id string, containerIDOrName string, previous []string, header string, running *execStillRunningError, keepANSI bool) {
+	outcome := <-running.Finished
+	var section string
+	exitCode := outcome.ExitCode
+	if outcome.Err != nil {
+		section = header + fmt.Sprintf("Error executing command: %v\n", outcome.Err)
+		exitCode = -1
+	} else {
+		section, _ = execSection(context.Background(), containerIDOrName, header, outcome.Stdout, outcome.Stderr, exitCode, keepANSI)
+	}
+	executionHistory.Complete(id, exitCode, strings.Join(append(previous, section), "\n"))
+}
+
+// execSummary is the final content item of a sandbox_exec result; exit_codes has one entry per command
+// that ran, -1 meaning the command could not be executed; sanitized is set when output was cleaned up
+type execSummary struct {
+	ExitCodes   []int               `json:"exit_codes"`
+	ExecutionID string              `json:"execution_id"`
+	Sanitized   *OutputSanitization `json:"sanitized,omitempty"`
+	// Summarized is set when summarize_output elided lines; executions://{execution_id}/output has all of them
+	Summarized *OutputSummary `json:"summarized,omitempty"`
+	// Running is set when the call's deadline passed before the last command finished
+	Running bool `json:"running,omitempty"`
+	// Limits are the container's CPU and memory limits, omitted when it has none
+	Limits *SandboxLimits `json:"limits,omitempty"`
+	// JSON holds the parsed stdout of the expect_json commands that printed valid JSON
+	JSON []execJSON `json:"json,omitempty"`
+	// Timing is how long the commands took, as {"exec_ms": 120}
+	Timing map[string]int64 `json:"timing,omitempty"`
 }
 
 // executeCommandWithOutput runs a command in a container and returns its stdout, stderr, exit code, and any error
 func executeCommandWithOutput(ctx context.Context, containerIDOrName string, cmd string) (stdout string, stderr string, exitCode int, err error) {
+	return executeCommandWithProgress(ctx, containerIDOrName, cmd, nil)
+}
+
+// execStillRunningError is returned when the context ends while a command is still running. The output so far
+// is returned along with it; Finished delivers the complete output once the command exits.
+type execStillRunningError struct {
+	Err      error
+	Finished <-chan execOutcome
+}
+
+func (e *execStillRunningError) Error() string {
+	return fmt.Sprintf("command still running: %v", e.Err)
+}
+
+func (e *execStillRunningError) Unwrap() error {
+	return e.Err
+}
+
+// execOutcome is the complete output of a command that outlived its caller
+type execOutcome struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+}
+
+// execOutput collects the output of an exec so it can be read while more is still arriving
+type execOutput struct {
+	mu       sync.Mutex
+	stdout   strings.Builder
+	stderr   strings.Builder
+	progress io.Writer
+}
+
+// execStream is the stdout or stderr side of an execOutput
+type execStream struct {
+	output *execOutput
+	buf    *strings.Builder
+}
+
+func (w execStream) Write(p []byte) (int, error) {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+	w.buf.Write(p)
+	if w.output.progress != nil {
+		w.output.progress.Write(p)
+	}
+	return len(p), nil
+}
+
+// detach stops copying to progress and returns the output so far
+func (o *execOutput) detach() (stdout string, stderr string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.progress = nil
+	return o.stdout.String(), o.stderr.String()
+}
+
+// executeCommandWithProgress is executeCommandWithOutput that also copies the output to progress as it arrives.
+// If ctx ends first, it returns the output so far with an *execStillRunningError and stops writing to progress.
+func executeCommandWithProgress(ctx context.Context, containerIDOrName string, cmd string, progress io.Writer) (stdout string, stderr string, exitCode int, err error) {
+	return executeArgvWithProgress(ctx, containerIDOrName, []string{"sh", "-c", cmd}, progress)
+}
+
+// executeArgvWithProgress is executeCommandWithProgress for a command given as argv rather than run with sh -c
+func executeArgvWithProgress(ctx context.Context, containerIDOrName string, argv []string, progress io.Writer) (stdout string, stderr string, exitCode int, err error) {
+	ctx, span := tracing.Start(ctx, "container.exec", tracing.ContainerKey.String(containerIDOrName))
+	defer func() {
+		if err == nil {
+			span.SetAttributes(tracing.ExitCodeKey.Int(exitCode))
+		}
+		tracing.End(span, err)
+	}()
+
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return "", "", -1, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
-	defer cli.Close()
-
-	// Create the exec configuration
-	exec, err := cli.ContainerExecCreate(ctx, containerIDOrName, container.ExecOptions{
-		Cmd:          []string{"sh", "-c", cmd},
+	// Create the exec configuration; the attached connection outlives the attach deadline
+	attachCtx, cancelAttach := withDockerTimeout(ctx, execAttachTimeout)
+	defer cancelAttach()
+	exec, err := cli.ContainerExecCreate(attachCtx, containerIDOrName, container.ExecOptions{
+		Cmd:          argv,
 		AttachStdout: true,
 		AttachStderr: true,
 	})
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to create exec: %w", err)
+		cli.Close()
+		return "", "", -1, fmt.Errorf("failed to create exec: %w", dockerTimeoutError(ctx, "creating the exec", execAttachTimeout, err))
 	}
 
 	// Attach to the exec instance to get output
-	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	resp, err := cli.ContainerExecAttach(attachCtx, exec.ID, container.ExecAttachOptions{})
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to attach to exec: %w", err)
+		cli.Close()
+		return "", "", -1, fmt.Errorf("failed to attach to exec: %w", dockerTimeoutError(ctx, "attaching to the exec", execAttachTimeout, err))
 	}
-	defer resp.Close()
 
-	// Read the output
-	var stdoutBuf, stderrBuf strings.Builder
-	_, err = stdcopy.StdCopy(&stdoutBuf, &stderrBuf, resp.Reader)
-	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to read command output: %w", err)
-	}
+	// Read the output; the attached connection isn't tied to ctx, so reading can outlast the call
+	output := &execOutput{progress: progress}
+	copied := make(chan error, 1)
+	go func() {
+		stderr := execStream{output, &output.stderr}
+		warning, err := DemuxOutput(execStream{output, &output.stdout}, stderr, resp.Reader)
+		if warning != "" {
+			fmt.Fprintf(stderr, "\nWarning: %s\n", warning)
+		}
+		copied <- err
+	}()
 
-	// Get the exit code
-	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
-	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to inspect exec: %w", err)
+	// finish waits for the output to end and gets the exit code
+	finish := func(ctx context.Context) execOutcome {
+		defer cli.Close()
+		defer resp.Close()
+		if err := <-copied; err != nil {
+			return execOutcome{ExitCode: -1, Err: fmt.Errorf("failed to read command output: %w", err)}
+		}
+		stdout, stderr := output.detach()
+		inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return execOutcome{ExitCode: -1, Err: fmt.Errorf("failed to inspect exec: %w", err)}
+		}
+		return execOutcome{Stdout: stdout, Stderr: stderr, ExitCode: inspect.ExitCode}
 	}
 
-	return stdoutBuf.String(), stderrBuf.String(), inspect.ExitCode, nil
+	select {
+	case err := <-copied:
+		copied <- err
+		outcome := finish(ctx)
+		if outcome.Err != nil {
+			return "", "", -1, outcome.Err
+		}
+		return outcome.Stdout, outcome.Stderr, outcome.ExitCode, nil
+	case <-ctx.Done():
+		finished := make(chan execOutcome, 1)
+		go func() { finished <- finish(context.WithoutCancel(ctx)) }()
+		stdout, stderr := output.detach()
+		return stdout, stderr, -1, &execStillRunningError{Err: ctx.Err(), Finished: finished}
+	}
 }
@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecSectionLabelsStderr(t *testing.T) {
+	ctx := context.Background()
+
+	// Progress on stderr of a successful command is not an error
+	section, _ := execSection(ctx, "box", "$ pip install requests\n", "Successfully installed requests\n", "WARNING: Running pip as root\n", 0, false)
+	assert.Equal(t, "$ pip install requests\nSuccessfully installed requests\nstderr: WARNING: Running pip as root\n", section)
+	assert.NotContains(t, section, "Error")
+
+	section, _ = execSection(ctx, "box", "$ git clone repo\n", "", "Cloning into 'repo'...", 0, false)
+	assert.Equal(t, "$ git clone repo\nstderr: Cloning into 'repo'...\n", section)
+
+	// A failed command keeps the Error label along with its exit code
+	section, _ = execSection(ctx, "box", "$ ls missing\n", "", "ls: missing: No such file or directory\n", 2, false)
+	assert.Contains(t, section, "$ ls missing\nError: ls: missing: No such file or directory\nCommand exited with code 2\n")
+}
+
+func TestShellModeArgv(t *testing.T) {
+	assert.Equal(t, []string{"sh", "-c", "node -v"}, shellMode{}.argv("", "node -v"))
+	assert.Equal(t, []string{"bash", "-lc", "node -v"}, shellMode{Login: true}.argv("bash", "node -v"))
+	assert.Equal(t, []string{"/bin/sh", "-lc", "node -v"}, shellMode{Login: true}.argv("/bin/sh", "node -v"))
+	assert.Equal(t, []string{"sh", "-c", "[ -r /etc/profile ] && . /etc/profile; node -v"}, shellMode{FromProfile: true}.argv("", "node -v"))
+}
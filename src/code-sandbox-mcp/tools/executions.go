@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Defaults for the execution history, overridable with SANDBOX_EXECUTION_HISTORY and SANDBOX_EXECUTION_HISTORY_BYTES
+const (
+	defaultExecutionHistoryEntries = 50
+	defaultExecutionHistoryBytes   = 10 * 1024 * 1024
+)
+
+// ExecutionRecord is the stored output of one tool run
+type ExecutionRecord struct {
+	ID        string    `json:"execution_id"`
+	Tool      string    `json:"tool"`
+	Container string    `json:"container"`
+	Timestamp time.Time `json:"timestamp"`
+	ExitCode  int       `json:"exit_code"`
+	Output    string    `json:"-"`
+	Truncated bool      `json:"truncated,omitempty"`
+	// Running is set while a command that outlived its tool call is still producing output
+	Running bool `json:"running,omitempty"`
+	// A schedule_exec run records its job and when it was due, so lateness shows
+	ScheduleID   string     `json:"schedule_id,omitempty"`
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	FiredAt      *time.Time `json:"fired_at,omitempty"`
+	// Manifest describes a run_command execution well enough to repeat it; see executions://{id}/manifest
+	Manifest *RunManifest `json:"-"`
+
+	seq int
+}
+
+// ExecutionStore keeps recent execution outputs, evicting the least recently used entry once
+// either the entry count or the total output size exceeds its limit
+type ExecutionStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	seq        int
+	order      *list.List // front is most recently used
+	entries    map[string]*list.Element
+}
+
+// NewExecutionStore creates a store holding at most maxEntries outputs totalling at most maxBytes
+func NewExecutionStore(maxEntries, maxBytes int) *ExecutionStore {
+	return &ExecutionStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Add stores a record under a new execution ID and returns the ID. Output larger than the byte limit
+// keeps only its tail, which is where errors usually are.
+func (s *ExecutionStore) Add(record ExecutionRecord) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	record.seq = s.seq
+	record.ID = fmt.Sprintf("exec-%d", s.seq)
+	s.truncate(&record)
+
+	s.entries[record.ID] = s.order.PushFront(&record)
+	s.bytes += len(record.Output)
+	s.evict()
+	return record.ID
+}
+
+// Complete replaces the output of a running record with the final output and exit code. It does nothing if the
+// record was evicted in the meantime.
+func (s *ExecutionStore) Complete(id string, exitCode int, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		return
+	}
+	record := elem.Value.(*ExecutionRecord)
+	s.bytes -= len(record.Output)
+	record.ExitCode = exitCode
+	record.Output = output
+	record.Running = false
+	s.truncate(record)
+	s.bytes += len(record.Output)
+	s.evict()
+}
+
+// MaxBytes is the most output a single record keeps
+func (s *ExecutionStore) MaxBytes() int {
+	return s.maxBytes
+}
+
+func (s *ExecutionStore) truncate(record *ExecutionRecord) {
+	if len(record.Output) > s.maxBytes {
+		// Start at the next character rather than in the middle of one
+		cut := len(record.Output) - s.maxBytes
+		for cut < len(record.Output) && !utf8.RuneStart(record.Output[cut]) {
+			cut++
+		}
+		record.Output = record.Output[cut:]
+		record.Truncated = true
+	}
+}
+
+func (s *ExecutionStore) evict() {
+	for s.order.Len() > s.maxEntries || s.bytes > s.maxBytes {
+		s.evictOldest()
+	}
+}
+
+// Get returns a record and marks it as recently used
+func (s *ExecutionStore) Get(id string) (ExecutionRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		return ExecutionRecord{}, false
+	}
+	s.order.MoveToFront(elem)
+	return *elem.Value.(*ExecutionRecord), true
+}
+
+// List returns the stored records, newest first
+func (s *ExecutionStore) List() []ExecutionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]ExecutionRecord, 0, len(s.entries))
+	for _, elem := range s.entries {
+		records = append(records, *elem.Value.(*ExecutionRecord))
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq > records[j].seq })
+	return records
+}
+
+func (s *ExecutionStore) evictOldest() {
+	elem := s.order.Back()
+	if elem == nil {
+		return
+	}
+	record := s.order.Remove(elem).(*ExecutionRecord)
+	delete(s.entries, record.ID)
+	s.bytes -= len(record.Output)
+}
+
+// executionHistory is the store shared by the tools and the executions:// resource
+var executionHistory = NewExecutionStore(defaultExecutionHistoryEntries, defaultExecutionHistoryBytes)
+
+// ConfigureExecutionHistoryFromEnv sizes the execution history from SANDBOX_EXECUTION_HISTORY (entries)
+// and SANDBOX_EXECUTION_HISTORY_BYTES (total output size)
+func ConfigureExecutionHistoryFromEnv() error {
+	entries, err := positiveIntFromEnv("SANDBOX_EXECUTION_HISTORY", defaultExecutionHistoryEntries)
+	if err != nil {
+		return err
+	}
+	bytes, err := positiveIntFromEnv("SANDBOX_EXECUTION_HISTORY_BYTES", defaultExecutionHistoryBytes)
+	if err != nil {
+		return err
+	}
+	executionHistory = NewExecutionStore(entries, bytes)
+	return nil
+}
+
+func positiveIntFromEnv(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", name, value)
+	}
+	return n, nil
+}
+
+// recordExecution stores a tool's output in the execution history and returns its ID
+func recordExecution(tool, containerIDOrName string, exitCode int, output string) string {
+	return executionHistory.Add(ExecutionRecord{
+		Tool:      tool,
+		Container: containerIDOrName,
+		Timestamp: time.Now().UTC(),
+		ExitCode:  exitCode,
+		Output:    output,
+	})
+}
+
+// recordRun stores the output and manifest of a run in a throwaway container and returns its execution ID
+func recordRun(tool string, result *commandResult, manifest *RunManifest) string {
+	return executionHistory.Add(ExecutionRecord{
+		Tool:      tool,
+		Container: manifest.ImageDigest,
+		Timestamp: time.Now().UTC(),
+		ExitCode:  result.ExitCode,
+		Output:    result.Stdout + result.Stderr,
+		Manifest:  manifest,
+	})
+}
+
+// startExecution stores the partial output of a command that is still running and returns its ID; the record is
+// completed with executionHistory.Complete once the command exits
+func startExecution(tool, containerIDOrName string, output string) string {
+	return executionHistory.Add(ExecutionRecord{
+		Tool:      tool,
+		Container: containerIDOrName,
+		Timestamp: time.Now().UTC(),
+		ExitCode:  -1,
+		Output:    output,
+		Running:   true,
+	})
+}
+
+// LookupExecution returns a stored execution by ID
+func LookupExecution(id string) (ExecutionRecord, bool) {
+	return executionHistory.Get(id)
+}
+
+// ListExecutions lists the stored executions with their tool, container, timestamp and exit code
+func ListExecutions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jsonData, err := json.Marshal(executionHistory.List())
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize execution list: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
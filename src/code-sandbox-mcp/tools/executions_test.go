@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionStoreRetrieval(t *testing.T) {
+	store := NewExecutionStore(10, 1024)
+	id := store.Add(ExecutionRecord{Tool: "sandbox_exec", Container: "box", ExitCode: 2, Output: "$ false\n"})
+
+	record, ok := store.Get(id)
+	require.True(t, ok)
+	assert.Equal(t, id, record.ID)
+	assert.Equal(t, "sandbox_exec", record.Tool)
+	assert.Equal(t, "box", record.Container)
+	assert.Equal(t, 2, record.ExitCode)
+	assert.Equal(t, "$ false\n", record.Output)
+
+	_, ok = store.Get("exec-999")
+	assert.False(t, ok)
+}
+
+func TestExecutionStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewExecutionStore(2, 1024)
+	first := store.Add(ExecutionRecord{Output: "one"})
+	second := store.Add(ExecutionRecord{Output: "two"})
+
+	// Reading the first entry makes the second the eviction candidate
+	_, ok := store.Get(first)
+	require.True(t, ok)
+	third := store.Add(ExecutionRecord{Output: "three"})
+
+	_, ok = store.Get(second)
+	assert.False(t, ok)
+	_, ok = store.Get(first)
+	assert.True(t, ok)
+
+	ids := []string{}
+	for _, record := range store.List() {
+		ids = append(ids, record.ID)
+	}
+	assert.Equal(t, []string{third, first}, ids)
+}
+
+func TestExecutionStoreEvictsBySize(t *testing.T) {
+	store := NewExecutionStore(10, 10)
+	first := store.Add(ExecutionRecord{Output: "123456"})
+	second := store.Add(ExecutionRecord{Output: "abcdef"})
+
+	_, ok := store.Get(first)
+	assert.False(t, ok)
+	_, ok = store.Get(second)
+	assert.True(t, ok)
+
+	// A single oversized output keeps its tail
+	big := store.Add(ExecutionRecord{Output: strings.Repeat("x", 20) + "tail"})
+	record, ok := store.Get(big)
+	require.True(t, ok)
+	assert.True(t, record.Truncated)
+	assert.Len(t, record.Output, 10)
+	assert.True(t, strings.HasSuffix(record.Output, "tail"))
+
+	// The tail starts at a character boundary: 10 bytes from the end is the middle of "é"
+	accented := store.Add(ExecutionRecord{Output: "xxxxxéééé€"})
+	record, ok = store.Get(accented)
+	require.True(t, ok)
+	assert.True(t, utf8.ValidString(record.Output))
+	assert.Equal(t, "ééé€", record.Output)
+}
+
+func TestExecutionStoreComplete(t *testing.T) {
+	store := NewExecutionStore(10, 10)
+	id := store.Add(ExecutionRecord{ExitCode: -1, Output: "partial", Running: true})
+
+	store.Complete(id, 0, "partial and the rest")
+	record, ok := store.Get(id)
+	require.True(t, ok)
+	assert.False(t, record.Running)
+	assert.Equal(t, 0, record.ExitCode)
+	assert.True(t, record.Truncated)
+	assert.Equal(t, "d the rest", record.Output)
+
+	// Completing an evicted record is a no-op
+	store.Complete("exec-999", 0, "gone")
+	assert.Len(t, store.List(), 1)
+}
+
+func TestListExecutionsOmitsOutput(t *testing.T) {
+	previous := executionHistory
+	executionHistory = NewExecutionStore(10, 1024)
+	t.Cleanup(func() { executionHistory = previous })
+
+	id := recordExecution("sandbox_exec", "box", 0, "secret output")
+
+	result, err := ListExecutions(context.Background(), newMockCallToolRequest("executions_list", map[string]interface{}{}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	assert.NotContains(t, text, "secret output")
+
+	var records []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(text), &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, id, records[0]["execution_id"])
+	assert.Equal(t, "box", records[0]["container"])
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// signalNames maps the signals commonly seen as 128+N exit codes
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+// explainExitCode describes signal-based exit codes (128+N); other codes get no explanation
+func explainExitCode(exitCode int, memoryLimit int64) string {
+	if exitCode <= 128 || exitCode > 128+64 {
+		return ""
+	}
+	signal := exitCode - 128
+	name, ok := signalNames[signal]
+	if !ok {
+		name = fmt.Sprintf("signal %d", signal)
+	}
+
+	switch signal {
+	case 9:
+		if memoryLimit > 0 {
+			return fmt.Sprintf("process was killed (%s), most likely by the out-of-memory killer (limit %s); consider raising memory_limit", name, formatMemory(memoryLimit))
+		}
+		return fmt.Sprintf("process was killed (%s), possibly by the out-of-memory killer", name)
+	case 11:
+		return fmt.Sprintf("process crashed with a segmentation fault (%s)", name)
+	default:
+		return fmt.Sprintf("process was terminated by %s", name)
+	}
+}
+
+// explainContainerState describes why a container stopped; a container that is still running gets no explanation
+func explainContainerState(state *container.State, memoryLimit int64) string {
+	if state == nil || state.Running {
+		return ""
+	}
+
+	var parts []string
+	if state.OOMKilled {
+		if memoryLimit > 0 {
+			parts = append(parts, fmt.Sprintf("process was killed: out of memory (limit %s); consider raising memory_limit", formatMemory(memoryLimit)))
+		} else {
+			parts = append(parts, "process was killed: out of memory")
+		}
+	} else if reason := explainExitCode(state.ExitCode, memoryLimit); reason != "" {
+		parts = append(parts, reason)
+	}
+	if state.Error != "" {
+		parts = append(parts, fmt.Sprintf("container error: %s", state.Error))
+	}
+
+	return fmt.Sprintf("container is no longer running (status %s, exit code %d)", state.Status, state.ExitCode) + joinReasons(parts)
+}
+
+// explainExecFailure explains a failed exec: why the container died if it stopped underneath the exec,
+// otherwise what a signal-based exit code of the command means. exitCode is ignored when negative.
+func explainExecFailure(ctx context.Context, containerIDOrName string, exitCode int) string {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return ""
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return ""
+	}
+	var memoryLimit int64
+	if inspect.HostConfig != nil {
+		memoryLimit = inspect.HostConfig.Memory
+	}
+	if reason := explainContainerState(inspect.State, memoryLimit); reason != "" {
+		return reason
+	}
+	if exitCode < 0 {
+		return ""
+	}
+	return explainExitCode(exitCode, memoryLimit)
+}
+
+func joinReasons(parts []string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(parts, "; ")
+}
+
+// formatMemory renders a byte count in MB or GB
+func formatMemory(bytes int64) string {
+	const mb = 1024 * 1024
+	if bytes >= 1024*mb && bytes%(1024*mb) == 0 {
+		return fmt.Sprintf("%dGB", bytes/(1024*mb))
+	}
+	return fmt.Sprintf("%dMB", bytes/mb)
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainExitCode(t *testing.T) {
+	assert.Empty(t, explainExitCode(0, 0))
+	assert.Empty(t, explainExitCode(1, 0))
+	assert.Empty(t, explainExitCode(127, 0))
+
+	assert.Equal(t,
+		"process was killed (SIGKILL), most likely by the out-of-memory killer (limit 256MB); consider raising memory_limit",
+		explainExitCode(137, 256*1024*1024),
+	)
+	assert.Equal(t, "process was killed (SIGKILL), possibly by the out-of-memory killer", explainExitCode(137, 0))
+	assert.Equal(t, "process crashed with a segmentation fault (SIGSEGV)", explainExitCode(139, 0))
+	assert.Equal(t, "process was terminated by SIGTERM", explainExitCode(143, 0))
+	assert.Equal(t, "process was terminated by signal 10", explainExitCode(138, 0))
+}
+
+func TestExplainContainerState(t *testing.T) {
+	assert.Empty(t, explainContainerState(nil, 0))
+	assert.Empty(t, explainContainerState(&container.State{Running: true, Status: "running"}, 0))
+
+	assert.Equal(t,
+		"container is no longer running (status exited, exit code 137): process was killed: out of memory (limit 2GB); consider raising memory_limit",
+		explainContainerState(&container.State{Status: "exited", ExitCode: 137, OOMKilled: true}, 2*1024*1024*1024),
+	)
+	assert.Equal(t,
+		"container is no longer running (status exited, exit code 139): process crashed with a segmentation fault (SIGSEGV)",
+		explainContainerState(&container.State{Status: "exited", ExitCode: 139}, 0),
+	)
+	assert.Equal(t,
+		"container is no longer running (status exited, exit code 0)",
+		explainContainerState(&container.State{Status: "exited"}, 0),
+	)
+	assert.Equal(t,
+		"container is no longer running (status dead, exit code 1): container error: failed to mount",
+		explainContainerState(&container.State{Status: "dead", ExitCode: 1, Error: "failed to mount"}, 0),
+	)
+}
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// archiveStats summarizes the content of an extracted archive
+type archiveStats struct {
+	Files int
+	Bytes int64
+}
+
+// ExtractArchive unpacks a local or base64-encoded zip/tar/tar.gz archive into a container's filesystem
+func ExtractArchive(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters using new API
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	localArchivePath := request.GetString("local_archive_path", "")
+	archiveBase64 := request.GetString("archive_base64", "")
+	if (localArchivePath == "") == (archiveBase64 == "") {
+		return mcp.NewToolResultText("exactly one of local_archive_path or archive_base64 is required"), nil
+	}
+
+	// A local archive is read from disk as it is converted rather than loaded whole
+	var archive io.ReaderAt
+	var size int64
+	source := "inline archive"
+	if localArchivePath != "" {
+		localArchivePath = translateHostPath(localArchivePath)
+		if err := checkHostPath(localArchivePath); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		f, err := os.Open(localArchivePath)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error reading archive: %v", localPathError(err))), nil
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error reading archive: %v", localPathError(err))), nil
+		}
+		archive, size = f, info.Size()
+		source = localArchivePath
+	} else {
+		data, err := base64.StdEncoding.DecodeString(archiveBase64)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error decoding archive_base64: %v", err)), nil
+		}
+		archive, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Get the destination path (optional parameter), defaulting to the working directory
+	destDir := inWorkDir(workDir, request.GetString("dest_dir", ""))
+	prefix := strings.TrimPrefix(path.Clean(filepath.ToSlash(destDir)), "/")
+
+	// Check every entry before anything is written, so a bad archive leaves the container untouched
+	stats, err := archiveToTar(archive, size, prefix, io.Discard)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Re-pack as a tar stream rooted at / so CopyToContainer lands the entries under destDir
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := archiveToTar(archive, size, prefix, pw)
+		pw.CloseWithError(err)
+	}()
+	err = copyArchiveToContainer(ctx, containerIDOrName, pr)
+	pr.Close()
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully extracted %s to %s in container %s (working directory %s): %d files, %d bytes", source, destDir, containerIDOrName, workDir, stats.Files, stats.Bytes)), nil
+}
+
+// copyArchiveToContainer uploads a tar stream whose entries are rooted at the container's filesystem root
+func copyArchiveToContainer(ctx context.Context, containerIDOrName string, tarArchive io.Reader) error {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	if err := cli.CopyToContainer(ctx, containerIDOrName, "/", tarArchive, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+
+	return nil
+}
+
+// archiveToTar detects the archive format and converts it to a tar stream written to w, with every entry placed
+// under prefix
+func archiveToTar(archive io.ReaderAt, size int64, prefix string, w io.Writer) (archiveStats, error) {
+	magic := make([]byte, 262)
+	n, err := archive.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return archiveStats{}, fmt.Errorf("failed to read archive: %w", err)
+	}
+	magic = magic[:n]
+	stream := io.NewSectionReader(archive, 0, size)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return zipToTar(archive, size, prefix, w)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			return archiveStats{}, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		return retarWithPrefix(tar.NewReader(gz), prefix, w)
+	case len(magic) == 262 && string(magic[257:262]) == "ustar":
+		return retarWithPrefix(tar.NewReader(stream), prefix, w)
+	default:
+		return archiveStats{}, fmt.Errorf("unsupported archive format: expected .zip, .tar, .tar.gz or .tgz")
+	}
+}
+
+// zipToTar converts a zip archive into a tar stream
+func zipToTar(archive io.ReaderAt, size int64, prefix string, w io.Writer) (archiveStats, error) {
+	var stats archiveStats
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return stats, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	for _, f := range zr.File {
+		name, err := safeArchivePath(f.Name)
+		if err != nil {
+			return stats, err
+		}
+		if name == "" {
+			continue
+		}
+
+		header, err := tar.FileInfoHeader(f.FileInfo(), "")
+		if err != nil {
+			return stats, fmt.Errorf("failed to convert zip entry %s: %w", f.Name, err)
+		}
+		if f.FileInfo().Mode()&os.ModeSymlink != 0 {
+			return stats, fmt.Errorf("unsupported zip entry %s: symlinks are not allowed", f.Name)
+		}
+		header.Name = path.Join(prefix, name)
+
+		if err := tw.WriteHeader(header); err != nil {
+			return stats, err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return stats, fmt.Errorf("failed to open zip entry %s: %w", f.Name, err)
+		}
+		n, err := io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return stats, fmt.Errorf("failed to read zip entry %s: %w", f.Name, err)
+		}
+		stats.Files++
+		stats.Bytes += n
+	}
+	return stats, tw.Close()
+}
+
+// retarWithPrefix copies a tar stream entry by entry, validating names and link targets. Entries are resolved
+// through the symlinks written before them, as the filesystem will resolve them, so a chain of links that each
+// stay inside can't carry a later entry outside the destination.
+func retarWithPrefix(tr *tar.Reader, prefix string, w io.Writer) (archiveStats, error) {
+	var stats archiveStats
+	tw := tar.NewWriter(w)
+	links := archiveLinks{}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		name, err := safeArchivePath(header.Name)
+		if err != nil {
+			return stats, err
+		}
+		if name == "" {
+			continue
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		// The entry is written where its directory really is, so nothing is written through a link
+		dir, err := links.resolve(path.Dir(name))
+		if err != nil {
+			return stats, fmt.Errorf("unsafe archive entry %s: %w", header.Name, err)
+		}
+		name = path.Join(dir, path.Base(name))
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir:
+			delete(links, name)
+		case tar.TypeSymlink:
+			// Relative targets are resolved against the link's own directory
+			if path.IsAbs(header.Linkname) {
+				return stats, fmt.Errorf("unsafe archive entry %s: symlink to absolute path %s", header.Name, header.Linkname)
+			}
+			delete(links, name)
+			if _, err := links.resolve(dir + "/" + header.Linkname); err != nil {
+				return stats, fmt.Errorf("unsafe archive entry %s: symlink %w", header.Name, err)
+			}
+			links[name] = header.Linkname
+		case tar.TypeLink:
+			target, err := safeArchivePath(header.Linkname)
+			if err == nil {
+				target, err = links.resolve(target)
+			}
+			if err != nil {
+				return stats, fmt.Errorf("unsafe archive entry %s: hard link escapes the destination", header.Name)
+			}
+			delete(links, name)
+			header.Linkname = path.Join(prefix, target)
+		default:
+			return stats, fmt.Errorf("unsupported archive entry %s: only files, directories and links are allowed", header.Name)
+		}
+
+		header.Name = path.Join(prefix, name)
+		if err := tw.WriteHeader(header); err != nil {
+			return stats, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			n, err := io.Copy(tw, tr)
+			if err != nil {
+				return stats, fmt.Errorf("failed to read archive entry %s: %w", header.Name, err)
+			}
+			stats.Files++
+			stats.Bytes += n
+		}
+	}
+	return stats, tw.Close()
+}
+
+// maxLinkHops bounds the symlinks followed resolving one path, as the kernel does, so a loop fails
+const maxLinkHops = 40
+
+// archiveLinks are the symlinks an archive has written so far, keyed by where they are relative to the
+// destination, with their targets as given
+type archiveLinks map[string]string
+
+// resolve follows the links through a relative path component by component, the way the filesystem does, and
+// returns where the path really is. A ".." after a link goes up from the link's target, not from the link.
+func (links archiveLinks) resolve(name string) (string, error) {
+	var resolved []string
+	pending := strings.Split(name, "/")
+	hops := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", fmt.Errorf("escapes the destination")
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		target, isLink := links[strings.Join(append(resolved[:len(resolved):len(resolved)], part), "/")]
+		if !isLink {
+			resolved = append(resolved, part)
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return "", fmt.Errorf("has too many levels of symlinks")
+		}
+		// The target takes the link's place, relative to the directory the link is in
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	if len(resolved) == 0 {
+		return ".", nil
+	}
+	return strings.Join(resolved, "/"), nil
+}
+
+// safeArchivePath normalizes an archive entry name and rejects absolute paths and traversal outside the root
+func safeArchivePath(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(slashed) || (len(slashed) >= 2 && slashed[1] == ':') {
+		return "", fmt.Errorf("unsafe archive entry %s: absolute paths are not allowed", name)
+	}
+
+	cleaned := path.Clean(slashed)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("unsafe archive entry %s: path escapes the destination", name)
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
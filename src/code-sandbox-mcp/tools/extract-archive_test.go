@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// convertArchive runs archiveToTar on an archive held in memory
+func convertArchive(data []byte, prefix string) (io.Reader, archiveStats, error) {
+	buf := new(bytes.Buffer)
+	stats, err := archiveToTar(bytes.NewReader(data), int64(len(data)), prefix, buf)
+	return buf, stats, err
+}
+
+// buildTar creates an uncompressed tar from headers, each given an empty body
+func buildTar(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, header := range headers {
+		require.NoError(t, tw.WriteHeader(header))
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// buildZip creates a zip archive with the given entry names and contents
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// buildNestedTgz creates a gzipped tar with nested directories and an executable file
+func buildNestedTgz(t *testing.T) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	write := func(header *tar.Header, body string) {
+		header.Size = int64(len(body))
+		require.NoError(t, tw.WriteHeader(header))
+		_, err := tw.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	write(&tar.Header{Name: "project/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	write(&tar.Header{Name: "project/src/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	write(&tar.Header{Name: "project/src/app.py", Typeflag: tar.TypeReg, Mode: 0644}, "print('app')\n")
+	write(&tar.Header{Name: "project/bin/run.sh", Typeflag: tar.TypeReg, Mode: 0755}, "#!/bin/sh\n")
+	write(&tar.Header{Name: "project/current", Typeflag: tar.TypeSymlink, Linkname: "src/app.py"}, "")
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveToTarNestedTgz(t *testing.T) {
+	archive, stats, err := convertArchive(buildNestedTgz(t), "app/data")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Files)
+	assert.Equal(t, int64(len("print('app')\n")+len("#!/bin/sh\n")), stats.Bytes)
+
+	entries := readTarEntries(t, archive)
+	require.Contains(t, entries, "app/data/project/src/app.py")
+	require.Contains(t, entries, "app/data/project/bin/run.sh")
+	assert.Equal(t, int64(0755), entries["app/data/project/bin/run.sh"].Mode)
+	assert.Equal(t, "src/app.py", entries["app/data/project/current"].Linkname)
+}
+
+func TestArchiveToTarZip(t *testing.T) {
+	archive, stats, err := convertArchive(buildZip(t, map[string]string{
+		"a.txt":          "a",
+		"nested/b.txt":   "bb",
+		"nested/deeper/": "",
+	}), "app")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Files)
+	assert.Equal(t, int64(3), stats.Bytes)
+
+	entries := readTarEntries(t, archive)
+	assert.Contains(t, entries, "app/a.txt")
+	assert.Contains(t, entries, "app/nested/b.txt")
+	assert.Contains(t, entries, "app/nested/deeper")
+}
+
+func TestArchiveToTarRejectsZipSlip(t *testing.T) {
+	for _, name := range []string{"../evil.txt", "ok/../../evil.txt", "/etc/passwd", "..\\evil.txt", "C:/evil.txt"} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := convertArchive(buildZip(t, map[string]string{
+				"good.txt": "fine",
+				name:       "owned",
+			}), "app")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unsafe archive entry")
+		})
+	}
+}
+
+func TestArchiveToTarRejectsEscapingSymlink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../../etc/shadow"}))
+	require.NoError(t, tw.Close())
+
+	_, _, err := convertArchive(buf.Bytes(), "app")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "symlink escapes")
+}
+
+func TestArchiveToTarRejectsSymlinkChains(t *testing.T) {
+	for name, headers := range map[string][]*tar.Header{
+		// a/b lands at b, so its .. is the destination's parent
+		"link to parent through a link": {
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "a/b/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		// Lexically deep/s/../../.. is the root, but deep/s is the root already
+		"dotdot after a link": {
+			{Name: "deep/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "deep/s", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "t", Typeflag: tar.TypeSymlink, Linkname: "deep/s/../.."},
+		},
+		"hard link through a link": {
+			{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "up/up2", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "h", Typeflag: tar.TypeLink, Linkname: "up/up2/etc/passwd"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := convertArchive(buildTar(t, headers...), "app")
+			assert.ErrorContains(t, err, "unsafe archive entry")
+		})
+	}
+
+	// A loop fails instead of hanging
+	_, _, err := convertArchive(buildTar(t,
+		&tar.Header{Name: "l1", Typeflag: tar.TypeSymlink, Linkname: "l2"},
+		&tar.Header{Name: "l2", Typeflag: tar.TypeSymlink, Linkname: "l1"},
+		&tar.Header{Name: "l1/x", Typeflag: tar.TypeReg, Mode: 0644},
+	), "app")
+	assert.ErrorContains(t, err, "too many levels of symlinks")
+}
+
+func TestArchiveToTarWritesThroughLinksResolved(t *testing.T) {
+	archive, _, err := convertArchive(buildTar(t,
+		&tar.Header{Name: "src/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "current", Typeflag: tar.TypeSymlink, Linkname: "src"},
+		&tar.Header{Name: "current/app.py", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "alias", Typeflag: tar.TypeLink, Linkname: "current/app.py"},
+	), "app")
+	require.NoError(t, err)
+	entries := readTarEntries(t, archive)
+	// Entries inside a link are written where the link points, not through it
+	assert.Contains(t, entries, "app/src/app.py")
+	assert.NotContains(t, entries, "app/current/app.py")
+	assert.Equal(t, "src", entries["app/current"].Linkname)
+	assert.Equal(t, "app/src/app.py", entries["app/alias"].Linkname)
+}
+
+func TestArchiveToTarUnsupportedFormat(t *testing.T) {
+	_, _, err := convertArchive([]byte("definitely not an archive"), "app")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported archive format")
+}
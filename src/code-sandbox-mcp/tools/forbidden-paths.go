@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// forbiddenPath is a host path that must never be handed to a sandbox, whatever the caller asks for: with the
+// Docker socket or the server's own binary a sandbox could control the host, and the Claude config holds
+// credentials and the server configuration itself
+type forbiddenPath struct {
+	Rule string // what the path is, for the error message
+	Path string
+}
+
+// ForbiddenPathError reports a host path refused by the denylist, naming the rule it hit
+type ForbiddenPathError struct {
+	Path      string
+	Rule      string
+	Forbidden string
+	Relation  string // "is", "is inside" or "contains"
+}
+
+func (e *ForbiddenPathError) Error() string {
+	if e.Relation == "is" {
+		return fmt.Sprintf("FORBIDDEN_PATH: %s is %s and can't be given to a sandbox", e.Path, e.Rule)
+	}
+	return fmt.Sprintf("FORBIDDEN_PATH: %s %s %s (%s) and can't be given to a sandbox", e.Path, e.Relation, e.Rule, e.Forbidden)
+}
+
+// forbiddenPaths lists the denied paths for the current host, resolved like the paths they are checked against
+func forbiddenPaths() []forbiddenPath {
+	home, _ := os.UserHomeDir()
+	var paths []forbiddenPath
+	add := func(rule string, p string) {
+		if p != "" {
+			paths = append(paths, forbiddenPath{Rule: rule, Path: canonicalHostPath(p)})
+		}
+	}
+
+	// Docker sockets: the system daemon, rootless and Docker Desktop, and whatever DOCKER_HOST points at
+	for _, socket := range []string{"/var/run/docker.sock", "/run/docker.sock"} {
+		add("the Docker socket", socket)
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		add("the Docker socket", filepath.Join(dir, "docker.sock"))
+	}
+	if home != "" {
+		add("the Docker socket", filepath.Join(home, ".docker", "run", "docker.sock"))
+		add("the Docker socket", filepath.Join(home, ".docker", "desktop", "docker.sock"))
+	}
+	if socket, found := strings.CutPrefix(os.Getenv("DOCKER_HOST"), "unix://"); found {
+		add("the Docker socket", socket)
+	}
+	if runtime.GOOS == "windows" {
+		add("the Docker socket", `\\.\pipe\docker_engine`)
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		add("the sandbox server executable", exe)
+	}
+
+	// Claude Code keeps its settings in ~/.claude and ~/.claude.json, Claude Desktop in a per-OS app dir
+	if home != "" {
+		add("the Claude config directory", filepath.Join(home, ".claude"))
+		add("the Claude config file", filepath.Join(home, ".claude.json"))
+		add("the Claude config directory", filepath.Join(home, ".config", "Claude"))
+		add("the Claude config directory", filepath.Join(home, "Library", "Application Support", "Claude"))
+	}
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		add("the Claude config directory", filepath.Join(appData, "Claude"))
+	}
+	return paths
+}
+
+// canonicalHostPath makes p absolute and resolves its symlinks, so a link can't be used to reach a denied path.
+// A path that doesn't exist is only cleaned; reading it fails later anyway.
+func canonicalHostPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return filepath.Clean(p)
+}
+
+// checkHostPath refuses a host path that is, lies inside or contains a denied path. It applies to every host path
+// read into a sandbox and has no override.
+func checkHostPath(p string) error {
+	resolved := canonicalHostPath(p)
+	for _, denied := range forbiddenPaths() {
+		if relation := pathRelation(resolved, denied.Path); relation != "" {
+			return &ForbiddenPathError{Path: p, Rule: denied.Rule, Forbidden: denied.Path, Relation: relation}
+		}
+	}
+	return nil
+}
+
+// pathRelation tells how p relates to denied: "is", "is inside", "contains", or "" when they are unrelated
+func pathRelation(p, denied string) string {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		// The default filesystems of both are case-insensitive
+		p, denied = strings.ToLower(p), strings.ToLower(denied)
+	}
+	switch {
+	case p == denied:
+		return "is"
+	case isWithin(p, denied):
+		return "is inside"
+	case isWithin(denied, p):
+		return "contains"
+	}
+	return ""
+}
+
+// isWithin reports whether p lies below dir
+func isWithin(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
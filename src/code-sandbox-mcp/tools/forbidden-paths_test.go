@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withForbiddenHome points HOME and DOCKER_HOST into a temp dir holding a fake socket and Claude config
+func withForbiddenHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("APPDATA", "")
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".claude"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".claude", "settings.json"), []byte("{}"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "run"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "run", "docker.sock"), nil, 0600))
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(home, "run", "docker.sock"))
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "project"), 0755))
+	return home
+}
+
+func TestCheckHostPathForbidden(t *testing.T) {
+	home := withForbiddenHome(t)
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		path     string
+		rule     string
+		relation string
+	}{
+		{"/var/run/docker.sock", "the Docker socket", "is"},
+		{filepath.Join(home, "run", "docker.sock"), "the Docker socket", "is"},
+		{filepath.Join(home, "run"), "the Docker socket", "contains"},
+		{exe, "the sandbox server executable", "is"},
+		{filepath.Dir(exe), "the sandbox server executable", "contains"},
+		{filepath.Join(home, ".claude"), "the Claude config directory", "is"},
+		{filepath.Join(home, ".claude", "settings.json"), "the Claude config directory", "is inside"},
+		{filepath.Join(home, ".claude", "..", ".claude"), "the Claude config directory", "is"},
+		{filepath.Join(home, ".claude.json"), "the Claude config file", "is"},
+		{home, "", "contains"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			err := checkHostPath(tc.path)
+			var forbidden *ForbiddenPathError
+			require.True(t, errors.As(err, &forbidden), "expected FORBIDDEN_PATH, got %v", err)
+			assert.Equal(t, tc.relation, forbidden.Relation)
+			if tc.rule != "" {
+				assert.Equal(t, tc.rule, forbidden.Rule)
+			}
+			assert.Contains(t, err.Error(), "FORBIDDEN_PATH: "+tc.path)
+		})
+	}
+
+	assert.NoError(t, checkHostPath(filepath.Join(home, "project")))
+	assert.NoError(t, checkHostPath(filepath.Join(home, "project", "missing.txt")))
+}
+
+func TestCheckHostPathFollowsSymlinks(t *testing.T) {
+	home := withForbiddenHome(t)
+	project := filepath.Join(home, "project")
+
+	links := map[string]string{
+		"sock":     filepath.Join(home, "run", "docker.sock"),
+		"claude":   filepath.Join(home, ".claude"),
+		"settings": filepath.Join(home, ".claude", "settings.json"),
+		"root":     home,
+	}
+	for name, target := range links {
+		require.NoError(t, os.Symlink(target, filepath.Join(project, name)))
+	}
+	for name := range links {
+		err := checkHostPath(filepath.Join(project, name))
+		assert.ErrorContains(t, err, "FORBIDDEN_PATH: "+filepath.Join(project, name), name)
+	}
+
+	// A symlinked project dir is checked where it points
+	require.NoError(t, os.Symlink(filepath.Join(home, ".claude"), filepath.Join(home, "innocent")))
+	assert.ErrorContains(t, checkHostPath(filepath.Join(home, "innocent")), "is the Claude config directory")
+}
+
+func TestForbiddenPathErrorMessage(t *testing.T) {
+	err := &ForbiddenPathError{Path: "/srv", Rule: "the sandbox server executable", Forbidden: "/srv/bin/code-sandbox-mcp", Relation: "contains"}
+	assert.EqualError(t, err, "FORBIDDEN_PATH: /srv contains the sandbox server executable (/srv/bin/code-sandbox-mcp) and can't be given to a sandbox")
+	err = &ForbiddenPathError{Path: "/run/docker.sock", Rule: "the Docker socket", Forbidden: "/run/docker.sock", Relation: "is"}
+	assert.EqualError(t, err, "FORBIDDEN_PATH: /run/docker.sock is the Docker socket and can't be given to a sandbox")
+}
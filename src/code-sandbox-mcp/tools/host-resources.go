@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+// Below these free amounts sandbox_initialize and run_command refuse to pull images or create containers, since a
+// full disk can wedge the Docker daemon; override them with SANDBOX_MIN_FREE_DISK_MB and SANDBOX_MIN_FREE_MEMORY_MB
+const (
+	defaultMinFreeDisk   = 2 * 1024 * 1024 * 1024
+	defaultMinFreeMemory = 512 * 1024 * 1024
+)
+
+// hostThresholds are the minimum free disk and memory in bytes; 0 disables a check
+var hostThresholds = struct {
+	Disk   uint64
+	Memory uint64
+}{Disk: defaultMinFreeDisk, Memory: defaultMinFreeMemory}
+
+// LoadHostThresholdsFromEnv reads SANDBOX_MIN_FREE_DISK_MB and SANDBOX_MIN_FREE_MEMORY_MB; 0 disables a check
+func LoadHostThresholdsFromEnv() error {
+	for _, setting := range []struct {
+		name   string
+		target *uint64
+	}{
+		{"SANDBOX_MIN_FREE_DISK_MB", &hostThresholds.Disk},
+		{"SANDBOX_MIN_FREE_MEMORY_MB", &hostThresholds.Memory},
+	} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		mb, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be a number of megabytes", setting.name, value)
+		}
+		*setting.target = mb * 1024 * 1024
+	}
+	return nil
+}
+
+// HostResources are the free disk space under the Docker root dir and the available host memory. Measurements
+// that aren't possible, such as for a remote daemon or one inside a VM, are left out.
+type HostResources struct {
+	DockerRootDir        string  `json:"docker_root_dir,omitempty"`
+	DiskFreeBytes        *uint64 `json:"disk_free_bytes,omitempty"`
+	MemoryAvailableBytes *uint64 `json:"memory_available_bytes,omitempty"`
+	MinDiskFreeBytes     uint64  `json:"min_disk_free_bytes"`
+	MinMemoryFreeBytes   uint64  `json:"min_memory_free_bytes"`
+}
+
+// HostResourcesLowError reports that the host is below a free disk or memory threshold
+type HostResourcesLowError struct {
+	Resources HostResources
+	Problems  []string
+}
+
+func (e *HostResourcesLowError) Error() string {
+	return fmt.Sprintf("HOST_RESOURCES_LOW: %s; free up space or memory, or pass force=true to proceed anyway", strings.Join(e.Problems, "; "))
+}
+
+// hostProbe measures the host, replaced in tests
+var hostProbe = struct {
+	DiskFree        func(path string) (uint64, error)
+	MemoryAvailable func() (uint64, error)
+}{
+	DiskFree:        diskFree,
+	MemoryAvailable: memoryAvailable,
+}
+
+// dockerInfoClient is the part of the Docker client used to find the daemon's root dir
+type dockerInfoClient interface {
+	DaemonHost() string
+	Info(ctx context.Context) (system.Info, error)
+}
+
+// measureHostResources measures the host of a daemon reached through a local socket; for a remote daemon the
+// local filesystem and memory say nothing about it
+func measureHostResources(ctx context.Context, cli dockerInfoClient) HostResources {
+	resources := HostResources{MinDiskFreeBytes: hostThresholds.Disk, MinMemoryFreeBytes: hostThresholds.Memory}
+	if !strings.HasPrefix(cli.DaemonHost(), "unix://") && !strings.HasPrefix(cli.DaemonHost(), "npipe://") {
+		return resources
+	}
+	if info, err := cli.Info(ctx); err == nil && info.DockerRootDir != "" {
+		resources.DockerRootDir = info.DockerRootDir
+		// Docker Desktop reports the root dir inside its VM, which doesn't exist on the host
+		if free, err := hostProbe.DiskFree(info.DockerRootDir); err == nil {
+			resources.DiskFreeBytes = &free
+		}
+	}
+	if available, err := hostProbe.MemoryAvailable(); err == nil {
+		resources.MemoryAvailableBytes = &available
+	}
+	return resources
+}
+
+// check returns a HostResourcesLowError when a measured amount is below its threshold
+func (r HostResources) check() error {
+	var problems []string
+	if r.DiskFreeBytes != nil && r.MinDiskFreeBytes > 0 && *r.DiskFreeBytes < r.MinDiskFreeBytes {
+		problems = append(problems, fmt.Sprintf("%s free on %s, below the minimum of %s",
+			formatBytes(*r.DiskFreeBytes), r.DockerRootDir, formatBytes(r.MinDiskFreeBytes)))
+	}
+	if r.MemoryAvailableBytes != nil && r.MinMemoryFreeBytes > 0 && *r.MemoryAvailableBytes < r.MinMemoryFreeBytes {
+		problems = append(problems, fmt.Sprintf("%s of host memory available, below the minimum of %s",
+			formatBytes(*r.MemoryAvailableBytes), formatBytes(r.MinMemoryFreeBytes)))
+	}
+	if len(problems) > 0 {
+		return &HostResourcesLowError{Resources: r, Problems: problems}
+	}
+	return nil
+}
+
+// checkHostResources refuses to go on when the Docker host is low on disk or memory
+func checkHostResources(ctx context.Context) error {
+	if hostThresholds.Disk == 0 && hostThresholds.Memory == 0 {
+		return nil
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+	return measureHostResources(ctx, cli).check()
+}
+
+// currentHostResources measures the host for sandbox_server_info, or returns nil when nothing can be measured
+func currentHostResources(ctx context.Context) *HostResources {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return nil
+	}
+	defer cli.Close()
+	resources := measureHostResources(ctx, cli)
+	if resources.DiskFreeBytes == nil && resources.MemoryAvailableBytes == nil {
+		return nil
+	}
+	return &resources
+}
+
+// memoryAvailable reads MemAvailable from /proc/meminfo, which only exists on Linux
+func memoryAvailable() (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMemAvailable(bufio.NewScanner(f))
+}
+
+// parseMemAvailable finds the "MemAvailable: N kB" line of /proc/meminfo
+func parseMemAvailable(scanner *bufio.Scanner) (uint64, error) {
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), "MemAvailable:")
+		if !found {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemAvailable line %q", scanner.Text())
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+}
+
+// formatBytes prints a size in MB, or in GB with one decimal from 1GB up
+func formatBytes(bytes uint64) string {
+	const mb = 1024 * 1024
+	if bytes >= 1024*mb {
+		return strconv.FormatFloat(float64(bytes)/(1024*mb), 'f', 1, 64) + "GB"
+	}
+	return fmt.Sprintf("%dMB", bytes/mb)
+}
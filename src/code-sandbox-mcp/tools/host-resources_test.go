@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr(v uint64) *uint64 { return &v }
+
+func TestHostResourcesCheck(t *testing.T) {
+	const mb = 1024 * 1024
+	base := HostResources{DockerRootDir: "/var/lib/docker", MinDiskFreeBytes: 2048 * mb, MinMemoryFreeBytes: 512 * mb}
+
+	tests := []struct {
+		name     string
+		disk     *uint64
+		memory   *uint64
+		problems []string
+	}{
+		{"plenty", ptr(50 * 1024 * mb), ptr(8 * 1024 * mb), nil},
+		{"at the thresholds", ptr(2048 * mb), ptr(512 * mb), nil},
+		{"disk just below", ptr(2048*mb - 1), ptr(8 * 1024 * mb), []string{"2.0GB free on /var/lib/docker, below the minimum of 2.0GB"}},
+		{"memory low", ptr(50 * 1024 * mb), ptr(300 * mb), []string{"300MB of host memory available, below the minimum of 512MB"}},
+		{"both low", ptr(1200 * mb), ptr(100 * mb), []string{
+			"1.2GB free on /var/lib/docker, below the minimum of 2.0GB",
+			"100MB of host memory available, below the minimum of 512MB",
+		}},
+		// What couldn't be measured isn't held against the host
+		{"unmeasured", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := base
+			r.DiskFreeBytes, r.MemoryAvailableBytes = tt.disk, tt.memory
+			err := r.check()
+			if tt.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var lowErr *HostResourcesLowError
+			require.ErrorAs(t, err, &lowErr)
+			assert.Equal(t, tt.problems, lowErr.Problems)
+			assert.True(t, strings.HasPrefix(err.Error(), "HOST_RESOURCES_LOW: "), err.Error())
+			assert.Contains(t, err.Error(), "force=true")
+		})
+	}
+
+	// A threshold of 0 disables its check
+	r := base
+	r.MinDiskFreeBytes = 0
+	r.DiskFreeBytes = ptr(0)
+	assert.NoError(t, r.check())
+}
+
+func TestLoadHostThresholdsFromEnv(t *testing.T) {
+	saved := hostThresholds
+	t.Cleanup(func() { hostThresholds = saved })
+
+	t.Setenv("SANDBOX_MIN_FREE_DISK_MB", "10240")
+	t.Setenv("SANDBOX_MIN_FREE_MEMORY_MB", "0")
+	require.NoError(t, LoadHostThresholdsFromEnv())
+	assert.Equal(t, uint64(10240*1024*1024), hostThresholds.Disk)
+	assert.Equal(t, uint64(0), hostThresholds.Memory)
+
+	t.Setenv("SANDBOX_MIN_FREE_DISK_MB", "2GB")
+	assert.ErrorContains(t, LoadHostThresholdsFromEnv(), "invalid SANDBOX_MIN_FREE_DISK_MB")
+}
+
+func TestParseMemAvailable(t *testing.T) {
+	meminfo := "MemTotal:       16314444 kB\nMemFree:          623872 kB\nMemAvailable:    8123456 kB\nBuffers:          401232 kB\n"
+	available, err := parseMemAvailable(bufio.NewScanner(strings.NewReader(meminfo)))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8123456*1024), available)
+
+	_, err = parseMemAvailable(bufio.NewScanner(strings.NewReader("MemTotal: 1 kB\n")))
+	assert.ErrorContains(t, err, "MemAvailable not found")
+}
+
+// fakeInfoClient reports a daemon host and root dir without a daemon
+type fakeInfoClient struct {
+	host    string
+	rootDir string
+}
+
+func (f fakeInfoClient) DaemonHost() string { return f.host }
+
+func (f fakeInfoClient) Info(ctx context.Context) (system.Info, error) {
+	return system.Info{DockerRootDir: f.rootDir}, nil
+}
+
+func TestMeasureHostResources(t *testing.T) {
+	saved := hostProbe
+	t.Cleanup(func() { hostProbe = saved })
+	const mb = 1024 * 1024
+	var probedPath string
+	hostProbe.DiskFree = func(path string) (uint64, error) {
+		probedPath = path
+		return 1500 * mb, nil
+	}
+	hostProbe.MemoryAvailable = func() (uint64, error) { return 4096 * mb, nil }
+
+	r := measureHostResources(context.Background(), fakeInfoClient{host: "unix:///var/run/docker.sock", rootDir: "/var/lib/docker"})
+	assert.Equal(t, "/var/lib/docker", probedPath)
+	require.NotNil(t, r.DiskFreeBytes)
+	assert.Equal(t, uint64(1500*mb), *r.DiskFreeBytes)
+	require.NotNil(t, r.MemoryAvailableBytes)
+	assert.ErrorContains(t, r.check(), "HOST_RESOURCES_LOW: 1.5GB free on /var/lib/docker, below the minimum of 2.0GB")
+
+	// Docker Desktop's root dir is inside its VM and can't be measured from the host
+	hostProbe.DiskFree = func(string) (uint64, error) { return 0, errors.New("no such file or directory") }
+	r = measureHostResources(context.Background(), fakeInfoClient{host: "unix:///var/run/docker.sock", rootDir: "/var/lib/docker"})
+	assert.Nil(t, r.DiskFreeBytes)
+	assert.NoError(t, r.check())
+
+	// The local host says nothing about a remote daemon
+	r = measureHostResources(context.Background(), fakeInfoClient{host: "tcp://10.0.0.5:2376", rootDir: "/var/lib/docker"})
+	assert.Nil(t, r.DiskFreeBytes)
+	assert.Nil(t, r.MemoryAvailableBytes)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package tools
+
+import "errors"
+
+// diskFree is not measured on Windows, where the daemon's root dir lives inside the Docker Desktop VM
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk space is not measured on Windows")
+}
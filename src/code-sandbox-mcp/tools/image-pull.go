@@ -0,0 +1,261 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/events"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
+	dockerImage "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// Image pull failure codes
+const (
+	PullImageNotFound       = "IMAGE_NOT_FOUND"
+	PullUnauthorized        = "IMAGE_UNAUTHORIZED"
+	PullRegistryUnreachable = "REGISTRY_UNREACHABLE"
+	PullDiskFull            = "DISK_FULL"
+	PullFailed              = "IMAGE_PULL_FAILED"
+)
+
+// ImagePullError is a classified image pull failure with a suggestion for what to do differently
+type ImagePullError struct {
+	Code       string
+	Image      string
+	Err        error
+	Suggestion string
+}
+
+func (e *ImagePullError) Error() string {
+	msg := fmt.Sprintf("%s: failed to pull Docker image %s: %v", e.Code, e.Image, e.Err)
+	if e.Suggestion != "" {
+		msg += "; " + e.Suggestion
+	}
+	return msg
+}
+
+func (e *ImagePullError) Unwrap() error {
+	return e.Err
+}
+
+// defaultMaxPulls bounds the distinct images pulled at once when SANDBOX_MAX_PULLS isn't set
+const defaultMaxPulls = 2
+
+// imagePulls coordinates the pulls of all tool calls
+var imagePulls = newPullCoordinator(defaultMaxPulls)
+
+// LoadPullLimitFromEnv reads SANDBOX_MAX_PULLS, the number of distinct images pulled at once
+func LoadPullLimitFromEnv() error {
+	limit, err := positiveIntFromEnv("SANDBOX_MAX_PULLS", defaultMaxPulls)
+	if err != nil {
+		return err
+	}
+	imagePulls = newPullCoordinator(limit)
+	return nil
+}
+
+// pullImage pulls an image and waits for the pull to finish, reporting its status lines to onStatus, which may be
+// nil. Failures reported in the progress stream, such as a full disk while extracting layers, are returned like
+// failures of the request itself. When the registry can't be reached, a copy of the image already present
+// locally is used.
+func pullImage(ctx context.Context, cli *client.Client, image string, onStatus func(string)) (err error) {
+	ctx, span := tracing.Start(ctx, "image.pull", tracing.ImageKey.String(image))
+	defer func() { tracing.End(span, err) }()
+
+	err = imagePulls.Pull(ctx, cli, image, onStatus)
+	if err == nil {
+		return nil
+	}
+	pullErr := classifyPullError(image, err)
+	if pullErr.Code == PullRegistryUnreachable {
+		if _, inspectErr := cli.ImageInspect(ctx, image); inspectErr == nil {
+			return nil
+		}
+	}
+	return pullErr
+}
+
+func pullAndWait(ctx context.Context, cli *client.Client, image string, onStatus func(string)) error {
+	pullCtx, cancel := withDockerTimeout(ctx, pullTimeout)
+	defer cancel()
+	start := time.Now()
+	reader, err := cli.ImagePull(pullCtx, image, dockerImage.PullOptions{})
+	if err != nil {
+		return dockerTimeoutError(ctx, "pulling the image", pullTimeout, err)
+	}
+	defer reader.Close()
+	if err := readPullStream(reader, onStatus); err != nil {
+		return dockerTimeoutError(ctx, "pulling the image", pullTimeout, err)
+	}
+	events.Emit(events.Event{Type: events.ImagePulled, Image: image, DurationMS: time.Since(start).Milliseconds()})
+	return nil
+}
+
+// readPullStream reads the progress stream of a pull to its end, passing on the status lines of each layer but
+// not the byte counts in between
+func readPullStream(reader io.Reader, onStatus func(string)) error {
+	decoder := json.NewDecoder(reader)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != nil {
+			return message.Error
+		}
+		if message.Status == "" || (message.Progress != nil && message.Progress.Current > 0) {
+			continue
+		}
+		status := message.Status
+		if message.ID != "" {
+			status = message.ID + ": " + status
+		}
+		onStatus(status)
+	}
+}
+
+// pullCoordinator shares one pull between the calls that need the same image at the same time, and queues the
+// pulls of distinct images beyond a limit, so parallel calls don't pull twice or trip registry rate limits
+type pullCoordinator struct {
+	mu    sync.Mutex
+	slots chan struct{}
+	pulls map[string]*sharedPull
+	pull  func(ctx context.Context, cli *client.Client, image string, onStatus func(string)) error // replaced in tests
+}
+
+// sharedPull is a pull in progress and the calls waiting for it
+type sharedPull struct {
+	done     chan struct{}
+	err      error
+	cancel   context.CancelFunc
+	waiters  int
+	next     int
+	watchers map[int]func(string)
+}
+
+func newPullCoordinator(limit int) *pullCoordinator {
+	return &pullCoordinator{slots: make(chan struct{}, limit), pulls: map[string]*sharedPull{}, pull: pullAndWait}
+}
+
+// Pull pulls image, or joins the pull of it already in progress, and waits for it to finish. The pull runs on
+// until no call is waiting for it; a call that gives up leaves it to the others.
+func (c *pullCoordinator) Pull(ctx context.Context, cli *client.Client, image string, onStatus func(string)) error {
+	c.mu.Lock()
+	p, joined := c.pulls[image]
+	if !joined {
+		pullCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		p = &sharedPull{done: make(chan struct{}), cancel: cancel, watchers: map[int]func(string){}}
+		c.pulls[image] = p
+		go c.run(pullCtx, cli, image, p)
+	}
+	id := p.next
+	p.next++
+	p.waiters++
+	if onStatus != nil {
+		p.watchers[id] = onStatus
+	}
+	c.mu.Unlock()
+	if joined && onStatus != nil {
+		onStatus(fmt.Sprintf("Waiting for the pull of %s already in progress", image))
+	}
+
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(p.watchers, id)
+		if p.waiters--; p.waiters == 0 {
+			p.cancel()
+		}
+	}()
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run pulls an image once a slot is free and hands the result to everyone waiting for it
+func (c *pullCoordinator) run(ctx context.Context, cli *client.Client, image string, p *sharedPull) {
+	err := func() error {
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			c.broadcast(p, fmt.Sprintf("Queued behind %d other image pulls", cap(c.slots)))
+			select {
+			case c.slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		defer func() { <-c.slots }()
+		return c.pull(ctx, cli, image, func(status string) { c.broadcast(p, status) })
+	}()
+
+	// A call that comes after this starts a pull of its own, which checks for a newer image again
+	c.mu.Lock()
+	delete(c.pulls, image)
+	p.err = err
+	c.mu.Unlock()
+	close(p.done)
+	p.cancel()
+}
+
+// broadcast passes a status line of a pull to every call waiting for it
+func (c *pullCoordinator) broadcast(p *sharedPull, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, onStatus := range p.watchers {
+		onStatus(status)
+	}
+}
+
+// classifyPullError maps a pull error to a code using the Docker error types, falling back to the message for
+// errors that only arrive as text in the progress stream
+func classifyPullError(image string, err error) *ImagePullError {
+	msg := strings.ToLower(err.Error())
+	containsAny := func(patterns ...string) bool {
+		for _, p := range patterns {
+			if strings.Contains(msg, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	pullErr := &ImagePullError{Code: PullFailed, Image: image, Err: err}
+	switch {
+	case containsAny("no space left on device", "disk quota exceeded"):
+		pullErr.Code = PullDiskFull
+		pullErr.Suggestion = "the Docker host is out of disk space; free space on it, for example with `docker system prune`"
+	// Docker Hub answers "pull access denied" for repositories that don't exist as well as private ones
+	case containsAny("pull access denied", "repository does not exist"):
+		pullErr.Code = PullImageNotFound
+		pullErr.Suggestion = "check the image name; if the repository is private, run `docker login` for its registry on the Docker host"
+	case errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err) ||
+		containsAny("unauthorized", "authentication required", "denied:", "401 ", "403 "):
+		pullErr.Code = PullUnauthorized
+		pullErr.Suggestion = "the registry rejected the credentials; run `docker login` for its registry on the Docker host"
+	case errdefs.IsNotFound(err) || containsAny("manifest unknown", "not found", "manifest for", "name unknown"):
+		pullErr.Code = PullImageNotFound
+		pullErr.Suggestion = "check that the image and tag exist, for example on the registry's web page; retrying the same name will fail again"
+	case errors.Is(err, context.DeadlineExceeded) || errdefs.IsUnavailable(err) ||
+		containsAny("no such host", "i/o timeout", "connection refused", "connection reset", "network is unreachable",
+			"tls handshake", "x509:", "dial tcp", "request canceled while waiting", "client.timeout exceeded", "temporary failure in name resolution",
+			"server misbehaving"):
+		pullErr.Code = PullRegistryUnreachable
+		pullErr.Suggestion = "the Docker daemon cannot reach the registry (offline, DNS, proxy or TLS problem); use an image that is already present locally or fix the daemon's network access"
+	}
+	return pullErr
+}
@@ -0,0 +1,280 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyPullError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"tag not found", errdefs.NotFound(errors.New("manifest for python:3.99 not found: manifest unknown")), PullImageNotFound},
+		{"manifest unknown in stream", &jsonmessage.JSONError{Message: "manifest unknown: manifest unknown"}, PullImageNotFound},
+		{"missing repository", errors.New("pull access denied for nosuchimage, repository does not exist or may require 'docker login'"), PullImageNotFound},
+		{"unauthorized", errdefs.Unauthorized(errors.New("unauthorized: authentication required")), PullUnauthorized},
+		{"forbidden", errors.New("Error response from daemon: Head \"https://ghcr.io/v2/org/img/manifests/1\": denied: denied"), PullUnauthorized},
+		{"dns", errors.New("Get \"https://registry-1.docker.io/v2/\": dial tcp: lookup registry-1.docker.io: no such host"), PullRegistryUnreachable},
+		{"tls", errors.New("Get \"https://registry.local/v2/\": tls: failed to verify certificate: x509: certificate signed by unknown authority"), PullRegistryUnreachable},
+		{"timeout", context.DeadlineExceeded, PullRegistryUnreachable},
+		{"disk full", &jsonmessage.JSONError{Message: "failed to register layer: write /var/lib/docker/tmp: no space left on device"}, PullDiskFull},
+		{"other", errors.New("invalid reference format"), PullFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := classifyPullError("img:tag", c.err)
+			assert.Equal(t, c.code, err.Code)
+			assert.True(t, strings.HasPrefix(err.Error(), c.code+": failed to pull Docker image img:tag: "), err.Error())
+			assert.ErrorIs(t, err, c.err)
+			if c.code != PullFailed {
+				assert.NotEmpty(t, err.Suggestion)
+			}
+		})
+	}
+}
+
+// fakeDockerAPI serves a pull whose progress stream ends with streamError, and reports whether the image is
+// present locally
+func fakeDockerAPI(t *testing.T, streamError string, present bool) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			fmt.Fprintln(w, `{"status":"Pulling from library/python"}`)
+			if streamError != "" {
+				fmt.Fprintf(w, "{\"errorDetail\":{\"message\":%q},\"error\":%q}\n", streamError, streamError)
+			}
+		case strings.HasSuffix(r.URL.Path, "/json") && present:
+			fmt.Fprintln(w, `{"Id":"sha256:abc"}`)
+		default:
+			http.Error(w, `{"message":"No such image"}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.47"))
+	require.NoError(t, err)
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func TestPullImageTiming(t *testing.T) {
+	ctx, timing := tracing.WithTiming(context.Background())
+	assert.Nil(t, timing.Milliseconds(), "nothing has been pulled yet")
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintln(w, `{"status":"Downloaded newer image for python:3.12"}`)
+	}))
+	t.Cleanup(slow.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(slow.URL, "http://")), client.WithVersion("1.47"))
+	require.NoError(t, err)
+	t.Cleanup(func() { cli.Close() })
+
+	require.NoError(t, pullImage(ctx, cli, "python:3.12", nil))
+	assert.GreaterOrEqual(t, timing.Milliseconds()["image_pull_ms"], int64(20))
+}
+
+func TestPullImageStreamError(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, pullImage(ctx, fakeDockerAPI(t, "", false), "python:3.12", nil))
+
+	err := pullImage(ctx, fakeDockerAPI(t, "write /var/lib/docker: no space left on device", false), "python:3.12", nil)
+	var pullErr *ImagePullError
+	require.ErrorAs(t, err, &pullErr)
+	assert.Equal(t, PullDiskFull, pullErr.Code)
+}
+
+func TestPullImageOfflineUsesLocalImage(t *testing.T) {
+	ctx := context.Background()
+	offline := "Get \"https://registry-1.docker.io/v2/\": dial tcp: lookup registry-1.docker.io: no such host"
+	assert.NoError(t, pullImage(ctx, fakeDockerAPI(t, offline, true), "python:3.12", nil))
+
+	err := pullImage(ctx, fakeDockerAPI(t, offline, false), "python:3.12", nil)
+	var pullErr *ImagePullError
+	require.ErrorAs(t, err, &pullErr)
+	assert.Equal(t, PullRegistryUnreachable, pullErr.Code)
+}
+
+// countingPuller stands in for the Docker client, counting the pulls of each image and holding them until released
+type countingPuller struct {
+	mu      sync.Mutex
+	pulls   map[string]int
+	running int
+	peak    int
+	release chan struct{}
+}
+
+func (p *countingPuller) pull(ctx context.Context, cli *client.Client, image string, onStatus func(string)) error {
+	p.mu.Lock()
+	p.pulls[image]++
+	p.running++
+	p.peak = max(p.peak, p.running)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+
+	onStatus("Pulling from library/" + image)
+	select {
+	case <-p.release:
+		onStatus("Status: Downloaded newer image for " + image)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *countingPuller) count(image string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pulls[image]
+}
+
+func newCountingCoordinator(limit int) (*pullCoordinator, *countingPuller) {
+	puller := &countingPuller{pulls: map[string]int{}, release: make(chan struct{})}
+	coordinator := newPullCoordinator(limit)
+	coordinator.pull = puller.pull
+	return coordinator, puller
+}
+
+func TestPullCoordinatorSharesPulls(t *testing.T) {
+	coordinator, puller := newCountingCoordinator(2)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	statuses := make([][]string, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = coordinator.Pull(context.Background(), nil, "python:3.12", func(status string) {
+				mu.Lock()
+				defer mu.Unlock()
+				statuses[i] = append(statuses[i], status)
+			})
+		}()
+	}
+	require.Eventually(t, func() bool {
+		coordinator.mu.Lock()
+		defer coordinator.mu.Unlock()
+		p := coordinator.pulls["python:3.12"]
+		return p != nil && p.waiters == callers
+	}, time.Second, time.Millisecond)
+	close(puller.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, puller.count("python:3.12"))
+	for i := range callers {
+		require.NoError(t, errs[i])
+		assert.Contains(t, statuses[i], "Status: Downloaded newer image for python:3.12", "caller %d sees the shared pull's progress", i)
+	}
+	assert.Empty(t, coordinator.pulls)
+
+	// Once the pull is over, the next call pulls again to check for a newer image
+	require.NoError(t, coordinator.Pull(context.Background(), nil, "python:3.12", nil))
+	assert.Equal(t, 2, puller.count("python:3.12"))
+}
+
+func TestPullCoordinatorLimitsDistinctPulls(t *testing.T) {
+	coordinator, puller := newCountingCoordinator(2)
+
+	images := []string{"python:3.12", "node:22", "golang:1.24", "alpine:3.20"}
+	var wg sync.WaitGroup
+	var queued sync.Map
+	for _, image := range images {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, coordinator.Pull(context.Background(), nil, image, func(status string) {
+				if strings.HasPrefix(status, "Queued behind") {
+					queued.Store(image, true)
+				}
+			}))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		puller.mu.Lock()
+		defer puller.mu.Unlock()
+		return puller.running == 2
+	}, time.Second, time.Millisecond)
+	close(puller.release)
+	wg.Wait()
+
+	assert.Equal(t, 2, puller.peak)
+	queuedCount := 0
+	queued.Range(func(any, any) bool { queuedCount++; return true })
+	assert.Equal(t, 2, queuedCount)
+	for _, image := range images {
+		assert.Equal(t, 1, puller.count(image))
+	}
+}
+
+func TestPullCoordinatorCancellation(t *testing.T) {
+	coordinator, puller := newCountingCoordinator(2)
+
+	// A caller giving up leaves the pull to the one still waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	gaveUp := make(chan error)
+	go func() { gaveUp <- coordinator.Pull(ctx, nil, "python:3.12", nil) }()
+	stayed := make(chan error)
+	require.Eventually(t, func() bool { return puller.count("python:3.12") == 1 }, time.Second, time.Millisecond)
+	go func() { stayed <- coordinator.Pull(context.Background(), nil, "python:3.12", nil) }()
+	require.Eventually(t, func() bool {
+		coordinator.mu.Lock()
+		defer coordinator.mu.Unlock()
+		return coordinator.pulls["python:3.12"].waiters == 2
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-gaveUp, context.Canceled)
+	close(puller.release)
+	assert.NoError(t, <-stayed)
+	assert.Equal(t, 1, puller.count("python:3.12"))
+
+	// When every caller gives up, the pull is cancelled
+	puller.release = make(chan struct{})
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { gaveUp <- coordinator.Pull(ctx, nil, "node:22", nil) }()
+	require.Eventually(t, func() bool { return puller.count("node:22") == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-gaveUp, context.Canceled)
+	require.Eventually(t, func() bool {
+		coordinator.mu.Lock()
+		defer coordinator.mu.Unlock()
+		return len(coordinator.pulls) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestReadPullStream(t *testing.T) {
+	stream := `{"status":"Pulling from library/python","id":"3.12"}
+{"status":"Downloading","progressDetail":{"current":10,"total":100},"progress":"[=>   ]","id":"a1b2"}
+{"status":"Pull complete","progressDetail":{},"id":"a1b2"}
+{"status":"Status: Downloaded newer image for python:3.12"}
+`
+	var statuses []string
+	require.NoError(t, readPullStream(strings.NewReader(stream), func(status string) { statuses = append(statuses, status) }))
+	assert.Equal(t, []string{"3.12: Pulling from library/python", "a1b2: Pull complete", "Status: Downloaded newer image for python:3.12"}, statuses)
+
+	err := readPullStream(strings.NewReader(`{"errorDetail":{"message":"no space left on device"},"error":"no space left on device"}`), func(string) {})
+	assert.EqualError(t, err, "no space left on device")
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// LatestSandbox is the container_id_or_name value that picks the most recently created running sandbox; the
+// "@" keeps it apart from container names, which cannot contain one
+const LatestSandbox = "@latest"
+
+// ImplicitSandboxDescription is appended to the container_id_or_name description of the tools that accept an
+// implicit sandbox
+const ImplicitSandboxDescription = `; "` + LatestSandbox + `" picks the most recently created sandbox, and it may be omitted while exactly one sandbox is running`
+
+// NoSandboxError is returned when an implicit reference finds no running sandbox
+type NoSandboxError struct{}
+
+func (e *NoSandboxError) Error() string {
+	return "NO_SANDBOX: no sandbox is running; create one with sandbox_initialize"
+}
+
+// listImplicitSandboxes lists the running sandboxes an implicit reference chooses from, replaced in tests
+var listImplicitSandboxes = func(ctx context.Context) ([]container.Summary, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+	return ListSandboxContainers(ctx, cli, false, false)
+}
+
+// pickImplicitSandbox resolves "@latest" to the newest sandbox, and an omitted reference to the only one
+func pickImplicitSandbox(sandboxes []container.Summary, ref string) (container.Summary, error) {
+	if len(sandboxes) == 0 {
+		return container.Summary{}, &NoSandboxError{}
+	}
+	sorted := append([]container.Summary(nil), sandboxes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Created > sorted[j].Created })
+	if ref == LatestSandbox || len(sorted) == 1 {
+		return sorted[0], nil
+	}
+
+	candidates := make([]string, len(sorted))
+	for i, c := range sorted {
+		candidates[i] = c.ID[:12]
+		if name := ContainerName(c); name != "" {
+			candidates[i] += " (" + name + ")"
+		}
+	}
+	return container.Summary{}, &AmbiguousReferenceError{Candidates: candidates}
+}
+
+// ImplicitSandboxMiddleware lets the named tools be called with container_id_or_name set to "@latest" or left
+// out, which picks the session's active sandbox if set_active_sandbox chose one. The reference is resolved
+// before the handler runs, and the result ends with a line naming the sandbox that was used, so there's no
+// doubt about what was touched.
+func ImplicitSandboxMiddleware(toolNames []string) server.ToolHandlerMiddleware {
+	accepts := make(map[string]bool, len(toolNames))
+	for _, name := range toolNames {
+		accepts[name] = true
+	}
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			ref := request.GetString("container_id_or_name", "")
+			if !accepts[request.Params.Name] || (ref != "" && ref != LatestSandbox) {
+				return next(ctx, request)
+			}
+
+			// An omitted reference means the session's active sandbox when it has one
+			var resolved, containerID, how string
+			if active, ok := lookupActiveSandbox(ctx); ok && ref == "" {
+				if active.Stopped {
+					return mcp.NewToolResultText(fmt.Sprintf("Error: %v", &NoActiveSandboxError{Stopped: active.Ref})), nil
+				}
+				resolved, containerID, how = active.Ref, active.ContainerID, "the active sandbox"
+			} else {
+				sandboxes, err := listImplicitSandboxes(ctx)
+				if err != nil {
+					return mcp.NewToolResultText(fmt.Sprintf("Error: failed to list sandboxes: %v", err)), nil
+				}
+				sandbox, err := pickImplicitSandbox(sandboxes, ref)
+				if err != nil {
+					return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+				}
+				resolved, containerID = ContainerName(sandbox), sandbox.ID[:12]
+				if resolved == "" {
+					resolved = sandbox.ID
+				}
+				how = "the only running sandbox"
+				if ref == LatestSandbox {
+					how = "the most recently created sandbox"
+				}
+			}
+
+			args := make(map[string]any, len(request.GetArguments())+1)
+			for key, value := range request.GetArguments() {
+				args[key] = value
+			}
+			args["container_id_or_name"] = resolved
+			request.Params.Arguments = args
+
+			result, err := next(ctx, request)
+			if result != nil {
+				result.Content = append(result.Content, mcp.NewTextContent(
+					fmt.Sprintf("Used sandbox %s (container ID %s), %s", resolved, containerID, how)))
+			}
+			return result, err
+		}
+	}
+}
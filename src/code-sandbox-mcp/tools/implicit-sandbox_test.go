@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	olderSandbox = container.Summary{ID: "aaaa11112222000000000000000000000000000000000000000000000000000", Names: []string{"/mcp-older"}, Created: 1700000000}
+	newerSandbox = container.Summary{ID: "bbbb33334444000000000000000000000000000000000000000000000000000", Names: []string{"/mcp-newer"}, Created: 1700000500}
+)
+
+// withImplicitSandboxes makes the middleware see the given running sandboxes
+func withImplicitSandboxes(t *testing.T, sandboxes ...container.Summary) {
+	t.Helper()
+	saved := listImplicitSandboxes
+	listImplicitSandboxes = func(ctx context.Context) ([]container.Summary, error) { return sandboxes, nil }
+	t.Cleanup(func() { listImplicitSandboxes = saved })
+}
+
+func TestPickImplicitSandbox(t *testing.T) {
+	// No sandbox
+	_, err := pickImplicitSandbox(nil, "")
+	assert.EqualError(t, err, "NO_SANDBOX: no sandbox is running; create one with sandbox_initialize")
+	_, err = pickImplicitSandbox(nil, LatestSandbox)
+	assert.ErrorAs(t, err, new(*NoSandboxError))
+
+	// One sandbox
+	for _, ref := range []string{"", LatestSandbox} {
+		got, err := pickImplicitSandbox([]container.Summary{olderSandbox}, ref)
+		require.NoError(t, err)
+		assert.Equal(t, olderSandbox.ID, got.ID)
+	}
+
+	// Several sandboxes: "@latest" picks the newest, an omitted reference is ambiguous
+	got, err := pickImplicitSandbox([]container.Summary{olderSandbox, newerSandbox}, LatestSandbox)
+	require.NoError(t, err)
+	assert.Equal(t, newerSandbox.ID, got.ID)
+
+	_, err = pickImplicitSandbox([]container.Summary{olderSandbox, newerSandbox}, "")
+	var ambiguous *AmbiguousReferenceError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []string{"bbbb33334444 (mcp-newer)", "aaaa11112222 (mcp-older)"}, ambiguous.Candidates)
+	assert.Contains(t, err.Error(), "AMBIGUOUS_REFERENCE: container_id_or_name was omitted but 2 sandboxes are running")
+}
+
+func TestImplicitSandboxMiddleware(t *testing.T) {
+	var seen string
+	handler := ImplicitSandboxMiddleware([]string{"sandbox_exec"})(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		seen = request.GetString("container_id_or_name", "")
+		return mcp.NewToolResultText("ok"), nil
+	})
+	call := func(tool string, args map[string]interface{}) *mcp.CallToolResult {
+		t.Helper()
+		seen = ""
+		result, err := handler(context.Background(), newMockCallToolRequest(tool, args))
+		require.NoError(t, err)
+		return result
+	}
+
+	withImplicitSandboxes(t, olderSandbox, newerSandbox)
+	result := call("sandbox_exec", map[string]interface{}{"container_id_or_name": LatestSandbox, "commands": []interface{}{"ls"}})
+	assert.Equal(t, "mcp-newer", seen)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, "Used sandbox mcp-newer (container ID bbbb33334444), the most recently created sandbox", result.Content[1].(mcp.TextContent).Text)
+
+	result = call("sandbox_exec", map[string]interface{}{"commands": []interface{}{"ls"}})
+	assert.Equal(t, "", seen, "the handler must not run on an ambiguous reference")
+	assert.Contains(t, resultText(t, result), "AMBIGUOUS_REFERENCE")
+
+	// Explicit references and other tools pass through untouched
+	result = call("sandbox_exec", map[string]interface{}{"container_id_or_name": "mcp-older"})
+	assert.Equal(t, "mcp-older", seen)
+	assert.Len(t, result.Content, 1)
+	result = call("sandbox_exec", map[string]interface{}{"container_id_or_name": "latest"})
+	assert.Equal(t, "latest", seen, "a sandbox named latest stays addressable")
+	assert.Len(t, result.Content, 1)
+	call("sandbox_checkpoints_list", map[string]interface{}{})
+	assert.Equal(t, "", seen)
+
+	withImplicitSandboxes(t, olderSandbox)
+	result = call("sandbox_exec", map[string]interface{}{})
+	assert.Equal(t, "mcp-older", seen)
+	assert.Equal(t, "Used sandbox mcp-older (container ID aaaa11112222), the only running sandbox", result.Content[1].(mcp.TextContent).Text)
+
+	withImplicitSandboxes(t)
+	result = call("sandbox_exec", map[string]interface{}{})
+	assert.Contains(t, resultText(t, result), "NO_SANDBOX")
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Files that give away a server running in a container, replaced in tests
+var (
+	dockerEnvFile = "/.dockerenv"
+	cgroupFile    = "/proc/1/cgroup"
+)
+
+// runningInDocker reports whether the server itself runs in a container, checked once
+var runningInDocker = sync.OnceValue(detectRunningInDocker)
+
+// detectRunningInDocker honors SANDBOX_RUNNING_IN_DOCKER, then looks for the /.dockerenv marker Docker creates and
+// for a container runtime in the cgroup of PID 1
+func detectRunningInDocker() bool {
+	if value := os.Getenv("SANDBOX_RUNNING_IN_DOCKER"); value != "" {
+		if inDocker, err := strconv.ParseBool(value); err == nil {
+			return inDocker
+		}
+	}
+	if _, err := os.Stat(dockerEnvFile); err == nil {
+		return true
+	}
+	data, err := os.ReadFile(cgroupFile)
+	return err == nil && cgroupInContainer(string(data))
+}
+
+// cgroupInContainer reports whether a /proc/<pid>/cgroup file names a container runtime. With cgroup v2 the
+// file is just "0::/" inside a container, so /.dockerenv is the better signal there.
+func cgroupInContainer(cgroup string) bool {
+	for _, runtime := range []string{"/docker/", "/docker-", "/kubepods", "/containerd/", "/libpod-"} {
+		if strings.Contains(cgroup, runtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostRoot maps a directory of the Docker host to where it is mounted in the server's container
+type hostRoot struct {
+	Host  string
+	Local string
+}
+
+// hostRoots are the mappings from SANDBOX_HOST_ROOT
+var hostRoots []hostRoot
+
+// LoadHostRootFromEnv reads SANDBOX_HOST_ROOT, a comma-separated list of host_dir=server_dir mappings for the
+// workspaces mounted into the server's container
+func LoadHostRootFromEnv() error {
+	roots, err := parseHostRoots(os.Getenv("SANDBOX_HOST_ROOT"))
+	if err != nil {
+		return err
+	}
+	hostRoots = roots
+	return nil
+}
+
+func parseHostRoots(value string) ([]hostRoot, error) {
+	var roots []hostRoot
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, local, found := strings.Cut(entry, "=")
+		host, local = normalizeHostPath(host), path.Clean(strings.TrimSpace(local))
+		if !found || host == "" || !path.IsAbs(local) {
+			return nil, fmt.Errorf("invalid SANDBOX_HOST_ROOT entry %q: must be host_dir=server_dir with absolute paths", entry)
+		}
+		roots = append(roots, hostRoot{Host: host, Local: local})
+	}
+	return roots, nil
+}
+
+// normalizeHostPath cleans a host path with forward slashes, so Windows host paths compare like the others
+func normalizeHostPath(p string) string {
+	p = strings.ReplaceAll(strings.TrimSpace(p), `\`, "/")
+	if p == "" {
+		return ""
+	}
+	return path.Clean(p)
+}
+
+// translateHostPath turns a path on the Docker host into the path the server reads it at, using the longest
+// matching SANDBOX_HOST_ROOT mapping. Paths outside every mapping, and all paths without one, are only cleaned.
+func translateHostPath(p string) string {
+	root, ok := matchHostRoot(p)
+	if !ok {
+		return filepath.Clean(p)
+	}
+	rest := strings.TrimPrefix(normalizeHostPath(p)[len(root.Host):], "/")
+	return filepath.FromSlash(path.Join(root.Local, rest))
+}
+
+// matchHostRoot finds the longest SANDBOX_HOST_ROOT mapping covering p
+func matchHostRoot(p string) (hostRoot, bool) {
+	normalized := normalizeHostPath(p)
+	var best hostRoot
+	found := false
+	for _, root := range hostRoots {
+		if hostPathWithin(normalized, root.Host) && (!found || len(root.Host) > len(best.Host)) {
+			best, found = root, true
+		}
+	}
+	return best, found
+}
+
+// unmappedInContainer reports whether the server runs in a container and no SANDBOX_HOST_ROOT mapping covers p,
+// so a file written there stays inside the server's container
+func unmappedInContainer(p string) bool {
+	if !runningInDocker() {
+		return false
+	}
+	_, ok := matchHostRoot(p)
+	return !ok
+}
+
+// hostPathWithin reports whether p is dir or lies below it; drive letters and the rest compare without case
+// when dir is a Windows path
+func hostPathWithin(p, dir string) bool {
+	if len(dir) >= 2 && dir[1] == ':' {
+		p, dir = strings.ToLower(p), strings.ToLower(dir)
+	}
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// localPathError explains a missing local path when the server runs in a container, where "local" paths are
+// read from the server's own filesystem rather than the Docker host's
+func localPathError(err error) error {
+	if !errors.Is(err, fs.ErrNotExist) || !runningInDocker() {
+		return err
+	}
+	return fmt.Errorf("%w; the server runs in a container, so local paths refer to its own filesystem: mount the "+
+		"directory into the server's container and map it with SANDBOX_HOST_ROOT=host_dir=server_dir", err)
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRunningInDocker(t *testing.T) {
+	dir := t.TempDir()
+	defer func(env, cgroup string) { dockerEnvFile, cgroupFile = env, cgroup }(dockerEnvFile, cgroupFile)
+	dockerEnvFile = filepath.Join(dir, ".dockerenv")
+	cgroupFile = filepath.Join(dir, "cgroup")
+	t.Setenv("SANDBOX_RUNNING_IN_DOCKER", "")
+
+	assert.False(t, detectRunningInDocker())
+
+	require.NoError(t, os.WriteFile(cgroupFile, []byte("12:pids:/docker/3f2a9c\n0::/\n"), 0o644))
+	assert.True(t, detectRunningInDocker())
+
+	require.NoError(t, os.WriteFile(cgroupFile, []byte("0::/init.scope\n"), 0o644))
+	assert.False(t, detectRunningInDocker())
+	require.NoError(t, os.WriteFile(dockerEnvFile, nil, 0o644))
+	assert.True(t, detectRunningInDocker())
+
+	// The override wins either way
+	t.Setenv("SANDBOX_RUNNING_IN_DOCKER", "false")
+	assert.False(t, detectRunningInDocker())
+	require.NoError(t, os.Remove(dockerEnvFile))
+	t.Setenv("SANDBOX_RUNNING_IN_DOCKER", "1")
+	assert.True(t, detectRunningInDocker())
+}
+
+func TestCgroupInContainer(t *testing.T) {
+	assert.True(t, cgroupInContainer("0::/system.slice/docker-3f2a9c.scope"))
+	assert.True(t, cgroupInContainer("11:memory:/kubepods/burstable/pod1234/abcd"))
+	assert.False(t, cgroupInContainer("0::/user.slice/user-1000.slice/session-2.scope"))
+	assert.False(t, cgroupInContainer("0::/"))
+}
+
+func TestParseHostRoots(t *testing.T) {
+	roots, err := parseHostRoots(" /home/me/projects=/workspace, C:\\Users\\me=/mnt/me ,")
+	require.NoError(t, err)
+	assert.Equal(t, []hostRoot{
+		{Host: "/home/me/projects", Local: "/workspace"},
+		{Host: "C:/Users/me", Local: "/mnt/me"},
+	}, roots)
+
+	roots, err = parseHostRoots("")
+	require.NoError(t, err)
+	assert.Empty(t, roots)
+
+	for _, value := range []string{"/home/me", "=/workspace", "/home/me=workspace"} {
+		_, err := parseHostRoots(value)
+		assert.ErrorContains(t, err, "invalid SANDBOX_HOST_ROOT entry", value)
+	}
+}
+
+func TestTranslateHostPath(t *testing.T) {
+	defer func(roots []hostRoot) { hostRoots = roots }(hostRoots)
+	var err error
+	hostRoots, err = parseHostRoots("/home/me=/host-home,/home/me/projects=/workspace,C:\\Users\\me=/mnt/me")
+	require.NoError(t, err)
+
+	tests := []struct{ in, want string }{
+		{"/home/me/projects/app/main.go", "/workspace/app/main.go"}, // the longest mapping wins
+		{"/home/me/projects", "/workspace"},
+		{"/home/me/notes.txt", "/host-home/notes.txt"},
+		{"/home/medium/file", "/home/medium/file"}, // a sibling with a common prefix isn't covered
+		{`c:\users\me\Desktop\data.csv`, "/mnt/me/Desktop/data.csv"},
+		{"/srv/other/../data", "/srv/data"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, filepath.FromSlash(tt.want), translateHostPath(tt.in), tt.in)
+	}
+}
+
+func TestLocalPathError(t *testing.T) {
+	defer func(detect func() bool) { runningInDocker = detect }(runningInDocker)
+	_, missing := os.Stat(filepath.Join(t.TempDir(), "missing"))
+
+	runningInDocker = func() bool { return false }
+	assert.Equal(t, missing, localPathError(missing))
+
+	runningInDocker = func() bool { return true }
+	err := localPathError(missing)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Contains(t, err.Error(), "SANDBOX_HOST_ROOT")
+	other := fmt.Errorf("permission denied")
+	assert.Equal(t, other, localPathError(other))
+
+	defer func(roots []hostRoot) { hostRoots = roots }(hostRoots)
+	hostRoots = []hostRoot{{Host: "/home/me", Local: "/workspace"}}
+	assert.False(t, unmappedInContainer("/home/me/out.txt"))
+	assert.True(t, unmappedInContainer("/tmp/out.txt"))
+}
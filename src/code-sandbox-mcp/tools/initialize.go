@@ -2,80 +2,423 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
 
-	dockerImage "github.com/docker/docker/api/types/image"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/events"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// DefaultImage is the image sandbox_initialize uses when none is given
+const DefaultImage = "python:3.12-slim-bookworm"
+
 // InitializeEnvironment creates a new container for code execution
 func InitializeEnvironment(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Expand the named template, if any, with the call's own arguments taking precedence
+	args, err := expandTemplate(request.GetArguments())
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	request.Params.Arguments = args
+	ctx, timing := tracing.WithTiming(ctx)
+
 	// Get the requested Docker image or use default using new API
-	image := request.GetString("image", "python:3.12-slim-bookworm")
+	image := request.GetString("image", DefaultImage)
+
+	// Without an image, the version files of the optional project directory pick one
+	var runtime *runtimeImage
+	if projectDir := request.GetString("project_dir", ""); projectDir != "" {
+		_, explicit := args["image"]
+		selected, detected, err := projectDirImage(ctx, projectDir, explicit)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		if detected != nil {
+			image, runtime = selected, detected
+		}
+	}
+
+	// Get the optional language, whose runtime the image is checked for once the container starts; an image
+	// picked from project_dir is checked for the runtime it was picked for
+	language := request.GetString("language", "")
+	if language == "" && runtime != nil {
+		language = runtime.Pin.Runtime
+	}
+	if language != "" {
+		if language, err = registryLanguage(language); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+	if request.GetBool("skip_validation", false) {
+		language = ""
+	}
 
 	// Get the optional container name
 	name := request.GetString("name", "")
 
+	// Get the optional memory limit, in MB or as a size such as "512m"
+	memoryLimit, err := parseSizeArgument("memory_limit", request.GetArguments()["memory_limit"], 1<<20)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Get the optional CPU limit, a number of CPUs that may be fractional
+	cpuLimit := request.GetFloat("cpu_limit", 0)
+	if cpuLimit < 0 {
+		return mcp.NewToolResultText("Error: cpu_limit must not be negative"), nil
+	}
+	if cpuLimit > 0 {
+		if err := requireAPIFeature("cpu_limit"); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+	limits := SandboxLimits{CPUs: cpuLimit, MemoryBytes: memoryLimit}
+
+	// Get the optional working directory, which relative paths of the other tools resolve against
+	workDir := request.GetString("workdir", "")
+	if workDir != "" && !path.IsAbs(workDir) {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: workdir must be an absolute path, got %q", workDir)), nil
+	}
+
+	// Run under the bundled baseline seccomp profile unless another one is asked for
+	seccomp, err := resolveSeccompProfile(request.GetString("seccomp_profile", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Read the optional .env file to inject into the container environment
+	var env []string
+	var secrets, secretKeys []string
+	var warnings []string
+	if envFile := request.GetString("env_file", ""); envFile != "" {
+		localEnvFile := translateHostPath(envFile)
+		if err := checkHostPath(localEnvFile); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		data, err := os.ReadFile(localEnvFile)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error reading env_file: %v", localPathError(err))), nil
+		}
+		var vars []dotenvVar
+		vars, warnings = parseDotenv(string(data))
+		env = dotenvEnv(vars)
+		for _, v := range vars {
+			secretKeys = append(secretKeys, v.Key)
+			secrets = append(secrets, v.Value)
+		}
+	}
+	envFileVars := len(env)
+
+	// Get the optional ulimits, which override the configured defaults by name
+	ulimits, err := parseUlimitsArgument(request.GetArguments()["ulimits"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Get the optional host files to mount read-only under /app/inputs
+	inputFiles, err := parseInputFilesArgument(request.GetArguments()["input_files"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Get the optional commands sandbox_stop runs before stopping the container
+	onStop, err := parseOnStopArgument(request.GetArguments()["on_stop_commands"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Get the optional packages to install once the container is running
+	packages, err := parsePackagesArgument(request.GetArguments()["packages"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	packageManager := request.GetString("package_manager", "")
+	if err := checkPackageManager(packageManager); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Point the package managers at internal mirrors when configured; an env_file can still override them
+	mirrors := packageMirrorsFromEnv().withArguments(request)
+	if err := mirrors.Validate(); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	env = append(mirrors.Env(), env...)
+	secrets = append(secrets, mirrors.Credentials()...)
+
+	// Size thread pools and heaps to the limits rather than the host; an env_file can still override them
+	env = append(limits.Env(), env...)
+
+	// Pulling onto a nearly full disk can wedge the Docker daemon
+	if !request.GetBool("force", false) {
+		if err := checkHostResources(ctx); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+
+	// Installing packages is a phase of its own only when there are any
+	phases := []string{phasePull, phaseSetup}
+	if len(packages) > 0 {
+		phases = append(phases, phaseInstall)
+	}
+	progress := newProgressReporter(ctx, request, phases...)
+
 	// Create and start the container
-	containerID, err := createContainer(ctx, image, name)
+	containerID, seccompWarning, err := createContainer(ctx, progress, image, name, sandboxOptions{
+		MemoryLimit:  limits.MemoryBytes,
+		CPULimit:     limits.CPUs,
+		WorkDir:      workDir,
+		Env:          env,
+		Ulimits:      mergeUlimits(defaultUlimits, ulimits),
+		Mounts:       inputFileMounts(inputFiles),
+		OnStop:       onStop,
+		RemoveOnExit: request.GetBool("remove_on_exit", false),
+		Seccomp:      seccomp,
+	})
 	if err != nil {
 		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
 	}
+	registerSecrets(containerID, name, secretKeys, secrets)
 
-	return mcp.NewToolResultText(fmt.Sprintf("container_id: %s", containerID)), nil
+	// An image without the runtime would only fail later with "not found", so it is removed right away
+	var runtimeVersion string
+	if language != "" {
+		runtimeVersion, err = probeRuntime(ctx, containerID, image, language)
+		if err != nil {
+			forgetSecrets(containerID)
+			if cleanupErr := stopAndRemoveContainer(ctx, containerID); cleanupErr != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("Error: %v\nWarning: failed to remove container %s: %v", err, containerID, cleanupErr)), nil
+			}
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v; container removed", err)), nil
+		}
+	}
+
+	// A sandbox whose packages failed to install is removed rather than handed back half set up
+	var installedWith string
+	if len(packages) > 0 {
+		progress.Phase(phaseInstall, fmt.Sprintf("Installing %d packages", len(packages)))
+		installedWith, err = installPackages(ctx, progress, containerID, packageManager, packages)
+		if err != nil {
+			forgetSecrets(containerID)
+			if cleanupErr := stopAndRemoveContainer(ctx, containerID); cleanupErr != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("Error: package installation failed: %v\nWarning: failed to remove container %s: %v", err, containerID, cleanupErr)), nil
+			}
+			return mcp.NewToolResultText(fmt.Sprintf("Error: package installation failed, container removed: %v", err)), nil
+		}
+	}
+
+	progress.Done("Sandbox ready")
+
+	message := fmt.Sprintf("container_id: %s", containerID)
+	if runtime != nil {
+		message += fmt.Sprintf("\nImage: %s (from %s)", image, runtime.Evidence())
+	}
+	if runtimeVersion != "" {
+		message += fmt.Sprintf("\nRuntime: %s", runtimeVersion)
+	}
+	for _, f := range inputFiles {
+		message += fmt.Sprintf("\nInput file: %s (read-only, from %s)", f.ContainerPath, f.HostPath)
+	}
+	if len(onStop) > 0 {
+		message += fmt.Sprintf("\nsandbox_stop runs %d on-stop commands first", len(onStop))
+	}
+	if installedWith != "" {
+		message += fmt.Sprintf("\nInstalled %d packages with %s", len(packages), installedWith)
+	}
+	if !limits.IsZero() {
+		message += fmt.Sprintf("\nLimits: %s", limits)
+	}
+	if envFileVars > 0 {
+		message += fmt.Sprintf("\nInjected %d variables from env_file", envFileVars)
+	}
+	if ms := timing.Milliseconds(); ms != nil {
+		message += "\nTiming: " + formatTiming(ms)
+	}
+	for _, warning := range warnings {
+		message += fmt.Sprintf("\nWarning: env_file %s, line skipped", warning)
+	}
+	if seccompWarning != "" {
+		message += fmt.Sprintf("\nWarning: %s", seccompWarning)
+	}
+	return mcp.NewToolResultText(message), nil
+}
+
+// formatTiming lists phase timings by name, e.g. "container_create_ms=120, image_pull_ms=850"
+func formatTiming(ms map[string]int64) string {
+	parts := make([]string, 0, len(ms))
+	for key, value := range ms {
+		parts = append(parts, fmt.Sprintf("%s=%d", key, value))
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ", ")
+}
+
+// sandboxOptions are the per-sandbox settings of sandbox_initialize
+type sandboxOptions struct {
+	MemoryLimit int64   // bytes, 0 for unlimited
+	CPULimit    float64 // CPUs, 0 for unlimited
+	WorkDir     string  // empty for the default /app
+	Env         []string
+	Ulimits     []*container.Ulimit
+	Mounts      []mount.Mount
+	OnStop      []string // commands sandbox_stop runs first, kept in onStopLabel
+	// RemoveOnExit removes the container once its main process exits and its logs are captured
+	RemoveOnExit bool
+	Seccomp      seccompProfile
 }
 
-// createContainer creates a new Docker container and returns its ID
-func createContainer(ctx context.Context, image string, name string) (string, error) {
+// createContainer creates a new Docker container and returns its ID, reporting the pull and setup phases. The
+// warning is set when the engine rejected the seccomp profile and the container runs under its default.
+func createContainer(ctx context.Context, progress *progressReporter, image string, name string, opts sandboxOptions) (string, string, error) {
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to create Docker client: %w", err)
+		return "", "", fmt.Errorf("failed to create Docker client: %w", err)
 	}
 	defer cli.Close()
 
 	// Pull the Docker image if not already available
-	reader, err := cli.ImagePull(ctx, image, dockerImage.PullOptions{})
-	if err != nil {
-		return "", fmt.Errorf("failed to pull Docker image %s: %w", image, err)
+	progress.Phase(phasePull, fmt.Sprintf("Pulling %s", image))
+	if err := pullImage(ctx, cli, image, progress.Report); err != nil {
+		return "", "", err
+	}
+
+	config := sandboxContainerConfig(image)
+	config.Env = opts.Env
+	if len(opts.OnStop) > 0 {
+		onStop, err := json.Marshal(opts.OnStop)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to encode on_stop_commands: %w", err)
+		}
+		config.Labels[onStopLabel] = string(onStop)
+	}
+	if opts.RemoveOnExit {
+		config.Labels[removeOnExitLabel] = "true"
+	}
+	if opts.WorkDir != "" {
+		config.WorkingDir = path.Clean(opts.WorkDir)
 	}
-	defer reader.Close()
+	hostConfig := sandboxHostConfig(opts.MemoryLimit)
+	hostConfig.Resources.Ulimits = opts.Ulimits
+	hostConfig.Resources.NanoCPUs = int64(opts.CPULimit * 1e9)
+	hostConfig.Mounts = opts.Mounts
+	applySeccompProfile(hostConfig, opts.Seccomp)
+	progress.Phase(phaseSetup, "Creating and starting the container")
+	return startWithSeccompFallback(ctx, cli, config, hostConfig, name)
+}
+
+// extraContainerLabels are added to every container the tools create; tests set them to find their containers
+var extraContainerLabels map[string]string
 
+// containerLabels returns labels together with extraContainerLabels
+func containerLabels(labels map[string]string) map[string]string {
+	for key, value := range extraContainerLabels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// sandboxContainerConfig returns the container config shared by every sandbox
+func sandboxContainerConfig(image string) *container.Config {
 	// Create container config with a working directory
-	config := &container.Config{
-		Image:       image,
-		WorkingDir:  "/app",
-		Tty:         true,
-		OpenStdin:   true,
-		StdinOnce:   false,
+	return &container.Config{
+		Image:      image,
+		WorkingDir: defaultWorkDir,
+		Tty:        true,
+		OpenStdin:  true,
+		StdinOnce:  false,
+		Labels:     containerLabels(map[string]string{SandboxLabel: "true", InstanceLabel: instanceName}),
 	}
+}
+
+// defaultPidsLimit caps the number of processes in one sandbox, so a fork bomb is contained to its own cgroup
+const defaultPidsLimit = 256
+
+// projectDirImage checks project_dir, read through the SANDBOX_HOST_ROOT mapping, and picks the image from its
+// version files unless one was given explicitly
+func projectDirImage(ctx context.Context, projectDir string, explicitImage bool) (string, *runtimeImage, error) {
+	localProjectDir := translateHostPath(projectDir)
+	if err := checkHostPath(localProjectDir); err != nil {
+		return "", nil, err
+	}
+	if explicitImage {
+		return "", nil, nil
+	}
+	image, detected, err := selectRuntimeImage(ctx, localProjectDir)
+	if err != nil {
+		return "", nil, localPathError(err)
+	}
+	return image, detected, nil
+}
 
+// sandboxHostConfig returns the host config for a sandbox with the given memory limit in bytes (0 for unlimited)
+func sandboxHostConfig(memoryLimit int64) *container.HostConfig {
+	pidsLimit := int64(defaultPidsLimit)
 	// Create host config
 	hostConfig := &container.HostConfig{
-		// Add any resource constraints here if needed
+		Resources: container.Resources{
+			Memory:    memoryLimit,
+			PidsLimit: &pidsLimit,
+		},
 	}
+	if memoryLimit > 0 {
+		// Without a matching swap limit the container would swap instead of hitting the limit
+		hostConfig.Resources.MemorySwap = memoryLimit
+	}
+	return hostConfig
+}
 
+// createAndStartContainer creates a container with the given configuration, starts it and returns its ID. A
+// container that was created but failed to start is left in place, and its ID is returned with the error.
+func createAndStartContainer(ctx context.Context, cli *client.Client, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
 	// Create the container
-	resp, err := cli.ContainerCreate(
-		ctx,
-		config,
-		hostConfig,
-		nil,
-		nil,
-		name, // Use the provided name here
-	)
+	id, err := createTracedContainer(ctx, cli, config, hostConfig, name)
 	if err != nil {
-		return "", fmt.Errorf("failed to create container: %w", err)
+		return "", err
 	}
 
 	// Start the container
-	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", fmt.Errorf("failed to start container: %w", err)
+	if err := startTracedContainer(ctx, cli, id); err != nil {
+		return id, err
 	}
 
+	return id, nil
+}
+
+// createTracedContainer creates a container within the create deadline, in a span of its own
+func createTracedContainer(ctx context.Context, cli *client.Client, config *container.Config, hostConfig *container.HostConfig, name string) (id string, err error) {
+	ctx, span := tracing.Start(ctx, "container.create", tracing.ImageKey.String(config.Image))
+	defer func() { tracing.End(span, err) }()
+
+	createCtx, cancel := withDockerTimeout(ctx, containerCreateTimeout)
+	defer cancel()
+	resp, err := cli.ContainerCreate(createCtx, config, hostConfig, nil, nil, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", dockerTimeoutError(ctx, "creating the container", containerCreateTimeout, err))
+	}
+	span.SetAttributes(tracing.ContainerKey.String(resp.ID))
+	events.Emit(events.Event{Type: events.ContainerCreated, Container: resp.ID, Image: config.Image})
 	return resp.ID, nil
 }
+
+// startTracedContainer starts a created container, in a span of its own
+func startTracedContainer(ctx context.Context, cli *client.Client, id string) (err error) {
+	ctx, span := tracing.Start(ctx, "container.start", tracing.ContainerKey.String(id))
+	defer func() { tracing.End(span, err) }()
+
+	if err := cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// inputFilesDir is where sandbox_initialize mounts its input_files
+const inputFilesDir = defaultWorkDir + "/inputs"
+
+// inputFile is a host file mounted read-only into a sandbox
+type inputFile struct {
+	HostPath      string // path on the Docker host, canonical when the server runs there too
+	ContainerPath string // inputFilesDir/<basename>
+}
+
+// parseInputFilesArgument validates the input_files argument of sandbox_initialize: host paths of regular files
+// that may be read into a sandbox, with no two sharing a basename, since each is mounted under its own
+func parseInputFilesArgument(value any) ([]inputFile, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("input_files must be an array of strings")
+	}
+	files := make([]inputFile, 0, len(items))
+	byName := make(map[string]string, len(items))
+	for _, item := range items {
+		p, ok := item.(string)
+		if !ok || p == "" {
+			return nil, fmt.Errorf("input_files must be an array of non-empty strings")
+		}
+		// The file is read where the server sees it, but the Docker host mounts it from its own path
+		local := translateHostPath(p)
+		if err := checkHostPath(local); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(local)
+		if err != nil {
+			return nil, fmt.Errorf("input file %s: %w", p, localPathError(err))
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("input file %s is not a regular file", p)
+		}
+		name := filepath.Base(p)
+		if other, taken := byName[name]; taken {
+			return nil, fmt.Errorf("input files %s and %s would both be mounted at %s; rename one of them", other, p, path.Join(inputFilesDir, name))
+		}
+		byName[name] = p
+		// Symlinks of the Docker host can only be resolved where the server runs on it
+		hostPath := canonicalHostPath(p)
+		if _, mapped := matchHostRoot(p); mapped {
+			hostPath = filepath.Clean(p)
+		}
+		files = append(files, inputFile{HostPath: hostPath, ContainerPath: path.Join(inputFilesDir, name)})
+	}
+	return files, nil
+}
+
+// inputFileMounts returns the read-only bind mounts of files
+func inputFileMounts(files []inputFile) []mount.Mount {
+	mounts := make([]mount.Mount, 0, len(files))
+	for _, f := range files {
+		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: f.HostPath, Target: f.ContainerPath, ReadOnly: true})
+	}
+	return mounts
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInputFilesArgument(t *testing.T) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "data.csv")
+	require.NoError(t, os.WriteFile(csv, []byte("a,b\n1,2\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "other"), 0755))
+	other := filepath.Join(dir, "other", "data.csv")
+	require.NoError(t, os.WriteFile(other, []byte("c\n"), 0644))
+
+	files, err := parseInputFilesArgument(nil)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	files, err = parseInputFilesArgument([]any{csv})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/app/inputs/data.csv", files[0].ContainerPath)
+	assert.Equal(t, canonicalHostPath(csv), files[0].HostPath)
+	assert.Equal(t, []mount.Mount{{Type: mount.TypeBind, Source: canonicalHostPath(csv), Target: "/app/inputs/data.csv", ReadOnly: true}}, inputFileMounts(files))
+
+	for name, tc := range map[string]struct {
+		value any
+		want  string
+	}{
+		"not an array":  {csv, "input_files must be an array of strings"},
+		"not strings":   {[]any{1}, "input_files must be an array of non-empty strings"},
+		"missing":       {[]any{filepath.Join(dir, "missing.csv")}, "no such file or directory"},
+		"directory":     {[]any{dir}, "is not a regular file"},
+		"same basename": {[]any{csv, other}, "input files " + csv + " and " + other + " would both be mounted at /app/inputs/data.csv"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseInputFilesArgument(tc.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
+
+func TestParseInputFilesArgumentHostRoot(t *testing.T) {
+	// The server runs in a container with the host's /home/me mounted at a directory of its own
+	local := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(local, "data.csv"), []byte("a\n"), 0644))
+	defer func(roots []hostRoot) { hostRoots = roots }(hostRoots)
+	hostRoots = []hostRoot{{Host: "/home/me", Local: local}}
+
+	files, err := parseInputFilesArgument([]any{"/home/me/data.csv"})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/home/me/data.csv", files[0].HostPath, "the bind mount names the path on the Docker host")
+	assert.Equal(t, "/app/inputs/data.csv", files[0].ContainerPath)
+
+	_, err = parseInputFilesArgument([]any{"/home/me/missing.csv"})
+	assert.ErrorContains(t, err, "input file /home/me/missing.csv")
+}
+
+func TestParseInputFilesArgumentForbidden(t *testing.T) {
+	home := withForbiddenHome(t)
+	_, err := parseInputFilesArgument([]any{filepath.Join(home, ".claude", "settings.json")})
+	var forbidden *ForbiddenPathError
+	assert.True(t, errors.As(err, &forbidden), "expected FORBIDDEN_PATH, got %v", err)
+}
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// installFailure is a known cause of a failed package install: a system library, header or build tool the
+// package needs and the image lacks, with the system packages that provide it
+type installFailure struct {
+	Pattern  *regexp.Regexp
+	Missing  string              // what the output shows is missing, for the hint
+	Packages map[string][]string // by system package manager: apt-get, apk, dnf
+}
+
+// installFailures are the missing system dependencies recognized in installer output, most specific first
+var installFailures = []installFailure{
+	{
+		Pattern: regexp.MustCompile(`pg_config executable not found|pg_config: (?:command )?not found|Could not run pg_config`),
+		Missing: "pg_config, which psycopg2 and other PostgreSQL client packages build against",
+		Packages: map[string][]string{
+			"apt-get": {"libpq-dev"},
+			"apk":     {"postgresql-dev"},
+			"dnf":     {"libpq-devel"},
+		},
+	},
+	{
+		Pattern: regexp.MustCompile(`ffi\.h: No such file or directory|Package 'libffi',? required by .*, not found|Package libffi was not found`),
+		Missing: "the libffi headers",
+		Packages: map[string][]string{
+			"apt-get": {"libffi-dev"},
+			"apk":     {"libffi-dev"},
+			"dnf":     {"libffi-devel"},
+		},
+	},
+	{
+		Pattern: regexp.MustCompile(`openssl/(?:opensslv|ssl|err)\.h: No such file or directory`),
+		Missing: "the OpenSSL headers",
+		Packages: map[string][]string{
+			"apt-get": {"libssl-dev"},
+			"apk":     {"openssl-dev"},
+			"dnf":     {"openssl-devel"},
+		},
+	},
+	{
+		Pattern: regexp.MustCompile(`headers or library files could not be found for (?:jpeg|zlib)`),
+		Missing: "the libjpeg and zlib headers Pillow builds against",
+		Packages: map[string][]string{
+			"apt-get": {"libjpeg-dev", "zlib1g-dev"},
+			"apk":     {"jpeg-dev", "zlib-dev"},
+			"dnf":     {"libjpeg-turbo-devel", "zlib-devel"},
+		},
+	},
+	{
+		Pattern: regexp.MustCompile(`Python\.h: No such file or directory`),
+		Missing: "the Python development headers",
+		Packages: map[string][]string{
+			"apt-get": {"python3-dev"},
+			"apk":     {"python3-dev"},
+			"dnf":     {"python3-devel"},
+		},
+	},
+	{
+		Pattern: regexp.MustCompile(`gyp ERR! (?:find Python|stack Error: not found: (?:make|g\+\+|python)|stack Error: Can't find Python)|gyp: No Xcode or CLT|node-gyp: (?:command )?not found`),
+		Missing: "the build tools node-gyp needs for native addons (Python, make and a C++ compiler)",
+		Packages: map[string][]string{
+			"apt-get": {"python3", "make", "g++"},
+			"apk":     {"python3", "make", "g++"},
+			"dnf":     {"python3", "make", "gcc-c++"},
+		},
+	},
+	{
+		Pattern: regexp.MustCompile(`command '(?:\S*/)?(?:x86_64-linux-gnu-)?gcc' failed: No such file or directory|unable to execute '(?:x86_64-linux-gnu-)?gcc': No such file|(?:^|\s)(?:gcc|cc|c\+\+): (?:command )?not found|Unknown compiler\(s\)|error: no acceptable C compiler found`),
+		Missing: "a C compiler",
+		Packages: map[string][]string{
+			"apt-get": {"build-essential"},
+			"apk":     {"build-base"},
+			"dnf":     {"gcc", "gcc-c++", "make"},
+		},
+	},
+}
+
+// matchInstallFailures returns the known causes found in installer output
+func matchInstallFailures(output string) []installFailure {
+	var found []installFailure
+	for _, failure := range installFailures {
+		if failure.Pattern.MatchString(output) {
+			found = append(found, failure)
+		}
+	}
+	return found
+}
+
+// installFailureHints explains the known causes of a failed install, naming the packages to install with
+// install_system_packages. With manager "" the packages of every supported manager are listed.
+func installFailureHints(output string, manager string) string {
+	var hints []string
+	for _, failure := range matchInstallFailures(output) {
+		packages := strings.Join(failure.Packages[manager], " ")
+		if packages == "" {
+			all := make([]string, len(systemPackageManagers))
+			for i, m := range systemPackageManagers {
+				all[i] = fmt.Sprintf("%s (%s)", strings.Join(failure.Packages[m], " "), m)
+			}
+			packages = strings.Join(all[:len(all)-1], ", ") + " or " + all[len(all)-1]
+		}
+		hints = append(hints, fmt.Sprintf("Hint: the image lacks %s; install %s with install_system_packages", failure.Missing, packages))
+	}
+	return strings.Join(hints, "\n")
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchInstallFailures(t *testing.T) {
+	// Hand-written outputs modeled on failed pip and npm installs in slim and alpine images, trimmed to the lines
+	// that matter
+	tests := []struct {
+		file string
+		want []string // Packages["apt-get"] of each match
+	}{
+		{"psycopg2-pg-config.txt", []string{"libpq-dev"}},
+		{"cffi-libffi.txt", []string{"libffi-dev"}},
+		{"missing-gcc.txt", []string{"build-essential"}},
+		{"node-gyp-python.txt", []string{"python3 make g++"}},
+		{"node-gyp-make.txt", []string{"python3 make g++"}},
+		{"pillow-jpeg.txt", []string{"libjpeg-dev zlib1g-dev"}},
+		{"unknown-package.txt", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", "install-failures", tt.file))
+			require.NoError(t, err)
+			var got []string
+			for _, failure := range matchInstallFailures(string(output)) {
+				got = append(got, strings.Join(failure.Packages["apt-get"], " "))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// A compiler that ran and failed is not a missing compiler
+	assert.Empty(t, matchInstallFailures("error: command '/usr/bin/gcc' failed with exit code 1"))
+}
+
+func TestInstallFailureHints(t *testing.T) {
+	output := "Error: pg_config executable not found."
+	assert.Equal(t,
+		"Hint: the image lacks pg_config, which psycopg2 and other PostgreSQL client packages build against; "+
+			"install libpq-dev with install_system_packages",
+		installFailureHints(output, "apt-get"))
+	assert.Equal(t,
+		"Hint: the image lacks pg_config, which psycopg2 and other PostgreSQL client packages build against; "+
+			"install libpq-dev (apt-get), postgresql-dev (apk) or libpq-devel (dnf) with install_system_packages",
+		installFailureHints(output, ""))
+
+	// One hint per cause
+	hints := installFailureHints("fatal error: ffi.h: No such file or directory\nfatal error: Python.h: No such file or directory", "apk")
+	assert.Equal(t,
+		"Hint: the image lacks the libffi headers; install libffi-dev with install_system_packages\n"+
+			"Hint: the image lacks the Python development headers; install python3-dev with install_system_packages",
+		hints)
+	assert.Empty(t, installFailureHints("ERROR: No matching distribution found for reqeusts", "apt-get"))
+}
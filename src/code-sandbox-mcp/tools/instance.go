@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// InstanceLabel records the server instance that created a sandbox, so instances sharing a Docker host leave
+// each other's sandboxes alone
+const InstanceLabel = "code-sandbox-mcp.instance"
+
+// maxInstanceNameLength keeps an instance name readable in labels and tool results
+const maxInstanceNameLength = 63
+
+// instanceNamePattern is what --instance-name accepts: letters, digits and . _ @ -, starting with a letter or digit
+var instanceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`)
+
+// instanceName is the instance this server is, set once at startup
+var instanceName = DefaultInstanceName()
+
+// DefaultInstanceName derives the instance name from the user and host, e.g. "alice@devbox", so it stays the
+// same across restarts and differs between users of a shared machine. Characters an instance name can't hold
+// become "-".
+func DefaultInstanceName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	// The short hostname; the domain adds nothing on one Docker host
+	host, _, _ = strings.Cut(host, ".")
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if name == "" {
+		name = fmt.Sprintf("uid%d", os.Getuid())
+	}
+	// A Windows user is DOMAIN\name
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return sanitizeInstanceName(name + "@" + host)
+}
+
+// sanitizeInstanceName replaces the characters an instance name can't hold and bounds its length
+func sanitizeInstanceName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '@', r == '-':
+			return r
+		}
+		return '-'
+	}, name)
+	sanitized = strings.TrimLeft(sanitized, "._@-")
+	if len(sanitized) > maxInstanceNameLength {
+		sanitized = sanitized[:maxInstanceNameLength]
+	}
+	if sanitized == "" {
+		return "default"
+	}
+	return sanitized
+}
+
+// SetInstanceName makes the server the named instance; names that aren't valid are refused rather than
+// sanitized, so that two names given on the command line never end up the same
+func SetInstanceName(name string) error {
+	if len(name) > maxInstanceNameLength || !instanceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid instance name %q: use up to %d letters, digits, '.', '_', '@' or '-', starting with a letter or digit", name, maxInstanceNameLength)
+	}
+	instanceName = name
+	return nil
+}
+
+// InstanceName is the name of this server instance, recorded in the InstanceLabel of its sandboxes
+func InstanceName() string {
+	return instanceName
+}
+
+// sandboxFilters selects the sandboxes of this instance, or those of every instance with allInstances.
+// Sandboxes created before instances were labeled only show up with allInstances.
+func sandboxFilters(allInstances bool) filters.Args {
+	args := filters.NewArgs(filters.Arg("label", SandboxLabel))
+	if !allInstances {
+		args.Add("label", InstanceLabel+"="+instanceName)
+	}
+	return args
+}
+
+// OtherInstanceError is returned when a tool is asked to stop a sandbox another server instance created
+type OtherInstanceError struct {
+	Container string
+	Instance  string
+}
+
+func (e *OtherInstanceError) Error() string {
+	return fmt.Sprintf("OTHER_INSTANCE: container %s belongs to server instance %q, not %q; pass all_instances=true to stop it anyway", e.Container, e.Instance, instanceName)
+}
+
+// checkInstance refuses a container labeled with another instance unless allInstances is set. Containers
+// without an instance label, which this server didn't create or created before instances were labeled, pass.
+func checkInstance(ref string, config *container.Config, allInstances bool) error {
+	if allInstances || config == nil {
+		return nil
+	}
+	if owner, ok := config.Labels[InstanceLabel]; ok && owner != instanceName {
+		return &OtherInstanceError{Container: ref, Instance: owner}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useInstance makes the server the named instance for the rest of a test
+func useInstance(t *testing.T, name string) {
+	t.Helper()
+	saved := instanceName
+	require.NoError(t, SetInstanceName(name))
+	t.Cleanup(func() { instanceName = saved })
+}
+
+func TestDefaultInstanceName(t *testing.T) {
+	name := DefaultInstanceName()
+	assert.Equal(t, name, DefaultInstanceName(), "the default is stable")
+	assert.Contains(t, name, "@")
+	assert.NoError(t, SetInstanceName(name), "the default is a valid name")
+}
+
+func TestSanitizeInstanceName(t *testing.T) {
+	assert.Equal(t, "alice@devbox", sanitizeInstanceName("alice@devbox"))
+	assert.Equal(t, "first-last@dev-box", sanitizeInstanceName("first last@dev box"))
+	assert.Equal(t, "j-rg@host", sanitizeInstanceName("jörg@host"))
+	assert.Equal(t, "name@host", sanitizeInstanceName("..name@host"))
+	assert.Equal(t, "default", sanitizeInstanceName("@@"))
+	assert.Len(t, sanitizeInstanceName(strings.Repeat("a", 100)+"@host"), maxInstanceNameLength)
+}
+
+func TestSetInstanceName(t *testing.T) {
+	useInstance(t, "project-a")
+	assert.Equal(t, "project-a", InstanceName())
+
+	for _, name := range []string{"", "two words", "-leading", "a/b", strings.Repeat("a", maxInstanceNameLength+1)} {
+		err := SetInstanceName(name)
+		assert.ErrorContains(t, err, "invalid instance name", name)
+	}
+	assert.Equal(t, "project-a", InstanceName(), "an invalid name leaves the instance alone")
+}
+
+func TestSandboxFilters(t *testing.T) {
+	useInstance(t, "project-a")
+	assert.ElementsMatch(t, []string{SandboxLabel, InstanceLabel + "=project-a"}, sandboxFilters(false).Get("label"))
+	assert.Equal(t, []string{SandboxLabel}, sandboxFilters(true).Get("label"))
+
+	useInstance(t, "project-b")
+	assert.ElementsMatch(t, []string{SandboxLabel, InstanceLabel + "=project-b"}, sandboxFilters(false).Get("label"))
+
+	config := sandboxContainerConfig("python:3.12-slim")
+	assert.Equal(t, "project-b", config.Labels[InstanceLabel])
+}
+
+func TestCheckInstance(t *testing.T) {
+	useInstance(t, "project-a")
+	own := &container.Config{Labels: map[string]string{SandboxLabel: "true", InstanceLabel: "project-a"}}
+	other := &container.Config{Labels: map[string]string{SandboxLabel: "true", InstanceLabel: "project-b"}}
+	legacy := &container.Config{Labels: map[string]string{SandboxLabel: "true"}}
+
+	assert.NoError(t, checkInstance("mcp-a", own, false))
+	assert.NoError(t, checkInstance("mcp-old", legacy, false))
+	assert.NoError(t, checkInstance("mcp-x", nil, false))
+	assert.NoError(t, checkInstance("mcp-b", other, true))
+
+	err := checkInstance("mcp-b", other, false)
+	var otherInstance *OtherInstanceError
+	require.ErrorAs(t, err, &otherInstance)
+	assert.Equal(t, "project-b", otherInstance.Instance)
+	assert.EqualError(t, err, `OTHER_INSTANCE: container mcp-b belongs to server instance "project-b", not "project-a"; pass all_instances=true to stop it anyway`)
+}
+
+func TestSandboxInfoInstance(t *testing.T) {
+	c := container.Summary{ID: "0123456789abcdef", Names: []string{"/mcp-b"}, State: "exited", Labels: map[string]string{InstanceLabel: "project-b"}}
+	assert.Equal(t, "project-b", sandboxInfo(c, nil, time.Now()).Instance)
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// junkNames are the OS metadata, cache and editor files that copy_project and write_files_sandbox leave out
+// unless include_junk is set. They match single path segments, so a directory that matches is left out with
+// everything in it.
+var junkNames = []string{
+	// Finder and Explorer metadata
+	".DS_Store", "._*", "Thumbs.db", "desktop.ini",
+	// Python bytecode and tool caches
+	"__pycache__", "*.pyc", ".pytest_cache", ".mypy_cache", ".ruff_cache",
+	// Vim swap files, backups and Emacs lock files
+	"*.swp", "*.swo", "*~", ".#*",
+}
+
+// isJunkName reports whether a file or directory name is one of junkNames
+func isJunkName(name string) bool {
+	for _, pattern := range junkNames {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isJunkPath reports whether any segment of a slash-separated path is one of junkNames
+func isJunkPath(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment != "" && isJunkName(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// junkSkippedNote describes the junk entries a copy left out, or returns "" when there were none
+func junkSkippedNote(skipped int) string {
+	if skipped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Skipped %d OS, cache and editor files such as .DS_Store and __pycache__ (a skipped directory counts once); pass include_junk=true to copy them", skipped)
+}
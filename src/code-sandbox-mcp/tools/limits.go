@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// SandboxLimits are the CPU and memory limits a sandbox runs under; zero means unlimited
+type SandboxLimits struct {
+	CPUs        float64 `json:"cpus,omitempty"`
+	MemoryBytes int64   `json:"memory_bytes,omitempty"`
+}
+
+// limitsFromHostConfig reads the effective limits of a container, from NanoCPUs or a CFS quota
+func limitsFromHostConfig(hostConfig *container.HostConfig) SandboxLimits {
+	if hostConfig == nil {
+		return SandboxLimits{}
+	}
+	limits := SandboxLimits{MemoryBytes: hostConfig.Memory}
+	if hostConfig.NanoCPUs > 0 {
+		limits.CPUs = float64(hostConfig.NanoCPUs) / 1e9
+	} else if hostConfig.CPUQuota > 0 {
+		period := hostConfig.CPUPeriod
+		if period <= 0 {
+			period = 100000 // the kernel's default CFS period in microseconds
+		}
+		limits.CPUs = float64(hostConfig.CPUQuota) / float64(period)
+	}
+	return limits
+}
+
+// IsZero reports whether the sandbox runs without CPU and memory limits
+func (l SandboxLimits) IsZero() bool {
+	return l.CPUs <= 0 && l.MemoryBytes <= 0
+}
+
+// Env returns the variables runtimes read to size themselves, which otherwise default to the host's cores
+// and memory: GOMAXPROCS and OMP_NUM_THREADS for the CPU limit, a V8 heap cap for the memory limit
+func (l SandboxLimits) Env() []string {
+	var env []string
+	if l.CPUs > 0 {
+		threads := strconv.Itoa(int(math.Max(1, math.Ceil(l.CPUs))))
+		env = append(env, "GOMAXPROCS="+threads, "OMP_NUM_THREADS="+threads)
+	}
+	if l.MemoryBytes > 0 {
+		// Leave a quarter of the limit for everything outside the V8 heap
+		heapMB := max(l.MemoryBytes*3/4/(1024*1024), 16)
+		env = append(env, fmt.Sprintf("NODE_OPTIONS=--max-old-space-size=%d", heapMB))
+	}
+	return env
+}
+
+func (l SandboxLimits) String() string {
+	var parts []string
+	if l.CPUs > 0 {
+		parts = append(parts, strconv.FormatFloat(l.CPUs, 'f', -1, 64)+" CPUs")
+	}
+	if l.MemoryBytes > 0 {
+		parts = append(parts, formatMemory(l.MemoryBytes)+" memory")
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// containerLimits returns the limits of a container, or nil when it has none or can't be inspected
+func containerLimits(ctx context.Context, containerIDOrName string) *SandboxLimits {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return nil
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return nil
+	}
+	limits := limitsFromHostConfig(inspect.HostConfig)
+	if limits.IsZero() {
+		return nil
+	}
+	return &limits
+}
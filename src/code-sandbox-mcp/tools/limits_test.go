@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxLimitsEnv(t *testing.T) {
+	assert.Empty(t, SandboxLimits{}.Env())
+	assert.Equal(t, []string{"GOMAXPROCS=2", "OMP_NUM_THREADS=2"}, SandboxLimits{CPUs: 1.5}.Env())
+	assert.Equal(t, []string{"GOMAXPROCS=1", "OMP_NUM_THREADS=1"}, SandboxLimits{CPUs: 0.25}.Env())
+	assert.Equal(t, []string{"NODE_OPTIONS=--max-old-space-size=384"}, SandboxLimits{MemoryBytes: 512 * 1024 * 1024}.Env())
+	// A tiny limit still leaves V8 a usable heap
+	assert.Equal(t, []string{"NODE_OPTIONS=--max-old-space-size=16"}, SandboxLimits{MemoryBytes: 8 * 1024 * 1024}.Env())
+}
+
+func TestLimitsFromHostConfig(t *testing.T) {
+	assert.True(t, limitsFromHostConfig(nil).IsZero())
+	assert.Equal(t, SandboxLimits{CPUs: 1.5, MemoryBytes: 256 * 1024 * 1024},
+		limitsFromHostConfig(&container.HostConfig{Resources: container.Resources{NanoCPUs: 1500000000, Memory: 256 * 1024 * 1024}}))
+	// docker run --cpu-quota without --cpu-period uses the default 100ms period
+	assert.Equal(t, SandboxLimits{CPUs: 0.5}, limitsFromHostConfig(&container.HostConfig{Resources: container.Resources{CPUQuota: 50000}}))
+	assert.Equal(t, SandboxLimits{CPUs: 2}, limitsFromHostConfig(&container.HostConfig{Resources: container.Resources{CPUQuota: 100000, CPUPeriod: 50000}}))
+}
+
+func TestSandboxLimitsString(t *testing.T) {
+	assert.Equal(t, "unlimited", SandboxLimits{}.String())
+	assert.Equal(t, "1.5 CPUs, 512MB memory", SandboxLimits{CPUs: 1.5, MemoryBytes: 512 * 1024 * 1024}.String())
+	assert.Equal(t, "1GB memory", SandboxLimits{MemoryBytes: 1024 * 1024 * 1024}.String())
+}
@@ -4,47 +4,77 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-// SandboxInfo holds information about a running sandbox container.
+// SandboxLabel marks containers created by sandbox_initialize
+const SandboxLabel = "code-sandbox-mcp.sandbox"
+
+// SandboxStates are the container states sandbox_list can filter on
+var SandboxStates = []string{"running", "exited", "paused", "created"}
+
+// SandboxInfo holds information about a sandbox container. Status is Docker's human-readable status, for display;
+// the other fields are meant to be parsed.
 type SandboxInfo struct {
-	ContainerID string `json:"container_id"`
-	Name        string `json:"name"`
-	Image       string `json:"image"`
-	Status      string `json:"status"`
+	ContainerID   string `json:"container_id"`
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	Status        string `json:"status"`
+	State         string `json:"state"`                    // created, running, paused, restarting, exited or dead
+	Health        string `json:"health,omitempty"`         // starting, healthy or unhealthy, when the image has a HEALTHCHECK
+	ExitCode      *int   `json:"exit_code,omitempty"`      // only for exited and dead containers
+	UptimeSeconds *int64 `json:"uptime_seconds,omitempty"` // only for running and paused containers
+	IdleSeconds   *int64 `json:"idle_seconds,omitempty"`   // since a tool last used it; only for running and paused containers
+	Instance      string `json:"instance,omitempty"`       // the server instance that created it
 }
 
-// ListSandboxes lists all running sandbox containers.
+// ListSandboxes lists the running sandbox containers, or those in the state given by the state argument, or all
+// of them with include_stopped. idle_longer_than keeps only the sandboxes unused for longer than a duration. Only
+// the sandboxes of this server instance are listed, unless all_instances is set.
 func ListSandboxes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	state := request.GetString("state", "")
+	if state != "" && !slices.Contains(SandboxStates, state) {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: unsupported state %q: use one of %s", state, strings.Join(SandboxStates, ", "))), nil
+	}
+	idleLongerThan, err := parseIdleLongerThan(request.GetString("idle_longer_than", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
 	if err != nil {
 		return nil, fmt.Errorf("DOCKER_CLIENT_ERROR: failed to create Docker client: %v", err)
 	}
 	defer cli.Close()
 
-	containers, err := cli.ContainerList(ctx, container.ListOptions{})
+	containers, err := ListSandboxContainers(ctx, cli, state != "" || request.GetBool("include_stopped", false), request.GetBool("all_instances", false))
 	if err != nil {
 		return nil, fmt.Errorf("CONTAINER_LIST_ERROR: failed to list containers: %v", err)
 	}
 
+	now := time.Now()
 	var sandboxes []SandboxInfo
 	for _, c := range containers {
-		var name string
-		if len(c.Names) > 0 {
-			name = strings.TrimPrefix(c.Names[0], "/")
+		if state != "" && c.State != state {
+			continue
 		}
-
-		sandboxes = append(sandboxes, SandboxInfo{
-			ContainerID: c.ID[:12],
-			Name:        name,
-			Image:       c.Image,
-			Status:      c.Status,
-		})
+		// Health, the exit code and the start time are only in the inspect data; a container removed in the
+		// meantime is listed without them
+		var inspectState *container.State
+		if inspect, err := cli.ContainerInspect(ctx, c.ID); err == nil && inspect.ContainerJSONBase != nil {
+			inspectState = inspect.State
+		}
+		sandboxes = append(sandboxes, sandboxInfo(c, inspectState, now))
+	}
+	if idleLongerThan > 0 {
+		sandboxes = idleSandboxes(sandboxes, idleLongerThan)
 	}
 
 	jsonData, err := json.Marshal(sandboxes)
@@ -54,3 +84,66 @@ func ListSandboxes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 
 	return mcp.NewToolResultText(string(jsonData)), nil
 }
+
+// sandboxInfo describes a sandbox from its list entry and, when available, the state from inspecting it
+func sandboxInfo(c container.Summary, state *container.State, now time.Time) SandboxInfo {
+	info := SandboxInfo{
+		ContainerID: c.ID[:12],
+		Name:        ContainerName(c),
+		Image:       c.Image,
+		Status:      c.Status,
+		State:       c.State,
+		Instance:    c.Labels[InstanceLabel],
+	}
+	if state == nil {
+		return info
+	}
+	if state.Health != nil && state.Health.Status != container.NoHealthcheck {
+		info.Health = state.Health.Status
+	}
+	switch info.State {
+	case "exited", "dead":
+		exitCode := state.ExitCode
+		info.ExitCode = &exitCode
+	case "running", "paused":
+		if started, err := time.Parse(time.RFC3339Nano, state.StartedAt); err == nil {
+			uptime := int64(now.Sub(started).Seconds())
+			info.UptimeSeconds = &uptime
+		}
+		info.IdleSeconds = idleSeconds(state.StartedAt, now, info.Name, c.ID)
+	}
+	return info
+}
+
+// idleSandboxes keeps the sandboxes that have been idle for longer than d; those without an idle time, which
+// aren't running, are left out
+func idleSandboxes(sandboxes []SandboxInfo, d time.Duration) []SandboxInfo {
+	var idle []SandboxInfo
+	for _, info := range sandboxes {
+		if info.IdleSeconds != nil && *info.IdleSeconds > int64(d.Seconds()) {
+			idle = append(idle, info)
+		}
+	}
+	return idle
+}
+
+// parseIdleLongerThan reads the idle_longer_than argument of sandbox_list, a duration such as 30m
+func parseIdleLongerThan(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid idle_longer_than %q: must be a positive duration such as 30m", value)
+	}
+	return d, nil
+}
+
+// ListSandboxContainers returns the containers carrying the sandbox label, including stopped ones when all is set.
+// They are those of this server instance, or of every instance when allInstances is set.
+func ListSandboxContainers(ctx context.Context, cli *client.Client, all, allInstances bool) ([]container.Summary, error) {
+	return cli.ContainerList(ctx, container.ListOptions{
+		All:     all,
+		Filters: sandboxFilters(allInstances),
+	})
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxInfo(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	summary := func(state, status string) container.Summary {
+		return container.Summary{ID: olderSandbox.ID, Names: []string{"/mcp-box"}, Image: "alpine:3.20", State: state, Status: status}
+	}
+
+	// Running with a HEALTHCHECK: uptime from the start time, no exit code
+	info := sandboxInfo(summary("running", "Up 5 minutes (healthy)"), &container.State{
+		Status:    "running",
+		StartedAt: "2024-05-01T11:55:00.123456789Z",
+		Health:    &container.Health{Status: container.Healthy},
+	}, now)
+	assert.Equal(t, "aaaa11112222", info.ContainerID)
+	assert.Equal(t, "mcp-box", info.Name)
+	assert.Equal(t, "Up 5 minutes (healthy)", info.Status)
+	assert.Equal(t, "running", info.State)
+	assert.Equal(t, "healthy", info.Health)
+	assert.Nil(t, info.ExitCode)
+	require.NotNil(t, info.UptimeSeconds)
+	assert.Equal(t, int64(299), *info.UptimeSeconds)
+
+	// Exited: the exit code, no uptime, no health without a HEALTHCHECK
+	info = sandboxInfo(summary("exited", "Exited (137) 2 minutes ago"), &container.State{
+		Status:    "exited",
+		ExitCode:  137,
+		StartedAt: "2024-05-01T11:55:00Z",
+	}, now)
+	assert.Equal(t, "exited", info.State)
+	assert.Empty(t, info.Health)
+	require.NotNil(t, info.ExitCode)
+	assert.Equal(t, 137, *info.ExitCode)
+	assert.Nil(t, info.UptimeSeconds)
+
+	// Created, and a container that could not be inspected
+	info = sandboxInfo(summary("created", "Created"), &container.State{Status: "created", StartedAt: "0001-01-01T00:00:00Z"}, now)
+	assert.Nil(t, info.ExitCode)
+	assert.Nil(t, info.UptimeSeconds)
+	info = sandboxInfo(summary("running", "Up 1 second"), nil, now)
+	assert.Equal(t, "running", info.State)
+	assert.Nil(t, info.UptimeSeconds)
+}
+
+func TestSandboxIdleSeconds(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := started
+	saved := sandboxActivity
+	sandboxActivity = &activityLog{last: map[string]time.Time{}, now: func() time.Time { return clock }}
+	t.Cleanup(func() { sandboxActivity = saved })
+
+	running := func(name string) SandboxInfo {
+		c := container.Summary{ID: olderSandbox.ID, Names: []string{"/" + name}, Image: "alpine:3.20", State: "running"}
+		return sandboxInfo(c, &container.State{Status: "running", StartedAt: started.Format(time.RFC3339Nano)}, clock)
+	}
+
+	// Unused since it started
+	clock = started.Add(10 * time.Minute)
+	info := running("mcp-box")
+	require.NotNil(t, info.IdleSeconds)
+	assert.Equal(t, int64(600), *info.IdleSeconds)
+
+	// Used by name, then read back by name or ID
+	sandboxActivity.Touch("mcp-box")
+	clock = clock.Add(90 * time.Second)
+	assert.Equal(t, int64(90), *running("mcp-box").IdleSeconds)
+	sandboxActivity.Touch(olderSandbox.ID)
+	clock = clock.Add(5 * time.Second)
+	assert.Equal(t, int64(5), *running("mcp-box").IdleSeconds)
+
+	// Activity from a previous run of the container doesn't count past its start
+	restarted := clock.Add(time.Second)
+	assert.Equal(t, int64(29), *idleSeconds(restarted.Format(time.RFC3339Nano), restarted.Add(29*time.Second), "mcp-box"))
+
+	// Stopped containers and forgotten ones
+	exited := sandboxInfo(container.Summary{ID: olderSandbox.ID, Names: []string{"/mcp-box"}, State: "exited"}, &container.State{Status: "exited"}, clock)
+	assert.Nil(t, exited.IdleSeconds)
+	sandboxActivity.Forget("mcp-box", olderSandbox.ID)
+	assert.Equal(t, int64(clock.Sub(started).Seconds()), *running("mcp-box").IdleSeconds)
+}
+
+func TestIdleSandboxes(t *testing.T) {
+	seconds := func(n int64) *int64 { return &n }
+	sandboxes := []SandboxInfo{
+		{Name: "busy", State: "running", IdleSeconds: seconds(30)},
+		{Name: "idle", State: "running", IdleSeconds: seconds(3600)},
+		{Name: "edge", State: "running", IdleSeconds: seconds(600)},
+		{Name: "stopped", State: "exited"},
+	}
+	idle := idleSandboxes(sandboxes, 10*time.Minute)
+	require.Len(t, idle, 1)
+	assert.Equal(t, "idle", idle[0].Name)
+	assert.Empty(t, idleSandboxes(sandboxes, 2*time.Hour))
+
+	d, err := parseIdleLongerThan("30m")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, d)
+	d, err = parseIdleLongerThan("")
+	require.NoError(t, err)
+	assert.Zero(t, d)
+	_, err = parseIdleLongerThan("soon")
+	assert.EqualError(t, err, `invalid idle_longer_than "soon": must be a positive duration such as 30m`)
+	_, err = parseIdleLongerThan("-5m")
+	assert.Error(t, err)
+}
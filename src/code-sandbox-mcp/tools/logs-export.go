@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// exportedLogFile is a host file sandbox_logs_export wrote and how many bytes went into it
+type exportedLogFile struct {
+	Path   string
+	Stream string // "stdout", "stderr" or "stdout and stderr"
+	Bytes  int64
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportLogs streams a container's logs into a host file, or with split_streams into one file for stdout and one
+// for stderr, so logs too large to return through MCP can still be read. Nothing is buffered beyond a frame.
+func ExportLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	localDestPath, err := request.RequireString("local_dest_path")
+	if err != nil || localDestPath == "" {
+		return mcp.NewToolResultText("local_dest_path is required"), nil
+	}
+	tail := request.GetInt("tail", 0)
+	if tail < 0 {
+		return mcp.NewToolResultText("Error: tail must not be negative"), nil
+	}
+	// Map the host path to where the server can write it, and check the path that is written
+	writePath := translateHostPath(localDestPath)
+	if err := checkHostPath(writePath); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	split := request.GetBool("split_streams", false)
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create Docker client: %v", err)), nil
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to inspect container: %v", err)), nil
+	}
+	// A TTY merges stdout and stderr before Docker sees them, so there is nothing to split
+	tty := inspect.Config != nil && inspect.Config.Tty
+	var notes []string
+	if split && tty {
+		split = false
+		notes = append(notes, "the container has a TTY, so its stdout and stderr can't be told apart and were exported together")
+	}
+
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Since: request.GetString("since", "")}
+	if tail > 0 {
+		options.Tail = strconv.Itoa(tail)
+	}
+	logs, err := cli.ContainerLogs(ctx, containerIDOrName, options)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to read container logs: %v", err)), nil
+	}
+	defer logs.Close()
+
+	unmapped := unmappedInContainer(localDestPath)
+	files := []exportedLogFile{{Path: writePath, Stream: "stdout and stderr"}}
+	if split {
+		files = splitLogPaths(files[0].Path)
+	}
+	warning, err := exportLogStream(logs, tty, files)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	if warning != "" {
+		notes = append(notes, warning)
+	}
+
+	lines := []string{fmt.Sprintf("Exported the logs of container %s:", containerIDOrName)}
+	for _, f := range files {
+		lines = append(lines, fmt.Sprintf("- %s: %s, %d bytes (%s)", f.Stream, f.Path, f.Bytes, formatBytes(uint64(f.Bytes))))
+	}
+	for _, note := range notes {
+		lines = append(lines, "Note: "+note)
+	}
+	if unmapped {
+		lines = append(lines, "Warning: the server runs in a container and no SANDBOX_HOST_ROOT mapping covers this path, so the logs were written inside the server's container rather than on the Docker host")
+	}
+	return mcp.NewToolResultText(strings.Join(lines, "\n")), nil
+}
+
+// splitLogPaths turns app.log into app.stdout.log and app.stderr.log
+func splitLogPaths(p string) []exportedLogFile {
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	return []exportedLogFile{
+		{Path: base + ".stdout" + ext, Stream: "stdout"},
+		{Path: base + ".stderr" + ext, Stream: "stderr"},
+	}
+}
+
+// exportLogStream copies a log stream into files, which hold either both streams or stdout then stderr, and
+// records the bytes written to each. A TTY stream isn't multiplexed and is copied as is.
+func exportLogStream(stream io.Reader, tty bool, files []exportedLogFile) (warning string, err error) {
+	writers := make([]*countingWriter, len(files))
+	flushers := make([]*bufio.Writer, len(files))
+	for i := range files {
+		if err := os.MkdirAll(filepath.Dir(files[i].Path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create destination directory: %w", err)
+		}
+		f, err := os.Create(files[i].Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", files[i].Path, err)
+		}
+		defer f.Close()
+		flushers[i] = bufio.NewWriter(f)
+		writers[i] = &countingWriter{w: flushers[i]}
+	}
+	defer func() {
+		for i := range files {
+			files[i].Bytes = writers[i].n
+		}
+	}()
+
+	stdout, stderr := writers[0], writers[len(writers)-1]
+	if tty {
+		_, err = io.Copy(stdout, stream)
+	} else {
+		warning, err = DemuxOutput(stdout, stderr, stream)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to export container logs: %w", err)
+	}
+	for i, w := range flushers {
+		if err := w.Flush(); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", files[i].Path, err)
+		}
+	}
+	return warning, nil
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multiplexedLogs streams n bytes of stdout and one line of stderr as Docker multiplexes them, without holding
+// the stream in memory
+func multiplexedLogs(n int) io.Reader {
+	r, w := io.Pipe()
+	go func() {
+		stdout := stdcopy.NewStdWriter(w, stdcopy.Stdout)
+		line := bytes.Repeat([]byte("x"), 1023)
+		line = append(line, '\n')
+		for written := 0; written < n; written += len(line) {
+			if _, err := stdout.Write(line[:min(len(line), n-written)]); err != nil {
+				return
+			}
+		}
+		_, _ = stdcopy.NewStdWriter(w, stdcopy.Stderr).Write([]byte("done\n"))
+		w.Close()
+	}()
+	return r
+}
+
+func TestSplitLogPaths(t *testing.T) {
+	assert.Equal(t, []exportedLogFile{
+		{Path: "/tmp/app.stdout.log", Stream: "stdout"},
+		{Path: "/tmp/app.stderr.log", Stream: "stderr"},
+	}, splitLogPaths("/tmp/app.log"))
+	assert.Equal(t, "/tmp/app.stdout", splitLogPaths("/tmp/app")[0].Path)
+}
+
+func TestExportLogStream(t *testing.T) {
+	dir := t.TempDir()
+	const size = 5 << 20
+
+	// Split into two files, with memory use bounded well below the size of the logs
+	files := splitLogPaths(filepath.Join(dir, "logs", "app.log"))
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	warning, err := exportLogStream(multiplexedLogs(size), false, files)
+	runtime.ReadMemStats(&after)
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(size/4), "the logs are streamed rather than buffered")
+
+	assert.Equal(t, int64(size), files[0].Bytes)
+	info, err := os.Stat(files[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), info.Size())
+	assert.Equal(t, int64(5), files[1].Bytes)
+	stderr, err := os.ReadFile(files[1].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(stderr))
+
+	// Combined into one file
+	combined := []exportedLogFile{{Path: filepath.Join(dir, "all.log")}}
+	_, err = exportLogStream(multiplexedLogs(4096), false, combined)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096+5), combined[0].Bytes)
+
+	// A TTY stream is copied unchanged
+	raw := []exportedLogFile{{Path: filepath.Join(dir, "tty.log")}}
+	_, err = exportLogStream(bytes.NewReader([]byte("plain output\r\n")), true, raw)
+	require.NoError(t, err)
+	content, err := os.ReadFile(raw[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "plain output\r\n", string(content))
+}
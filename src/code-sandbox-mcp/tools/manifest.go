@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// RunManifest records what a run_command execution ran with, so the same run can be repeated. Environment
+// values are left out; only their names are kept.
+type RunManifest struct {
+	Tool           string              `json:"tool"`
+	Image          string              `json:"image"`
+	ImageDigest    string              `json:"image_digest"`
+	Command        []string            `json:"command"`
+	WorkDir        string              `json:"workdir,omitempty"`
+	EnvNames       []string            `json:"env_names,omitempty"`
+	Network        string              `json:"network"`
+	TimeoutSeconds int                 `json:"timeout_seconds"`
+	Limits         SandboxLimits       `json:"limits"`
+	Ulimits        []*container.Ulimit `json:"ulimits,omitempty"`
+	// SeccompProfile is the seccomp_profile the run asked for; manifests without one get the default
+	SeccompProfile string    `json:"seccomp_profile,omitempty"`
+	Created        time.Time `json:"created"`
+}
+
+// newRunManifest describes a run_command container; the digest is filled in once the image is resolved
+func newRunManifest(tool, image string, config *container.Config, hostConfig *container.HostConfig, timeout time.Duration) *RunManifest {
+	manifest := &RunManifest{
+		Tool:           tool,
+		Image:          image,
+		Command:        config.Cmd,
+		WorkDir:        config.WorkingDir,
+		Network:        string(hostConfig.NetworkMode),
+		TimeoutSeconds: int(timeout / time.Second),
+		Limits:         limitsFromHostConfig(hostConfig),
+		Ulimits:        hostConfig.Resources.Ulimits,
+		Created:        time.Now().UTC(),
+	}
+	for _, kv := range config.Env {
+		name, _, _ := strings.Cut(kv, "=")
+		manifest.EnvNames = append(manifest.EnvNames, name)
+	}
+	sort.Strings(manifest.EnvNames)
+	return manifest
+}
+
+// resolveImageDigest returns the repository digest (repo@sha256:...) of a local image, which pins it on every
+// daemon that can pull from the registry. Locally built images have none and are pinned by their image ID.
+func resolveImageDigest(ctx context.Context, cli *client.Client, image string) (string, error) {
+	inspect, err := cli.ImageInspect(ctx, image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	return pickDigest(image, inspect.RepoDigests, inspect.ID), nil
+}
+
+// pickDigest prefers the digest of the repository the image was named by
+func pickDigest(image string, repoDigests []string, id string) string {
+	repo := image
+	if i := strings.LastIndex(repo, "@"); i >= 0 {
+		repo = repo[:i]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	for _, digest := range repoDigests {
+		if name, _, _ := strings.Cut(digest, "@"); name == repo || strings.TrimPrefix(name, "docker.io/library/") == repo {
+			return digest
+		}
+	}
+	if len(repoDigests) > 0 {
+		return repoDigests[0]
+	}
+	return id
+}
+
+// manifestConfig rebuilds the container configuration of a manifest with the image pinned to its digest. Values
+// for the recorded variable names come from env; it returns the names no value was given for.
+func manifestConfig(manifest RunManifest, env map[string]string) (*container.Config, *container.HostConfig, []string) {
+	config := sandboxContainerConfig(manifest.ImageDigest)
+	config.Cmd = manifest.Command
+	config.Tty = false
+	config.OpenStdin = false
+	if manifest.WorkDir != "" {
+		config.WorkingDir = manifest.WorkDir
+	}
+
+	var missing []string
+	for _, name := range manifest.EnvNames {
+		value, ok := env[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		config.Env = append(config.Env, name+"="+value)
+	}
+
+	hostConfig := sandboxHostConfig(manifest.Limits.MemoryBytes)
+	hostConfig.Resources.NanoCPUs = int64(manifest.Limits.CPUs * 1e9)
+	hostConfig.Resources.Ulimits = manifest.Ulimits
+	hostConfig.NetworkMode = container.NetworkMode(manifest.Network)
+	return config, hostConfig, missing
+}
+
+// parseManifestArgument reads a manifest given inline or by the execution_id of the run that recorded it
+func parseManifestArgument(request mcp.CallToolRequest) (RunManifest, error) {
+	var manifest RunManifest
+	if id := request.GetString("execution_id", ""); id != "" {
+		record, ok := LookupExecution(id)
+		if !ok {
+			return manifest, fmt.Errorf("execution %s not found; it may have been evicted", id)
+		}
+		if record.Manifest == nil {
+			return manifest, fmt.Errorf("execution %s has no manifest; only run_command and run_from_manifest record one", id)
+		}
+		return *record.Manifest, nil
+	}
+
+	value, ok := request.GetArguments()["manifest"]
+	if !ok {
+		return manifest, fmt.Errorf("manifest or execution_id is required")
+	}
+	// The manifest may arrive as an object or as the JSON text read from executions://{id}/manifest
+	data, isText := value.(string)
+	if !isText {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return manifest, fmt.Errorf("invalid manifest: %v", err)
+		}
+		data = string(encoded)
+	}
+	if err := json.Unmarshal([]byte(data), &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.ImageDigest == "" || len(manifest.Command) == 0 {
+		return manifest, fmt.Errorf("invalid manifest: image_digest and command are required")
+	}
+	if manifest.Network != "bridge" && manifest.Network != "none" {
+		return manifest, fmt.Errorf("invalid manifest: unsupported network %q", manifest.Network)
+	}
+	return manifest, nil
+}
+
+// manifestTimeout is the run timeout recorded in a manifest, or the run_command default when it is out of range
+func manifestTimeout(manifest RunManifest) time.Duration {
+	timeout := time.Duration(manifest.TimeoutSeconds) * time.Second
+	if timeout <= 0 || timeout > maxRunCommandTimeout {
+		return defaultRunCommandTimeout
+	}
+	return timeout
+}
+
+// RunFromManifest repeats a recorded run_command execution with the image pinned to the recorded digest
+func RunFromManifest(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := requireAPIFeature("run_command"); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	manifest, err := parseManifestArgument(request)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	envArg, err := parseEnvArgument(request.GetArguments()["env"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	// The server's package mirrors fill in their variables, as they do for run_command
+	mirrors := packageMirrorsFromEnv()
+	env := map[string]string{}
+	for _, kv := range append(mirrors.Env(), envArg...) {
+		name, value, _ := strings.Cut(kv, "=")
+		env[name] = value
+	}
+
+	if !request.GetBool("force", false) {
+		if err := checkHostResources(ctx); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+
+	seccomp, err := resolveSeccompProfile(manifest.SeccompProfile)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	config, hostConfig, missing := manifestConfig(manifest, env)
+	applySeccompProfile(hostConfig, seccomp)
+	timeout := manifestTimeout(manifest)
+
+	progress := newProgressReporter(ctx, request, phasePull, phaseSetup, phaseExecute, phaseCollect)
+	result, err := runOnce(ctx, progress, config, hostConfig, timeout, request.GetBool("collect_stats", true))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	progress.Done(fmt.Sprintf("Command exited with code %d", result.ExitCode))
+	result.Stdout = redactValues(result.Stdout, mirrors.Credentials())
+	result.Stderr = redactValues(result.Stderr, mirrors.Credentials())
+	rerun := newRunManifest("run_from_manifest", manifest.ImageDigest, config, hostConfig, timeout)
+	rerun.ImageDigest = result.ImageDigest
+	rerun.SeccompProfile = seccomp.Name
+	result.ExecutionID = recordRun("run_from_manifest", result, rerun)
+	result.MissingEnv = missing
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize command result: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
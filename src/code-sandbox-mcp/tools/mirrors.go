@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PackageMirrors point the package managers in a sandbox at internal mirrors instead of the public indexes
+type PackageMirrors struct {
+	PipIndexURL string
+	NPMRegistry string
+	// GoProxy is a GOPROXY list such as "https://goproxy.corp,direct"
+	GoProxy string
+}
+
+// packageMirrorsFromEnv reads the server-wide mirrors from SANDBOX_PIP_INDEX_URL, SANDBOX_NPM_REGISTRY and
+// SANDBOX_GOPROXY
+func packageMirrorsFromEnv() PackageMirrors {
+	return PackageMirrors{
+		PipIndexURL: os.Getenv("SANDBOX_PIP_INDEX_URL"),
+		NPMRegistry: os.Getenv("SANDBOX_NPM_REGISTRY"),
+		GoProxy:     os.Getenv("SANDBOX_GOPROXY"),
+	}
+}
+
+// withArguments overrides the mirrors with the pip_index_url, npm_registry and goproxy arguments of a call
+func (m PackageMirrors) withArguments(request mcp.CallToolRequest) PackageMirrors {
+	m.PipIndexURL = request.GetString("pip_index_url", m.PipIndexURL)
+	m.NPMRegistry = request.GetString("npm_registry", m.NPMRegistry)
+	m.GoProxy = request.GetString("goproxy", m.GoProxy)
+	return m
+}
+
+// Validate checks that the mirrors are http(s) URLs; GoProxy may also list direct and off
+func (m PackageMirrors) Validate() error {
+	check := func(name, value string) error {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			// The URL may hold credentials, so only its name is reported
+			return fmt.Errorf("%s must be an http or https URL", name)
+		}
+		return nil
+	}
+	if m.PipIndexURL != "" {
+		if err := check("pip_index_url", m.PipIndexURL); err != nil {
+			return err
+		}
+	}
+	if m.NPMRegistry != "" {
+		if err := check("npm_registry", m.NPMRegistry); err != nil {
+			return err
+		}
+	}
+	for _, entry := range goProxyEntries(m.GoProxy) {
+		if entry == "direct" || entry == "off" {
+			continue
+		}
+		if err := check("goproxy", entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Env returns the environment variables that make pip, npm and go use the mirrors
+func (m PackageMirrors) Env() []string {
+	var env []string
+	if m.PipIndexURL != "" {
+		env = append(env, "PIP_INDEX_URL="+m.PipIndexURL)
+	}
+	if m.NPMRegistry != "" {
+		env = append(env, "npm_config_registry="+m.NPMRegistry)
+	}
+	if m.GoProxy != "" {
+		env = append(env, "GOPROXY="+m.GoProxy)
+	}
+	return env
+}
+
+// Credentials returns the secrets embedded in the mirror URLs: the password, or the user name when it is a
+// bare token as in https://TOKEN@host
+func (m PackageMirrors) Credentials() []string {
+	var credentials []string
+	for _, value := range append([]string{m.PipIndexURL, m.NPMRegistry}, goProxyEntries(m.GoProxy)...) {
+		u, err := url.Parse(value)
+		if err != nil || u.User == nil {
+			continue
+		}
+		if password, ok := u.User.Password(); ok {
+			credentials = append(credentials, password)
+		} else if username := u.User.Username(); username != "" {
+			credentials = append(credentials, username)
+		}
+	}
+	return credentials
+}
+
+// goProxyEntries splits a GOPROXY list, whose entries are separated by commas or pipes
+func goProxyEntries(goProxy string) []string {
+	if goProxy == "" {
+		return nil
+	}
+	return strings.FieldsFunc(goProxy, func(r rune) bool { return r == ',' || r == '|' })
+}
+
+// redactValues masks each value in s
+func redactValues(s string, values []string) string {
+	for _, value := range values {
+		if len(value) >= minRedactedLength {
+			s = strings.ReplaceAll(s, value, "[REDACTED]")
+		}
+	}
+	return s
+}
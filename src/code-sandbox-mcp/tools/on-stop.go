@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// onStopLabel holds the JSON array of on_stop_commands a sandbox was created with
+const onStopLabel = "code-sandbox-mcp.on-stop"
+
+// onStopTimeout bounds all of a sandbox's on-stop commands together; with the 10 seconds stopping may take, it
+// stays under the watchdog's call deadline
+const onStopTimeout = 30 * time.Second
+
+// parseOnStopArgument validates the on_stop_commands argument of sandbox_initialize
+func parseOnStopArgument(value any) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("on_stop_commands must be an array of strings")
+	}
+	commands := make([]string, 0, len(items))
+	for _, item := range items {
+		command, ok := item.(string)
+		if !ok || strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("on_stop_commands must be an array of non-empty strings")
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// onStopCommands returns the on-stop commands recorded in a container's labels
+func onStopCommands(config *container.Config) ([]string, error) {
+	if config == nil || config.Labels[onStopLabel] == "" {
+		return nil, nil
+	}
+	var commands []string
+	if err := json.Unmarshal([]byte(config.Labels[onStopLabel]), &commands); err != nil {
+		return nil, fmt.Errorf("invalid %s label: %w", onStopLabel, err)
+	}
+	return commands, nil
+}
+
+// runOnStopCommands runs a sandbox's on-stop commands in order within onStopTimeout and returns their output
+// sections. A command that fails or runs out of time ends the hooks with a warning; the stop goes ahead either
+// way.
+func runOnStopCommands(ctx context.Context, containerID string, commands []string) (sections []string, warning string) {
+	ctx, cancel := context.WithTimeout(ctx, onStopTimeout)
+	defer cancel()
+
+	for _, cmd := range commands {
+		header := fmt.Sprintf("$ %s\n", redactSecrets(containerID, cmd))
+		stdout, stderr, exitCode, err := executeCommandWithOutput(ctx, containerID, cmd)
+		var running *execStillRunningError
+		switch {
+		case errors.As(err, &running):
+			section, _ := execSection(ctx, containerID, header, stdout, stderr, 0, false)
+			return append(sections, section), fmt.Sprintf("on-stop command %q did not finish within %s; stopping anyway", redactSecrets(containerID, cmd), onStopTimeout)
+		case err != nil:
+			return append(sections, header+fmt.Sprintf("Error executing command: %v\n", err)), fmt.Sprintf("on-stop command %q could not be run; stopping anyway", redactSecrets(containerID, cmd))
+		}
+		section, _ := execSection(ctx, containerID, header, stdout, stderr, exitCode, false)
+		sections = append(sections, section)
+		if exitCode != 0 {
+			return sections, fmt.Sprintf("on-stop command %q exited with code %d; stopping anyway", redactSecrets(containerID, cmd), exitCode)
+		}
+	}
+	return sections, ""
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOnStopArgument(t *testing.T) {
+	commands, err := parseOnStopArgument(nil)
+	require.NoError(t, err)
+	assert.Empty(t, commands)
+
+	commands, err = parseOnStopArgument([]any{"pg_dump app > /backup/app.sql", "sync"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pg_dump app > /backup/app.sql", "sync"}, commands)
+
+	_, err = parseOnStopArgument("sync")
+	assert.EqualError(t, err, "on_stop_commands must be an array of strings")
+	_, err = parseOnStopArgument([]any{"sync", " "})
+	assert.EqualError(t, err, "on_stop_commands must be an array of non-empty strings")
+}
+
+func TestOnStopCommands(t *testing.T) {
+	commands, err := onStopCommands(&container.Config{Labels: map[string]string{onStopLabel: `["echo \"bye\"","sync"]`}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`echo "bye"`, "sync"}, commands)
+
+	commands, err = onStopCommands(&container.Config{Labels: map[string]string{SandboxLabel: "true"}})
+	require.NoError(t, err)
+	assert.Empty(t, commands)
+
+	_, err = onStopCommands(&container.Config{Labels: map[string]string{onStopLabel: "sync"}})
+	assert.ErrorContains(t, err, "invalid code-sandbox-mcp.on-stop label")
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/client"
+)
+
+// overwritePolicy says what copy_project and copy_file do with a destination that already exists
+type overwritePolicy string
+
+const (
+	// overwriteMerge copies over the destination, leaving files the copy doesn't include in place
+	overwriteMerge overwritePolicy = "merge"
+	// overwriteReplace removes the destination first, so files deleted locally don't linger in the sandbox
+	overwriteReplace overwritePolicy = "replace"
+	// overwriteFailIfExists refuses to copy onto an existing destination
+	overwriteFailIfExists overwritePolicy = "fail_if_exists"
+)
+
+// parseOverwritePolicy reads the overwrite_policy argument, merge when it is omitted
+func parseOverwritePolicy(value string) (overwritePolicy, error) {
+	switch policy := overwritePolicy(value); policy {
+	case "":
+		return overwriteMerge, nil
+	case overwriteMerge, overwriteReplace, overwriteFailIfExists:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown overwrite_policy %q: use merge, replace or fail_if_exists", value)
+}
+
+// DestinationExistsError is returned under fail_if_exists when the destination is already there
+type DestinationExistsError struct {
+	Path string
+}
+
+func (e *DestinationExistsError) Error() string {
+	return fmt.Sprintf("DESTINATION_EXISTS: %s already exists in the container; use overwrite_policy merge to copy over it or replace to remove it first", e.Path)
+}
+
+// preparedDestination is what applying an overwrite policy did before the copy
+type preparedDestination struct {
+	Policy overwritePolicy
+	// Removed counts the entries replace deleted: the files and directories inside a directory, or 1 for a file
+	Removed int
+}
+
+// String is the line added to the copy result, e.g. "Overwrite policy: replace (removed 3 preexisting entries)"
+func (p preparedDestination) String() string {
+	if p.Policy != overwriteReplace {
+		return fmt.Sprintf("Overwrite policy: %s", p.Policy)
+	}
+	entries := "entries"
+	if p.Removed == 1 {
+		entries = "entry"
+	}
+	return fmt.Sprintf("Overwrite policy: replace (removed %d preexisting %s)", p.Removed, entries)
+}
+
+// removableDestination checks that replace may delete target with rm -rf: it must lie inside the container's
+// working directory, and a working directory of / protects nothing, so it allows no removal at all
+func removableDestination(target, workDir string) error {
+	target, workDir = path.Clean(target), path.Clean(workDir)
+	rel := strings.TrimPrefix(target, workDir+"/")
+	if workDir == "/" || rel == target || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("overwrite_policy replace only removes destinations inside the container's working directory %s, and %s is not; use merge or fail_if_exists instead", workDir, target)
+	}
+	return nil
+}
+
+// prepareDestination applies an overwrite policy to target, the path the copy is about to create, before
+// anything is copied. merge leaves the container alone.
+func prepareDestination(ctx context.Context, containerIDOrName, target, workDir string, policy overwritePolicy) (preparedDestination, error) {
+	prepared := preparedDestination{Policy: policy}
+	switch policy {
+	case overwriteMerge:
+		return prepared, nil
+	case overwriteReplace:
+		// Refused before the container is even looked at
+		if err := removableDestination(target, workDir); err != nil {
+			return prepared, err
+		}
+	case overwriteFailIfExists:
+	default:
+		// Only replace may delete anything, so an unknown policy never gets near rm -rf
+		_, err := parseOverwritePolicy(string(policy))
+		return prepared, err
+	}
+
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return prepared, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	stat, err := cli.ContainerStatPath(ctx, containerIDOrName, target)
+	if client.IsErrNotFound(err) {
+		return prepared, nil
+	}
+	if err != nil {
+		return prepared, fmt.Errorf("failed to check %s in the container: %w", target, err)
+	}
+	if policy == overwriteFailIfExists {
+		return prepared, &DestinationExistsError{Path: target}
+	}
+
+	prepared.Removed = 1
+	if stat.Mode.IsDir() {
+		// Count what is about to go; without find this fails before anything is removed
+		stdout, stderr, exitCode, err := executeArgvWithProgress(ctx, containerIDOrName, []string{"find", target, "-mindepth", "1"}, nil)
+		if err != nil || exitCode != 0 {
+			return prepared, fmt.Errorf("overwrite_policy replace needs find and rm in the container to clear %s: %s", target, execFailure(err, exitCode, stderr))
+		}
+		prepared.Removed = strings.Count(stdout, "\n")
+	}
+	if err := executeCommandAndWait(ctx, containerIDOrName, []string{"rm", "-rf", target}); err != nil {
+		return prepared, fmt.Errorf("failed to remove %s before copying: %w", target, err)
+	}
+	return prepared, nil
+}
+
+// execFailure describes why a helper command failed: the error running it, or its exit code and stderr
+func execFailure(err error, exitCode int, stderr string) string {
+	if err != nil {
+		return err.Error()
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Sprintf("exit code %d: %s", exitCode, stderr)
+	}
+	return fmt.Sprintf("exit code %d", exitCode)
+}
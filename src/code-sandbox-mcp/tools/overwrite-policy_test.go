@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemovableDestination(t *testing.T) {
+	for _, target := range []string{"/app/proj", "/app/proj/proj", "/app/a/../proj", "/app/proj/"} {
+		assert.NoError(t, removableDestination(target, "/app"), target)
+	}
+	assert.NoError(t, removableDestination("/srv/app/proj", "/srv/app/"))
+
+	for _, c := range []struct{ target, workDir string }{
+		{"/app", "/app"},
+		{"/app/", "/app"},
+		{"/", "/app"},
+		{"/etc", "/app"},
+		{"/application/proj", "/app"},
+		{"/app/../etc", "/app"},
+		{"/app/proj", "/"},
+		{"/usr", "/"},
+	} {
+		err := removableDestination(c.target, c.workDir)
+		require.Error(t, err, "%s in %s", c.target, c.workDir)
+		assert.Contains(t, err.Error(), "only removes destinations inside the container's working directory")
+	}
+}
+
+func TestPrepareDestinationRefusesReplaceOutsideWorkDir(t *testing.T) {
+	// Refused before Docker is asked anything, so this needs no daemon
+	_, err := prepareDestination(context.Background(), "no-such-container", "/etc", "/app", overwriteReplace)
+	assert.EqualError(t, err, "overwrite_policy replace only removes destinations inside the container's working directory /app, and /etc is not; use merge or fail_if_exists instead")
+
+	prepared, err := prepareDestination(context.Background(), "no-such-container", "/etc", "/app", overwriteMerge)
+	require.NoError(t, err)
+	assert.Equal(t, preparedDestination{Policy: overwriteMerge}, prepared)
+}
+
+func TestParseOverwritePolicy(t *testing.T) {
+	for value, want := range map[string]overwritePolicy{"": overwriteMerge, "merge": overwriteMerge, "replace": overwriteReplace, "fail_if_exists": overwriteFailIfExists} {
+		policy, err := parseOverwritePolicy(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, policy)
+	}
+	_, err := parseOverwritePolicy("Replace")
+	assert.EqualError(t, err, `unknown overwrite_policy "Replace": use merge, replace or fail_if_exists`)
+}
+
+func TestPrepareDestinationRefusesUnknownPolicy(t *testing.T) {
+	// An unknown policy is refused before the container is looked at, whatever the target
+	_, err := prepareDestination(context.Background(), "no-such-container", "/etc", "/app", overwritePolicy("clobber"))
+	assert.ErrorContains(t, err, `unknown overwrite_policy "clobber"`)
+}
+
+func TestPreparedDestinationString(t *testing.T) {
+	assert.Equal(t, "Overwrite policy: merge", preparedDestination{Policy: overwriteMerge}.String())
+	assert.Equal(t, "Overwrite policy: fail_if_exists", preparedDestination{Policy: overwriteFailIfExists}.String())
+	assert.Equal(t, "Overwrite policy: replace (removed 0 preexisting entries)", preparedDestination{Policy: overwriteReplace}.String())
+	assert.Equal(t, "Overwrite policy: replace (removed 1 preexisting entry)", preparedDestination{Policy: overwriteReplace, Removed: 1}.String())
+	assert.Equal(t, "Overwrite policy: replace (removed 5 preexisting entries)", preparedDestination{Policy: overwriteReplace, Removed: 5}.String())
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxInstallOutput caps the installer output returned when preinstalling packages fails
+const maxInstallOutput = 16 * 1024
+
+// packageManagers are the supported package_manager values in auto-detection order, each with the binaries
+// that provide it
+var packageManagers = []struct {
+	Name     string
+	Binaries []string
+}{
+	{"pip", []string{"pip", "pip3"}},
+	{"npm", []string{"npm"}},
+	{"apk", []string{"apk"}},
+	{"apt", []string{"apt-get"}},
+}
+
+// parsePackagesArgument validates the packages argument of sandbox_initialize
+func parsePackagesArgument(value any) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("packages must be an array of strings")
+	}
+	packages := make([]string, 0, len(items))
+	for _, item := range items {
+		pkg, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("each package must be a string")
+		}
+		pkg = strings.TrimSpace(pkg)
+		if pkg == "" {
+			continue
+		}
+		// Packages are passed as single arguments, so a leading dash would be read as an installer option
+		if strings.HasPrefix(pkg, "-") {
+			return nil, fmt.Errorf("invalid package %q: package names cannot start with -", pkg)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// checkPackageManager validates the package_manager argument; "" means auto-detect
+func checkPackageManager(name string) error {
+	if name == "" {
+		return nil
+	}
+	names := make([]string, 0, len(packageManagers))
+	for _, pm := range packageManagers {
+		if pm.Name == name {
+			return nil
+		}
+		names = append(names, pm.Name)
+	}
+	return fmt.Errorf("unsupported package_manager %q: supported values are %s", name, strings.Join(names, ", "))
+}
+
+// findPackageManager returns the binary to run for the package manager, detecting the manager from the binaries
+// present in the container when name is ""
+func findPackageManager(ctx context.Context, containerIDOrName string, name string) (string, string, error) {
+	for _, pm := range packageManagers {
+		if name != "" && pm.Name != name {
+			continue
+		}
+		for _, binary := range pm.Binaries {
+			found, err := containerHasCommand(ctx, containerIDOrName, binary)
+			if err != nil {
+				return "", "", err
+			}
+			if found {
+				return pm.Name, binary, nil
+			}
+		}
+		if name != "" {
+			return "", "", &MissingToolError{Container: containerIDOrName, Tool: pm.Binaries[0], Hint: "choose an image that provides it or another package_manager"}
+		}
+	}
+	return "", "", fmt.Errorf("could not detect a package manager in the image; set package_manager to pip, npm, apk or apt")
+}
+
+// installCommand builds the shell command that installs packages with a package manager binary
+func installCommand(manager, binary string, packages []string) string {
+	quoted := make([]string, len(packages))
+	for i, pkg := range packages {
+		quoted[i] = shellQuote(pkg)
+	}
+	args := strings.Join(quoted, " ")
+
+	switch manager {
+	case "pip":
+		return fmt.Sprintf("PIP_ROOT_USER_ACTION=ignore PIP_DISABLE_PIP_VERSION_CHECK=1 %s install %s", binary, args)
+	case "npm":
+		return fmt.Sprintf("%s install --no-fund --no-audit %s", binary, args)
+	case "apk":
+		return fmt.Sprintf("%s add --no-cache %s", binary, args)
+	default:
+		return fmt.Sprintf("%s update && DEBIAN_FRONTEND=noninteractive %s install -y --no-install-recommends %s", binary, binary, args)
+	}
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// installPackages installs packages in a running sandbox, reporting each line of installer output as progress.
+// It returns the package manager used, or an error carrying the installer output on failure.
+func installPackages(ctx context.Context, progress *progressReporter, containerIDOrName string, manager string, packages []string) (string, error) {
+	manager, binary, err := findPackageManager(ctx, containerIDOrName, manager)
+	if err != nil {
+		return "", err
+	}
+
+	progress.Report(fmt.Sprintf("Installing %d packages with %s", len(packages), manager))
+	if err := runInstaller(ctx, progress, containerIDOrName, manager, installCommand(manager, binary, packages)); err != nil {
+		return "", err
+	}
+	return manager, nil
+}
+
+// runInstaller runs an install command in a running sandbox, reporting each line of its output as progress. A
+// non-zero exit fails with the end of the output, followed by hints for the missing system dependencies it shows.
+func runInstaller(ctx context.Context, progress *progressReporter, containerIDOrName string, manager string, cmd string) (err error) {
+	ctx, span := tracing.Start(ctx, "dependencies.install", tracing.ContainerKey.String(containerIDOrName), attribute.String("package.manager", manager))
+	defer func() { tracing.End(span, err) }()
+
+	lines := &lineWriter{fn: func(line string) { progress.Report(redactSecrets(containerIDOrName, line)) }}
+	stdout, stderr, exitCode, err := executeCommandWithProgress(ctx, containerIDOrName, cmd, lines)
+	lines.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", manager, err)
+	}
+	span.SetAttributes(tracing.ExitCodeKey.Int(exitCode))
+	if exitCode == 0 {
+		return nil
+	}
+
+	output := stdout + stderr
+	var hints string
+	if len(matchInstallFailures(output)) > 0 {
+		// Name the packages of the image's distribution when it can be told
+		systemManager, _ := detectSystemPackageManager(ctx, containerIDOrName)
+		hints = installFailureHints(output, systemManager)
+	}
+	if len(output) > maxInstallOutput {
+		output = "...\n" + output[len(output)-maxInstallOutput:]
+	}
+	output, _ = SanitizeOutput(redactSecrets(containerIDOrName, output), false)
+	if hints != "" {
+		output = strings.TrimRight(output, "\n") + "\n" + hints
+	}
+	return fmt.Errorf("%s install exited with code %d:\n%s", manager, exitCode, output)
+}
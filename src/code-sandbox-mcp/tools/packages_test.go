@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePackagesArgument(t *testing.T) {
+	packages, err := parsePackagesArgument([]any{"requests", " numpy==1.26 ", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"requests", "numpy==1.26"}, packages)
+
+	packages, err = parsePackagesArgument(nil)
+	require.NoError(t, err)
+	assert.Empty(t, packages)
+
+	_, err = parsePackagesArgument("requests")
+	assert.Error(t, err)
+	_, err = parsePackagesArgument([]any{1})
+	assert.Error(t, err)
+	_, err = parsePackagesArgument([]any{"--index-url=http://evil"})
+	assert.ErrorContains(t, err, "cannot start with -")
+}
+
+func TestCheckPackageManager(t *testing.T) {
+	for _, name := range []string{"", "pip", "npm", "apk", "apt"} {
+		assert.NoError(t, checkPackageManager(name), name)
+	}
+	assert.EqualError(t, checkPackageManager("conda"), `unsupported package_manager "conda": supported values are pip, npm, apk, apt`)
+}
+
+func TestInstallCommand(t *testing.T) {
+	packages := []string{"requests", "numpy>=1.26", "it's"}
+	assert.Equal(t,
+		`PIP_ROOT_USER_ACTION=ignore PIP_DISABLE_PIP_VERSION_CHECK=1 pip3 install 'requests' 'numpy>=1.26' 'it'\''s'`,
+		installCommand("pip", "pip3", packages))
+	assert.Equal(t, `npm install --no-fund --no-audit 'lodash'`, installCommand("npm", "npm", []string{"lodash"}))
+	assert.Equal(t, `apk add --no-cache 'curl'`, installCommand("apk", "apk", []string{"curl"}))
+	assert.Equal(t,
+		`apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends 'curl'`,
+		installCommand("apt", "apt-get", []string{"curl"}))
+}
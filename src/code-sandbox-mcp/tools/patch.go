@@ -0,0 +1,402 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// patchHunk is one @@ section of a unified diff
+type patchHunk struct {
+	OldStart int
+	OldLines []string
+	NewLines []string
+	// OldNoEOL/NewNoEOL record a "\ No newline at end of file" marker on either side
+	OldNoEOL bool
+	NewNoEOL bool
+}
+
+// filePatch is a parsed single-file unified diff
+type filePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []patchHunk
+}
+
+// HunkRejectedError reports a hunk whose context could not be found in the file
+type HunkRejectedError struct {
+	Hunk     int
+	Line     int
+	Expected []string
+	Actual   []string
+}
+
+func (e *HunkRejectedError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "PATCH_REJECTED: hunk %d does not apply at line %d\n", e.Hunk, e.Line)
+	b.WriteString("expected:\n")
+	for _, line := range e.Expected {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("found:\n")
+	for _, line := range e.Actual {
+		b.WriteString("  " + line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// patchResult summarizes a successful application
+type patchResult struct {
+	Content string
+	Hunks   int
+	// Offsets holds the line offset each hunk was applied at, relative to its header
+	Offsets []int
+}
+
+// parsePatch parses a unified diff for a single file. The ---/+++ header is optional.
+func parsePatch(diff string) (*filePatch, error) {
+	patch := &filePatch{}
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var hunk *patchHunk
+	oldLeft, newLeft := 0, 0
+	lastSide := byte(0)
+	for i, line := range lines {
+		switch {
+		case hunk != nil && (oldLeft > 0 || newLeft > 0):
+			if line == "" {
+				// Some editors strip the trailing space of empty context lines
+				line = " "
+			}
+			switch line[0] {
+			case ' ':
+				hunk.OldLines = append(hunk.OldLines, line[1:])
+				hunk.NewLines = append(hunk.NewLines, line[1:])
+				oldLeft--
+				newLeft--
+			case '-':
+				hunk.OldLines = append(hunk.OldLines, line[1:])
+				oldLeft--
+			case '+':
+				hunk.NewLines = append(hunk.NewLines, line[1:])
+				newLeft--
+			case '\\':
+				markNoEOL(hunk, lastSide)
+				continue
+			default:
+				return nil, fmt.Errorf("invalid patch line %d: %q", i+1, line)
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("invalid patch line %d: hunk is longer than its header declares", i+1)
+			}
+			lastSide = line[0]
+		case strings.HasPrefix(line, "\\"):
+			if hunk == nil {
+				return nil, fmt.Errorf("invalid patch line %d: %q", i+1, line)
+			}
+			markNoEOL(hunk, lastSide)
+		case strings.HasPrefix(line, "--- "):
+			if patch.OldPath != "" {
+				return nil, fmt.Errorf("patch touches more than one file; apply one file at a time")
+			}
+			patch.OldPath = headerPath(line[4:])
+		case strings.HasPrefix(line, "+++ "):
+			patch.NewPath = headerPath(line[4:])
+		case strings.HasPrefix(line, "@@"):
+			parsed, oldCount, newCount, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hunk header on line %d: %w", i+1, err)
+			}
+			patch.Hunks = append(patch.Hunks, parsed)
+			hunk = &patch.Hunks[len(patch.Hunks)-1]
+			oldLeft, newLeft = oldCount, newCount
+			lastSide = 0
+		case hunk == nil:
+			// Preamble such as "diff --git" or "index" lines
+		default:
+			return nil, fmt.Errorf("invalid patch line %d: %q", i+1, line)
+		}
+	}
+
+	if len(patch.Hunks) == 0 {
+		return nil, fmt.Errorf("patch contains no hunks")
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("patch is truncated: last hunk is shorter than its header declares")
+	}
+	return patch, nil
+}
+
+func markNoEOL(hunk *patchHunk, side byte) {
+	switch side {
+	case '-':
+		hunk.OldNoEOL = true
+	case '+':
+		hunk.NewNoEOL = true
+	case ' ':
+		hunk.OldNoEOL = true
+		hunk.NewNoEOL = true
+	}
+}
+
+// headerPath strips the timestamp and the a/ or b/ prefix from a ---/+++ header
+func headerPath(value string) string {
+	if i := strings.IndexByte(value, '\t'); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if value == "/dev/null" {
+		return value
+	}
+	if strings.HasPrefix(value, "a/") || strings.HasPrefix(value, "b/") {
+		value = value[2:]
+	}
+	return value
+}
+
+// parseHunkHeader parses "@@ -start,count +start,count @@"
+func parseHunkHeader(line string) (patchHunk, int, int, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return patchHunk{}, 0, 0, fmt.Errorf("%q", line)
+	}
+	oldStart, oldCount, err := parseRange(fields[1][1:])
+	if err != nil {
+		return patchHunk{}, 0, 0, err
+	}
+	_, newCount, err := parseRange(fields[2][1:])
+	if err != nil {
+		return patchHunk{}, 0, 0, err
+	}
+	return patchHunk{OldStart: oldStart}, oldCount, newCount, nil
+}
+
+func parseRange(value string) (int, int, error) {
+	start, count, found := strings.Cut(value, ",")
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q", value)
+	}
+	c := 1
+	if found {
+		if c, err = strconv.Atoi(count); err != nil {
+			return 0, 0, fmt.Errorf("invalid range %q", value)
+		}
+	}
+	return s, c, nil
+}
+
+// applyPatch applies the hunks in order. A hunk whose context is not at the line its header names
+// is searched for nearby, and the offset carries over to the following hunks as patch(1) does.
+func applyPatch(content string, patch *filePatch) (*patchResult, error) {
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	out := make([]string, 0, len(lines))
+	result := &patchResult{Hunks: len(patch.Hunks)}
+	next := 0   // first line of the original file not yet copied to out
+	offset := 0 // accumulated offset of earlier hunks
+	for i, hunk := range patch.Hunks {
+		header := hunk.OldStart - 1
+		if len(hunk.OldLines) == 0 {
+			// Pure insertions name the line they go after
+			header = hunk.OldStart
+		}
+		want := header + offset
+		if want < next {
+			want = next
+		}
+
+		pos, ok := findHunk(lines, hunk.OldLines, want, next)
+		if !ok {
+			line := want
+			if line > len(lines) {
+				line = len(lines)
+			}
+			end := line + len(hunk.OldLines)
+			if end > len(lines) {
+				end = len(lines)
+			}
+			return nil, &HunkRejectedError{
+				Hunk:     i + 1,
+				Line:     line + 1,
+				Expected: hunk.OldLines,
+				Actual:   lines[line:end],
+			}
+		}
+
+		offset = pos - header
+		result.Offsets = append(result.Offsets, offset)
+		out = append(out, lines[next:pos]...)
+		out = append(out, hunk.NewLines...)
+		next = pos + len(hunk.OldLines)
+
+		if next == len(lines) {
+			if hunk.NewNoEOL {
+				trailingNewline = false
+			} else if hunk.OldNoEOL {
+				trailingNewline = true
+			}
+		}
+	}
+	out = append(out, lines[next:]...)
+
+	result.Content = strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result.Content += "\n"
+	}
+	return result, nil
+}
+
+// findHunk looks for the hunk's old lines at want, then alternately further after and before it,
+// never before min so hunks cannot overlap
+func findHunk(lines, old []string, want, min int) (int, bool) {
+	matches := func(pos int) bool {
+		if pos < min || pos+len(old) > len(lines) {
+			return false
+		}
+		for j, line := range old {
+			if lines[pos+j] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; want+delta <= len(lines) || want-delta >= min; delta++ {
+		if matches(want + delta) {
+			return want + delta, true
+		}
+		if delta > 0 && matches(want-delta) {
+			return want - delta, true
+		}
+	}
+	return 0, false
+}
+
+// maxDiffCells bounds the line-comparison table of unifiedDiff
+const maxDiffCells = 4_000_000
+
+// noEOLMarker tags a final line without a newline so it never matches the same text with one
+const noEOLMarker = "\x00"
+
+// unifiedDiff renders the line differences between a and b as a unified diff with the given number of
+// context lines, or "" when they are identical; the output can be applied with applyPatch
+func unifiedDiff(aName, bName, a, b string, context int) (string, error) {
+	if a == b {
+		return "", nil
+	}
+	aLines := splitDiffLines(a)
+	bLines := splitDiffLines(b)
+	if (len(aLines)+1)*(len(bLines)+1) > maxDiffCells {
+		return "", fmt.Errorf("files are too large to diff (%d and %d lines)", len(aLines), len(bLines))
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of aLines[i:] and bLines[j:]
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table into an edit script; a and b are the line indexes before each edit
+	type edit struct {
+		op   byte
+		line string
+		a, b int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(aLines) || j < len(bLines) {
+		switch {
+		case i < len(aLines) && j < len(bLines) && aLines[i] == bLines[j]:
+			edits = append(edits, edit{' ', aLines[i], i, j})
+			i++
+			j++
+		case i < len(aLines) && (j == len(bLines) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', aLines[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', bLines[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(edits); {
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		// Changes separated by at most 2*context unchanged lines share a hunk
+		last := first
+		for k := first + 1; k < len(edits) && k-last <= 2*context; k++ {
+			if edits[k].op != ' ' {
+				last = k
+			}
+		}
+		from := max(first-context, start)
+		to := min(last+context+1, len(edits))
+
+		oldCount, newCount := 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[from].a, oldCount), hunkRange(edits[from].b, newCount))
+		for _, e := range edits[from:to] {
+			line, noEOL := strings.CutSuffix(e.line, noEOLMarker)
+			out.WriteByte(e.op)
+			out.WriteString(line)
+			out.WriteByte('\n')
+			if noEOL {
+				out.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return out.String(), nil
+}
+
+// splitDiffLines splits s into lines, tagging a final line that has no newline
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	trimmed, hasEOL := strings.CutSuffix(s, "\n")
+	lines := strings.Split(trimmed, "\n")
+	if !hasEOL {
+		lines[len(lines)-1] += noEOLMarker
+	}
+	return lines
+}
+
+// hunkRange formats a hunk header range; an empty range names the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
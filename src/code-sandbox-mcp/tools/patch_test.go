@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const patchOriginal = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+func helper() int {
+	return 1
+}
+`
+
+func TestApplyPatchClean(t *testing.T) {
+	diff := `--- a/main.go
++++ b/main.go
+@@ -5,3 +5,3 @@
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("hello, world")
+ }
+@@ -9,3 +9,4 @@
+ func helper() int {
+-	return 1
++	x := 2
++	return x
+ }
+`
+	patch, err := parsePatch(diff)
+	require.NoError(t, err)
+	assert.Equal(t, "main.go", patch.OldPath)
+	assert.Equal(t, "main.go", patch.NewPath)
+
+	result, err := applyPatch(patchOriginal, patch)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Hunks)
+	assert.Equal(t, []int{0, 0}, result.Offsets)
+	assert.Contains(t, result.Content, `fmt.Println("hello, world")`)
+	assert.Contains(t, result.Content, "\tx := 2\n\treturn x\n}\n")
+	assert.NotContains(t, result.Content, "return 1")
+}
+
+func TestApplyPatchOffset(t *testing.T) {
+	// The file gained three lines above the hunk since the diff was made
+	content := "// line one\n// line two\n// line three\n" + patchOriginal
+	diff := `@@ -9,3 +9,3 @@
+ func helper() int {
+-	return 1
++	return 42
+ }
+`
+	patch, err := parsePatch(diff)
+	require.NoError(t, err)
+
+	result, err := applyPatch(content, patch)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, result.Offsets)
+	assert.Contains(t, result.Content, "return 42")
+	assert.True(t, len(result.Content) > 0 && result.Content[len(result.Content)-1] == '\n')
+}
+
+func TestApplyPatchRejectedHunk(t *testing.T) {
+	diff := `@@ -5,3 +5,3 @@
+ func main() {
+-	fmt.Println("goodbye")
++	fmt.Println("hello, world")
+ }
+`
+	patch, err := parsePatch(diff)
+	require.NoError(t, err)
+
+	_, err = applyPatch(patchOriginal, patch)
+	require.Error(t, err)
+	var rejected *HunkRejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, 1, rejected.Hunk)
+	assert.Equal(t, 5, rejected.Line)
+	assert.Contains(t, err.Error(), "PATCH_REJECTED")
+	assert.Contains(t, err.Error(), `fmt.Println("goodbye")`)
+	assert.Contains(t, err.Error(), `fmt.Println("hello")`)
+}
+
+func TestApplyPatchNoNewlineAtEOF(t *testing.T) {
+	diff := `@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+`
+	patch, err := parsePatch(diff)
+	require.NoError(t, err)
+
+	result, err := applyPatch("a\nb", patch)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nc\n", result.Content)
+}
+
+func TestApplyPatchCreatesFile(t *testing.T) {
+	diff := `--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++first
++second
+`
+	patch, err := parsePatch(diff)
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/null", patch.OldPath)
+
+	result, err := applyPatch("", patch)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", result.Content)
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	_, err := parsePatch("just some text\n")
+	assert.ErrorContains(t, err, "no hunks")
+
+	_, err = parsePatch("@@ -1,3 +1,3 @@\n a\n")
+	assert.ErrorContains(t, err, "truncated")
+
+	_, err = parsePatch("--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n")
+	assert.ErrorContains(t, err, "more than one file")
+}
+
+func TestResolveInWorkDir(t *testing.T) {
+	got, err := resolveInWorkDir("/app", "src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "/app/src/main.go", got)
+
+	got, err = resolveInWorkDir("/app", "/app/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "/app/main.go", got)
+
+	for _, p := range []string{"../etc/passwd", "/etc/passwd", "/application/x", "src/../../x", "."} {
+		_, err := resolveInWorkDir("/app", p)
+		assert.Error(t, err, p)
+	}
+}
+
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	changed := strings.Replace(patchOriginal, `fmt.Println("hello")`, `fmt.Println("hello, world")`, 1)
+	changed = strings.Replace(changed, "\treturn 1\n", "\tx := 2\n\treturn x\n", 1)
+
+	cases := map[string][2]string{
+		"edits":               {patchOriginal, changed},
+		"new file":            {"", "one\ntwo\n"},
+		"deleted content":     {"one\ntwo\n", ""},
+		"trailing newline":    {"one\ntwo", "one\ntwo\n"},
+		"no newline either":   {"one\ntwo", "one\nthree"},
+		"change at both ends": {"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n", "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			diff, err := unifiedDiff("a/f", "b/f", c[0], c[1], 3)
+			require.NoError(t, err)
+			patch, err := parsePatch(diff)
+			require.NoError(t, err, diff)
+			result, err := applyPatch(c[0], patch)
+			require.NoError(t, err, diff)
+			assert.Equal(t, c[1], result.Content, diff)
+		})
+	}
+
+	diff, err := unifiedDiff("a/f", "b/f", patchOriginal, changed, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(diff, "@@ -"), "changes within 2*context lines share a hunk")
+
+	ends := cases["change at both ends"]
+	diff, err = unifiedDiff("a/f", "b/f", ends[0], ends[1], 3)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(diff, "@@ -"), "distant changes get separate hunks")
+
+	diff, err = unifiedDiff("a/f", "b/f", patchOriginal, patchOriginal, 3)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestFileArchiveCreatesParents(t *testing.T) {
+	archive, err := fileArchive("/app/new/pkg/util.go", []byte("package pkg\n"), 0644)
+	require.NoError(t, err)
+	entries := readTarEntries(t, archive)
+	// Rooted at /, so the missing /app/new/pkg is created by the extraction
+	require.Contains(t, entries, "app/new/pkg/util.go")
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int64(0644), entries["app/new/pkg/util.go"].Mode)
+}
@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// MissingToolError reports that a binary a tool depends on is not available in the container
+type MissingToolError struct {
+	Container string
+	Tool      string
+	Hint      string
+}
+
+func (e *MissingToolError) Error() string {
+	msg := fmt.Sprintf("MISSING_TOOL: container %s has no `%s` on PATH", e.Container, e.Tool)
+	if e.Hint != "" {
+		msg += "; " + e.Hint
+	}
+	return msg
+}
+
+// requireCommand returns a MissingToolError if the named binary cannot be found in the container
+func requireCommand(ctx context.Context, containerIDOrName string, name string, hint string) error {
+	found, err := containerHasCommand(ctx, containerIDOrName, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return &MissingToolError{Container: containerIDOrName, Tool: name, Hint: hint}
+	}
+	return nil
+}
+
+// containerHasCommand probes the container with `command -v` to check whether a binary is available.
+// A container without `sh` reports every binary, including `sh` itself, as missing.
+func containerHasCommand(ctx context.Context, containerIDOrName string, name string) (bool, error) {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	attachCtx, cancel := withDockerTimeout(ctx, execAttachTimeout)
+	defer cancel()
+	exec, err := cli.ContainerExecCreate(attachCtx, containerIDOrName, container.ExecOptions{
+		Cmd:          []string{"sh", "-c", fmt.Sprintf("command -v %s", name)},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := cli.ContainerExecAttach(attachCtx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		if isExecutableNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer resp.Close()
+
+	// Drain the output so the exec runs to completion
+	if _, err := io.Copy(io.Discard, resp.Reader); err != nil {
+		return false, fmt.Errorf("failed to read probe output: %w", err)
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	// 126/127 come back from the runtime when `sh` itself is missing
+	return inspect.ExitCode == 0, nil
+}
+
+// isExecutableNotFound checks whether the runtime refused to start an exec because the binary does not exist
+func isExecutableNotFound(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "executable file not found") || strings.Contains(msg, "no such file or directory")
+}
+
+// containerShells caches the shell picked for each container, since every login_shell exec would otherwise probe
+// for bash first
+var containerShells = struct {
+	sync.Mutex
+	shells map[string]string
+}{shells: map[string]string{}}
+
+// containerShell returns bash when the container has it, and /bin/sh otherwise. A failed probe isn't cached.
+func containerShell(ctx context.Context, containerIDOrName string) string {
+	containerShells.Lock()
+	shell, ok := containerShells.shells[containerIDOrName]
+	containerShells.Unlock()
+	if ok {
+		return shell
+	}
+
+	found, err := containerHasCommand(ctx, containerIDOrName, "bash")
+	if err != nil {
+		return "/bin/sh"
+	}
+	shell = "/bin/sh"
+	if found {
+		shell = "bash"
+	}
+	containerShells.Lock()
+	containerShells.shells[containerIDOrName] = shell
+	containerShells.Unlock()
+	return shell
+}
+
+// forgetShell drops the cached shell of a container that is going away, as its name may be reused
+func forgetShell(containerIDOrName string) {
+	containerShells.Lock()
+	defer containerShells.Unlock()
+	delete(containerShells.shells, containerIDOrName)
+}
+
+// shellMode is how sandbox_exec runs a command: with plain sh -c by default, as a login shell that reads the
+// profile scripts, or after sourcing /etc/profile
+type shellMode struct {
+	Login       bool
+	FromProfile bool
+}
+
+// argv wraps cmd for the mode; shell is the login shell, from containerShell
+func (m shellMode) argv(shell string, cmd string) []string {
+	if m.FromProfile {
+		cmd = "[ -r /etc/profile ] && . /etc/profile; " + cmd
+	}
+	if m.Login {
+		return []string{shell, "-lc", cmd}
+	}
+	return []string{"sh", "-c", cmd}
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Progress phases of the long-running tools. Each tool lists the phases of a call up front, and progress counts
+// the phases completed out of a total equal to their number.
+const (
+	phasePull    = "pull"
+	phaseSetup   = "setup"
+	phaseInstall = "install"
+	phaseArchive = "archive"
+	phaseUpload  = "upload"
+	phaseExtract = "extract"
+	phaseExecute = "execute"
+	phaseCollect = "collect"
+)
+
+// progressReporter sends notifications/progress messages for a tool call; it does nothing when the client
+// didn't ask for progress by setting a progress token
+type progressReporter struct {
+	ctx       context.Context
+	token     mcp.ProgressToken
+	phases    []string
+	completed int // phases finished before the current one
+	steps     int // updates sent within the current phase
+	sent      bool
+	progress  float64
+	send      func(params map[string]any) // replaced in tests
+}
+
+// newProgressReporter reports the progress of a call going through the given phases
+func newProgressReporter(ctx context.Context, request mcp.CallToolRequest, phases ...string) *progressReporter {
+	r := &progressReporter{ctx: ctx, phases: phases}
+	if request.Params.Meta != nil {
+		r.token = request.Params.Meta.ProgressToken
+	}
+	if srv := server.ServerFromContext(ctx); srv != nil {
+		r.send = func(params map[string]any) {
+			// Progress is best effort; a client that went away doesn't fail the call
+			_ = srv.SendNotificationToClient(ctx, "notifications/progress", params)
+		}
+	}
+	return r
+}
+
+// Phase starts the named phase, counting the phases before it as completed; phases that were skipped count too
+func (r *progressReporter) Phase(name string, message string) {
+	i := slices.Index(r.phases, name)
+	if i < r.completed || (i == r.completed && r.sent) {
+		// An unknown phase, or one already started, is just an update
+		r.Report(message)
+		return
+	}
+	r.completed, r.steps = i, 0
+	r.emit(float64(i), message)
+}
+
+// Report sends an update within the current phase. Progress creeps towards the end of the phase without reaching
+// it, so it keeps increasing however many updates a phase sends.
+func (r *progressReporter) Report(message string) {
+	r.steps++
+	r.emit(float64(r.completed)+float64(r.steps)/float64(r.steps+1), message)
+}
+
+// Done marks every phase completed
+func (r *progressReporter) Done(message string) {
+	r.completed, r.steps = len(r.phases), 0
+	r.emit(float64(len(r.phases)), message)
+}
+
+// emit sends one notification, with the phase count as its total
+func (r *progressReporter) emit(progress float64, message string) {
+	if r.token == nil || r.send == nil || (r.sent && progress <= r.progress) {
+		return
+	}
+	r.sent, r.progress = true, progress
+	params := map[string]any{
+		"progressToken": r.token,
+		"progress":      progress,
+		"message":       message,
+	}
+	if len(r.phases) > 0 {
+		params["total"] = float64(len(r.phases))
+	}
+	r.send(params)
+}
+
+// ReportBytes sends the progress of a transfer as bytes done out of total, for tools that move a single file
+func (r *progressReporter) ReportBytes(done, total int64, message string) {
+	if r.token == nil || r.send == nil {
+		return
+	}
+	r.sent, r.progress = true, float64(done)
+	r.send(map[string]any{
+		"progressToken": r.token,
+		"progress":      r.progress,
+		"total":         float64(total),
+		"message":       message,
+	})
+}
+
+// countingReader passes reads through and calls onProgress with the bytes read so far each time another step
+// bytes have gone by, and once more at EOF
+type countingReader struct {
+	r          io.Reader
+	step       int64
+	onProgress func(n int64)
+	n          int64
+	reported   int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n-c.reported >= c.step || (err == io.EOF && c.n != c.reported) {
+		c.reported = c.n
+		c.onProgress(c.n)
+	}
+	return n, err
+}
+
+// lineWriter calls fn with each complete line written to it, without the newline
+type lineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.fn(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush passes on a final line that had no newline
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.fn(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{fn: func(line string) { lines = append(lines, line) }}
+
+	w.Write([]byte("Collecting requests\r\nDownloading"))
+	w.Write([]byte(" idna\nInstalling"))
+	assert.Equal(t, []string{"Collecting requests", "Downloading idna"}, lines)
+
+	w.Flush()
+	assert.Equal(t, []string{"Collecting requests", "Downloading idna", "Installing"}, lines)
+}
+
+func TestProgressReporterWithoutToken(t *testing.T) {
+	// Without a progress token, or outside a server, reporting is a no-op
+	r := newProgressReporter(context.Background(), newMockCallToolRequest("sandbox_initialize", nil))
+	r.Report("Installing")
+	assert.Zero(t, r.progress)
+
+	request := newMockCallToolRequest("sandbox_initialize", nil)
+	request.Params.Meta = &mcp.Meta{ProgressToken: "init-1"}
+	r = newProgressReporter(context.Background(), request)
+	r.Report("Installing")
+	assert.Zero(t, r.progress)
+}
+
+// capturingReporter returns a reporter with a progress token whose notifications are collected in sent
+func capturingReporter(phases ...string) (*progressReporter, *[]map[string]any) {
+	request := newMockCallToolRequest("run_command", nil)
+	request.Params.Meta = &mcp.Meta{ProgressToken: "run-1"}
+	r := newProgressReporter(context.Background(), request, phases...)
+	var sent []map[string]any
+	r.send = func(params map[string]any) { sent = append(sent, params) }
+	return r, &sent
+}
+
+func TestProgressReporterPhases(t *testing.T) {
+	r, sent := capturingReporter(phasePull, phaseSetup, phaseExecute, phaseCollect)
+	r.Phase(phasePull, "Pulling alpine")
+	r.Report("Downloading layer 1")
+	r.Report("Downloading layer 2")
+	r.Phase(phaseSetup, "Creating the container")
+	// Skipping execute counts it as completed
+	r.Phase(phaseCollect, "Collecting the output")
+	r.Report("Reading logs")
+	r.Done("Command exited with code 0")
+
+	var progress []float64
+	for _, params := range *sent {
+		assert.Equal(t, "run-1", params["progressToken"])
+		assert.Equal(t, 4.0, params["total"])
+		progress = append(progress, params["progress"].(float64))
+	}
+	assert.Equal(t, []float64{0, 0.5, 2.0 / 3, 1, 3, 3.5, 4}, progress)
+	assert.Equal(t, "Command exited with code 0", (*sent)[len(*sent)-1]["message"])
+}
+
+func TestProgressReporterStrictlyIncreases(t *testing.T) {
+	r, sent := capturingReporter(phaseArchive, phaseUpload)
+	r.Phase(phaseUpload, "Uploading")
+	// Going back to an earlier phase or repeating one is only an update
+	r.Phase(phaseArchive, "Archiving")
+	r.Phase(phaseUpload, "Uploading again")
+	for i := 0; i < 100; i++ {
+		r.Report("Still uploading")
+	}
+	r.Done("Copied")
+	r.Done("Copied twice")
+
+	last := -1.0
+	for _, params := range *sent {
+		p := params["progress"].(float64)
+		assert.Greater(t, p, last)
+		assert.LessOrEqual(t, p, 2.0)
+		last = p
+	}
+	assert.Equal(t, 2.0, last)
+	assert.Len(t, *sent, 104)
+}
+
+func TestProgressReporterWithoutPhases(t *testing.T) {
+	// A reporter without phases sends no total
+	r, sent := capturingReporter()
+	r.Report("Installing")
+	r.Report("Installed")
+	if assert.Len(t, *sent, 2) {
+		assert.NotContains(t, (*sent)[0], "total")
+		assert.Less(t, (*sent)[0]["progress"], (*sent)[1]["progress"])
+	}
+}
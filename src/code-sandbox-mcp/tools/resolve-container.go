@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// AmbiguousReferenceError is returned when a container reference is an ID prefix shared by several containers,
+// or when it is omitted (an empty Ref) while several sandboxes are running
+type AmbiguousReferenceError struct {
+	Ref        string
+	Candidates []string
+}
+
+func (e *AmbiguousReferenceError) Error() string {
+	if e.Ref == "" {
+		return fmt.Sprintf("AMBIGUOUS_REFERENCE: container_id_or_name was omitted but %d sandboxes are running: %s; pass one of them, \"latest\" for the most recently created, or select one with set_active_sandbox",
+			len(e.Candidates), strings.Join(e.Candidates, ", "))
+	}
+	return fmt.Sprintf("AMBIGUOUS_REFERENCE: %q matches %d containers: %s; use a longer ID or the container name",
+		e.Ref, len(e.Candidates), strings.Join(e.Candidates, ", "))
+}
+
+// ContainerName returns the primary name of a listed container without Docker's leading slash
+func ContainerName(c container.Summary) string {
+	for _, name := range c.Names {
+		name = strings.TrimPrefix(name, "/")
+		// Legacy links add names such as /other/alias; those aren't the container's own name
+		if !strings.Contains(name, "/") {
+			return name
+		}
+	}
+	return ""
+}
+
+// ResolveContainer turns a container_id_or_name argument into a reference that names exactly one container,
+// and records that the container is being used, for its idle_seconds. An exact name wins over an ID, then a full
+// ID, then an ID prefix that no other container shares. The result is the container's name, which Docker also
+// looks up before ID prefixes, or its full ID if it has no name.
+func ResolveContainer(ctx context.Context, containerIDOrName string) (string, error) {
+	ref, err := lookupContainer(ctx, containerIDOrName)
+	if err != nil {
+		return "", err
+	}
+	sandboxActivity.Touch(ref)
+	return ref, nil
+}
+
+// lookupContainer resolves a reference like ResolveContainer, for the tools that only look at a container or
+// stop it, which don't make it any less idle
+func lookupContainer(ctx context.Context, containerIDOrName string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %w", err)
+	}
+	return matchContainer(containers, containerIDOrName)
+}
+
+// matchContainer applies the ResolveContainer precedence to a container list
+func matchContainer(containers []container.Summary, containerIDOrName string) (string, error) {
+	ref := strings.TrimPrefix(containerIDOrName, "/")
+	if ref == "" {
+		return "", fmt.Errorf("container_id_or_name is empty")
+	}
+	canonical := func(c container.Summary) string {
+		if name := ContainerName(c); name != "" {
+			return name
+		}
+		return c.ID
+	}
+
+	for _, c := range containers {
+		if ContainerName(c) == ref {
+			return canonical(c), nil
+		}
+	}
+	for _, c := range containers {
+		if c.ID == ref {
+			return canonical(c), nil
+		}
+	}
+
+	var matches []container.Summary
+	for _, c := range containers {
+		if strings.HasPrefix(c.ID, ref) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no such container: %s", containerIDOrName)
+	case 1:
+		return canonical(matches[0]), nil
+	}
+	candidates := make([]string, len(matches))
+	for i, c := range matches {
+		candidates[i] = c.ID[:12]
+		if name := ContainerName(c); name != "" {
+			candidates[i] += " (" + name + ")"
+		}
+	}
+	return "", &AmbiguousReferenceError{Ref: containerIDOrName, Candidates: candidates}
+}
+
+// defaultWorkDir is the base for relative paths in containers without a WORKDIR, and the working directory of
+// sandboxes that don't set one
+const defaultWorkDir = "/app"
+
+// containerWorkDir returns the container's working directory, defaulting to /app
+func containerWorkDir(ctx context.Context, cli *client.Client, containerIDOrName string) (string, error) {
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if inspect.Config != nil && inspect.Config.WorkingDir != "" {
+		return path.Clean(inspect.Config.WorkingDir), nil
+	}
+	return defaultWorkDir, nil
+}
+
+// lookupWorkDir is containerWorkDir with its own Docker client, for tools that don't otherwise need one
+func lookupWorkDir(ctx context.Context, containerIDOrName string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+	return containerWorkDir(ctx, cli, containerIDOrName)
+}
+
+// inWorkDir resolves a container path against the working directory unless it is absolute
+func inWorkDir(workDir, p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(workDir, p)
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchContainer(t *testing.T) {
+	containers := []container.Summary{
+		{ID: "abc123def4560000000000000000000000000000000000000000000000000000", Names: []string{"/web"}},
+		{ID: "abc123def4561111111111111111111111111111111111111111111111111111", Names: []string{"/worker", "/web/worker"}},
+		// A sandbox whose name looks like an ID prefix of the others
+		{ID: "ffff000000000000000000000000000000000000000000000000000000000000", Names: []string{"/abc123"}},
+		{ID: "0123456789ab0000000000000000000000000000000000000000000000000000"},
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"web", "web"},
+		{"/worker", "worker"},
+		// The exact name wins over the ID prefix it collides with
+		{"abc123", "abc123"},
+		{"abc123def4560000000000000000000000000000000000000000000000000000", "web"},
+		{"abc123def4561", "worker"},
+		{"ffff", "abc123"},
+		// Containers without a name resolve to their full ID
+		{"0123", "0123456789ab0000000000000000000000000000000000000000000000000000"},
+	}
+	for _, tt := range tests {
+		got, err := matchContainer(containers, tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.want, got, tt.ref)
+	}
+
+	_, err := matchContainer(containers, "abc123def456")
+	var ambiguous *AmbiguousReferenceError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []string{"abc123def456 (web)", "abc123def456 (worker)"}, ambiguous.Candidates)
+	assert.Contains(t, err.Error(), "AMBIGUOUS_REFERENCE: ")
+
+	_, err = matchContainer(containers, "missing")
+	assert.ErrorContains(t, err, "no such container: missing")
+}
+
+func TestContainerName(t *testing.T) {
+	assert.Equal(t, "worker", ContainerName(container.Summary{Names: []string{"/web/worker", "/worker"}}))
+	assert.Equal(t, "", ContainerName(container.Summary{}))
+}
+
+func TestInWorkDir(t *testing.T) {
+	tests := []struct {
+		workDir, path, want string
+	}{
+		{"/app", "", "/app"},
+		{"/app", "main.py", "/app/main.py"},
+		{"/usr/src/app", "src/index.js", "/usr/src/app/src/index.js"},
+		{"/workspace", "../etc/hosts", "/etc/hosts"},
+		{"/workspace", "/tmp//out.txt", "/tmp/out.txt"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, inWorkDir(tt.workDir, tt.path), "inWorkDir(%q, %q)", tt.workDir, tt.path)
+	}
+}
@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/events"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultRunCommandTimeout applies when run_command gets no timeout_seconds
+	defaultRunCommandTimeout = 60 * time.Second
+	// maxRunCommandTimeout caps timeout_seconds
+	maxRunCommandTimeout = 10 * time.Minute
+)
+
+// commandResult is the result of run_command
+type commandResult struct {
+	ExitCode    int    `json:"exit_code"`
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	TimedOut    bool   `json:"timed_out,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+	// OutputWarning says how the output was recovered when it couldn't be split into stdout and stderr
+	OutputWarning string `json:"output_warning,omitempty"`
+	// SeccompWarning is set when the engine rejected the seccomp profile and the command ran under its default
+	SeccompWarning string `json:"seccomp_warning,omitempty"`
+	// Usage is left out when collect_stats is false
+	Usage *ResourceUsage `json:"usage,omitempty"`
+	// ImageDigest pins the image that ran; with ExecutionID it identifies the run's manifest
+	ImageDigest string `json:"image_digest,omitempty"`
+	ExecutionID string `json:"execution_id,omitempty"`
+	// MissingEnv lists the manifest variables run_from_manifest got no value for
+	MissingEnv []string `json:"missing_env,omitempty"`
+	// Timing is how long the run's phases took, e.g. {"image_pull_ms": 850, "exec_ms": 120}
+	Timing map[string]int64 `json:"timing,omitempty"`
+	// Summarized is set when summarize_output elided lines of stdout or stderr
+	Summarized *OutputSummary `json:"summarized,omitempty"`
+}
+
+// RunCommand runs a single command in a new container and removes the container afterwards
+func RunCommand(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := requireAPIFeature("run_command"); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	image, err := request.RequireString("image")
+	if err != nil {
+		return mcp.NewToolResultText("image is required"), nil
+	}
+
+	cmd, err := parseCommandArgument(request.GetArguments()["command"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	env, err := parseEnvArgument(request.GetArguments()["env"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	timeout, err := runCommandTimeout(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	network := request.GetString("network", "bridge")
+	if network != "bridge" && network != "none" {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: unsupported network %q: use bridge or none", network)), nil
+	}
+	seccomp, err := resolveSeccompProfile(request.GetString("seccomp_profile", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	mirrors := packageMirrorsFromEnv()
+	config := sandboxContainerConfig(image)
+	config.Cmd = cmd
+	config.Env = append(mirrors.Env(), env...)
+	// Without a TTY stdout and stderr stay separate
+	config.Tty = false
+	config.OpenStdin = false
+	if workdir := request.GetString("workdir", ""); workdir != "" {
+		config.WorkingDir = workdir
+	}
+	hostConfig := sandboxHostConfig(0)
+	hostConfig.Resources.Ulimits = defaultUlimits
+	hostConfig.NetworkMode = container.NetworkMode(network)
+	applySeccompProfile(hostConfig, seccomp)
+
+	// Pulling onto a nearly full disk can wedge the Docker daemon
+	if !request.GetBool("force", false) {
+		if err := checkHostResources(ctx); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+
+	// Sampling stats costs a request to the daemon per second; callers timing tiny commands can turn it off
+	collectStats := request.GetBool("collect_stats", true)
+
+	progress := newProgressReporter(ctx, request, phasePull, phaseSetup, phaseExecute, phaseCollect)
+	result, err := runOnce(ctx, progress, config, hostConfig, timeout, collectStats)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	progress.Done(fmt.Sprintf("Command exited with code %d", result.ExitCode))
+	result.Stdout = redactValues(result.Stdout, mirrors.Credentials())
+	result.Stderr = redactValues(result.Stderr, mirrors.Credentials())
+
+	// Record a manifest so the run can be repeated with run_from_manifest
+	manifest := newRunManifest("run_command", image, config, hostConfig, timeout)
+	manifest.ImageDigest = result.ImageDigest
+	manifest.SeccompProfile = seccomp.Name
+	result.ExecutionID = recordRun("run_command", result, manifest)
+	if request.GetBool("summarize_output", false) {
+		result.summarize()
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize command result: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// runCommandTimeout reads the timeout_seconds of run_command, defaulting and capping it
+func runCommandTimeout(request mcp.CallToolRequest) (time.Duration, error) {
+	timeout, err := parseDurationArgument("timeout_seconds", request.GetArguments()["timeout_seconds"], time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if timeout == 0 {
+		timeout = defaultRunCommandTimeout
+	}
+	return min(timeout, maxRunCommandTimeout), nil
+}
+
+// summarize shortens stdout and stderr with SummarizeOutput; it runs after the result is recorded, so
+// executions://{execution_id}/output keeps the full output
+func (r *commandResult) summarize() {
+	var total OutputSummary
+	var summary OutputSummary
+	r.Stdout, summary = SummarizeOutput(r.Stdout)
+	total.Add(summary)
+	r.Stderr, summary = SummarizeOutput(r.Stderr)
+	total.Add(summary)
+	if total.Elided > 0 {
+		r.Summarized = &total
+	}
+}
+
+// parseCommandArgument accepts a shell command string, run with sh -c, or an argv array run as is
+func parseCommandArgument(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil, fmt.Errorf("command must not be empty")
+		}
+		return []string{"/bin/sh", "-c", v}, nil
+	case []any:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("command must not be empty")
+		}
+		argv := make([]string, len(v))
+		for i, item := range v {
+			arg, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("each command argument must be a string")
+			}
+			argv[i] = arg
+		}
+		return argv, nil
+	default:
+		return nil, fmt.Errorf("command must be a string or an array of strings")
+	}
+}
+
+// parseEnvArgument turns an object of environment variables into KEY=VALUE entries, sorted by name
+func parseEnvArgument(value any) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	vars, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of strings")
+	}
+	env := make([]string, 0, len(vars))
+	for key, v := range vars {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("env value of %s must be a string", key)
+		}
+		env = append(env, key+"="+s)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// runOnce creates and starts a container, waits for it to exit or the timeout to pass, and collects its output
+// and, with collectStats, its resource usage. The container is removed in every case. It reports the pull, setup,
+// execute and collect phases, and times them in the result.
+func runOnce(ctx context.Context, progress *progressReporter, config *container.Config, hostConfig *container.HostConfig, timeout time.Duration, collectStats bool) (*commandResult, error) {
+	ctx, timing := tracing.WithTiming(ctx)
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	// An image ID from a manifest names a local image that can't be pulled
+	if !strings.HasPrefix(config.Image, "sha256:") {
+		progress.Phase(phasePull, fmt.Sprintf("Pulling %s", config.Image))
+		if err := pullImage(ctx, cli, config.Image, progress.Report); err != nil {
+			return nil, err
+		}
+	}
+	digest, err := resolveImageDigest(ctx, cli, config.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Phase(phaseSetup, "Creating the container")
+	id, err := createTracedContainer(ctx, cli, config, hostConfig, "")
+	seccompWarning, retry := seccompFallback(hostConfig, err)
+	if retry {
+		id, err = createTracedContainer(ctx, cli, config, hostConfig, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Remove the container even when the call was cancelled
+		if err := cli.ContainerRemove(context.WithoutCancel(ctx), id, container.RemoveOptions{Force: true, RemoveVolumes: true}); err == nil {
+			events.Emit(events.Event{Type: events.ContainerRemoved, Container: id})
+		}
+	}()
+
+	// Wait for the next exit before starting, so a command that exits at once isn't missed
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	waitCh, errCh := cli.ContainerWait(waitCtx, id, container.WaitConditionNextExit)
+
+	progress.Phase(phaseExecute, "Running the command")
+	started := time.Now()
+	err = startTracedContainer(ctx, cli, id)
+	if warning, retry := seccompFallback(hostConfig, err); retry {
+		// Most engines only load the profile at start, so the container is replaced by one without it
+		seccompWarning = warning
+		if err := removeContainer(ctx, cli, id); err != nil {
+			return nil, fmt.Errorf("failed to remove the container the seccomp profile kept from starting: %w", err)
+		}
+		if id, err = createTracedContainer(ctx, cli, config, hostConfig, ""); err != nil {
+			return nil, err
+		}
+		waitCh, errCh = cli.ContainerWait(waitCtx, id, container.WaitConditionNextExit)
+		started = time.Now()
+		err = startTracedContainer(ctx, cli, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, waitSpan := tracing.Start(ctx, "container.wait", tracing.ContainerKey.String(id))
+	var usage <-chan ResourceUsage
+	statsCtx, stopStats := context.WithCancel(waitCtx)
+	defer stopStats()
+	if collectStats {
+		usage = sampleUsage(statsCtx, cli, id)
+	}
+
+	result, err := waitForCommand(ctx, cli, id, waitCh, errCh, timeout, hostConfig.Resources.Memory)
+	if err == nil {
+		waitSpan.SetAttributes(tracing.ExitCodeKey.Int(result.ExitCode))
+	}
+	tracing.End(waitSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	result.ImageDigest = digest
+	result.SeccompWarning = seccompWarning
+	wall := time.Since(started)
+	if usage != nil {
+		stopStats()
+		u := <-usage
+		u.WallSeconds = wall.Seconds()
+		result.Usage = &u
+	}
+
+	progress.Phase(phaseCollect, "Collecting the output")
+	if err := collectOutput(ctx, cli, id, result); err != nil {
+		return nil, err
+	}
+	result.Timing = timing.Milliseconds()
+	return result, nil
+}
+
+// waitForCommand waits for the container of a run to exit, killing it when the run's timeout passes first. A
+// call that ends before the command, such as one cut off by the call watchdog, kills it too, so the output it
+// produced so far can still be collected and returned.
+func waitForCommand(ctx context.Context, cli *client.Client, id string, waitCh <-chan container.WaitResponse, errCh <-chan error, timeout time.Duration, memoryLimit int64) (*commandResult, error) {
+	result := &commandResult{}
+	select {
+	case status := <-waitCh:
+		if status.Error != nil {
+			return nil, fmt.Errorf("failed to wait for container: %s", status.Error.Message)
+		}
+		result.ExitCode = int(status.StatusCode)
+		result.Explanation = explainExitCode(result.ExitCode, memoryLimit)
+	case err := <-errCh:
+		switch {
+		case ctx.Err() != nil:
+			result.Explanation = "the tool call ended before the command finished, so it was killed; the output is what it produced until then"
+		case errors.Is(err, context.DeadlineExceeded):
+			result.Explanation = fmt.Sprintf("command did not finish within %s and was killed", timeout)
+		default:
+			return nil, fmt.Errorf("failed to wait for container: %w", err)
+		}
+		result.TimedOut = true
+		result.ExitCode = -1
+		if err := cli.ContainerKill(context.WithoutCancel(ctx), id, "KILL"); err != nil {
+			return nil, fmt.Errorf("failed to kill timed out container: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// collectOutput reads the stdout and stderr of a finished run into result, even when the call was cancelled
+func collectOutput(ctx context.Context, cli *client.Client, id string, result *commandResult) (err error) {
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "logs.collect", tracing.ContainerKey.String(id))
+	defer func() { tracing.End(span, err) }()
+
+	logs, err := cli.ContainerLogs(ctx, id, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return fmt.Errorf("failed to read container output: %w", err)
+	}
+	defer logs.Close()
+	var stdout, stderr bytes.Buffer
+	if result.OutputWarning, err = DemuxOutput(&stdout, &stderr, logs); err != nil {
+		return fmt.Errorf("failed to read container output: %w", err)
+	}
+	result.Stdout, _ = SanitizeOutput(stdout.String(), false)
+	result.Stderr, _ = SanitizeOutput(stderr.String(), false)
+	return nil
+}
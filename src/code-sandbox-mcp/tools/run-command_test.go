@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommandArgument(t *testing.T) {
+	cmd, err := parseCommandArgument("ffmpeg -version | head -1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/bin/sh", "-c", "ffmpeg -version | head -1"}, cmd)
+
+	cmd, err = parseCommandArgument([]any{"uname", "-a"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uname", "-a"}, cmd)
+
+	for _, value := range []any{nil, "", []any{}, []any{"ls", 1}, 42} {
+		_, err := parseCommandArgument(value)
+		assert.Error(t, err, "%v", value)
+	}
+}
+
+func TestParseEnvArgument(t *testing.T) {
+	env, err := parseEnvArgument(map[string]any{"LANG": "C.UTF-8", "DEBUG": "1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DEBUG=1", "LANG=C.UTF-8"}, env)
+
+	env, err = parseEnvArgument(nil)
+	require.NoError(t, err)
+	assert.Empty(t, env)
+
+	_, err = parseEnvArgument(map[string]any{"N": 1})
+	assert.ErrorContains(t, err, "env value of N must be a string")
+	_, err = parseEnvArgument([]any{"A=1"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// runtimeProbe checks that an image ships the runtime of a language
+type runtimeProbe struct {
+	Runtime   string     // the runtime named in errors
+	Commands  [][]string // version commands, tried in order
+	Suggested string     // an image known to ship the runtime
+}
+
+// runtimeProbes are keyed by the languages of registryLanguage
+var runtimeProbes = map[string]runtimeProbe{
+	"python": {Runtime: "python", Commands: [][]string{{"python3", "--version"}, {"python", "--version"}, {"pypy3", "--version"}}, Suggested: DefaultImage},
+	"nodejs": {Runtime: "node", Commands: [][]string{{"node", "--version"}}, Suggested: "node:22-slim"},
+	"go":     {Runtime: "go", Commands: [][]string{{"go", "version"}}, Suggested: "golang:1.24-bookworm"},
+}
+
+// RuntimeMissingError is returned when a sandbox's image lacks the runtime of the language it was created for
+type RuntimeMissingError struct {
+	Image     string
+	Language  string
+	Runtime   string
+	Output    string // what the last probe printed, e.g. the shell's "not found"
+	Suggested string
+}
+
+func (e *RuntimeMissingError) Error() string {
+	msg := fmt.Sprintf("RUNTIME_MISSING: image %s has no %s runtime for %s", e.Image, e.Runtime, e.Language)
+	if e.Output != "" {
+		msg += fmt.Sprintf(" (%s)", e.Output)
+	}
+	return msg + fmt.Sprintf("; use an image that ships it, such as %s, or pass skip_validation=true if the image provides it in a way the check can't see", e.Suggested)
+}
+
+// probeRuntime runs the version command of a language in a started sandbox and returns the version it reports,
+// e.g. "Python 3.12.4"
+func probeRuntime(ctx context.Context, containerID, image, language string) (string, error) {
+	probe, ok := runtimeProbes[language]
+	if !ok {
+		return "", fmt.Errorf("no runtime check for language %q", language)
+	}
+	var output string
+	for _, argv := range probe.Commands {
+		stdout, stderr, exitCode, err := executeArgvWithProgress(ctx, containerID, argv, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to check the %s runtime: %w", probe.Runtime, err)
+		}
+		// Python 2 prints its version on stderr
+		version := strings.TrimSpace(stdout + stderr)
+		if exitCode == 0 && version != "" {
+			return firstLine(version), nil
+		}
+		output = fmt.Sprintf("%s: %s", strings.Join(argv, " "), firstLine(version))
+	}
+	return "", &RuntimeMissingError{Image: image, Language: language, Runtime: probe.Runtime, Output: output, Suggested: probe.Suggested}
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
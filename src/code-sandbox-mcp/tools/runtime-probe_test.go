@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeMissingError(t *testing.T) {
+	err := &RuntimeMissingError{
+		Image:     "alpine:3.20",
+		Language:  "python",
+		Runtime:   "python",
+		Output:    `pypy3 --version: OCI runtime exec failed: exec: "pypy3": executable file not found in $PATH`,
+		Suggested: DefaultImage,
+	}
+	assert.Equal(t, `RUNTIME_MISSING: image alpine:3.20 has no python runtime for python (pypy3 --version: OCI runtime exec failed: exec: "pypy3": executable file not found in $PATH); `+
+		`use an image that ships it, such as python:3.12-slim-bookworm, or pass skip_validation=true if the image provides it in a way the check can't see`, err.Error())
+}
+
+func TestRuntimeProbesCoverEveryLanguage(t *testing.T) {
+	for _, language := range []string{"python", "py", "node", "nodejs", "ts", "go", "golang"} {
+		normalized, err := registryLanguage(language)
+		if assert.NoError(t, err, language) {
+			assert.Contains(t, runtimeProbes, normalized, language)
+		}
+	}
+	// The runtimes project_dir pins are checked too
+	for _, runtime := range []string{"python", "node", "go"} {
+		normalized, err := registryLanguage(runtime)
+		if assert.NoError(t, err, runtime) {
+			assert.Contains(t, runtimeProbes, normalized, runtime)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/client"
+)
+
+// runtimePin is a runtime version a project pins in a version file
+type runtimePin struct {
+	Runtime string // python, node or go
+	Version string // as written, e.g. "3.8.10" or "lts/hydrogen"
+	Line    int
+}
+
+// versionFiles are the files that pin a project's runtime, in the order they are consulted
+var versionFiles = []struct {
+	Name  string
+	Parse func(content string) []runtimePin
+}{
+	{".tool-versions", parseToolVersions},
+	{".python-version", firstLinePin("python")},
+	{".nvmrc", firstLinePin("node")},
+	{".node-version", firstLinePin("node")},
+	{"go.mod", parseGoModPin},
+}
+
+// toolVersionsRuntimes maps the asdf plugin names of .tool-versions to runtimes
+var toolVersionsRuntimes = map[string]string{
+	"python": "python",
+	"nodejs": "node",
+	"node":   "node",
+	"golang": "go",
+	"go":     "go",
+}
+
+// nodeLTSCodenames maps the "lts/<codename>" aliases of .nvmrc to major versions
+var nodeLTSCodenames = map[string]string{
+	"argon":    "4",
+	"boron":    "6",
+	"carbon":   "8",
+	"dubnium":  "10",
+	"erbium":   "12",
+	"fermium":  "14",
+	"gallium":  "16",
+	"hydrogen": "18",
+	"iron":     "20",
+	"jod":      "22",
+}
+
+// versionNumber matches the leading major[.minor[.patch]] of a version
+var versionNumber = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// runtimeImage is the image picked for a project from one of its version files
+type runtimeImage struct {
+	File       string
+	Pin        runtimePin
+	Candidates []string // official image tags, most specific first
+}
+
+// Evidence names the file and line the image was picked from
+func (r *runtimeImage) Evidence() string {
+	return fmt.Sprintf("%s line %d: %s %s", r.File, r.Pin.Line, r.Pin.Runtime, r.Pin.Version)
+}
+
+// imageTagExists reports whether an image is present locally or in its registry; replaced in tests
+var imageTagExists = func(ctx context.Context, image string) bool {
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
+	)
+	if err != nil {
+		return false
+	}
+	defer cli.Close()
+	if _, err := cli.ImageInspect(ctx, image); err == nil {
+		return true
+	}
+	lookupCtx, cancel := withDockerTimeout(ctx, containerCreateTimeout)
+	defer cancel()
+	_, err = cli.DistributionInspect(lookupCtx, image, "")
+	return err == nil
+}
+
+// selectRuntimeImage picks the image for the project in dir from its version files: the most specific candidate
+// tag that exists, or the most specific one when none can be confirmed, so the pull reports why. It returns nil
+// when no version file pins a supported runtime.
+func selectRuntimeImage(ctx context.Context, dir string) (string, *runtimeImage, error) {
+	detected, err := detectRuntimeImage(dir)
+	if err != nil || detected == nil {
+		return "", nil, err
+	}
+	for _, candidate := range detected.Candidates {
+		if imageTagExists(ctx, candidate) {
+			return candidate, detected, nil
+		}
+	}
+	return detected.Candidates[0], detected, nil
+}
+
+// detectRuntimeImage reads the version files of the project in dir; a pin whose version can't be mapped to an
+// image, such as "system", is skipped
+func detectRuntimeImage(dir string) (*runtimeImage, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project_dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("project_dir %s is not a directory", dir)
+	}
+	for _, file := range versionFiles {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		for _, pin := range file.Parse(string(data)) {
+			if candidates := runtimeImageCandidates(pin.Runtime, pin.Version); len(candidates) > 0 {
+				return &runtimeImage{File: file.Name, Pin: pin, Candidates: candidates}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// versionFileLines returns the non-empty lines of a version file with their numbers, without comments
+func versionFileLines(content string) ([]string, []int) {
+	var lines []string
+	var numbers []int
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+			numbers = append(numbers, n)
+		}
+	}
+	return lines, numbers
+}
+
+// firstLinePin parses a file holding one version of a runtime, such as .python-version or .nvmrc. pyenv lists
+// fallback versions on later lines; the first is the one it uses.
+func firstLinePin(runtime string) func(string) []runtimePin {
+	return func(content string) []runtimePin {
+		lines, numbers := versionFileLines(content)
+		if len(lines) == 0 {
+			return nil
+		}
+		return []runtimePin{{Runtime: runtime, Version: strings.Fields(lines[0])[0], Line: numbers[0]}}
+	}
+}
+
+// parseToolVersions parses the "<tool> <version> [fallbacks...]" lines of an asdf .tool-versions file
+func parseToolVersions(content string) []runtimePin {
+	var pins []runtimePin
+	lines, numbers := versionFileLines(content)
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if runtime, ok := toolVersionsRuntimes[fields[0]]; ok && len(fields) > 1 {
+			pins = append(pins, runtimePin{Runtime: runtime, Version: fields[1], Line: numbers[i]})
+		}
+	}
+	return pins
+}
+
+// parseGoModPin parses the go directive of a go.mod file
+func parseGoModPin(content string) []runtimePin {
+	lines, numbers := versionFileLines(strings.ReplaceAll(content, "//", "#"))
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "go" {
+			return []runtimePin{{Runtime: "go", Version: fields[1], Line: numbers[i]}}
+		}
+	}
+	return nil
+}
+
+// runtimeImageCandidates maps a pinned version to official image tags, most specific first. Python falls back
+// to the minor version and Go to the minor version and then the default variant, since older Go releases
+// predate bookworm; Node falls back to the major version, which keeps compatibility.
+func runtimeImageCandidates(runtime, version string) []string {
+	switch runtime {
+	case "python":
+		// pyenv virtualenvs are written as 3.11.4/envs/name
+		version, _, _ = strings.Cut(version, "/")
+		repo := "python"
+		if rest, ok := strings.CutPrefix(version, "pypy"); ok {
+			repo, version = "pypy", rest
+		}
+		// A major version alone, such as "3", says too little to pick an image
+		return tagCandidates(repo, versionParts(version), 2, "-slim")
+	case "node":
+		version = strings.TrimPrefix(strings.ToLower(version), "v")
+		switch {
+		case version == "lts/*":
+			return []string{"node:lts-slim"}
+		case version == "node" || version == "stable" || version == "latest" || version == "current":
+			return []string{"node:current-slim"}
+		case strings.HasPrefix(version, "lts/"):
+			if major, ok := nodeLTSCodenames[strings.TrimPrefix(version, "lts/")]; ok {
+				return []string{"node:" + major + "-slim"}
+			}
+			return nil
+		}
+		return tagCandidates("node", versionParts(version), 1, "-slim")
+	case "go":
+		parts := versionParts(strings.TrimPrefix(version, "go"))
+		if len(parts) < 2 {
+			return nil
+		}
+		candidates := tagCandidates("golang", parts, 2, "-bookworm")
+		return append(candidates, "golang:"+strings.Join(parts[:2], "."))
+	}
+	return nil
+}
+
+// versionParts returns the numeric major, minor and patch a version starts with; "3.12.0rc1" gives 3, 12, 0
+func versionParts(version string) []string {
+	match := versionNumber.FindStringSubmatch(version)
+	if match == nil {
+		return nil
+	}
+	var parts []string
+	for _, part := range match[1:] {
+		if part == "" {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// tagCandidates returns repo:<version><suffix> tags from the full version down to minParts components
+func tagCandidates(repo string, parts []string, minParts int, suffix string) []string {
+	var tags []string
+	for n := len(parts); n >= minParts && n > 0; n-- {
+		tags = append(tags, repo+":"+strings.Join(parts[:n], ".")+suffix)
+	}
+	return tags
+}
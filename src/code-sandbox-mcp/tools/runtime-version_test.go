@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRuntimeImage(t *testing.T) {
+	tests := []struct {
+		project    string
+		evidence   string
+		candidates []string
+	}{
+		{"python-patch", ".python-version line 1: python 3.8.10", []string{"python:3.8.10-slim", "python:3.8-slim"}},
+		{"python-virtualenv", ".python-version line 1: python 3.11.4/envs/api", []string{"python:3.11.4-slim", "python:3.11-slim"}},
+		{"python-pypy", ".python-version line 1: python pypy3.9-7.3.12", []string{"pypy:3.9-slim"}},
+		{"python-fallbacks", ".python-version line 1: python 3.12.1", []string{"python:3.12.1-slim", "python:3.12-slim"}},
+		{"system-python", ".nvmrc line 1: node v18.17.0", []string{"node:18.17.0-slim", "node:18.17-slim", "node:18-slim"}},
+		{"nvmrc-lts", ".nvmrc line 1: node lts/hydrogen", []string{"node:18-slim"}},
+		{"nvmrc-lts-latest", ".nvmrc line 1: node lts/*", []string{"node:lts-slim"}},
+		{"nvmrc-comment", ".nvmrc line 3: node v20", []string{"node:20-slim"}},
+		{"node-version", ".node-version line 1: node 22.3.0", []string{"node:22.3.0-slim", "node:22.3-slim", "node:22-slim"}},
+		{"tool-versions", ".tool-versions line 3: node 18.17.0", []string{"node:18.17.0-slim", "node:18.17-slim", "node:18-slim"}},
+		{"tool-versions-ref", ".tool-versions line 2: python 3.10.13", []string{"python:3.10.13-slim", "python:3.10-slim"}},
+		{"tool-versions-first", ".tool-versions line 1: python 3.9.18", []string{"python:3.9.18-slim", "python:3.9-slim"}},
+		{"gomod", "go.mod line 3: go 1.21", []string{"golang:1.21-bookworm", "golang:1.21"}},
+		{"gomod-patch", "go.mod line 3: go 1.22.3", []string{"golang:1.22.3-bookworm", "golang:1.22-bookworm", "golang:1.22"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			detected, err := detectRuntimeImage(filepath.Join("testdata", "version-files", tt.project))
+			require.NoError(t, err)
+			require.NotNil(t, detected)
+			assert.Equal(t, tt.evidence, detected.Evidence())
+			assert.Equal(t, tt.candidates, detected.Candidates)
+		})
+	}
+
+	// Versions that can't be mapped to an image leave the choice to the default
+	for _, project := range []string{"python-major-only", "nvmrc-range", "empty", "no-version-files"} {
+		detected, err := detectRuntimeImage(filepath.Join("testdata", "version-files", project))
+		require.NoError(t, err, project)
+		assert.Nil(t, detected, project)
+	}
+
+	_, err := detectRuntimeImage(filepath.Join("testdata", "version-files", "gomod", "go.mod"))
+	assert.ErrorContains(t, err, "is not a directory")
+	_, err = detectRuntimeImage(filepath.Join("testdata", "version-files", "missing"))
+	assert.ErrorContains(t, err, "failed to read project_dir")
+}
+
+func TestRuntimeImageCandidates(t *testing.T) {
+	tests := []struct {
+		runtime, version string
+		want             []string
+	}{
+		{"python", "3.12.0rc1", []string{"python:3.12.0-slim", "python:3.12-slim"}},
+		{"python", "anaconda3-2023.03", nil},
+		{"python", "system", nil},
+		{"node", "V16", []string{"node:16-slim"}},
+		{"node", "node", []string{"node:current-slim"}},
+		{"node", "lts/unknown", nil},
+		{"node", "iojs", nil},
+		{"go", "go1.20", []string{"golang:1.20-bookworm", "golang:1.20"}},
+		{"go", "1", nil},
+		{"ruby", "3.2.2", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, runtimeImageCandidates(tt.runtime, tt.version), "%s %s", tt.runtime, tt.version)
+	}
+}
+
+func TestSelectRuntimeImageFallsBack(t *testing.T) {
+	original := imageTagExists
+	t.Cleanup(func() { imageTagExists = original })
+	project := filepath.Join("testdata", "version-files", "gomod-patch")
+
+	// The exact release has no tag, its minor version does
+	var checked []string
+	imageTagExists = func(ctx context.Context, image string) bool {
+		checked = append(checked, image)
+		return image == "golang:1.22-bookworm"
+	}
+	image, detected, err := selectRuntimeImage(context.Background(), project)
+	require.NoError(t, err)
+	assert.Equal(t, "golang:1.22-bookworm", image)
+	assert.Equal(t, "go.mod", detected.File)
+	assert.Equal(t, []string{"golang:1.22.3-bookworm", "golang:1.22-bookworm"}, checked)
+
+	// Nothing can be confirmed, e.g. offline: the most specific tag is pulled so the failure names it
+	imageTagExists = func(ctx context.Context, image string) bool { return false }
+	image, _, err = selectRuntimeImage(context.Background(), project)
+	require.NoError(t, err)
+	assert.Equal(t, "golang:1.22.3-bookworm", image)
+
+	image, detected, err = selectRuntimeImage(context.Background(), filepath.Join("testdata", "version-files", "no-version-files"))
+	require.NoError(t, err)
+	assert.Empty(t, image)
+	assert.Nil(t, detected)
+}
+
+func TestProjectDirImageHostRoot(t *testing.T) {
+	original := imageTagExists
+	t.Cleanup(func() { imageTagExists = original })
+	imageTagExists = func(ctx context.Context, image string) bool { return true }
+
+	// The server runs in a container with the host's /home/me mounted at the version-files fixtures
+	local, err := filepath.Abs(filepath.Join("testdata", "version-files"))
+	require.NoError(t, err)
+	defer func(roots []hostRoot) { hostRoots = roots }(hostRoots)
+	hostRoots = []hostRoot{{Host: "/home/me", Local: local}}
+
+	image, detected, err := projectDirImage(context.Background(), "/home/me/gomod", false)
+	require.NoError(t, err)
+	require.NotNil(t, detected)
+	assert.Equal(t, "go.mod", detected.File)
+	assert.Equal(t, "golang:1.21-bookworm", image)
+
+	// An explicit image is kept, but the directory is still checked
+	image, detected, err = projectDirImage(context.Background(), "/home/me/gomod", true)
+	require.NoError(t, err)
+	assert.Empty(t, image)
+	assert.Nil(t, detected)
+
+	_, _, err = projectDirImage(context.Background(), "/home/me/missing", false)
+	assert.ErrorContains(t, err, "failed to read project_dir")
+}
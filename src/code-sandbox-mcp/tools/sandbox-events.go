@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// SandboxEventsURI is the resource holding the recent lifecycle events of sandbox containers
+const SandboxEventsURI = "sandbox://events"
+
+// sandboxEventLogSize is the number of events kept, oldest dropped first
+var sandboxEventLogSize = 200
+
+// Watching the Docker events stream is retried after a failure, doubling the delay up to the maximum
+var (
+	sandboxEventsRetryDelay    = time.Second
+	sandboxEventsMaxRetryDelay = 30 * time.Second
+)
+
+// SandboxEvent is a lifecycle event of a sandbox container
+type SandboxEvent struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"` // start, stop, die or oom
+	ContainerID string    `json:"container_id"`
+	Name        string    `json:"name,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	ExitCode    *int      `json:"exit_code,omitempty"`
+	Explanation string    `json:"explanation,omitempty"`
+}
+
+// sandboxEventActions are the Docker container events worth telling a client about
+var sandboxEventActions = map[events.Action]bool{
+	events.ActionStart: true,
+	events.ActionStop:  true,
+	events.ActionDie:   true,
+	events.ActionOOM:   true,
+}
+
+// sandboxEventLog holds the recent events, oldest first
+var sandboxEventLog = struct {
+	sync.Mutex
+	events []SandboxEvent
+}{}
+
+// SandboxEvents returns the recent sandbox events, oldest first
+func SandboxEvents() []SandboxEvent {
+	sandboxEventLog.Lock()
+	defer sandboxEventLog.Unlock()
+	return append([]SandboxEvent{}, sandboxEventLog.events...)
+}
+
+func appendSandboxEvent(event SandboxEvent) {
+	sandboxEventLog.Lock()
+	defer sandboxEventLog.Unlock()
+	sandboxEventLog.events = append(sandboxEventLog.events, event)
+	if excess := len(sandboxEventLog.events) - sandboxEventLogSize; excess > 0 {
+		sandboxEventLog.events = append([]SandboxEvent(nil), sandboxEventLog.events[excess:]...)
+	}
+}
+
+// translateSandboxEvent turns a Docker container event into a SandboxEvent, or reports false for events that
+// aren't of interest
+func translateSandboxEvent(msg events.Message) (SandboxEvent, bool) {
+	if msg.Type != events.ContainerEventType || !sandboxEventActions[msg.Action] {
+		return SandboxEvent{}, false
+	}
+	event := SandboxEvent{
+		Time:        eventTime(msg).UTC(),
+		Action:      string(msg.Action),
+		ContainerID: msg.Actor.ID,
+		Name:        msg.Actor.Attributes["name"],
+		Image:       msg.Actor.Attributes["image"],
+	}
+	if len(event.ContainerID) > 12 {
+		event.ContainerID = event.ContainerID[:12]
+	}
+	if code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"]); err == nil && msg.Action == events.ActionDie {
+		event.ExitCode = &code
+		event.Explanation = explainExitCode(code, 0)
+	}
+	if msg.Action == events.ActionOOM {
+		event.Explanation = "the container ran out of memory"
+	}
+	return event, true
+}
+
+// eventTime is when the event happened; daemons before API 1.22 only report whole seconds
+func eventTime(msg events.Message) time.Time {
+	if msg.TimeNano == 0 {
+		return time.Unix(msg.Time, 0)
+	}
+	return time.Unix(0, msg.TimeNano)
+}
+
+// dockerEventSource is the part of the Docker client used to watch events
+type dockerEventSource interface {
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+}
+
+// watchSandboxEvents records the lifecycle events of sandbox containers and calls notify with the resource URI
+// after each one, until ctx is done. When the stream ends it reconnects with a growing delay and asks for the
+// events it missed in between.
+func watchSandboxEvents(ctx context.Context, source dockerEventSource, notify func(uri string)) {
+	delay := sandboxEventsRetryDelay
+	var since time.Time
+	for {
+		options := events.ListOptions{Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("label", SandboxLabel),
+			filters.Arg("label", InstanceLabel+"="+instanceName),
+		)}
+		if !since.IsZero() {
+			options.Since = strconv.FormatInt(since.Unix(), 10)
+		}
+		streamCtx, cancel := context.WithCancel(ctx)
+		messages, errs := source.Events(streamCtx, options)
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					break stream
+				}
+				// Reconnecting with a Since in whole seconds repeats the events of the last second
+				at := eventTime(msg)
+				if !at.After(since) {
+					continue
+				}
+				since = at
+				delay = sandboxEventsRetryDelay
+				if event, ok := translateSandboxEvent(msg); ok {
+					appendSandboxEvent(event)
+					notify(SandboxEventsURI)
+					// The logs of a sandbox that exited on its own are kept before anyone removes it
+					if capturer, ok := source.(exitLogSource); ok && msg.Action == events.ActionDie {
+						go captureSandboxExit(ctx, capturer, event)
+					}
+				}
+			case <-errs:
+				break stream
+			}
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, sandboxEventsMaxRetryDelay)
+	}
+}
+
+// StartSandboxEvents watches the Docker daemon for sandbox lifecycle events in the background until ctx is
+// done. It returns false when no Docker client can be created.
+func StartSandboxEvents(ctx context.Context, notify func(uri string)) bool {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return false
+	}
+	go func() {
+		defer cli.Close()
+		watchSandboxEvents(ctx, cli, notify)
+	}()
+	return true
+}
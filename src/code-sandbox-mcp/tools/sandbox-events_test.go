@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventSource hands out one stream per Events call and records the options of each call
+type fakeEventSource struct {
+	mu      sync.Mutex
+	streams chan fakeEventStream
+	options []events.ListOptions
+}
+
+type fakeEventStream struct {
+	messages chan events.Message
+	errs     chan error
+}
+
+func newFakeEventSource() *fakeEventSource {
+	return &fakeEventSource{streams: make(chan fakeEventStream, 4)}
+}
+
+// open makes the next Events call return a fresh stream
+func (f *fakeEventSource) open() fakeEventStream {
+	stream := fakeEventStream{messages: make(chan events.Message), errs: make(chan error, 1)}
+	f.streams <- stream
+	return stream
+}
+
+func (f *fakeEventSource) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	f.mu.Lock()
+	f.options = append(f.options, options)
+	f.mu.Unlock()
+	stream := <-f.streams
+	return stream.messages, stream.errs
+}
+
+// resetSandboxEvents empties the event log and shortens the retry delay for a test
+func resetSandboxEvents(t *testing.T, logSize int) {
+	t.Helper()
+	savedSize, savedDelay := sandboxEventLogSize, sandboxEventsRetryDelay
+	sandboxEventLogSize, sandboxEventsRetryDelay = logSize, time.Millisecond
+	sandboxEventLog.Lock()
+	sandboxEventLog.events = nil
+	sandboxEventLog.Unlock()
+	t.Cleanup(func() {
+		sandboxEventLogSize, sandboxEventsRetryDelay = savedSize, savedDelay
+		sandboxEventLog.Lock()
+		sandboxEventLog.events = nil
+		sandboxEventLog.Unlock()
+	})
+}
+
+func containerMessage(action events.Action, id string, at time.Time, attrs map[string]string) events.Message {
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id, Attributes: attrs},
+		Time:     at.Unix(),
+		TimeNano: at.UnixNano(),
+	}
+}
+
+func TestTranslateSandboxEvent(t *testing.T) {
+	at := time.Unix(1700000000, 500).UTC()
+	event, ok := translateSandboxEvent(containerMessage(events.ActionDie, "0123456789abcdef", at,
+		map[string]string{"name": "mcp-api", "image": "python:3.12", "exitCode": "137"}))
+	require.True(t, ok)
+	assert.Equal(t, "die", event.Action)
+	assert.Equal(t, "0123456789ab", event.ContainerID)
+	assert.Equal(t, "mcp-api", event.Name)
+	assert.Equal(t, at, event.Time)
+	require.NotNil(t, event.ExitCode)
+	assert.Equal(t, 137, *event.ExitCode)
+	assert.Contains(t, event.Explanation, "SIGKILL")
+
+	event, ok = translateSandboxEvent(containerMessage(events.ActionOOM, "0123456789abcdef", at, nil))
+	require.True(t, ok)
+	assert.Nil(t, event.ExitCode)
+	assert.Equal(t, "the container ran out of memory", event.Explanation)
+
+	_, ok = translateSandboxEvent(containerMessage(events.ActionExecStart, "0123456789abcdef", at, nil))
+	assert.False(t, ok)
+}
+
+func TestWatchSandboxEventsNotifiesAndReconnects(t *testing.T) {
+	resetSandboxEvents(t, 200)
+	source := newFakeEventSource()
+	first := source.open()
+
+	notified := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watchSandboxEvents(ctx, source, func(uri string) { notified <- uri })
+		close(done)
+	}()
+
+	start := time.Unix(1700000000, 0)
+	first.messages <- containerMessage(events.ActionStart, "aaaa", start, map[string]string{"name": "mcp-web"})
+	assert.Equal(t, SandboxEventsURI, <-notified)
+	// Events that aren't lifecycle changes are recorded nowhere
+	first.messages <- containerMessage(events.ActionCreate, "aaaa", start.Add(time.Second), nil)
+
+	// The stream ends; the watcher reconnects from the last event it saw and skips the repeats
+	second := source.open()
+	first.errs <- errors.New("unexpected EOF")
+	second.messages <- containerMessage(events.ActionStart, "aaaa", start, nil)
+	second.messages <- containerMessage(events.ActionDie, "aaaa", start.Add(2*time.Second), map[string]string{"exitCode": "1"})
+	assert.Equal(t, SandboxEventsURI, <-notified)
+
+	cancel()
+	<-done
+	assert.Empty(t, notified)
+
+	recorded := SandboxEvents()
+	require.Len(t, recorded, 2)
+	assert.Equal(t, "start", recorded[0].Action)
+	assert.Equal(t, "die", recorded[1].Action)
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	require.Len(t, source.options, 2)
+	assert.Empty(t, source.options[0].Since)
+	assert.Equal(t, "1700000001", source.options[1].Since)
+	assert.ElementsMatch(t, []string{SandboxLabel, InstanceLabel + "=" + InstanceName()}, source.options[0].Filters.Get("label"))
+}
+
+func TestSandboxEventLogIsCapped(t *testing.T) {
+	resetSandboxEvents(t, 3)
+	for i := 0; i < 5; i++ {
+		appendSandboxEvent(SandboxEvent{Action: "start", ContainerID: string(rune('a' + i))})
+	}
+	recorded := SandboxEvents()
+	require.Len(t, recorded, 3)
+	assert.Equal(t, "c", recorded[0].ContainerID)
+	assert.Equal(t, "e", recorded[2].ContainerID)
+}
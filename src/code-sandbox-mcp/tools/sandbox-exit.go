@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// removeOnExitLabel marks a sandbox created with remove_on_exit, removed as soon as its logs are captured
+const removeOnExitLabel = "code-sandbox-mcp.remove-on-exit"
+
+// maxSandboxExits is the number of exited sandboxes remembered, oldest dropped first; their logs are also bounded
+// by the execution history they are kept in
+const maxSandboxExits = 100
+
+// SandboxExit is a sandbox whose main process exited on its own, with the logs it left behind captured into the
+// execution history
+type SandboxExit struct {
+	ContainerID string    `json:"container_id"`
+	Name        string    `json:"name,omitempty"`
+	ExitCode    int       `json:"exit_code"`
+	Explanation string    `json:"explanation,omitempty"`
+	FinishedAt  time.Time `json:"finished_at"`
+	ExecutionID string    `json:"execution_id"`
+	// Removed is set when remove_on_exit removed the container after the capture
+	Removed bool `json:"removed,omitempty"`
+
+	fullID string
+}
+
+// sandboxExits holds the recent exits, oldest first
+var sandboxExits = struct {
+	sync.Mutex
+	exits []SandboxExit
+}{}
+
+// sandboxExitNotify is called with each captured exit; main sends it to the clients
+var sandboxExitNotify = struct {
+	sync.Mutex
+	fn func(SandboxExit)
+}{}
+
+// SetSandboxExitNotifier makes notify receive every sandbox exit whose logs were captured
+func SetSandboxExitNotifier(notify func(SandboxExit)) {
+	sandboxExitNotify.Lock()
+	defer sandboxExitNotify.Unlock()
+	sandboxExitNotify.fn = notify
+}
+
+// stoppingSandboxes are the containers sandbox_stop is stopping; their exit was asked for and isn't captured
+var stoppingSandboxes = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: map[string]bool{}}
+
+func markStopping(containerID string, stopping bool) {
+	stoppingSandboxes.Lock()
+	defer stoppingSandboxes.Unlock()
+	if stopping {
+		stoppingSandboxes.ids[shortID(containerID)] = true
+	} else {
+		delete(stoppingSandboxes.ids, shortID(containerID))
+	}
+}
+
+func isStopping(containerID string) bool {
+	stoppingSandboxes.Lock()
+	defer stoppingSandboxes.Unlock()
+	return stoppingSandboxes.ids[shortID(containerID)]
+}
+
+func shortID(containerID string) string {
+	return containerID[:min(len(containerID), 12)]
+}
+
+// LookupSandboxExit finds the captured exit of a container by name, full ID or ID prefix, newest first, so the
+// logs resource can still serve a container that has since been removed. An exit whose logs were evicted from
+// the execution history is not found.
+func LookupSandboxExit(ref string) (SandboxExit, ExecutionRecord, bool) {
+	sandboxExits.Lock()
+	defer sandboxExits.Unlock()
+	for i := len(sandboxExits.exits) - 1; i >= 0; i-- {
+		exit := sandboxExits.exits[i]
+		if ref == "" || (exit.Name != ref && !strings.HasPrefix(exit.fullID, ref)) {
+			continue
+		}
+		record, ok := executionHistory.Get(exit.ExecutionID)
+		return exit, record, ok
+	}
+	return SandboxExit{}, ExecutionRecord{}, false
+}
+
+func appendSandboxExit(exit SandboxExit) {
+	sandboxExits.Lock()
+	defer sandboxExits.Unlock()
+	sandboxExits.exits = append(sandboxExits.exits, exit)
+	if excess := len(sandboxExits.exits) - maxSandboxExits; excess > 0 {
+		sandboxExits.exits = append([]SandboxExit(nil), sandboxExits.exits[excess:]...)
+	}
+}
+
+// exitLogSource is the part of the Docker client used to capture an exited sandbox
+type exitLogSource interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+}
+
+// captureSandboxExit keeps the logs of a sandbox whose main process died, so they outlive the container, and
+// removes the container when it was created with remove_on_exit. Exits sandbox_stop asked for, a container that
+// is already gone and one that a restart policy brought back are left alone.
+func captureSandboxExit(ctx context.Context, cli exitLogSource, event SandboxEvent) (SandboxExit, bool) {
+	if isStopping(event.ContainerID) {
+		return SandboxExit{}, false
+	}
+	inspect, err := cli.ContainerInspect(ctx, event.ContainerID)
+	if err != nil || inspect.State == nil || inspect.State.Running || isStopping(inspect.ID) {
+		return SandboxExit{}, false
+	}
+
+	reader, err := cli.ContainerLogs(ctx, inspect.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return SandboxExit{}, false
+	}
+	// The history only keeps the tail, so that is all that is held while the logs are read
+	logs := &tailBuffer{max: executionHistory.MaxBytes()}
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(logs, reader)
+	} else {
+		_, err = DemuxOutput(logs, logs, reader)
+	}
+	reader.Close()
+	if err != nil {
+		return SandboxExit{}, false
+	}
+
+	name := strings.TrimPrefix(inspect.Name, "/")
+	ref := name
+	if ref == "" {
+		ref = inspect.ID
+	}
+	output, _ := SanitizeOutput(redactSecrets(ref, logs.String()), false)
+	exit := SandboxExit{
+		ContainerID: shortID(inspect.ID),
+		Name:        name,
+		ExitCode:    inspect.State.ExitCode,
+		Explanation: explainExitCode(inspect.State.ExitCode, 0),
+		FinishedAt:  event.Time,
+		fullID:      inspect.ID,
+	}
+	if finished, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt); err == nil && !finished.IsZero() {
+		exit.FinishedAt = finished.UTC()
+	}
+	exit.ExecutionID = executionHistory.Add(ExecutionRecord{
+		Tool:      "sandbox_exit",
+		Container: ref,
+		Timestamp: exit.FinishedAt,
+		ExitCode:  exit.ExitCode,
+		Output:    output,
+		Truncated: logs.Truncated(),
+	})
+
+	if inspect.Config != nil && inspect.Config.Labels[removeOnExitLabel] == "true" {
+		if err := cli.ContainerRemove(ctx, inspect.ID, container.RemoveOptions{Force: true}); err == nil || client.IsErrNotFound(err) {
+			exit.Removed = true
+			forgetContainer(ref, name, inspect.ID)
+		}
+	}
+	appendSandboxExit(exit)
+
+	sandboxExitNotify.Lock()
+	notify := sandboxExitNotify.fn
+	sandboxExitNotify.Unlock()
+	if notify != nil {
+		notify(exit)
+	}
+	return exit, true
+}
+
+// FullID is the full ID of the container that exited
+func (e SandboxExit) FullID() string {
+	return e.fullID
+}
+
+// String is the message sent to clients, e.g. "Sandbox mcp-web (0123456789ab) exited with code 1: ..."
+func (e SandboxExit) String() string {
+	who := e.ContainerID
+	if e.Name != "" {
+		who = fmt.Sprintf("%s (%s)", e.Name, e.ContainerID)
+	}
+	message := fmt.Sprintf("Sandbox %s exited with code %d", who, e.ExitCode)
+	if e.Explanation != "" {
+		message += ": " + e.Explanation
+	}
+	message += fmt.Sprintf("; its logs are kept at executions://%s/output", e.ExecutionID)
+	if e.Removed {
+		message += " and the container was removed (remove_on_exit)"
+	}
+	return message
+}
+
+// tailBuffer is a writer keeping only the last max bytes written to it
+type tailBuffer struct {
+	max     int
+	buf     []byte
+	written int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.written += len(p)
+	b.buf = append(b.buf, p...)
+	// Shift only once the buffer is twice the limit, so each byte is copied a bounded number of times
+	if len(b.buf) > 2*b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// Truncated reports whether more than max bytes were written
+func (b *tailBuffer) Truncated() bool {
+	return b.written > b.max
+}
+
+// String returns the kept tail, starting at a character boundary when the start was dropped
+func (b *tailBuffer) String() string {
+	tail := b.buf
+	if len(tail) > b.max {
+		tail = tail[len(tail)-b.max:]
+	}
+	if b.Truncated() {
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+	}
+	return string(tail)
+}
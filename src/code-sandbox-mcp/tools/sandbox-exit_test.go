@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExitSource serves a single container's inspect and logs, and records whether it was removed
+type fakeExitSource struct {
+	inspect container.InspectResponse
+	logs    []byte
+	removed []string
+}
+
+func (f *fakeExitSource) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	if len(f.removed) > 0 || f.inspect.ContainerJSONBase == nil {
+		return container.InspectResponse{}, errdefs.NotFound(io.EOF)
+	}
+	return f.inspect, nil
+}
+
+func (f *fakeExitSource) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(f.logs)), nil
+}
+
+func (f *fakeExitSource) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	f.removed = append(f.removed, containerID)
+	return nil
+}
+
+func newFakeExitSource(id, name string, exitCode int, labels map[string]string, logs []byte) *fakeExitSource {
+	return &fakeExitSource{
+		inspect: container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				ID:   id,
+				Name: "/" + name,
+				State: &container.State{
+					ExitCode:   exitCode,
+					FinishedAt: "2026-01-02T03:04:05.5Z",
+				},
+			},
+			Config: &container.Config{Labels: labels},
+		},
+		logs: logs,
+	}
+}
+
+// resetSandboxExits gives a test an empty execution history and exit log, and no notifier
+func resetSandboxExits(t *testing.T) {
+	t.Helper()
+	previous := executionHistory
+	executionHistory = NewExecutionStore(10, 1<<20)
+	sandboxExits.Lock()
+	sandboxExits.exits = nil
+	sandboxExits.Unlock()
+	t.Cleanup(func() {
+		executionHistory = previous
+		sandboxExits.Lock()
+		sandboxExits.exits = nil
+		sandboxExits.Unlock()
+		SetSandboxExitNotifier(nil)
+	})
+}
+
+func TestCaptureSandboxExit(t *testing.T) {
+	resetSandboxExits(t)
+	var notified []SandboxExit
+	SetSandboxExitNotifier(func(exit SandboxExit) { notified = append(notified, exit) })
+
+	id := "0123456789abcdef0123"
+	source := newFakeExitSource(id, "mcp-job", 3, nil,
+		multiplexed(t, [2]string{"stdout", "starting\n"}, [2]string{"stderr", "boom\n"}))
+	exit, ok := captureSandboxExit(context.Background(), source, SandboxEvent{ContainerID: shortID(id), Time: time.Unix(1, 0)})
+	require.True(t, ok)
+	assert.Equal(t, "0123456789ab", exit.ContainerID)
+	assert.Equal(t, "mcp-job", exit.Name)
+	assert.Equal(t, 3, exit.ExitCode)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 5e8, time.UTC), exit.FinishedAt)
+	assert.False(t, exit.Removed)
+	assert.Empty(t, source.removed)
+	assert.Equal(t, []SandboxExit{exit}, notified)
+	assert.Contains(t, exit.String(), "Sandbox mcp-job (0123456789ab) exited with code 3")
+	assert.Contains(t, exit.String(), "executions://"+exit.ExecutionID+"/output")
+
+	record, ok := executionHistory.Get(exit.ExecutionID)
+	require.True(t, ok)
+	assert.Equal(t, "sandbox_exit", record.Tool)
+	assert.Equal(t, "mcp-job", record.Container)
+	assert.Equal(t, 3, record.ExitCode)
+	assert.Equal(t, "starting\nboom\n", record.Output)
+
+	// Found by name, ID prefix and full ID
+	for _, ref := range []string{"mcp-job", "0123456789ab", id} {
+		found, foundRecord, ok := LookupSandboxExit(ref)
+		require.True(t, ok, ref)
+		assert.Equal(t, exit.ExecutionID, found.ExecutionID)
+		assert.Equal(t, id, found.FullID())
+		assert.Equal(t, record.Output, foundRecord.Output)
+	}
+	for _, ref := range []string{"", "mcp-other", "fedcba"} {
+		_, _, ok := LookupSandboxExit(ref)
+		assert.False(t, ok, ref)
+	}
+}
+
+func TestCaptureSandboxExitRemoveOnExit(t *testing.T) {
+	resetSandboxExits(t)
+	id := "abcdef0123456789abcd"
+	source := newFakeExitSource(id, "mcp-once", 0, map[string]string{removeOnExitLabel: "true"},
+		multiplexed(t, [2]string{"stdout", "done\n"}))
+	exit, ok := captureSandboxExit(context.Background(), source, SandboxEvent{ContainerID: shortID(id)})
+	require.True(t, ok)
+	assert.True(t, exit.Removed)
+	assert.Equal(t, []string{id}, source.removed)
+	assert.Contains(t, exit.String(), "the container was removed (remove_on_exit)")
+
+	// The logs outlive the container
+	_, record, ok := LookupSandboxExit("mcp-once")
+	require.True(t, ok)
+	assert.Equal(t, "done\n", record.Output)
+}
+
+func TestCaptureSandboxExitTTY(t *testing.T) {
+	resetSandboxExits(t)
+	source := newFakeExitSource("1111222233334444", "mcp-tty", 1, nil, []byte("\x1b[31mred\x1b[0m\n"))
+	source.inspect.Config.Tty = true
+	exit, ok := captureSandboxExit(context.Background(), source, SandboxEvent{ContainerID: "111122223333"})
+	require.True(t, ok)
+	record, ok := executionHistory.Get(exit.ExecutionID)
+	require.True(t, ok)
+	assert.Equal(t, "red\n", record.Output)
+}
+
+func TestCaptureSandboxExitKeepsTail(t *testing.T) {
+	resetSandboxExits(t)
+	executionHistory = NewExecutionStore(10, 64)
+	noisy := strings.Repeat("progress line\n", 1000) + "Traceback: the end\n"
+	source := newFakeExitSource("5555666677778888", "mcp-noisy", 1, nil, multiplexed(t, [2]string{"stdout", noisy}))
+	exit, ok := captureSandboxExit(context.Background(), source, SandboxEvent{ContainerID: "555566667777"})
+	require.True(t, ok)
+	record, ok := executionHistory.Get(exit.ExecutionID)
+	require.True(t, ok)
+	assert.True(t, record.Truncated)
+	assert.Len(t, record.Output, 64)
+	assert.True(t, strings.HasSuffix(record.Output, "Traceback: the end\n"))
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	for i := 0; i < 100; i++ {
+		_, _ = b.Write([]byte("abc"))
+	}
+	assert.LessOrEqual(t, cap(b.buf), 64, "the buffer stays bounded")
+	assert.Equal(t, "bcabcabc", b.String())
+	assert.True(t, b.Truncated())
+
+	// A tail starting inside a character starts at the next one instead
+	b = &tailBuffer{max: 5}
+	_, _ = b.Write([]byte("xäöü"))
+	assert.Equal(t, "öü", b.String())
+
+	b = &tailBuffer{max: 8}
+	_, _ = b.Write([]byte("short"))
+	assert.Equal(t, "short", b.String())
+	assert.False(t, b.Truncated())
+}
+
+func TestCaptureSandboxExitSkips(t *testing.T) {
+	resetSandboxExits(t)
+	notified := 0
+	SetSandboxExitNotifier(func(SandboxExit) { notified++ })
+
+	// sandbox_stop asked for this exit
+	id := "5555666677778888"
+	stopping := newFakeExitSource(id, "mcp-stopping", 137, map[string]string{removeOnExitLabel: "true"}, nil)
+	markStopping(id, true)
+	_, ok := captureSandboxExit(context.Background(), stopping, SandboxEvent{ContainerID: shortID(id)})
+	markStopping(id, false)
+	assert.False(t, ok)
+	assert.Empty(t, stopping.removed)
+
+	// A restart policy brought it back
+	restarted := newFakeExitSource("9999aaaabbbbcccc", "mcp-restarted", 1, nil, nil)
+	restarted.inspect.State.Running = true
+	_, ok = captureSandboxExit(context.Background(), restarted, SandboxEvent{ContainerID: "9999aaaabbbb"})
+	assert.False(t, ok)
+
+	// Already gone
+	_, ok = captureSandboxExit(context.Background(), &fakeExitSource{}, SandboxEvent{ContainerID: "ddddeeeeffff"})
+	assert.False(t, ok)
+
+	assert.Zero(t, notified)
+	assert.Empty(t, executionHistory.List())
+}
+
+func TestStopRequestedContainerIsNotCaptured(t *testing.T) {
+	resetSandboxExits(t)
+	notified := 0
+	SetSandboxExitNotifier(func(SandboxExit) { notified++ })
+
+	// The watcher sees the die event while the container is being stopped, as it does for a rollback
+	id := "aaaabbbbccccdddd"
+	replaced := newFakeExitSource(id, "mcp-rolled-back", 137, map[string]string{removeOnExitLabel: "true"}, nil)
+	var captured bool
+	previous := removeStoppedContainer
+	removeStoppedContainer = func(ctx context.Context, containerIdOrName string) error {
+		_, captured = captureSandboxExit(ctx, replaced, SandboxEvent{ContainerID: shortID(id)})
+		return nil
+	}
+	t.Cleanup(func() { removeStoppedContainer = previous })
+
+	require.NoError(t, stopRequestedContainer(context.Background(), id, "mcp-rolled-back"))
+	assert.False(t, captured)
+	assert.Empty(t, replaced.removed)
+	assert.False(t, isStopping(id))
+	assert.Zero(t, notified)
+	assert.Empty(t, executionHistory.List())
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ansiEscapePattern matches CSI sequences (colors, cursor movement), OSC sequences (titles, hyperlinks)
+// terminated by BEL or ST, character set selections such as ESC ( B, and the remaining two-byte escapes
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[ -/]+[0-~]|\x1b[@-Z\\-_]`)
+
+// OutputSanitization reports what SanitizeOutput changed
+type OutputSanitization struct {
+	ANSISequences int `json:"ansi_sequences_removed,omitempty"`
+	InvalidBytes  int `json:"invalid_utf8_bytes_replaced,omitempty"`
+}
+
+// Changed reports whether anything was removed or replaced
+func (s OutputSanitization) Changed() bool {
+	return s.ANSISequences > 0 || s.InvalidBytes > 0
+}
+
+// Add accumulates another report
+func (s *OutputSanitization) Add(other OutputSanitization) {
+	s.ANSISequences += other.ANSISequences
+	s.InvalidBytes += other.InvalidBytes
+}
+
+func (s OutputSanitization) String() string {
+	var parts []string
+	if s.ANSISequences > 0 {
+		parts = append(parts, fmt.Sprintf("removed %d ANSI escape sequences", s.ANSISequences))
+	}
+	if s.InvalidBytes > 0 {
+		parts = append(parts, fmt.Sprintf("replaced %d invalid UTF-8 bytes with U+FFFD", s.InvalidBytes))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SanitizeOutput makes command output safe to return to MCP clients: each invalid UTF-8 byte becomes the
+// replacement character and ANSI escape sequences are removed unless keepANSI is set
+func SanitizeOutput(s string, keepANSI bool) (string, OutputSanitization) {
+	var report OutputSanitization
+
+	if !utf8.ValidString(s) {
+		var b strings.Builder
+		b.Grow(len(s))
+		for i := 0; i < len(s); {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b.WriteRune(utf8.RuneError)
+				report.InvalidBytes++
+			} else {
+				b.WriteString(s[i : i+size])
+			}
+			i += size
+		}
+		s = b.String()
+	}
+
+	if !keepANSI && strings.IndexByte(s, '\x1b') >= 0 {
+		s = ansiEscapePattern.ReplaceAllStringFunc(s, func(string) string {
+			report.ANSISequences++
+			return ""
+		})
+	}
+
+	return s, report
+}
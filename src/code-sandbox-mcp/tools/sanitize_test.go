@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeOutput(t *testing.T) {
+	// Compiler-style colored output mixed with a stray binary blob and an OSC hyperlink
+	raw := "\x1b[1m\x1b[31merror\x1b[0m: build failed\n" +
+		"\x1b]8;;file:///app/main.go\x07main.go\x1b]8;;\x07:3:1\n" +
+		"blob: \xff\xfe\x00ok\n" +
+		"truncated rune: \xe2\x82\n" +
+		"\x1b(Bplain ✓\n"
+
+	out, report := SanitizeOutput(raw, false)
+	assert.True(t, utf8.ValidString(out))
+	assert.Equal(t, "error: build failed\nmain.go:3:1\nblob: ��\x00ok\ntruncated rune: ��\nplain ✓\n", out)
+	assert.Equal(t, OutputSanitization{ANSISequences: 6, InvalidBytes: 4}, report)
+	assert.Equal(t, "removed 6 ANSI escape sequences, replaced 4 invalid UTF-8 bytes with U+FFFD", report.String())
+
+	// The result survives a JSON round trip unchanged
+	encoded, err := json.Marshal(out)
+	require.NoError(t, err)
+	var decoded string
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, out, decoded)
+}
+
+func TestSanitizeOutputKeepANSI(t *testing.T) {
+	out, report := SanitizeOutput("\x1b[32mPASS\x1b[0m \xc3\n", true)
+	assert.Equal(t, "\x1b[32mPASS\x1b[0m �\n", out)
+	assert.Equal(t, OutputSanitization{InvalidBytes: 1}, report)
+}
+
+func TestSanitizeOutputClean(t *testing.T) {
+	out, report := SanitizeOutput("héllo wörld\n", false)
+	assert.Equal(t, "héllo wörld\n", out)
+	assert.False(t, report.Changed())
+}
@@ -0,0 +1,307 @@
+package tools
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// builtinScaffolds holds the file templates shipped with the server, one directory per template with a
+// template.json describing it
+//
+//go:embed all:scaffolds
+var builtinScaffolds embed.FS
+
+// scaffoldManifest is the file in a template directory that describes it; it isn't written to the container
+const scaffoldManifest = "template.json"
+
+// ScaffoldURIPrefix is the scheme of the resources exposing the file templates, e.g. templates://python/fastapi-app
+const ScaffoldURIPrefix = "templates://"
+
+// scaffoldVariablePattern matches a {{variable}} placeholder, with optional spaces inside the braces
+var scaffoldVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Scaffold is a named set of files rendered into a sandbox by scaffold_sandbox
+type Scaffold struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Variables   map[string]string `json:"variables"` // variable names with their default values
+	Files       []ScaffoldFile    `json:"files"`
+}
+
+// ScaffoldFile is one file of a template, with {{variable}} placeholders in its path and contents
+type ScaffoldFile struct {
+	Path     string `json:"path"`
+	Contents string `json:"contents"`
+	Mode     int64  `json:"-"`
+}
+
+// scaffolds holds the built-in templates and those of SANDBOX_SCAFFOLDS_DIR, keyed by name
+var scaffolds = mustLoadBuiltinScaffolds()
+
+func mustLoadBuiltinScaffolds() map[string]Scaffold {
+	sub, err := fs.Sub(builtinScaffolds, "scaffolds")
+	if err != nil {
+		panic(err)
+	}
+	loaded, err := loadScaffolds(sub)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in file templates: %v", err))
+	}
+	return loaded
+}
+
+// LoadScaffoldsFromEnv adds the file templates under SANDBOX_SCAFFOLDS_DIR, if set, to the built-in ones;
+// a user template replaces a built-in template of the same name
+func LoadScaffoldsFromEnv() error {
+	dir := os.Getenv("SANDBOX_SCAFFOLDS_DIR")
+	if dir == "" {
+		return nil
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("SANDBOX_SCAFFOLDS_DIR %s is not a directory", dir)
+	}
+
+	loaded, err := loadScaffolds(os.DirFS(dir))
+	if err != nil {
+		return fmt.Errorf("invalid file templates in SANDBOX_SCAFFOLDS_DIR %s: %w", dir, err)
+	}
+	for name, scaffold := range loaded {
+		scaffolds[name] = scaffold
+	}
+	return nil
+}
+
+// loadScaffolds reads every directory holding a template.json as a template named by its path, e.g.
+// python/fastapi-app. Placeholders for variables the template doesn't declare are rejected here, so rendering
+// can only fail on the caller's input.
+func loadScaffolds(fsys fs.FS) (map[string]Scaffold, error) {
+	loaded := map[string]Scaffold{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != scaffoldManifest || p == scaffoldManifest {
+			return nil
+		}
+		scaffold, err := loadScaffold(fsys, path.Dir(p))
+		if err != nil {
+			return fmt.Errorf("template %s: %w", path.Dir(p), err)
+		}
+		loaded[scaffold.Name] = scaffold
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// loadScaffold reads the manifest and files of the template in dir
+func loadScaffold(fsys fs.FS, dir string) (Scaffold, error) {
+	scaffold := Scaffold{Name: dir}
+	data, err := fs.ReadFile(fsys, path.Join(dir, scaffoldManifest))
+	if err != nil {
+		return scaffold, err
+	}
+	if err := json.Unmarshal(data, &scaffold); err != nil {
+		return scaffold, fmt.Errorf("failed to parse %s: %w", scaffoldManifest, err)
+	}
+	scaffold.Name = dir
+	if scaffold.Variables == nil {
+		scaffold.Variables = map[string]string{}
+	}
+
+	err = fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// A nested template is a template of its own
+			if p != dir {
+				if _, err := fs.Stat(fsys, path.Join(p, scaffoldManifest)); err == nil {
+					return fs.SkipDir
+				}
+			}
+			return nil
+		}
+		rel := strings.TrimPrefix(p, dir+"/")
+		if rel == scaffoldManifest {
+			return nil
+		}
+		contents, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		// Embedded files carry no permissions; files from a templates dir keep their executable bit
+		mode := int64(0644)
+		if info, err := d.Info(); err == nil && info.Mode()&0100 != 0 {
+			mode = 0755
+		}
+		scaffold.Files = append(scaffold.Files, ScaffoldFile{Path: rel, Contents: string(contents), Mode: mode})
+		return nil
+	})
+	if err != nil {
+		return scaffold, err
+	}
+	if len(scaffold.Files) == 0 {
+		return scaffold, fmt.Errorf("no files besides %s", scaffoldManifest)
+	}
+
+	for _, f := range scaffold.Files {
+		for _, text := range []string{f.Path, f.Contents} {
+			for _, match := range scaffoldVariablePattern.FindAllStringSubmatch(text, -1) {
+				if _, ok := scaffold.Variables[match[1]]; !ok {
+					return scaffold, fmt.Errorf("%s uses {{%s}}, which is not declared in %s", f.Path, match[1], scaffoldManifest)
+				}
+			}
+		}
+	}
+	return scaffold, nil
+}
+
+// ScaffoldNames returns the sorted names of the available file templates
+func ScaffoldNames() []string {
+	names := make([]string, 0, len(scaffolds))
+	for name := range scaffolds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupScaffold returns the file template with the given name
+func LookupScaffold(name string) (Scaffold, error) {
+	scaffold, ok := scaffolds[name]
+	if !ok {
+		return scaffold, fmt.Errorf("unknown template %q: available templates are %s", name, strings.Join(ScaffoldNames(), ", "))
+	}
+	return scaffold, nil
+}
+
+// render substitutes the variables into the paths and contents of the template's files, which are placed under
+// destDir. Variables the caller doesn't give take their defaults; unknown variables are rejected.
+func (s Scaffold) render(destDir string, vars map[string]string) ([]batchFile, error) {
+	values := make(map[string]string, len(s.Variables))
+	for name, value := range s.Variables {
+		values[name] = value
+	}
+	for name, value := range vars {
+		if _, ok := s.Variables[name]; !ok {
+			declared := make([]string, 0, len(s.Variables))
+			for name := range s.Variables {
+				declared = append(declared, name)
+			}
+			sort.Strings(declared)
+			if len(declared) == 0 {
+				return nil, fmt.Errorf("unknown variable %q: template %s takes no variables", name, s.Name)
+			}
+			return nil, fmt.Errorf("unknown variable %q: template %s takes %s", name, s.Name, strings.Join(declared, ", "))
+		}
+		values[name] = value
+	}
+	substitute := func(text string) string {
+		return scaffoldVariablePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			return values[scaffoldVariablePattern.FindStringSubmatch(placeholder)[1]]
+		})
+	}
+
+	files := make([]batchFile, 0, len(s.Files))
+	seen := map[string]bool{}
+	for _, f := range s.Files {
+		fullPath := path.Join(destDir, substitute(f.Path))
+		if !strings.HasPrefix(fullPath, strings.TrimSuffix(destDir, "/")+"/") {
+			return nil, fmt.Errorf("file %s of template %s renders to %s, outside %s", f.Path, s.Name, fullPath, destDir)
+		}
+		if seen[fullPath] {
+			return nil, fmt.Errorf("files of template %s render to the same path %s", s.Name, fullPath)
+		}
+		seen[fullPath] = true
+		files = append(files, batchFile{Path: fullPath, Data: []byte(substitute(f.Contents)), Mode: f.Mode})
+	}
+	return files, nil
+}
+
+// parseScaffoldVariables reads the variables tool argument, an object of strings
+func parseScaffoldVariables(value any) (map[string]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("variables must be an object of strings")
+	}
+	vars := make(map[string]string, len(obj))
+	for name, v := range obj {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("variable %s must be a string", name)
+		}
+		vars[name] = s
+	}
+	return vars, nil
+}
+
+// ScaffoldSandbox renders a file template into the container with a single archive upload
+func ScaffoldSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters using new API
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	name, err := request.RequireString("template")
+	if err != nil {
+		return mcp.NewToolResultText("template is required"), nil
+	}
+	scaffold, err := LookupScaffold(name)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	vars, err := parseScaffoldVariables(request.GetArguments()["variables"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	// A relative dest_dir is resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	destDir := inWorkDir(workDir, request.GetString("dest_dir", "."))
+
+	files, err := scaffold.render(destDir, vars)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	archive, err := filesToTar(files, time.Now())
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	if err := copyArchiveToContainer(ctx, containerIDOrName, archive); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err)), nil
+	}
+
+	written := make([]writtenFile, 0, len(files))
+	for _, f := range files {
+		written = append(written, writtenFile{Path: f.Path, Bytes: len(f.Data)})
+	}
+	jsonData, err := json.Marshal(map[string]interface{}{"template": scaffold.Name, "dest_dir": destDir, "files": written})
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize written files: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinScaffolds(t *testing.T) {
+	assert.Contains(t, ScaffoldNames(), "python/fastapi-app")
+	assert.Contains(t, ScaffoldNames(), "node/express-app")
+
+	scaffold, err := LookupScaffold("python/fastapi-app")
+	require.NoError(t, err)
+	assert.Equal(t, "app", scaffold.Variables["project_name"])
+	for _, f := range scaffold.Files {
+		assert.NotEqual(t, scaffoldManifest, f.Path)
+	}
+}
+
+func TestScaffoldRenderSubstitutesVariables(t *testing.T) {
+	loaded, err := loadScaffolds(fstest.MapFS{
+		"go/cli/template.json":          {Data: []byte(`{"description": "Go CLI", "variables": {"module": "example.com/app", "binary": "app"}}`)},
+		"go/cli/go.mod":                 {Data: []byte("module {{module}}\n")},
+		"go/cli/cmd/{{binary}}/main.go": {Data: []byte(`package main // {{ binary }} of {{module}}` + "\n")},
+		"go/cli/run.sh":                 {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	})
+	require.NoError(t, err)
+	require.Contains(t, loaded, "go/cli")
+
+	files, err := loaded["go/cli"].render("/app/tool", map[string]string{"binary": "greet"})
+	require.NoError(t, err)
+	rendered := map[string]string{}
+	modes := map[string]int64{}
+	for _, f := range files {
+		rendered[f.Path] = string(f.Data)
+		modes[f.Path] = f.Mode
+	}
+	assert.Equal(t, map[string]string{
+		"/app/tool/go.mod":            "module example.com/app\n",
+		"/app/tool/cmd/greet/main.go": "package main // greet of example.com/app\n",
+		"/app/tool/run.sh":            "#!/bin/sh\n",
+	}, rendered)
+	assert.Equal(t, int64(0644), modes["/app/tool/go.mod"])
+	assert.Equal(t, int64(0755), modes["/app/tool/run.sh"])
+}
+
+func TestScaffoldRenderErrors(t *testing.T) {
+	_, err := LookupScaffold("python/django-app")
+	assert.ErrorContains(t, err, `unknown template "python/django-app": available templates are `)
+	assert.ErrorContains(t, err, "python/fastapi-app")
+
+	scaffold, err := LookupScaffold("python/fastapi-app")
+	require.NoError(t, err)
+	_, err = scaffold.render("/app", map[string]string{"projectname": "api"})
+	assert.EqualError(t, err, `unknown variable "projectname": template python/fastapi-app takes description, project_name`)
+
+	// A variable can't move a file out of the destination directory
+	loaded, err := loadScaffolds(fstest.MapFS{
+		"t/template.json":     {Data: []byte(`{"variables": {"name": "x"}}`)},
+		"t/{{name}}/file.txt": {Data: []byte("")},
+	})
+	require.NoError(t, err)
+	_, err = loaded["t"].render("/app", map[string]string{"name": "../../etc"})
+	assert.ErrorContains(t, err, "outside /app")
+}
+
+func TestLoadScaffoldsRejectsUndeclaredVariables(t *testing.T) {
+	_, err := loadScaffolds(fstest.MapFS{
+		"t/template.json": {Data: []byte(`{"variables": {"name": "x"}}`)},
+		"t/README.md":     {Data: []byte("# {{title}}\n")},
+	})
+	assert.EqualError(t, err, "template t: README.md uses {{title}}, which is not declared in template.json")
+
+	_, err = loadScaffolds(fstest.MapFS{"t/template.json": {Data: []byte(`{}`)}})
+	assert.EqualError(t, err, "template t: no files besides template.json")
+}
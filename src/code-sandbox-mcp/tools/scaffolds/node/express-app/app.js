@@ -0,0 +1,13 @@
+const express = require("express");
+
+const app = express();
+
+app.get("/health", (req, res) => {
+  res.json({ status: "ok" });
+});
+
+app.get("/", (req, res) => {
+  res.json({ name: "{{project_name}}" });
+});
+
+module.exports = app;
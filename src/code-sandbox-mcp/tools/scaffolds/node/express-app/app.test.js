@@ -0,0 +1,16 @@
+const test = require("node:test");
+const assert = require("node:assert");
+
+const app = require("./app");
+
+test("GET /health", async () => {
+  const server = app.listen(0);
+  try {
+    const { port } = server.address();
+    const response = await fetch(`http://127.0.0.1:${port}/health`);
+    assert.strictEqual(response.status, 200);
+    assert.deepStrictEqual(await response.json(), { status: "ok" });
+  } finally {
+    server.close();
+  }
+});
@@ -0,0 +1,7 @@
+const app = require("./app");
+
+const port = process.env.PORT || {{port}};
+
+app.listen(port, () => {
+  console.log(`{{project_name}} listening on port ${port}`);
+});
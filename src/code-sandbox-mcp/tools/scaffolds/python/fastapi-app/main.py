@@ -0,0 +1,13 @@
+from fastapi import FastAPI
+
+app = FastAPI(title="{{project_name}}", description="{{description}}")
+
+
+@app.get("/health")
+def health() -> dict[str, str]:
+    return {"status": "ok"}
+
+
+@app.get("/")
+def root() -> dict[str, str]:
+    return {"name": "{{project_name}}"}
@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxScheduleDelay caps delay_seconds of schedule_exec
+	maxScheduleDelay = time.Hour
+	// scheduledExecTimeout is how long a scheduled command may run; there's no tool call to give it a deadline
+	scheduledExecTimeout = 10 * time.Minute
+)
+
+// ScheduledJob is a command schedule_exec will run in a sandbox once it's due
+type ScheduledJob struct {
+	ID        string    `json:"schedule_id"`
+	Container string    `json:"container"`
+	Command   string    `json:"command"`
+	CreatedAt time.Time `json:"created_at"`
+	DueAt     time.Time `json:"due_at"`
+
+	timer *time.Timer
+	seq   int
+}
+
+// scheduledJobs holds the pending jobs; they live in memory, so they die with the server
+var scheduledJobs = struct {
+	sync.Mutex
+	seq  int
+	jobs map[string]*ScheduledJob
+}{jobs: map[string]*ScheduledJob{}}
+
+// runScheduledCommand runs a due command in its sandbox; replaced in tests
+var runScheduledCommand = func(ctx context.Context, containerIDOrName, cmd string) (stdout string, stderr string, exitCode int, err error) {
+	return executeCommandWithOutput(ctx, containerIDOrName, cmd)
+}
+
+// scheduleNotify is told the URI of each scheduled run's output once it's recorded
+var scheduleNotify = struct {
+	sync.Mutex
+	fn func(uri string)
+}{}
+
+// SetScheduleNotifier makes notify receive the executions://{id}/output URI of each finished scheduled run
+func SetScheduleNotifier(notify func(uri string)) {
+	scheduleNotify.Lock()
+	defer scheduleNotify.Unlock()
+	scheduleNotify.fn = notify
+}
+
+// ScheduleExec registers a command to run in a sandbox after a delay. Its output goes to the execution history
+// like a sandbox_exec run, with the requested and actual fire times.
+func ScheduleExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	command, err := request.RequireString("command")
+	if err != nil || command == "" {
+		return mcp.NewToolResultText("command is required"), nil
+	}
+	delay, err := parseScheduleDelay(request.GetArguments()["delay_seconds"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	job := scheduleJob(containerIDOrName, command, delay)
+	jsonData, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize scheduled job: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// ListScheduled lists the jobs that haven't run yet, soonest first
+func ListScheduled(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jsonData, err := json.Marshal(pendingJobs())
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize scheduled jobs: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// CancelScheduled cancels a job that hasn't run yet
+func CancelScheduled(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireString("schedule_id")
+	if err != nil {
+		return mcp.NewToolResultText("schedule_id is required"), nil
+	}
+	if !cancelJob(id) {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: no pending job %s; it may have run already, see executions_list", id)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Cancelled scheduled job %s", id)), nil
+}
+
+// parseScheduleDelay reads delay_seconds, in seconds or as a duration such as "5m", which must be from 1 second
+// to maxScheduleDelay
+func parseScheduleDelay(value any) (time.Duration, error) {
+	if value == nil {
+		return 0, fmt.Errorf("delay_seconds is required")
+	}
+	delay, err := parseDurationArgument("delay_seconds", value, time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if delay < time.Second || delay > maxScheduleDelay {
+		return 0, fmt.Errorf("delay_seconds must be between 1 and %d", int(maxScheduleDelay.Seconds()))
+	}
+	return delay, nil
+}
+
+// scheduleJob starts the timer of a new job and returns a copy of it
+func scheduleJob(containerIDOrName, command string, delay time.Duration) ScheduledJob {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	scheduledJobs.seq++
+	now := time.Now().UTC()
+	job := &ScheduledJob{
+		ID:        fmt.Sprintf("sched-%d", scheduledJobs.seq),
+		Container: containerIDOrName,
+		Command:   command,
+		CreatedAt: now,
+		DueAt:     now.Add(delay),
+		seq:       scheduledJobs.seq,
+	}
+	scheduledJobs.jobs[job.ID] = job
+	job.timer = time.AfterFunc(delay, func() { fireJob(job) })
+	return *job
+}
+
+// pendingJobs returns copies of the jobs that haven't run yet, soonest first
+func pendingJobs() []ScheduledJob {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	jobs := make([]ScheduledJob, 0, len(scheduledJobs.jobs))
+	for _, job := range scheduledJobs.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].DueAt.Equal(jobs[j].DueAt) {
+			return jobs[i].DueAt.Before(jobs[j].DueAt)
+		}
+		return jobs[i].seq < jobs[j].seq
+	})
+	return jobs
+}
+
+// cancelJob stops a pending job, reporting false if there's no such job or it's already running
+func cancelJob(id string) bool {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	job, ok := scheduledJobs.jobs[id]
+	if !ok || !job.timer.Stop() {
+		return false
+	}
+	delete(scheduledJobs.jobs, id)
+	return true
+}
+
+// cancelContainerJobs drops the pending jobs of a stopped sandbox, known by any of refs
+func cancelContainerJobs(refs ...string) {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	for id, job := range scheduledJobs.jobs {
+		for _, ref := range refs {
+			if ref != "" && job.Container == ref {
+				job.timer.Stop()
+				delete(scheduledJobs.jobs, id)
+				break
+			}
+		}
+	}
+}
+
+// fireJob runs a due job, records its output in the execution history and tells the notifier
+func fireJob(job *ScheduledJob) {
+	scheduledJobs.Lock()
+	if _, ok := scheduledJobs.jobs[job.ID]; !ok {
+		// Cancelled while the timer was firing
+		scheduledJobs.Unlock()
+		return
+	}
+	delete(scheduledJobs.jobs, job.ID)
+	scheduledJobs.Unlock()
+
+	fired := time.Now().UTC()
+	ctx, cancel := context.WithTimeout(context.Background(), scheduledExecTimeout)
+	defer cancel()
+
+	header := fmt.Sprintf("$ %s\n", redactSecrets(job.Container, job.Command))
+	section := header
+	exitCode := -1
+	// A scheduled run counts as a command for the container's lock, so a stop in progress isn't raced
+	release, err := sandboxLocks.acquire(ctx, job.Container, "schedule_exec", lockUse, containerLockWait)
+	if err == nil {
+		sandboxActivity.Touch(job.Container)
+		var stdout, stderr string
+		stdout, stderr, exitCode, err = runScheduledCommand(ctx, job.Container, job.Command)
+		release()
+		if err == nil {
+			section, _ = execSection(ctx, job.Container, header, stdout, stderr, exitCode, false)
+		} else {
+			exitCode = -1
+		}
+	}
+	if err != nil {
+		section += fmt.Sprintf("Error executing command: %v\n", err)
+	}
+
+	due := job.DueAt
+	id := executionHistory.Add(ExecutionRecord{
+		Tool:         "schedule_exec",
+		Container:    job.Container,
+		Timestamp:    fired,
+		ExitCode:     exitCode,
+		Output:       fmt.Sprintf("Scheduled %s for %s, fired at %s\n", job.ID, job.DueAt.Format(time.RFC3339Nano), fired.Format(time.RFC3339Nano)) + section,
+		ScheduleID:   job.ID,
+		ScheduledFor: &due,
+		FiredAt:      &fired,
+	})
+
+	scheduleNotify.Lock()
+	notify := scheduleNotify.fn
+	scheduleNotify.Unlock()
+	if notify != nil {
+		notify(fmt.Sprintf("executions://%s/output", id))
+	}
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForScheduledRun polls the execution history for the run of a scheduled job
+func waitForScheduledRun(t *testing.T, scheduleID string, within time.Duration) ExecutionRecord {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for time.Now().Before(deadline) {
+		for _, record := range executionHistory.List() {
+			if record.ScheduleID == scheduleID {
+				full, ok := LookupExecution(record.ID)
+				require.True(t, ok)
+				return full
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("scheduled job %s didn't run within %s", scheduleID, within)
+	return ExecutionRecord{}
+}
+
+func TestParseScheduleDelay(t *testing.T) {
+	delay, err := parseScheduleDelay(float64(60))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, delay)
+
+	_, err = parseScheduleDelay(nil)
+	assert.EqualError(t, err, "delay_seconds is required")
+	for _, seconds := range []float64{0, 0.5, 3601} {
+		_, err = parseScheduleDelay(seconds)
+		assert.EqualError(t, err, "delay_seconds must be between 1 and 3600", "%v", seconds)
+	}
+}
+
+func TestScheduledJobs(t *testing.T) {
+	saved := runScheduledCommand
+	runScheduledCommand = func(ctx context.Context, containerIDOrName, cmd string) (string, string, int, error) {
+		return fmt.Sprintf("ran %q in %s\n", cmd, containerIDOrName), "", 0, nil
+	}
+	t.Cleanup(func() { runScheduledCommand = saved })
+	notified := make(chan string, 1)
+	SetScheduleNotifier(func(uri string) { notified <- uri })
+	t.Cleanup(func() { SetScheduleNotifier(nil) })
+
+	later := scheduleJob("mcp-box", "date", time.Hour)
+	soon := scheduleJob("mcp-box", "date +%s", 2*time.Second)
+	other := scheduleJob("mcp-other", "true", 30*time.Minute)
+
+	// Pending jobs are listed soonest first
+	var ids []string
+	for _, job := range pendingJobs() {
+		ids = append(ids, job.ID)
+	}
+	assert.Equal(t, []string{soon.ID, other.ID, later.ID}, ids)
+
+	// Jobs can be cancelled once, and stopping a sandbox cancels its jobs
+	assert.True(t, cancelJob(other.ID))
+	assert.False(t, cancelJob(other.ID))
+	cancelContainerJobs("mcp-box-2", "mcp-box")
+	assert.Empty(t, pendingJobs())
+
+	// A job that comes due is recorded with when it was due and when it ran
+	job := scheduleJob("mcp-box", "echo hi", 2*time.Second)
+	record := waitForScheduledRun(t, job.ID, 5*time.Second)
+	assert.Equal(t, "schedule_exec", record.Tool)
+	assert.Equal(t, "mcp-box", record.Container)
+	assert.Equal(t, 0, record.ExitCode)
+	require.NotNil(t, record.ScheduledFor)
+	require.NotNil(t, record.FiredAt)
+	assert.Equal(t, job.DueAt, *record.ScheduledFor)
+	assert.False(t, record.FiredAt.Before(job.DueAt))
+	assert.Contains(t, record.Output, fmt.Sprintf("Scheduled %s for ", job.ID))
+	assert.Contains(t, record.Output, "$ echo hi\nran \"echo hi\" in mcp-box\n")
+	select {
+	case uri := <-notified:
+		assert.Equal(t, fmt.Sprintf("executions://%s/output", record.ID), uri)
+	case <-time.After(time.Second):
+		t.Fatal("no resource update was sent")
+	}
+	assert.Empty(t, pendingJobs())
+}
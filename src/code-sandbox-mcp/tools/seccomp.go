@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/events"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// The bundled seccomp profiles. baseline.json is the default profile of Docker Engine 28.0 (moby
+// profiles/seccomp/default.json, Apache License 2.0), so sandboxes get the same syscall restrictions whatever the
+// engine's version or whether it has a default at all. paranoid.json is the same profile with
+// paranoidBlockedSyscalls taken out of every rule that allows them.
+var (
+	//go:embed seccomp/baseline.json
+	baselineSeccompJSON []byte
+	//go:embed seccomp/paranoid.json
+	paranoidSeccompJSON []byte
+)
+
+// SeccompProfiles are the named values of seccomp_profile; any other value is a path to a profile on the host
+var SeccompProfiles = []string{"default", "baseline", "paranoid"}
+
+// defaultSeccompProfile is used when seccomp_profile is omitted
+const defaultSeccompProfile = "baseline"
+
+// paranoidBlockedSyscalls are the debugging and kernel-tracing syscalls the paranoid profile never allows
+var paranoidBlockedSyscalls = []string{"ptrace", "perf_event_open", "bpf", "userfaultfd"}
+
+// seccompProfile is the profile a sandbox runs under
+type seccompProfile struct {
+	// Name is default, baseline, paranoid or the path of a profile on the host
+	Name string
+	// JSON is the profile sent to the engine, nil to leave the engine's default in place
+	JSON []byte
+}
+
+// resolveSeccompProfile reads the seccomp_profile argument: one of SeccompProfiles, or a host path to a profile
+// in Docker's JSON format, which is read and checked here so a bad file fails before anything is created
+func resolveSeccompProfile(value string) (seccompProfile, error) {
+	switch value {
+	case "":
+		return resolveSeccompProfile(defaultSeccompProfile)
+	case "default":
+		return seccompProfile{Name: value}, nil
+	case "baseline":
+		return seccompProfile{Name: value, JSON: baselineSeccompJSON}, nil
+	case "paranoid":
+		return seccompProfile{Name: value, JSON: paranoidSeccompJSON}, nil
+	}
+
+	path := filepath.Clean(value)
+	local := translateHostPath(value)
+	if err := checkHostPath(local); err != nil {
+		return seccompProfile{}, err
+	}
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return seccompProfile{}, fmt.Errorf("failed to read seccomp_profile: %w", localPathError(err))
+	}
+	var profile struct {
+		DefaultAction string            `json:"defaultAction"`
+		Syscalls      []json.RawMessage `json:"syscalls"`
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return seccompProfile{}, fmt.Errorf("seccomp_profile %s is not a JSON seccomp profile: %w", path, err)
+	}
+	if profile.DefaultAction == "" {
+		return seccompProfile{}, fmt.Errorf("seccomp_profile %s has no defaultAction; use one of %s or a profile in Docker's seccomp format", path, strings.Join(SeccompProfiles, ", "))
+	}
+	// The engine takes the profile inline, so it travels without its indentation
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return seccompProfile{}, fmt.Errorf("seccomp_profile %s is not a JSON seccomp profile: %w", path, err)
+	}
+	return seccompProfile{Name: path, JSON: compact.Bytes()}, nil
+}
+
+// securityOpt is the HostConfig.SecurityOpt entry applying the profile, empty for the engine's default
+func (p seccompProfile) securityOpt() string {
+	if p.JSON == nil {
+		return ""
+	}
+	return "seccomp=" + string(p.JSON)
+}
+
+// applySeccompProfile sets the profile on a host config
+func applySeccompProfile(hostConfig *container.HostConfig, profile seccompProfile) {
+	if opt := profile.securityOpt(); opt != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, opt)
+	}
+}
+
+// dropSeccompProfile removes the seccomp options from a host config and reports whether there were any
+func dropSeccompProfile(hostConfig *container.HostConfig) bool {
+	kept := hostConfig.SecurityOpt[:0]
+	for _, opt := range hostConfig.SecurityOpt {
+		if !strings.HasPrefix(opt, "seccomp=") && !strings.HasPrefix(opt, "seccomp:") {
+			kept = append(kept, opt)
+		}
+	}
+	dropped := len(kept) < len(hostConfig.SecurityOpt)
+	hostConfig.SecurityOpt = kept
+	if len(kept) == 0 {
+		hostConfig.SecurityOpt = nil
+	}
+	return dropped
+}
+
+// isSeccompRejection reports whether a create or start error is the engine refusing a seccomp profile, such as
+// "seccomp is not enabled in your kernel" or a profile it can't decode
+func isSeccompRejection(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "seccomp")
+}
+
+// seccompFallback decides whether a failed create or start is retried without the profile. When the engine
+// rejected the profile, it is dropped from hostConfig and the returned warning says the sandbox runs under the
+// engine's default instead.
+func seccompFallback(hostConfig *container.HostConfig, err error) (string, bool) {
+	if !isSeccompRejection(err) || !dropSeccompProfile(hostConfig) {
+		return "", false
+	}
+	return fmt.Sprintf("the Docker engine rejected the seccomp profile (%v); the container runs under the engine's default seccomp profile instead", err), true
+}
+
+// startSandboxContainer creates and starts a container, returning its ID also when only the start failed;
+// replaced in tests
+var startSandboxContainer = createAndStartContainer
+
+// startWithSeccompFallback creates and starts a container under the seccomp profile of hostConfig. An engine
+// that rejects the profile gets the container again without it, and the warning says so; other errors are
+// returned as they are.
+func startWithSeccompFallback(ctx context.Context, cli *client.Client, config *container.Config, hostConfig *container.HostConfig, name string) (string, string, error) {
+	id, err := startSandboxContainer(ctx, cli, config, hostConfig, name)
+	warning, retry := seccompFallback(hostConfig, err)
+	if !retry {
+		return id, "", err
+	}
+	// A container that was created but didn't start still holds the name
+	if id != "" {
+		if err := removeContainer(ctx, cli, id); err != nil {
+			return "", "", fmt.Errorf("failed to remove the container the seccomp profile kept from starting: %w", err)
+		}
+	}
+	id, err = startSandboxContainer(ctx, cli, config, hostConfig, name)
+	if err != nil {
+		return id, "", err
+	}
+	return id, warning, nil
+}
+
+// removeContainer force-removes a container that never ran; replaced in tests
+var removeContainer = func(ctx context.Context, cli *client.Client, id string) error {
+	if err := cli.ContainerRemove(context.WithoutCancel(ctx), id, container.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil {
+		return err
+	}
+	events.Emit(events.Event{Type: events.ContainerRemoved, Container: id})
+	return nil
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allowedSyscalls collects the syscalls a profile allows in any rule
+func allowedSyscalls(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	var profile struct {
+		DefaultAction string `json:"defaultAction"`
+		Syscalls      []struct {
+			Names  []string `json:"names"`
+			Action string   `json:"action"`
+		} `json:"syscalls"`
+	}
+	require.NoError(t, json.Unmarshal(data, &profile))
+	require.Equal(t, "SCMP_ACT_ERRNO", profile.DefaultAction)
+	allowed := map[string]bool{}
+	for _, rule := range profile.Syscalls {
+		if rule.Action == "SCMP_ACT_ALLOW" {
+			for _, name := range rule.Names {
+				allowed[name] = true
+			}
+		}
+	}
+	return allowed
+}
+
+func TestBundledSeccompProfiles(t *testing.T) {
+	baseline := allowedSyscalls(t, baselineSeccompJSON)
+	paranoid := allowedSyscalls(t, paranoidSeccompJSON)
+	assert.True(t, baseline["ptrace"])
+	assert.True(t, baseline["read"])
+
+	// The paranoid profile is the baseline without the blocked syscalls
+	for _, name := range paranoidBlockedSyscalls {
+		assert.False(t, paranoid[name], name)
+		delete(baseline, name)
+	}
+	assert.Equal(t, baseline, paranoid)
+}
+
+func TestResolveSeccompProfile(t *testing.T) {
+	profile, err := resolveSeccompProfile("")
+	require.NoError(t, err)
+	assert.Equal(t, "baseline", profile.Name)
+	assert.Equal(t, baselineSeccompJSON, profile.JSON)
+
+	profile, err = resolveSeccompProfile("paranoid")
+	require.NoError(t, err)
+	assert.Equal(t, paranoidSeccompJSON, profile.JSON)
+
+	profile, err = resolveSeccompProfile("default")
+	require.NoError(t, err)
+	assert.Nil(t, profile.JSON)
+
+	dir := t.TempDir()
+	custom := filepath.Join(dir, "custom.json")
+	require.NoError(t, os.WriteFile(custom, []byte("{\n  \"defaultAction\": \"SCMP_ACT_ALLOW\",\n  \"syscalls\": []\n}\n"), 0644))
+	profile, err = resolveSeccompProfile(custom)
+	require.NoError(t, err)
+	assert.Equal(t, custom, profile.Name)
+	assert.Equal(t, `{"defaultAction":"SCMP_ACT_ALLOW","syscalls":[]}`, string(profile.JSON))
+
+	// A containerized server reads the profile through the SANDBOX_HOST_ROOT mapping
+	previousRoots := hostRoots
+	hostRoots = []hostRoot{{Host: "/home/me", Local: dir}}
+	profile, err = resolveSeccompProfile("/home/me/custom.json")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/me/custom.json", profile.Name)
+	assert.Equal(t, `{"defaultAction":"SCMP_ACT_ALLOW","syscalls":[]}`, string(profile.JSON))
+	hostRoots = previousRoots
+
+	notJSON := filepath.Join(dir, "profile.txt")
+	require.NoError(t, os.WriteFile(notJSON, []byte("allow everything"), 0644))
+	_, err = resolveSeccompProfile(notJSON)
+	assert.ErrorContains(t, err, "is not a JSON seccomp profile")
+
+	noAction := filepath.Join(dir, "empty.json")
+	require.NoError(t, os.WriteFile(noAction, []byte(`{"syscalls": []}`), 0644))
+	_, err = resolveSeccompProfile(noAction)
+	assert.ErrorContains(t, err, "has no defaultAction")
+
+	_, err = resolveSeccompProfile(filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "failed to read seccomp_profile")
+}
+
+func TestApplySeccompProfile(t *testing.T) {
+	hostConfig := sandboxHostConfig(0)
+	applySeccompProfile(hostConfig, seccompProfile{Name: "default"})
+	assert.Nil(t, hostConfig.SecurityOpt)
+
+	paranoid, err := resolveSeccompProfile("paranoid")
+	require.NoError(t, err)
+	applySeccompProfile(hostConfig, paranoid)
+	require.Len(t, hostConfig.SecurityOpt, 1)
+	assert.Equal(t, "seccomp="+string(paranoidSeccompJSON), hostConfig.SecurityOpt[0])
+
+	// Dropping the profile leaves the other options alone
+	hostConfig.SecurityOpt = append([]string{"no-new-privileges"}, hostConfig.SecurityOpt...)
+	assert.True(t, dropSeccompProfile(hostConfig))
+	assert.Equal(t, []string{"no-new-privileges"}, hostConfig.SecurityOpt)
+	assert.False(t, dropSeccompProfile(hostConfig))
+}
+
+// fakeSandboxStarts replaces the container start with fn and records the SecurityOpt of each attempt and the
+// containers removed in between
+func fakeSandboxStarts(t *testing.T, fn func(attempt int) (string, error)) (*[][]string, *[]string) {
+	t.Helper()
+	savedStart, savedRemove := startSandboxContainer, removeContainer
+	t.Cleanup(func() { startSandboxContainer, removeContainer = savedStart, savedRemove })
+	var attempts [][]string
+	var removed []string
+	startSandboxContainer = func(ctx context.Context, cli *client.Client, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
+		attempts = append(attempts, slices.Clone(hostConfig.SecurityOpt))
+		return fn(len(attempts))
+	}
+	removeContainer = func(ctx context.Context, cli *client.Client, id string) error {
+		removed = append(removed, id)
+		return nil
+	}
+	return &attempts, &removed
+}
+
+func TestStartWithSeccompFallback(t *testing.T) {
+	rejected := errors.New(`failed to start container: Error response from daemon: failed to create task for container: OCI runtime create failed: seccomp: config provided but seccomp not supported`)
+	attempts, removed := fakeSandboxStarts(t, func(attempt int) (string, error) {
+		if attempt == 1 {
+			return "created-1", rejected
+		}
+		return "started-2", nil
+	})
+
+	hostConfig := sandboxHostConfig(0)
+	applySeccompProfile(hostConfig, seccompProfile{Name: "baseline", JSON: baselineSeccompJSON})
+	id, warning, err := startWithSeccompFallback(context.Background(), nil, sandboxContainerConfig("python:3.12-slim"), hostConfig, "mcp-seccomp")
+	require.NoError(t, err)
+	assert.Equal(t, "started-2", id)
+	assert.True(t, strings.HasPrefix(warning, "the Docker engine rejected the seccomp profile ("), warning)
+	assert.Contains(t, warning, "runs under the engine's default seccomp profile instead")
+	require.Len(t, *attempts, 2)
+	assert.Len(t, (*attempts)[0], 1)
+	assert.Empty(t, (*attempts)[1], "the retry has no seccomp profile")
+	assert.Equal(t, []string{"created-1"}, *removed, "the container that didn't start frees its name")
+}
+
+func TestStartWithSeccompFallbackOtherErrors(t *testing.T) {
+	// An error unrelated to seccomp is returned as it is
+	attempts, removed := fakeSandboxStarts(t, func(int) (string, error) {
+		return "", errors.New("failed to create container: Conflict. The container name is already in use")
+	})
+	hostConfig := sandboxHostConfig(0)
+	applySeccompProfile(hostConfig, seccompProfile{Name: "baseline", JSON: baselineSeccompJSON})
+	_, warning, err := startWithSeccompFallback(context.Background(), nil, sandboxContainerConfig("python:3.12-slim"), hostConfig, "mcp-seccomp")
+	assert.ErrorContains(t, err, "already in use")
+	assert.Empty(t, warning)
+	assert.Len(t, *attempts, 1)
+	assert.Empty(t, *removed)
+
+	// Without a profile of ours there is nothing to fall back from
+	attempts, _ = fakeSandboxStarts(t, func(int) (string, error) {
+		return "", errors.New("seccomp is not enabled in your kernel")
+	})
+	_, _, err = startWithSeccompFallback(context.Background(), nil, sandboxContainerConfig("python:3.12-slim"), sandboxHostConfig(0), "mcp-seccomp")
+	assert.ErrorContains(t, err, "seccomp is not enabled")
+	assert.Len(t, *attempts, 1)
+
+	// A retry that fails too returns its error
+	attempts, _ = fakeSandboxStarts(t, func(attempt int) (string, error) {
+		if attempt == 1 {
+			return "", errors.New("Decoding seccomp profile failed: invalid character")
+		}
+		return "", errors.New("failed to create container: no such image")
+	})
+	hostConfig = sandboxHostConfig(0)
+	applySeccompProfile(hostConfig, seccompProfile{Name: "paranoid", JSON: paranoidSeccompJSON})
+	_, warning, err = startWithSeccompFallback(context.Background(), nil, sandboxContainerConfig("python:3.12-slim"), hostConfig, "mcp-seccomp")
+	assert.ErrorContains(t, err, "no such image")
+	assert.Empty(t, warning)
+	assert.Len(t, *attempts, 2)
+}
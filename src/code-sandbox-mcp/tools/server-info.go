@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/installer"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ServerInfo describes the running server build, its configuration and the Docker daemon it talks to
+type ServerInfo struct {
+	Version   string `json:"version"`
+	BuildMode string `json:"build_mode"`
+	GitCommit string `json:"git_commit"`
+	Transport string `json:"transport"`
+	// Instance is the name of this server instance, which its sandboxes are labeled with
+	Instance string            `json:"instance"`
+	Features map[string]bool   `json:"features"`
+	Limits   map[string]string `json:"limits,omitempty"`
+	Docker   *DockerInfo       `json:"docker,omitempty"`
+	// Host is the free disk and memory checked before containers are created, when they can be measured
+	Host *HostResources `json:"host,omitempty"`
+}
+
+// DockerInfo holds the version details of the Docker daemon, or why they could not be read
+type DockerInfo struct {
+	Version    string `json:"version,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+	OS         string `json:"os,omitempty"`
+	Arch       string `json:"arch,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// API is the negotiated API version and the features an older daemon disables
+	API *DockerAPIStatus `json:"api,omitempty"`
+}
+
+// serverConfig is the runtime configuration reported by sandbox_server_info, set once at startup
+var serverConfig = ServerInfo{Features: map[string]bool{}}
+
+// SetServerConfig records the transport, feature flags and limits the server was started with
+func SetServerConfig(transport string, features map[string]bool, limits map[string]string) {
+	serverConfig = ServerInfo{Transport: transport, Features: features, Limits: limits}
+}
+
+// CurrentServerInfo assembles the build information, startup configuration and live Docker daemon details
+func CurrentServerInfo(ctx context.Context) ServerInfo {
+	info := serverConfig
+	info.Version = installer.Version
+	info.BuildMode = installer.BuildMode
+	info.GitCommit = installer.GitCommit
+	info.Instance = instanceName
+	info.Docker = dockerInfo(ctx)
+	info.Host = currentHostResources(ctx)
+	return info
+}
+
+// dockerInfo queries the daemon version; failures are reported in the result rather than as an error
+func dockerInfo(ctx context.Context) *DockerInfo {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return &DockerInfo{Error: fmt.Sprintf("failed to create Docker client: %v", err)}
+	}
+	defer cli.Close()
+
+	// Negotiating again keeps the feature gates current if the daemon was upgraded or replaced
+	status, err := negotiateDockerAPI(ctx, cli)
+	if err != nil {
+		return &DockerInfo{Error: err.Error()}
+	}
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return &DockerInfo{Error: fmt.Sprintf("failed to reach Docker daemon: %v", err)}
+	}
+	return &DockerInfo{
+		Version:    version.Version,
+		APIVersion: version.APIVersion,
+		OS:         version.Os,
+		Arch:       version.Arch,
+		API:        &status,
+	}
+}
+
+// GetServerInfo returns the server build, configuration and Docker daemon details
+func GetServerInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jsonData, err := json.Marshal(CurrentServerInfo(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize server info: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
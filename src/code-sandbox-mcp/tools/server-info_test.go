@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/installer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetServerInfoReportsBuildVariables(t *testing.T) {
+	// build.sh injects these with -ldflags "-X .../installer.Version=..."
+	version, buildMode, commit := installer.Version, installer.BuildMode, installer.GitCommit
+	installer.Version, installer.BuildMode, installer.GitCommit = "9.9.9", "release", "abc1234"
+	t.Cleanup(func() {
+		installer.Version, installer.BuildMode, installer.GitCommit = version, buildMode, commit
+	})
+
+	previous := serverConfig
+	t.Cleanup(func() { serverConfig = previous })
+	SetServerConfig("sse", map[string]bool{"rate_limiting": true}, map[string]string{"global": "60/1m0s"})
+
+	result, err := GetServerInfo(context.Background(), newMockCallToolRequest("sandbox_server_info", nil))
+	require.NoError(t, err)
+
+	var info ServerInfo
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &info))
+	assert.Equal(t, "9.9.9", info.Version)
+	assert.Equal(t, "release", info.BuildMode)
+	assert.Equal(t, "abc1234", info.GitCommit)
+	assert.Equal(t, "sse", info.Transport)
+	assert.True(t, info.Features["rate_limiting"])
+	assert.Equal(t, "60/1m0s", info.Limits["global"])
+	require.NotNil(t, info.Docker, "docker details or the reason they are missing are always reported")
+}
@@ -0,0 +1,348 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxShellBuffer caps the unread output kept per session; older output is dropped first
+	maxShellBuffer = 1024 * 1024
+	// maxShellWait caps the wait_ms long-poll of sandbox_shell_read
+	maxShellWait = 30 * time.Second
+)
+
+// shellIdleTimeout closes sessions nobody has written to or read from for this long
+var shellIdleTimeout = 30 * time.Minute
+
+// shellSession is an interactive TTY exec whose output is buffered until read
+type shellSession struct {
+	ID        string
+	Container string // ID of the container the shell runs in
+	Name      string // name of that container
+	Shell     string
+
+	input io.Writer
+	close func()
+
+	mu       sync.Mutex
+	buf      []byte
+	dropped  int
+	exited   bool
+	lastUsed time.Time
+	// ready is closed and replaced whenever output arrives or the shell exits
+	ready chan struct{}
+}
+
+func newShellSession(id string, output io.Reader, input io.Writer, close func()) *shellSession {
+	s := &shellSession{ID: id, input: input, close: close, lastUsed: time.Now(), ready: make(chan struct{})}
+	go s.pump(output)
+	return s
+}
+
+// pump copies the shell's output into the session buffer until the shell exits or the session is closed
+func (s *shellSession) pump(output io.Reader) {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := output.Read(chunk)
+		s.mu.Lock()
+		if n > 0 {
+			s.buf = append(s.buf, chunk[:n]...)
+			if over := len(s.buf) - maxShellBuffer; over > 0 {
+				s.buf = s.buf[over:]
+				s.dropped += over
+			}
+		}
+		if err != nil {
+			s.exited = true
+		}
+		close(s.ready)
+		s.ready = make(chan struct{})
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Write sends input to the shell
+func (s *shellSession) Write(data string) (int, error) {
+	s.mu.Lock()
+	s.lastUsed = time.Now()
+	exited := s.exited
+	s.mu.Unlock()
+	if exited {
+		return 0, fmt.Errorf("shell session %s has exited", s.ID)
+	}
+	return io.WriteString(s.input, data)
+}
+
+// Read drains the buffered output, waiting up to wait for some to arrive when there is none. An incomplete
+// UTF-8 sequence at the end stays buffered for the next read.
+func (s *shellSession) Read(wait time.Duration) (output string, dropped int, exited bool) {
+	deadline := time.After(wait)
+	for {
+		s.mu.Lock()
+		s.lastUsed = time.Now()
+		if len(s.buf) > 0 || s.exited || wait <= 0 {
+			break
+		}
+		ready := s.ready
+		s.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-deadline:
+			wait = 0
+		}
+	}
+	defer s.mu.Unlock()
+
+	end := len(s.buf)
+	if !s.exited {
+		end = completeUTF8Prefix(s.buf)
+	}
+	output = string(s.buf[:end])
+	s.buf = append([]byte(nil), s.buf[end:]...)
+	dropped, s.dropped = s.dropped, 0
+	return output, dropped, s.exited && len(s.buf) == 0
+}
+
+// completeUTF8Prefix returns the length of b without a trailing incomplete UTF-8 sequence
+func completeUTF8Prefix(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+// idleSince reports whether the session has been unused since t
+func (s *shellSession) idleSince(t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsed.Before(t)
+}
+
+// matches reports whether a container reference (full or short ID, or name) names the session's container
+func (s *shellSession) matches(ref string) bool {
+	return ref != "" && (ref == s.Name || strings.HasPrefix(s.Container, ref))
+}
+
+// shellSessions holds the open shell sessions by ID
+var shellSessions = struct {
+	sync.Mutex
+	entries map[string]*shellSession
+	next    int
+	reaper  sync.Once
+}{entries: map[string]*shellSession{}}
+
+// addShellSession registers a session under a new ID and starts the idle reaper on first use
+func addShellSession(create func(id string) *shellSession) *shellSession {
+	shellSessions.reaper.Do(func() { go reapIdleShells() })
+
+	shellSessions.Lock()
+	defer shellSessions.Unlock()
+	shellSessions.next++
+	session := create(fmt.Sprintf("shell-%d", shellSessions.next))
+	shellSessions.entries[session.ID] = session
+	return session
+}
+
+func lookupShellSession(id string) (*shellSession, error) {
+	shellSessions.Lock()
+	defer shellSessions.Unlock()
+	session, ok := shellSessions.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("shell session %s not found; open one with sandbox_shell_open", id)
+	}
+	return session, nil
+}
+
+// closeShellSessions closes the sessions selected by match and returns how many were closed
+func closeShellSessions(match func(*shellSession) bool) int {
+	shellSessions.Lock()
+	var closing []*shellSession
+	for id, session := range shellSessions.entries {
+		if match(session) {
+			closing = append(closing, session)
+			delete(shellSessions.entries, id)
+		}
+	}
+	shellSessions.Unlock()
+
+	for _, session := range closing {
+		session.close()
+	}
+	return len(closing)
+}
+
+// closeContainerShells closes every session of a sandbox, used when it is stopped
+func closeContainerShells(containerIDOrName string) int {
+	return closeShellSessions(func(s *shellSession) bool { return s.matches(containerIDOrName) })
+}
+
+func reapIdleShells() {
+	for range time.Tick(time.Minute) {
+		cutoff := time.Now().Add(-shellIdleTimeout)
+		closeShellSessions(func(s *shellSession) bool { return s.idleSince(cutoff) })
+	}
+}
+
+// ShellOpen starts an interactive shell with a TTY in a container and returns its session ID
+func ShellOpen(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters using new API
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Default to bash when the image has it
+	shell := request.GetString("shell", "")
+	if shell == "" {
+		shell = containerShell(ctx, containerIDOrName)
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create Docker client: %v", err)), nil
+	}
+
+	inspect, err := cli.ContainerInspect(ctx, containerIDOrName)
+	if err != nil {
+		cli.Close()
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to inspect container: %v", err)), nil
+	}
+
+	exec, err := cli.ContainerExecCreate(ctx, containerIDOrName, container.ExecOptions{
+		Cmd:          []string{shell},
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          []string{"TERM=xterm-256color"},
+	})
+	if err != nil {
+		cli.Close()
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create exec: %v", err)), nil
+	}
+
+	// The hijacked connection outlives this call, so it must not be tied to the request context
+	// The session outlives the call, so only setting up the attach is bounded
+	attachCtx, cancelAttach := withDockerTimeout(context.WithoutCancel(ctx), execAttachTimeout)
+	resp, err := cli.ContainerExecAttach(attachCtx, exec.ID, container.ExecAttachOptions{Tty: true})
+	cancelAttach()
+	if err != nil {
+		cli.Close()
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to attach to exec: %v", err)), nil
+	}
+
+	session := addShellSession(func(id string) *shellSession {
+		s := newShellSession(id, resp.Reader, resp.Conn, func() {
+			resp.Close()
+			cli.Close()
+		})
+		s.Container = inspect.ID
+		s.Name = strings.TrimPrefix(inspect.Name, "/")
+		s.Shell = shell
+		return s
+	})
+
+	jsonData, err := json.Marshal(map[string]string{"session_id": session.ID, "shell": shell})
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize shell session: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// ShellInput sends keystrokes or text to a shell session
+func ShellInput(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("session_id")
+	if err != nil {
+		return mcp.NewToolResultText("session_id is required"), nil
+	}
+	input, err := request.RequireString("input")
+	if err != nil {
+		return mcp.NewToolResultText("input is required"), nil
+	}
+
+	session, err := lookupShellSession(sessionID)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	n, err := session.Write(input)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Sent %d bytes to %s", n, sessionID)), nil
+}
+
+// shellOutput is the result of sandbox_shell_read
+type shellOutput struct {
+	Output       string `json:"output"`
+	DroppedBytes int    `json:"dropped_bytes,omitempty"`
+	Exited       bool   `json:"exited"`
+}
+
+// ShellRead returns the output a shell session produced since the last read
+func ShellRead(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("session_id")
+	if err != nil {
+		return mcp.NewToolResultText("session_id is required"), nil
+	}
+	wait, err := parseDurationArgument("wait_ms", request.GetArguments()["wait_ms"], time.Millisecond)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	wait = min(wait, maxShellWait)
+
+	session, err := lookupShellSession(sessionID)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	output, dropped, exited := session.Read(wait)
+	// Terminal clients render the escape sequences themselves, so only invalid UTF-8 is cleaned up
+	output, _ = SanitizeOutput(output, true)
+	if exited {
+		closeShellSessions(func(s *shellSession) bool { return s == session })
+	}
+
+	jsonData, err := json.Marshal(shellOutput{Output: output, DroppedBytes: dropped, Exited: exited})
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize shell output: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// ShellClose ends a shell session
+func ShellClose(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("session_id")
+	if err != nil {
+		return mcp.NewToolResultText("session_id is required"), nil
+	}
+
+	if closeShellSessions(func(s *shellSession) bool { return s.ID == sessionID }) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: shell session %s not found", sessionID)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Closed shell session %s", sessionID)), nil
+}
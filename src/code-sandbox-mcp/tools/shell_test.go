@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeShell returns a session whose input is echoed back as output, like cat on a TTY
+func pipeShell(t *testing.T, id string) *shellSession {
+	t.Helper()
+	r, w := io.Pipe()
+	session := newShellSession(id, r, w, func() { w.Close() })
+	t.Cleanup(func() { w.Close() })
+	return session
+}
+
+func TestShellSessionReadWait(t *testing.T) {
+	session := pipeShell(t, "shell-test")
+
+	// Nothing to read and no wait returns immediately
+	output, _, exited := session.Read(0)
+	assert.Empty(t, output)
+	assert.False(t, exited)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		session.Write("hello\n")
+	}()
+	output, _, exited = session.Read(5 * time.Second)
+	assert.Equal(t, "hello\n", output)
+	assert.False(t, exited)
+
+	start := time.Now()
+	output, _, _ = session.Read(100 * time.Millisecond)
+	assert.Empty(t, output)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestShellSessionExit(t *testing.T) {
+	r, w := io.Pipe()
+	session := newShellSession("shell-test", r, io.Discard, func() {})
+	go func() {
+		w.Write([]byte("bye\n"))
+		w.Close()
+	}()
+
+	var output string
+	for !strings.Contains(output, "bye") {
+		out, _, _ := session.Read(time.Second)
+		output += out
+	}
+	output, _, exited := session.Read(time.Second)
+	assert.Empty(t, output)
+	assert.True(t, exited)
+
+	_, err := session.Write("ls\n")
+	assert.ErrorContains(t, err, "has exited")
+}
+
+func TestShellSessionHoldsPartialRune(t *testing.T) {
+	session := &shellSession{buf: []byte("caf\xc3"), ready: make(chan struct{})}
+	output, _, _ := session.Read(0)
+	assert.Equal(t, "caf", output)
+
+	session.buf = append(session.buf, 0xa9)
+	output, _, _ = session.Read(0)
+	assert.Equal(t, "é", output)
+}
+
+func TestShellSessionBufferCap(t *testing.T) {
+	r, w := io.Pipe()
+	session := newShellSession("shell-test", r, io.Discard, func() {})
+	w.Write([]byte(strings.Repeat("a", maxShellBuffer)))
+	w.Write([]byte("tail"))
+	w.Close()
+
+	require.Eventually(t, func() bool {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		return session.exited
+	}, time.Second, 10*time.Millisecond)
+
+	output, dropped, _ := session.Read(0)
+	assert.Len(t, output, maxShellBuffer)
+	assert.True(t, strings.HasSuffix(output, "tail"))
+	assert.Equal(t, 4, dropped)
+}
+
+func TestCloseContainerShells(t *testing.T) {
+	var closed []string
+	add := func(containerID, name string) *shellSession {
+		return addShellSession(func(id string) *shellSession {
+			r, _ := io.Pipe()
+			s := newShellSession(id, r, io.Discard, func() { closed = append(closed, id) })
+			s.Container = containerID
+			s.Name = name
+			return s
+		})
+	}
+	a := add("0123456789abcdef", "sandbox-a")
+	b := add("fedcba9876543210", "sandbox-b")
+	t.Cleanup(func() { closeShellSessions(func(s *shellSession) bool { return s == b }) })
+
+	assert.Equal(t, 0, closeContainerShells("sandbox"))
+	assert.Equal(t, 1, closeContainerShells("0123"))
+	assert.Equal(t, []string{a.ID}, closed)
+
+	_, err := lookupShellSession(a.ID)
+	assert.ErrorContains(t, err, "not found")
+	_, err = lookupShellSession(b.ID)
+	assert.NoError(t, err)
+}
+
+func TestShellSessionIdle(t *testing.T) {
+	session := pipeShell(t, "shell-test")
+	cutoff := time.Now()
+	assert.True(t, session.idleSince(cutoff.Add(time.Millisecond)))
+
+	session.Read(0)
+	assert.False(t, session.idleSince(cutoff))
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ResourceUsage summarises what a run consumed. Wall time is always measured; CPU and memory come from the
+// Docker stats stream, which has nothing to report for a container that exits within its first sample.
+type ResourceUsage struct {
+	WallSeconds     float64 `json:"wall_seconds"`
+	CPUSeconds      float64 `json:"cpu_seconds,omitempty"`
+	PeakMemoryBytes uint64  `json:"peak_memory_bytes,omitempty"`
+}
+
+// add folds one stats sample into the usage
+func (u *ResourceUsage) add(stats container.StatsResponse) {
+	// CPU usage is cumulative, so the latest sample holds the total
+	if cpu := float64(stats.CPUStats.CPUUsage.TotalUsage) / float64(time.Second); cpu > u.CPUSeconds {
+		u.CPUSeconds = cpu
+	}
+	if memory := memoryUsage(stats.MemoryStats); memory > u.PeakMemoryBytes {
+		u.PeakMemoryBytes = memory
+	}
+}
+
+// memoryUsage returns the memory a container used, preferring the peak recorded by cgroup v1 and otherwise
+// discounting reclaimable page cache the way docker stats does
+func memoryUsage(stats container.MemoryStats) uint64 {
+	if stats.MaxUsage > 0 {
+		return stats.MaxUsage
+	}
+	usage := stats.Usage
+	for _, key := range []string{"inactive_file", "total_inactive_file"} {
+		if cache, ok := stats.Stats[key]; ok && cache < usage {
+			return usage - cache
+		}
+	}
+	return usage
+}
+
+// sampleUsage reads the stats stream of a running container, about one sample per second, until the container
+// stops or ctx ends. The returned channel delivers the usage seen without wall time.
+func sampleUsage(ctx context.Context, cli *client.Client, containerID string) <-chan ResourceUsage {
+	usage := make(chan ResourceUsage, 1)
+	go func() {
+		var u ResourceUsage
+		defer func() { usage <- u }()
+
+		stats, err := cli.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			return
+		}
+		defer stats.Body.Close()
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var sample container.StatsResponse
+			if err := decoder.Decode(&sample); err != nil {
+				return
+			}
+			u.add(sample)
+		}
+	}()
+	return usage
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceUsageAdd(t *testing.T) {
+	sample := func(cpuNanos, usage, inactive uint64) container.StatsResponse {
+		var s container.StatsResponse
+		s.CPUStats.CPUUsage.TotalUsage = cpuNanos
+		s.MemoryStats.Usage = usage
+		s.MemoryStats.Stats = map[string]uint64{"inactive_file": inactive}
+		return s
+	}
+
+	var u ResourceUsage
+	u.add(sample(500_000_000, 40<<20, 10<<20))
+	u.add(sample(1_500_000_000, 80<<20, 20<<20))
+	// The sample after the container stopped is all zeros and must not lower anything
+	u.add(sample(0, 0, 0))
+
+	assert.InDelta(t, 1.5, u.CPUSeconds, 1e-9)
+	assert.Equal(t, uint64(60<<20), u.PeakMemoryBytes)
+}
+
+func TestMemoryUsage(t *testing.T) {
+	// cgroup v1 reports the peak directly
+	assert.Equal(t, uint64(100), memoryUsage(container.MemoryStats{Usage: 50, MaxUsage: 100}))
+	assert.Equal(t, uint64(30), memoryUsage(container.MemoryStats{Usage: 50, Stats: map[string]uint64{"total_inactive_file": 20}}))
+	assert.Equal(t, uint64(50), memoryUsage(container.MemoryStats{Usage: 50}))
+}
@@ -3,7 +3,10 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/events"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -16,13 +19,93 @@ func StopContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 	if err != nil {
 		return mcp.NewToolResultText("Error: container_id_or_name is required"), nil
 	}
+	containerIdOrName, err = lookupContainer(ctx, containerIdOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Checkpoints are garbage-collected with the sandbox unless the caller keeps them
+	keep := request.GetBool("keep", false)
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation(), dockerdebug.ClientOpt)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to create Docker client: %v", err)), nil
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, containerIdOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: failed to inspect container: %v", err)), nil
+	}
+	name := strings.TrimPrefix(inspect.Name, "/")
+	if err := checkInstance(containerIdOrName, inspect.Config, request.GetBool("all_instances", false)); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Run the on-stop commands of sandbox_initialize first, unless skipped; they can't run in a stopped container
+	var hookOutput []string
+	var warnings []string
+	if !request.GetBool("skip_hooks", false) {
+		onStop, err := onStopCommands(inspect.Config)
+		switch {
+		case err != nil:
+			warnings = append(warnings, err.Error())
+		case len(onStop) > 0 && (inspect.State == nil || !inspect.State.Running):
+			warnings = append(warnings, fmt.Sprintf("%d on-stop commands were not run because the container is not running", len(onStop)))
+		case len(onStop) > 0:
+			var warning string
+			hookOutput, warning = runOnStopCommands(ctx, containerIdOrName, onStop)
+			if warning != "" {
+				warnings = append(warnings, warning)
+			}
+		}
+	}
 
 	// Stop and remove the container
-	if err := stopAndRemoveContainer(ctx, containerIdOrName); err != nil {
+	if err := stopRequestedContainer(ctx, inspect.ID, containerIdOrName); err != nil {
 		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
 	}
+	forgetContainer(containerIdOrName, name, inspect.ID)
+
+	message := fmt.Sprintf("Successfully stopped and removed container: %s", containerIdOrName)
+	if len(hookOutput) > 0 {
+		message += "\nOn-stop commands:\n" + strings.TrimSuffix(strings.Join(hookOutput, "\n"), "\n")
+	}
+	for _, warning := range warnings {
+		message += fmt.Sprintf("\nWarning: %s", warning)
+	}
+	if !keep {
+		removed, err := removeCheckpoints(ctx, cli, name)
+		if err != nil {
+			message += fmt.Sprintf("\nWarning: %v", err)
+		} else if removed > 0 {
+			message += fmt.Sprintf("\nRemoved %d checkpoint(s)", removed)
+		}
+	}
+
+	return mcp.NewToolResultText(message), nil
+}
+
+// forgetContainer drops what the tools keep about a removed container: ref is the reference ResolveContainer
+// returned for it, name and id its name and full ID
+func forgetContainer(ref, name, id string) {
+	forgetSecrets(ref)
+	forgetShell(ref)
+	clearActiveSandbox(ref)
+	closeContainerShells(ref)
+	cancelContainerJobs(ref, name, id)
+	sandboxActivity.Forget(ref, name, id)
+}
+
+// removeStoppedContainer stops and removes a container for stopRequestedContainer; replaced in tests
+var removeStoppedContainer = stopAndRemoveContainer
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully stopped and removed container: %s", containerIdOrName)), nil
+// stopRequestedContainer stops and removes a sandbox on the server's own request, so its exit isn't captured
+// like that of a sandbox that exited on its own
+func stopRequestedContainer(ctx context.Context, id, containerIdOrName string) error {
+	markStopping(id, true)
+	defer markStopping(id, false)
+	return removeStoppedContainer(ctx, containerIdOrName)
 }
 
 // stopAndRemoveContainer stops and removes a Docker container
@@ -30,6 +113,7 @@ func stopAndRemoveContainer(ctx context.Context, containerIdOrName string) error
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -49,6 +133,7 @@ func stopAndRemoveContainer(ctx context.Context, containerIdOrName string) error
 	}); err != nil {
 		return fmt.Errorf("failed to remove container: %w", err)
 	}
+	events.Emit(events.Event{Type: events.ContainerRemoved, Container: containerIdOrName})
 
 	return nil
 }
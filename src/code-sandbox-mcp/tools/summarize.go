@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// summaryEdgeLines is how many lines are kept at the start and at the end of summarized output
+	summaryEdgeLines = 20
+	// maxSummaryMatches bounds the error and warning lines kept from the middle, so output that warns on
+	// every line still comes out short
+	maxSummaryMatches = 100
+	// maxBlockLines bounds a kept block such as a traceback or a goroutine dump
+	maxBlockLines = 40
+)
+
+// summaryRule is a pattern for a line worth keeping from the middle of verbose output. After keeps that many
+// following lines with it. Block keeps the indented lines that follow instead, such as a stack trace's frames or
+// a compiler's source excerpt, and End extends the block to the line after it when that line matches, which is
+// how Python ends a traceback with its exception.
+type summaryRule struct {
+	Pattern *regexp.Regexp
+	After   int
+	Block   bool
+	End     *regexp.Regexp
+}
+
+// summaryRules are the rule tables, keyed by the language or tool they come from. The language of a command
+// isn't known, so all of them are applied; the counts of a summary say which ones matched.
+var summaryRules = map[string][]summaryRule{
+	"python": {
+		{Pattern: regexp.MustCompile(`^Traceback \(most recent call last\):`), Block: true, End: regexp.MustCompile(`^[A-Za-z_][\w.]*(:|$)`)},
+		{Pattern: regexp.MustCompile(`^\S+:\d+: \w*Warning: `), After: 1},
+		{Pattern: regexp.MustCompile(`^(ERROR|FAILED) `)},
+		{Pattern: regexp.MustCompile(`^E {3}`)},
+	},
+	"javascript": {
+		{Pattern: regexp.MustCompile(`^npm (ERR!|error|WARN|warn) `)},
+		{Pattern: regexp.MustCompile(`^(Uncaught )?[A-Z]\w*Error( \[\w+\])?: `), Block: true},
+		{Pattern: regexp.MustCompile(`^\S+\.[cm]?[jt]sx?:\d+(:\d+)?$`), After: 3},
+		{Pattern: regexp.MustCompile(`^\s*(●|✕) `)},
+	},
+	"go": {
+		{Pattern: regexp.MustCompile(`^(panic|fatal error): `), After: 12},
+		{Pattern: regexp.MustCompile(`^\s*--- FAIL: `)},
+		{Pattern: regexp.MustCompile(`^FAIL(\s|$)`)},
+		{Pattern: regexp.MustCompile(`^\s*\S+\.go:\d+(:\d+)?: `)},
+	},
+	"compiler": {
+		{Pattern: regexp.MustCompile(`^\S+: In (function|member function|constructor|destructor) `)},
+		{Pattern: regexp.MustCompile(`^\S+:\d+(:\d+)?: (fatal )?(error|warning)`), Block: true},
+		{Pattern: regexp.MustCompile(`^(error|warning)(\[\w+\])?: `), Block: true},
+	},
+	"generic": {
+		{Pattern: regexp.MustCompile(`(?i)\b(error|fatal|failed|failure|exception)\b`)},
+		{Pattern: regexp.MustCompile(`(?i)\bwarn(ing)?\b`)},
+	},
+}
+
+// summaryRuleOrder is the order rules are tried in, so the table credited with a line doesn't depend on map
+// iteration; the generic table comes last
+var summaryRuleOrder = []string{"python", "javascript", "go", "compiler", "generic"}
+
+// OutputSummary reports what summarize_output dropped from a result
+type OutputSummary struct {
+	Lines  int `json:"lines"`
+	Kept   int `json:"kept"`
+	Elided int `json:"elided"`
+	// Matches counts the middle lines kept by each rule table, e.g. {"python": 6, "generic": 2}
+	Matches map[string]int `json:"matches,omitempty"`
+}
+
+// Add accumulates another summary
+func (s *OutputSummary) Add(other OutputSummary) {
+	s.Lines += other.Lines
+	s.Kept += other.Kept
+	s.Elided += other.Elided
+	for table, n := range other.Matches {
+		if s.Matches == nil {
+			s.Matches = map[string]int{}
+		}
+		s.Matches[table] += n
+	}
+}
+
+func (s OutputSummary) String() string {
+	text := fmt.Sprintf("kept %d of %d lines, %d elided", s.Kept, s.Lines, s.Elided)
+	if len(s.Matches) > 0 {
+		tables := make([]string, 0, len(s.Matches))
+		for table := range s.Matches {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		var parts []string
+		for _, table := range tables {
+			parts = append(parts, fmt.Sprintf("%s %d", table, s.Matches[table]))
+		}
+		text += " (error and warning lines kept: " + strings.Join(parts, ", ") + ")"
+	}
+	return text
+}
+
+// SummarizeOutput shortens verbose output to its first and last lines and the error and warning lines between
+// them, each run of dropped lines replaced by a "... [N lines elided] ..." marker. Output short enough to keep
+// whole is returned as it is. The result depends only on the input.
+func SummarizeOutput(s string) (string, OutputSummary) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	summary := OutputSummary{Lines: len(lines), Kept: len(lines)}
+	if len(lines) <= 2*summaryEdgeLines {
+		return s, summary
+	}
+
+	keep := make([]bool, len(lines))
+	for i := range summaryEdgeLines {
+		keep[i] = true
+		keep[len(lines)-1-i] = true
+	}
+	matched := 0
+	for i := summaryEdgeLines; i < len(lines)-summaryEdgeLines && matched < maxSummaryMatches; i++ {
+		if keep[i] {
+			continue
+		}
+		table, rule, ok := matchSummaryRule(strings.TrimRight(lines[i], "\r\n"))
+		if !ok {
+			continue
+		}
+		end := i + 1 + rule.After
+		if rule.Block {
+			end = i + 1
+			for end < len(lines) && end-i < maxBlockLines && isIndented(lines[end]) {
+				end++
+			}
+			if rule.End != nil && end < len(lines) && rule.End.MatchString(lines[end]) {
+				end++
+			}
+		}
+		end = min(end, len(lines), i+maxBlockLines)
+		kept := 0
+		for j := i; j < end && matched+kept < maxSummaryMatches; j++ {
+			if !keep[j] {
+				keep[j] = true
+				kept++
+			}
+		}
+		if summary.Matches == nil {
+			summary.Matches = map[string]int{}
+		}
+		summary.Matches[table] += kept
+		matched += kept
+	}
+
+	var out strings.Builder
+	elided := 0
+	for i, line := range lines {
+		if !keep[i] {
+			elided++
+			continue
+		}
+		if elided > 0 {
+			fmt.Fprintf(&out, "... [%d lines elided] ...\n", elided)
+			summary.Elided += elided
+			elided = 0
+		}
+		out.WriteString(line)
+	}
+	summary.Kept = summary.Lines - summary.Elided
+	return out.String(), summary
+}
+
+// matchSummaryRule finds the first rule, in table order, that matches a line
+func matchSummaryRule(line string) (string, summaryRule, bool) {
+	for _, table := range summaryRuleOrder {
+		for _, rule := range summaryRules[table] {
+			if rule.Pattern.MatchString(line) {
+				return table, rule, true
+			}
+		}
+	}
+	return "", summaryRule{}, false
+}
+
+// isIndented reports whether a line continues a block, like the frames of a stack trace
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// summarizeSections summarizes each sandbox_exec section and returns the total, or nil when nothing was elided
+func summarizeSections(sections []string) *OutputSummary {
+	var total OutputSummary
+	for i, section := range sections {
+		summarized, summary := SummarizeOutput(section)
+		sections[i] = summarized
+		total.Add(summary)
+	}
+	if total.Elided == 0 {
+		return nil
+	}
+	return &total
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// elidedMarker matches the line that stands in for a run of dropped lines
+var elidedMarker = regexp.MustCompile(`(?m)^\.\.\. \[(\d+) lines elided\] \.\.\.$`)
+
+// The fixtures are hand-written, modeled on the output formats of Python, npm, go test and gcc/make
+func TestSummarizeOutputFixtures(t *testing.T) {
+	tests := []struct {
+		file    string
+		lines   int
+		matches map[string]int
+		kept    []string
+	}{
+		{
+			file:    "python-traceback.txt",
+			lines:   319,
+			matches: map[string]int{"python": 18, "generic": 1},
+			kept: []string{
+				"ERROR:worker:record 137 could not be parsed\nTraceback (most recent call last):\n",
+				`    raise JSONDecodeError("Expecting value", s, err.value) from None` + "\njson.decoder.JSONDecodeError: Expecting value: line 1 column 29 (char 28)\n",
+				"/app/worker.py:18: RuntimeWarning: record 210 has no timestamp, using the current time\n  warnings.warn(",
+			},
+		},
+		{
+			file:    "npm-install-error.txt",
+			lines:   258,
+			matches: map[string]int{"javascript": 10},
+			kept: []string{
+				"npm error code ENOTFOUND\n",
+				"npm error network request to https://registry.npmjs.org/left-pad-does-not-exist-42 failed",
+			},
+		},
+		{
+			file:    "go-test-fail.txt",
+			lines:   408,
+			matches: map[string]int{"go": 3},
+			kept: []string{
+				`    inventory_test.go:17: Price("sku-073") = 965, want 1073` + "\n",
+				"    --- FAIL: TestPrice/sku-073 (0.00s)\n",
+				"FAIL\texample.com/inventory\t0.004s\n",
+			},
+		},
+		{
+			file:    "go-panic.txt",
+			lines:   125,
+			matches: map[string]int{"generic": 1},
+			kept: []string{
+				"warning: row 60 has a duplicate name\n",
+				"panic: runtime error: index out of range [1] with length 1\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:33 +0x48c\n",
+			},
+		},
+		{
+			file:    "c-compile-error.txt",
+			lines:   103,
+			matches: map[string]int{"compiler": 10, "generic": 1},
+			kept: []string{
+				"src/parser.c: In function 'parse_header':\nsrc/parser.c:6:26: error: expected ';' before 'return'\n    6 |                 return -1\n",
+				"src/parser.c:8:1: warning: control reaches end of non-void function [-Wreturn-type]\n    8 | }\n      | ^\nmake: *** [Makefile:6: build/parser.o] Error 1\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "summarize", tt.file))
+			require.NoError(t, err)
+			output := string(data)
+
+			summarized, summary := SummarizeOutput(output)
+			assert.Equal(t, tt.lines, summary.Lines)
+			assert.Equal(t, tt.matches, summary.Matches)
+			assert.Equal(t, summary.Lines, summary.Kept+summary.Elided)
+			assert.Positive(t, summary.Elided)
+			for _, kept := range tt.kept {
+				assert.Contains(t, summarized, kept)
+			}
+
+			// The result keeps the ends and accounts for every dropped line
+			lines := strings.SplitAfter(strings.TrimSuffix(output, "\n"), "\n")
+			assert.True(t, strings.HasPrefix(summarized, strings.Join(lines[:summaryEdgeLines], "")) || strings.HasPrefix(summarized, "... ["))
+			assert.True(t, strings.HasSuffix(summarized, strings.Join(lines[len(lines)-summaryEdgeLines:], "")+"\n"))
+			elided := 0
+			for _, m := range elidedMarker.FindAllStringSubmatch(summarized, -1) {
+				n, _ := strconv.Atoi(m[1])
+				elided += n
+			}
+			assert.Equal(t, summary.Elided, elided)
+
+			again, summaryAgain := SummarizeOutput(output)
+			assert.Equal(t, summarized, again, "summarizing is deterministic")
+			assert.Equal(t, summary, summaryAgain)
+		})
+	}
+}
+
+func TestSummarizeOutputShort(t *testing.T) {
+	for _, output := range []string{"", "hello\n", "no trailing newline", strings.Repeat("line\n", 2*summaryEdgeLines)} {
+		summarized, summary := SummarizeOutput(output)
+		assert.Equal(t, output, summarized)
+		assert.Zero(t, summary.Elided)
+		assert.Nil(t, summary.Matches)
+	}
+
+	// One line over keeps the edges and elides the middle line
+	output := strings.Repeat("ok\n", summaryEdgeLines) + "middle\n" + strings.Repeat("ok\n", summaryEdgeLines)
+	summarized, summary := SummarizeOutput(output)
+	assert.Equal(t, OutputSummary{Lines: 41, Kept: 40, Elided: 1}, summary)
+	assert.Contains(t, summarized, "ok\n... [1 lines elided] ...\nok\n")
+}
+
+func TestSummarizeOutputBoundsMatches(t *testing.T) {
+	var output strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&output, "warning: deprecated option %d\n", i)
+	}
+	summarized, summary := SummarizeOutput(output.String())
+	assert.Equal(t, 1000, summary.Lines)
+	assert.Equal(t, 2*summaryEdgeLines+maxSummaryMatches, summary.Kept)
+	assert.Equal(t, map[string]int{"compiler": maxSummaryMatches}, summary.Matches)
+	assert.Contains(t, summarized, "warning: deprecated option 119\n... [860 lines elided] ...\nwarning: deprecated option 980\n")
+}
+
+func TestSummarizeSections(t *testing.T) {
+	long := "$ make\n" + strings.Repeat("cc -c x.c\n", 100) + "Command exited with code 2\n"
+	sections := []string{"$ ls\nmain.go\n", long}
+	total := summarizeSections(sections)
+	require.NotNil(t, total)
+	assert.Equal(t, 104, total.Lines)
+	assert.Equal(t, 62, total.Elided)
+	assert.Equal(t, "$ ls\nmain.go\n", sections[0])
+	assert.True(t, strings.HasPrefix(sections[1], "$ make\n"))
+	assert.True(t, strings.HasSuffix(sections[1], "Command exited with code 2\n"))
+	assert.Equal(t, "kept 42 of 104 lines, 62 elided", total.String())
+
+	assert.Nil(t, summarizeSections([]string{"$ ls\nmain.go\n"}))
+}
+
+func TestCommandResultSummarize(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "summarize", "go-panic.txt"))
+	require.NoError(t, err)
+	result := &commandResult{Stdout: strings.Repeat("row\n", 10), Stderr: string(data)}
+	result.summarize()
+	assert.Equal(t, strings.Repeat("row\n", 10), result.Stdout)
+	require.NotNil(t, result.Summarized)
+	assert.Equal(t, 135, result.Summarized.Lines)
+	assert.Equal(t, 84, result.Summarized.Elided)
+	assert.Equal(t, "kept 51 of 135 lines, 84 elided (error and warning lines kept: generic 1)", result.Summarized.String())
+
+	short := &commandResult{Stdout: "ok\n"}
+	short.summarize()
+	assert.Nil(t, short.Summarized)
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// systemPackagesTimeout bounds an install_system_packages install, which can take minutes for a package such
+// as build-essential
+const systemPackagesTimeout = 10 * time.Minute
+
+// systemPackageManagers are the package managers install_system_packages runs
+var systemPackageManagers = []string{"apt-get", "apk", "dnf"}
+
+// osReleaseManagers maps the distribution IDs of /etc/os-release, and the IDs they name in ID_LIKE, to their
+// package manager
+var osReleaseManagers = map[string]string{
+	"debian":    "apt-get",
+	"ubuntu":    "apt-get",
+	"alpine":    "apk",
+	"fedora":    "dnf",
+	"rhel":      "dnf",
+	"centos":    "dnf",
+	"rocky":     "dnf",
+	"almalinux": "dnf",
+	"amzn":      "dnf",
+}
+
+// parseOSRelease returns the ID followed by the ID_LIKE entries of an /etc/os-release file
+func parseOSRelease(content string) []string {
+	var id, like []string
+	for _, line := range strings.Split(content, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = []string{value}
+		case "ID_LIKE":
+			like = strings.Fields(value)
+		}
+	}
+	return append(id, like...)
+}
+
+// systemPackageManagerFor picks the package manager of the distribution an /etc/os-release file describes
+func systemPackageManagerFor(osRelease string) (string, bool) {
+	for _, id := range parseOSRelease(osRelease) {
+		if manager, ok := osReleaseManagers[strings.ToLower(id)]; ok {
+			return manager, true
+		}
+	}
+	return "", false
+}
+
+// detectSystemPackageManager reads /etc/os-release in the container to pick its system package manager
+func detectSystemPackageManager(ctx context.Context, containerIDOrName string) (string, error) {
+	stdout, _, exitCode, err := executeCommandWithOutput(ctx, containerIDOrName, "cat /etc/os-release")
+	if err != nil {
+		return "", fmt.Errorf("failed to read /etc/os-release: %w", err)
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("the image has no /etc/os-release, so its package manager is unknown")
+	}
+	manager, ok := systemPackageManagerFor(stdout)
+	if !ok {
+		ids := parseOSRelease(stdout)
+		return "", fmt.Errorf("unsupported distribution %q: install_system_packages supports Debian, Ubuntu, Alpine and Fedora/RHEL based images", strings.Join(ids, " "))
+	}
+	return manager, nil
+}
+
+// systemInstallCommand builds the non-interactive shell command that installs system packages
+func systemInstallCommand(manager string, packages []string) string {
+	switch manager {
+	case "apk":
+		return installCommand("apk", "apk", packages)
+	case "dnf":
+		quoted := make([]string, len(packages))
+		for i, pkg := range packages {
+			quoted[i] = shellQuote(pkg)
+		}
+		return fmt.Sprintf("dnf install -y --setopt=install_weak_deps=False %s", strings.Join(quoted, " "))
+	default:
+		return installCommand("apt", "apt-get", packages)
+	}
+}
+
+// InstallSystemPackages installs system packages in a sandbox with the package manager of its distribution
+func InstallSystemPackages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("Error: container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	packages, err := parsePackagesArgument(request.GetArguments()["packages"])
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	if len(packages) == 0 {
+		return mcp.NewToolResultText("Error: at least one package is required"), nil
+	}
+
+	manager, err := detectSystemPackageManager(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	if err := requireCommand(ctx, containerIDOrName, manager, "the image's package manager was removed"); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	progress := newProgressReporter(ctx, request, phaseInstall)
+	progress.Phase(phaseInstall, fmt.Sprintf("Installing %d system packages with %s", len(packages), manager))
+	installCtx, cancel := context.WithTimeout(ctx, systemPackagesTimeout)
+	defer cancel()
+	if err := runInstaller(installCtx, progress, containerIDOrName, manager, systemInstallCommand(manager, packages)); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %s did not finish within %s", manager, systemPackagesTimeout)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	progress.Done("System packages installed")
+	return mcp.NewToolResultText(fmt.Sprintf("Installed %d system packages with %s: %s", len(packages), manager, strings.Join(packages, ", "))), nil
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemPackageManagerFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		want      string
+	}{
+		{"debian", "PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nNAME=\"Debian GNU/Linux\"\nVERSION_ID=\"12\"\nID=debian\n", "apt-get"},
+		{"ubuntu", "NAME=\"Ubuntu\"\nVERSION_ID=\"24.04\"\nID=ubuntu\nID_LIKE=debian\n", "apt-get"},
+		{"alpine", "NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.20.3\n", "apk"},
+		{"fedora", "NAME=\"Fedora Linux\"\nVERSION=\"40 (Container Image)\"\nID=fedora\n", "dnf"},
+		{"rocky through ID_LIKE", "NAME=\"Rocky Linux\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n", "dnf"},
+		{"derivative through ID_LIKE", "ID=pop\nID_LIKE=\"ubuntu debian\"\n", "apt-get"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := systemPackageManagerFor(tt.osRelease)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, ok := systemPackageManagerFor("ID=arch\n")
+	assert.False(t, ok)
+	_, ok = systemPackageManagerFor("")
+	assert.False(t, ok)
+}
+
+func TestSystemInstallCommand(t *testing.T) {
+	packages := []string{"libpq-dev", "build-essential"}
+	assert.Equal(t,
+		`apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends 'libpq-dev' 'build-essential'`,
+		systemInstallCommand("apt-get", packages))
+	assert.Equal(t, `apk add --no-cache 'postgresql-dev'`, systemInstallCommand("apk", []string{"postgresql-dev"}))
+	assert.Equal(t, `dnf install -y --setopt=install_weak_deps=False 'libpq-devel'`, systemInstallCommand("dnf", []string{"libpq-devel"}))
+}
@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// SandboxTemplate is a named preset of sandbox_initialize arguments
+type SandboxTemplate struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Arguments   map[string]any `json:"arguments"`
+}
+
+// sandboxTemplates holds the presets loaded at startup, keyed by name
+var sandboxTemplates = map[string]SandboxTemplate{}
+
+// LoadTemplatesFromEnv loads the templates file named by SANDBOX_TEMPLATES, if set, and validates
+// every preset against the input schema of the initialize tool
+func LoadTemplatesFromEnv(initializeTool mcp.Tool) error {
+	path := os.Getenv("SANDBOX_TEMPLATES")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read SANDBOX_TEMPLATES file: %w", err)
+	}
+
+	templates, err := parseTemplates(data, filepath.Ext(path), initializeTool.InputSchema)
+	if err != nil {
+		return fmt.Errorf("invalid SANDBOX_TEMPLATES file %s: %w", path, err)
+	}
+
+	sandboxTemplates = templates
+	return nil
+}
+
+// parseTemplates decodes a JSON or YAML templates file of the form {name: {description, arguments}}
+func parseTemplates(data []byte, ext string, schema mcp.ToolInputSchema) (map[string]SandboxTemplate, error) {
+	var raw map[string]SandboxTemplate
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
+
+	templates := make(map[string]SandboxTemplate, len(raw))
+	var problems []string
+	for name, tmpl := range raw {
+		if strings.TrimSpace(name) == "" {
+			problems = append(problems, "template names must not be empty")
+			continue
+		}
+		tmpl.Name = name
+		if tmpl.Arguments == nil {
+			tmpl.Arguments = map[string]any{}
+		}
+		for key, value := range tmpl.Arguments {
+			if err := validateTemplateArgument(key, value, schema); err != nil {
+				problems = append(problems, fmt.Sprintf("template %q: %v", name, err))
+			}
+		}
+		templates[name] = tmpl
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return templates, nil
+}
+
+// validateTemplateArgument checks that a preset argument is a known parameter of the expected JSON type
+func validateTemplateArgument(key string, value any, schema mcp.ToolInputSchema) error {
+	if key == "template" {
+		return fmt.Errorf("templates cannot reference other templates")
+	}
+	prop, ok := schema.Properties[key].(map[string]any)
+	if !ok {
+		return fmt.Errorf("unknown argument %q", key)
+	}
+
+	wantType, _ := prop["type"].(string)
+	var valid bool
+	switch wantType {
+	case "string":
+		_, valid = value.(string)
+	case "boolean":
+		_, valid = value.(bool)
+	case "number", "integer":
+		switch value.(type) {
+		case int, int64, float64:
+			valid = true
+		}
+	case "array":
+		_, valid = value.([]any)
+	case "object":
+		_, valid = value.(map[string]any)
+	default:
+		valid = true
+	}
+	if !valid {
+		return fmt.Errorf("argument %q must be of type %s", key, wantType)
+	}
+	return nil
+}
+
+// expandTemplate merges the named template's arguments under the call's own arguments, which win on conflicts
+func expandTemplate(args map[string]any) (map[string]any, error) {
+	name, _ := args["template"].(string)
+	if name == "" {
+		return args, nil
+	}
+
+	tmpl, ok := sandboxTemplates[name]
+	if !ok {
+		available := templateNames()
+		if len(available) == 0 {
+			return nil, fmt.Errorf("unknown template %q: no templates are configured (set SANDBOX_TEMPLATES)", name)
+		}
+		return nil, fmt.Errorf("unknown template %q: available templates are %s", name, strings.Join(available, ", "))
+	}
+
+	merged := make(map[string]any, len(tmpl.Arguments)+len(args))
+	for key, value := range tmpl.Arguments {
+		merged[key] = value
+	}
+	for key, value := range args {
+		if key == "template" {
+			continue
+		}
+		merged[key] = value
+	}
+	return merged, nil
+}
+
+// templateNames returns the sorted names of the configured templates
+func templateNames() []string {
+	names := make([]string, 0, len(sandboxTemplates))
+	for name := range sandboxTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListTemplates lists the configured sandbox templates with their descriptions and arguments
+func ListTemplates(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	templates := make([]SandboxTemplate, 0, len(sandboxTemplates))
+	for _, name := range templateNames() {
+		templates = append(templates, sandboxTemplates[name])
+	}
+
+	jsonData, err := json.Marshal(templates)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize template list: %v", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInitializeSchema mirrors the parameters sandbox_initialize declares in main.go
+var testInitializeSchema = mcp.NewTool("sandbox_initialize",
+	mcp.WithString("image"),
+	mcp.WithString("name"),
+	mcp.WithString("template"),
+	mcp.WithArray("packages"),
+).InputSchema
+
+// withTemplates installs templates for the duration of a test
+func withTemplates(t *testing.T, templates map[string]SandboxTemplate) {
+	t.Helper()
+	previous := sandboxTemplates
+	sandboxTemplates = templates
+	t.Cleanup(func() { sandboxTemplates = previous })
+}
+
+func TestParseTemplatesJSONAndYAML(t *testing.T) {
+	jsonData := []byte(`{"data-science": {"description": "Python with numpy", "arguments": {"image": "python:3.12", "packages": ["numpy"]}}}`)
+	templates, err := parseTemplates(jsonData, ".json", testInitializeSchema)
+	require.NoError(t, err)
+	require.Contains(t, templates, "data-science")
+	assert.Equal(t, "Python with numpy", templates["data-science"].Description)
+	assert.Equal(t, "python:3.12", templates["data-science"].Arguments["image"])
+
+	yamlData := []byte("node:\n  description: Node LTS\n  arguments:\n    image: node:20-slim\n")
+	templates, err = parseTemplates(yamlData, ".yaml", testInitializeSchema)
+	require.NoError(t, err)
+	assert.Equal(t, "node", templates["node"].Name)
+	assert.Equal(t, "node:20-slim", templates["node"].Arguments["image"])
+}
+
+func TestParseTemplatesValidation(t *testing.T) {
+	data := []byte(`{
+		"bad-key": {"arguments": {"imagee": "python"}},
+		"bad-type": {"arguments": {"image": 3}},
+		"nested": {"arguments": {"template": "bad-key"}}
+	}`)
+	_, err := parseTemplates(data, ".json", testInitializeSchema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `template "bad-key": unknown argument "imagee"`)
+	assert.Contains(t, err.Error(), `template "bad-type": argument "image" must be of type string`)
+	assert.Contains(t, err.Error(), `template "nested": templates cannot reference other templates`)
+
+	_, err = parseTemplates([]byte("{not json"), ".json", testInitializeSchema)
+	assert.ErrorContains(t, err, "failed to parse JSON")
+}
+
+func TestExpandTemplateOverridePrecedence(t *testing.T) {
+	withTemplates(t, map[string]SandboxTemplate{
+		"data-science": {Name: "data-science", Arguments: map[string]any{
+			"image": "python:3.12",
+			"name":  "ds",
+		}},
+	})
+
+	merged, err := expandTemplate(map[string]any{"template": "data-science", "name": "mine"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"image": "python:3.12", "name": "mine"}, merged)
+
+	// Without a template the arguments pass through untouched
+	args := map[string]any{"image": "alpine"}
+	merged, err = expandTemplate(args)
+	require.NoError(t, err)
+	assert.Equal(t, args, merged)
+}
+
+func TestExpandTemplateUnknownName(t *testing.T) {
+	withTemplates(t, map[string]SandboxTemplate{})
+	_, err := expandTemplate(map[string]any{"template": "nope"})
+	assert.ErrorContains(t, err, "no templates are configured")
+
+	withTemplates(t, map[string]SandboxTemplate{"b": {Name: "b"}, "a": {Name: "a"}})
+	_, err = expandTemplate(map[string]any{"template": "nope"})
+	assert.ErrorContains(t, err, `unknown template "nope": available templates are a, b`)
+}
+
+func TestExpandTemplateYAMLIntegers(t *testing.T) {
+	schema := mcp.NewTool("sandbox_initialize",
+		mcp.WithString("image"),
+		mcp.WithString("template"),
+		mcp.WithNumber("memory_limit", OrUnitString()),
+		mcp.WithNumber("timeout_seconds", OrUnitString()),
+	).InputSchema
+	data := []byte("big:\n  arguments:\n    image: python:3.12\n    memory_limit: 4096\n    timeout_seconds: 120\n")
+	templates, err := parseTemplates(data, ".yaml", schema)
+	require.NoError(t, err)
+	withTemplates(t, templates)
+
+	args, err := expandTemplate(map[string]any{"template": "big"})
+	require.NoError(t, err)
+	memoryLimit, err := parseSizeArgument("memory_limit", args["memory_limit"], 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096<<20), memoryLimit)
+	timeout, err := parseDurationArgument("timeout_seconds", args["timeout_seconds"], time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 120*time.Second, timeout)
+}
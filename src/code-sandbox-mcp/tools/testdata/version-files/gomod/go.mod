@@ -0,0 +1,9 @@
+module example.com/api
+
+go 1.21 // minimum supported
+
+toolchain go1.22.1
+
+require (
+	github.com/google/uuid v1.6.0
+)
@@ -3,36 +3,87 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/goleak"
+
+	"github.com/Automata-Labs-team/code-sandbox-mcp/internal/dockertest"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tracing"
 )
 
 func newMockCallToolRequest(toolName string, params map[string]interface{}) mcp.CallToolRequest {
 	return mcp.CallToolRequest{
-		Params: struct {
-			Name      string                 `json:"name"`
-			Arguments map[string]interface{} `json:"arguments,omitempty"`
-			Meta      *struct {
-				ProgressToken mcp.ProgressToken "json:\"progressToken,omitempty\""
-			} "json:\"_meta,omitempty\""
-		}{
+		Params: mcp.CallToolParams{
 			Name:      toolName,
 			Arguments: params,
 		},
 	}
 }
 
+func TestMain(m *testing.M) {
+	// Mark the containers created through the tools so the harness cleans up after crashed runs
+	extraContainerLabels = dockertest.Labels(nil)
+	dockertest.Main(m)
+}
+
+// startSandbox initializes a sandbox from image and registers its removal with t.Cleanup
+func startSandbox(t *testing.T, image string, name string) string {
+	t.Helper()
+	return startSandboxWithArgs(t, map[string]interface{}{"image": image, "name": name})
+}
+
+// startSandboxWithArgs initializes a sandbox with the given sandbox_initialize arguments, which must include a name
+func startSandboxWithArgs(t *testing.T, args map[string]interface{}) string {
+	t.Helper()
+	ctx := context.Background()
+	name := args["name"].(string)
+
+	initResult, err := InitializeEnvironment(ctx, newMockCallToolRequest("sandbox_initialize", args))
+	require.NoError(t, err)
+	textContent, ok := initResult.Content[0].(mcp.TextContent)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(textContent.Text, "container_id: "), textContent.Text)
+
+	t.Cleanup(func() {
+		_, err := StopContainer(ctx, newMockCallToolRequest("sandbox_stop", map[string]interface{}{
+			"container_id_or_name": name,
+		}))
+		assert.NoError(t, err)
+	})
+	return name
+}
+
+// resultText returns the text of the first content item of a tool result
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	require.NotNil(t, result)
+	require.NotEmpty(t, result.Content)
+	textContent, ok := result.Content[0].(mcp.TextContent)
+	require.True(t, ok)
+	return textContent.Text
+}
+
 func TestSandboxLifecycle(t *testing.T) {
+	dockertest.Require(t)
 	ctx := context.Background()
 	containerName := "mcp-test-container-lifecycle"
 
 	// 1. Initialize
 	initRequest := newMockCallToolRequest("sandbox_initialize", map[string]interface{}{
-		"image": "alpine:latest",
+		"image": dockertest.Image,
 		"name":  containerName,
 	})
 	initResult, err := InitializeEnvironment(ctx, initRequest)
@@ -65,7 +116,7 @@ func TestSandboxLifecycle(t *testing.T) {
 
 	listTextContent, ok := listResult.Content[0].(mcp.TextContent)
 	require.True(t, ok)
-	
+
 	var sandboxes []SandboxInfo
 	err = json.Unmarshal([]byte(listTextContent.Text), &sandboxes)
 	require.NoError(t, err)
@@ -75,23 +126,1171 @@ func TestSandboxLifecycle(t *testing.T) {
 		if s.Name == containerName {
 			found = true
 			assert.Equal(t, containerID[:12], s.ContainerID)
-			assert.True(t, strings.HasPrefix(s.Image, "alpine:latest"), "Image should be alpine:latest")
+			assert.True(t, strings.HasPrefix(s.Image, dockertest.Image), "Image should be the test image")
+			assert.Equal(t, "running", s.State)
+			assert.Nil(t, s.ExitCode)
+			require.NotNil(t, s.UptimeSeconds)
+			assert.GreaterOrEqual(t, *s.UptimeSeconds, int64(0))
 			break
 		}
 	}
 	assert.True(t, found, "Newly created container should be in the list")
 
-
-	// 3. Exec
+	// 3. Exec: one content item per command plus a summary
 	execRequest := newMockCallToolRequest("sandbox_exec", map[string]interface{}{
 		"container_id_or_name": containerName,
-		"commands":             []string{"echo", "hello world"},
+		"commands":             []interface{}{"echo hello world", "echo second", "exit 3", "echo never"},
 	})
 	execResult, err := Exec(ctx, execRequest)
 	require.NoError(t, err)
-	require.Len(t, execResult.Content, 1)
+	require.Len(t, execResult.Content, 4)
+
+	texts := make([]string, 0, len(execResult.Content))
+	for _, content := range execResult.Content {
+		textContent, ok := content.(mcp.TextContent)
+		require.True(t, ok)
+		texts = append(texts, textContent.Text)
+	}
+	assert.Equal(t, "$ echo hello world\nhello world\n", texts[0])
+	assert.Equal(t, "$ echo second\nsecond\n", texts[1])
+	assert.Contains(t, texts[2], "$ exit 3\nCommand exited with code 3")
+
+	var summary struct {
+		ExitCodes   []int  `json:"exit_codes"`
+		ExecutionID string `json:"execution_id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(texts[3]), &summary))
+	assert.Equal(t, []int{0, 0, 3}, summary.ExitCodes)
+	assert.NotEmpty(t, summary.ExecutionID)
+
+	// 4. Exec with merged output
+	mergedResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": containerName,
+		"commands":             []interface{}{"echo hello world", "echo second"},
+		"merge_output":         true,
+	}))
+	require.NoError(t, err)
+	require.Len(t, mergedResult.Content, 1)
+	merged := resultText(t, mergedResult)
+	assert.Contains(t, merged, "$ echo hello world\nhello world\n\n$ echo second\nsecond\n")
+	assert.Contains(t, merged, "execution_id: ")
+
+	// 5. A stopped sandbox is only listed with include_stopped or its state
+	cli := dockertest.Require(t)
+	timeout := 0
+	require.NoError(t, cli.ContainerStop(ctx, containerName, container.StopOptions{Timeout: &timeout}))
+	listed := func(args map[string]interface{}) *SandboxInfo {
+		result, err := ListSandboxes(ctx, newMockCallToolRequest("sandbox_list", args))
+		require.NoError(t, err)
+		var sandboxes []SandboxInfo
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &sandboxes))
+		for _, s := range sandboxes {
+			if s.Name == containerName {
+				return &s
+			}
+		}
+		return nil
+	}
+	assert.Nil(t, listed(nil))
+	assert.Nil(t, listed(map[string]interface{}{"state": "running"}))
+	for _, args := range []map[string]interface{}{{"include_stopped": true}, {"state": "exited"}} {
+		stopped := listed(args)
+		if assert.NotNil(t, stopped, "%v", args) {
+			assert.Equal(t, "exited", stopped.State)
+			assert.NotNil(t, stopped.ExitCode)
+			assert.Nil(t, stopped.UptimeSeconds)
+		}
+	}
+}
+
+func TestSandboxInstancesAreIsolated(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	useInstance(t, "mcp-test-instance-a")
+	nameA := startSandbox(t, dockertest.Image, "mcp-test-instance-a")
+	useInstance(t, "mcp-test-instance-b")
+	nameB := startSandbox(t, dockertest.Image, "mcp-test-instance-b")
+
+	listed := func(args map[string]interface{}) map[string]string {
+		result, err := ListSandboxes(ctx, newMockCallToolRequest("sandbox_list", args))
+		require.NoError(t, err)
+		var sandboxes []SandboxInfo
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &sandboxes))
+		instances := map[string]string{}
+		for _, s := range sandboxes {
+			instances[s.Name] = s.Instance
+		}
+		return instances
+	}
+
+	// Each instance lists only its own sandbox, unless it asks for all of them
+	own := listed(nil)
+	assert.Equal(t, "mcp-test-instance-b", own[nameB])
+	assert.NotContains(t, own, nameA)
+	all := listed(map[string]interface{}{"all_instances": true})
+	assert.Equal(t, "mcp-test-instance-a", all[nameA])
+	assert.Equal(t, "mcp-test-instance-b", all[nameB])
+
+	// Instance b can't stop a's sandbox by accident
+	result, err := StopContainer(ctx, newMockCallToolRequest("sandbox_stop", map[string]interface{}{"container_id_or_name": nameA}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "OTHER_INSTANCE: container mcp-test-instance-a belongs to server instance \"mcp-test-instance-a\"")
+	_, err = dockertest.Require(t).ContainerInspect(ctx, nameA)
+	require.NoError(t, err, "the sandbox of the other instance is still there")
+
+	useInstance(t, "mcp-test-instance-a")
+	own = listed(nil)
+	assert.Contains(t, own, nameA)
+	assert.NotContains(t, own, nameB)
+	result, err = StopContainer(ctx, newMockCallToolRequest("sandbox_stop", map[string]interface{}{"container_id_or_name": nameB, "all_instances": true}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "Successfully stopped and removed container: "+nameB)
+}
+
+// writeProjectFixture creates a small nested project directory and returns its path
+func writeProjectFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "proj")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "pkg", "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.py"), []byte("print('hi')\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pkg", "sub", "run.sh"), []byte("#!/bin/sh\necho run\n"), 0755))
+	return root
+}
+
+func TestCopyProjectBusybox(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, "busybox:latest", "mcp-test-copy-busybox")
+	project := writeProjectFixture(t)
+
+	result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"local_src_dir":        project,
+	}))
+	require.NoError(t, err)
+	require.Contains(t, resultText(t, result), "Successfully copied")
+
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"cat /app/proj/proj/pkg/sub/run.sh"},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, execResult), "echo run")
+}
+
+func TestCopyProjectDirectMatchesExtract(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-copy-layout")
+	project := writeProjectFixture(t)
+
+	for _, dest := range []string{"direct", "extract"} {
+		result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+			"container_id_or_name": name,
+			"local_src_dir":        project,
+			"dest_dir":             dest,
+			"extract_in_container": dest == "extract",
+		}))
+		require.NoError(t, err)
+		require.Contains(t, resultText(t, result), "Successfully copied")
+	}
+
+	listing := func(dir string) string {
+		execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+			"container_id_or_name": name,
+			"commands":             []interface{}{fmt.Sprintf("cd /app/%s && find . -exec stat -c '%%n %%a' {} + | sort", dir)},
+		}))
+		require.NoError(t, err)
+		return resultText(t, execResult)
+	}
+	direct := listing("direct")
+	assert.Contains(t, direct, "./proj/pkg/sub/run.sh 755")
+	assert.Equal(t, strings.Replace(listing("extract"), "/app/extract", "/app/direct", 1), direct)
+
+	// Nothing is left behind in /tmp by the default path
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"ls /tmp"},
+	}))
+	require.NoError(t, err)
+	assert.NotContains(t, resultText(t, execResult), "project_proj.tar")
+}
+
+func TestCopyProjectSources(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-copy-sources")
+	project := writeProjectFixture(t)
+	shared := writeSecondRoot(t)
+
+	result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"sources": []interface{}{
+			map[string]interface{}{"src": project, "dest": "packages/api"},
+			map[string]interface{}{"src": shared, "dest": "packages/shared"},
+		},
+	}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	require.Contains(t, text, "Successfully copied 2 directories")
+	assert.Contains(t, text, project+" -> /app/packages/api (2 files)")
+	assert.Contains(t, text, shared+" -> /app/packages/shared (1 file)")
+
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"cd /app/packages && find . -type f | sort"},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, execResult), "./api/main.py\n./api/pkg/sub/run.sh\n./shared/lib/util.py\n")
+
+	// Overlapping destinations are refused before anything is copied
+	result, err = CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"sources": []interface{}{
+			map[string]interface{}{"src": project, "dest": "nested"},
+			map[string]interface{}{"src": shared, "dest": "nested/shared"},
+		},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "DESTINATION_OVERLAP")
+	execResult, err = Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"test ! -e /app/nested"},
+	}))
+	require.NoError(t, err)
+	assert.NotContains(t, resultText(t, execResult), "Error:")
+}
+
+func TestCopyProjectDistrolessFallback(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, "gcr.io/distroless/python3-debian12:latest", "mcp-test-copy-distroless")
+	project := writeProjectFixture(t)
+
+	result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"local_src_dir":        project,
+	}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	require.Contains(t, text, "Successfully copied")
+	assert.Contains(t, text, "tar not available")
+
+	// No shell in the image, so read the file back through the Docker API
+	dest := filepath.Join(t.TempDir(), "main.py")
+	require.NoError(t, copySingleFileFromContainer(ctx, name, "/app/proj/proj/main.py", dest, newProgressReporter(ctx, newMockCallToolRequest("copy_file_from_sandbox", nil))))
+	contents, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "print('hi')\n", string(contents))
+}
+
+func TestCopyProjectOverwritePolicy(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-copy-overwrite")
+	project := writeProjectFixture(t)
+
+	copyProject := func(policy string) string {
+		args := map[string]interface{}{"container_id_or_name": name, "local_src_dir": project}
+		if policy != "" {
+			args["overwrite_policy"] = policy
+		}
+		result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", args))
+		require.NoError(t, err)
+		return resultText(t, result)
+	}
+	files := func() string {
+		execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+			"container_id_or_name": name,
+			"commands":             []interface{}{"cd /app/proj/proj && find . -type f | sort"},
+		}))
+		require.NoError(t, err)
+		return resultText(t, execResult)
+	}
+
+	assert.Contains(t, copyProject(""), "Overwrite policy: merge")
+
+	// A file deleted locally lingers under merge
+	require.NoError(t, os.Remove(filepath.Join(project, "main.py")))
+	text := copyProject("merge")
+	assert.Contains(t, text, "Successfully copied")
+	assert.Contains(t, files(), "./main.py")
+
+	// fail_if_exists leaves the destination alone
+	text = copyProject("fail_if_exists")
+	assert.Contains(t, text, "DESTINATION_EXISTS: /app/proj/proj already exists")
+	assert.NotContains(t, text, "Successfully copied")
+	assert.Contains(t, files(), "./main.py")
+
+	// replace clears main.py, pkg, pkg/sub and pkg/sub/run.sh before copying
+	text = copyProject("replace")
+	assert.Contains(t, text, "Overwrite policy: replace (removed 4 preexisting entries)")
+	listing := files()
+	assert.NotContains(t, listing, "./main.py")
+	assert.Contains(t, listing, "./pkg/sub/run.sh")
+
+	// A destination that doesn't exist yet copies under every policy
+	require.NoError(t, os.WriteFile(filepath.Join(project, "main.py"), []byte("print('again')\n"), 0644))
+	result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"local_src_dir":        project,
+		"dest_dir":             "fresh",
+		"overwrite_policy":     "fail_if_exists",
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "Overwrite policy: fail_if_exists")
+
+	// replace outside the working directory is refused
+	result, err = CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"local_src_dir":        project,
+		"dest_dir":             "/tmp",
+		"overwrite_policy":     "replace",
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "only removes destinations inside the container's working directory /app")
+
+	// copy_file applies the same policies to a single file; main.py has been gone from the sandbox since the replace
+	copyFile := func(policy string) string {
+		result, err := CopyFile(ctx, newMockCallToolRequest("copy_file", map[string]interface{}{
+			"container_id_or_name": name,
+			"local_src_file":       filepath.Join(project, "main.py"),
+			"dest_path":            "proj/proj/main.py",
+			"overwrite_policy":     policy,
+		}))
+		require.NoError(t, err)
+		return resultText(t, result)
+	}
+	assert.Contains(t, copyFile("fail_if_exists"), "Overwrite policy: fail_if_exists")
+	assert.Contains(t, copyFile("fail_if_exists"), "DESTINATION_EXISTS: /app/proj/proj/main.py already exists")
+	assert.Contains(t, copyFile("replace"), "Overwrite policy: replace (removed 1 preexisting entry)")
+}
+
+func TestWriteFileDistrolessMissingShell(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, "gcr.io/distroless/python3-debian12:latest", "mcp-test-write-distroless")
+
+	result, err := WriteFile(ctx, newMockCallToolRequest("write_file_sandbox", map[string]interface{}{
+		"container_id_or_name": name,
+		"file_name":            "hello.txt",
+		"file_contents":        "hello",
+	}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	assert.Contains(t, text, "MISSING_TOOL")
+	assert.Contains(t, text, "`sh`")
+}
+
+func TestExecExplainsOOMKill(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandboxWithArgs(t, map[string]interface{}{
+		"image":        "python:3.12-slim-bookworm",
+		"name":         "mcp-test-exec-oom",
+		"memory_limit": 64,
+	})
+
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{`python -c "x = bytearray(512 * 1024 * 1024); print(len(x))"`},
+	}))
+	require.NoError(t, err)
+	text := resultText(t, execResult)
+	assert.Contains(t, text, "Command exited with code 137")
+	assert.Contains(t, text, "out-of-memory killer (limit 64MB); consider raising memory_limit")
+}
+
+func TestExecLoginShell(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-exec-login")
+
+	exec := func(args map[string]interface{}) string {
+		args["container_id_or_name"] = name
+		result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", args))
+		require.NoError(t, err)
+		return resultText(t, result)
+	}
+
+	// The binary is only on PATH once the profile script has run
+	setup := exec(map[string]interface{}{"commands": []interface{}{
+		"mkdir -p /opt/greeter/bin",
+		"printf '#!/bin/sh\\necho hello from profile\\n' > /opt/greeter/bin/greet && chmod +x /opt/greeter/bin/greet",
+		"echo 'export PATH=/opt/greeter/bin:$PATH' > /etc/profile.d/greeter.sh",
+	}})
+	require.NotContains(t, setup, "Error")
+
+	assert.Contains(t, exec(map[string]interface{}{"commands": []interface{}{"greet"}}), "Command exited with code 127")
+	assert.Contains(t, exec(map[string]interface{}{"commands": []interface{}{"greet"}, "login_shell": true}), "hello from profile")
+	assert.Contains(t, exec(map[string]interface{}{"commands": []interface{}{"greet"}, "env_from_profile": true}), "hello from profile")
+}
+
+func TestCheckpointRollback(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-checkpoint")
+
+	exec := func(cmd string) string {
+		result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+			"container_id_or_name": name,
+			"commands":             []interface{}{cmd},
+		}))
+		require.NoError(t, err)
+		return resultText(t, result)
+	}
+
+	exec("echo precious > /app/data.txt")
+
+	checkpointResult, err := CheckpointSandbox(ctx, newMockCallToolRequest("sandbox_checkpoint", map[string]interface{}{
+		"container_id_or_name": name,
+	}))
+	require.NoError(t, err)
+	checkpointText := resultText(t, checkpointResult)
+	require.True(t, strings.HasPrefix(checkpointText, "checkpoint_id: "), checkpointText)
+	checkpointID := strings.TrimPrefix(checkpointText, "checkpoint_id: ")
+
+	exec("rm /app/data.txt")
+	assert.Contains(t, exec("ls /app/data.txt"), "Command exited with code")
+
+	listResult, err := ListCheckpoints(ctx, newMockCallToolRequest("sandbox_checkpoints_list", map[string]interface{}{
+		"container_id_or_name": name,
+	}))
+	require.NoError(t, err)
+	var checkpoints []CheckpointInfo
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, listResult)), &checkpoints))
+	require.Len(t, checkpoints, 1)
+	assert.Equal(t, checkpointID, checkpoints[0].CheckpointID)
+	assert.Equal(t, name, checkpoints[0].Sandbox)
+
+	rollbackResult, err := RollbackSandbox(ctx, newMockCallToolRequest("sandbox_rollback", map[string]interface{}{
+		"container_id_or_name": name,
+		"checkpoint_id":        checkpointID,
+	}))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(resultText(t, rollbackResult), "container_id: "), resultText(t, rollbackResult))
+
+	assert.Contains(t, exec("cat /app/data.txt"), "precious")
+}
+
+func TestDescribeSandbox(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-describe")
+
+	result, err := DescribeSandbox(ctx, newMockCallToolRequest("sandbox_describe", map[string]interface{}{
+		"container_id_or_name": name,
+	}))
+	require.NoError(t, err)
+	var desc SandboxDescription
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &desc), resultText(t, result))
+
+	assert.Equal(t, name, desc.Name)
+	assert.Equal(t, dockertest.Image, desc.Image)
+	assert.Equal(t, "/app", desc.WorkingDir)
+	assert.True(t, desc.State.Running)
+	assert.Equal(t, "running", desc.State.Status)
+	assert.NotEmpty(t, desc.ImageDigests)
+	assert.Equal(t, "true", desc.Labels[SandboxLabel])
+
+	// The short ID resolves to the same container
+	byID, err := DescribeSandbox(ctx, newMockCallToolRequest("sandbox_describe", map[string]interface{}{
+		"container_id_or_name": desc.ContainerID[:12],
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, byID), `"name":"`+name+`"`)
+}
+
+func TestInitializeUlimits(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandboxWithArgs(t, map[string]interface{}{
+		"image":   dockertest.Image,
+		"name":    "mcp-test-ulimits",
+		"ulimits": []interface{}{map[string]interface{}{"name": "nofile", "soft": float64(512), "hard": float64(768)}},
+	})
+
+	result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"ulimit -n", "ulimit -c"},
+	}))
+	require.NoError(t, err)
+	require.Len(t, result.Content, 3)
+	assert.Equal(t, "$ ulimit -n\n512\n", result.Content[0].(mcp.TextContent).Text)
+	// core dumps are disabled by the defaults
+	assert.Equal(t, "$ ulimit -c\n0\n", result.Content[1].(mcp.TextContent).Text)
+}
+
+func TestCompareSandboxWithHost(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-compare")
+	project := writeProjectFixture(t)
+
+	result, err := CopyProject(ctx, newMockCallToolRequest("copy_project", map[string]interface{}{
+		"container_id_or_name": name,
+		"local_src_dir":        project,
+		"dest_dir":             "/work",
+	}))
+	require.NoError(t, err)
+	require.Contains(t, resultText(t, result), "Successfully copied")
+
+	_, err = Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands": []interface{}{
+			"echo \"print('bye')\" >> /work/proj/main.py",
+			"echo new > /work/proj/new.txt",
+			"rm /work/proj/pkg/sub/run.sh",
+		},
+	}))
+	require.NoError(t, err)
+
+	result, err = CompareSandbox(ctx, newMockCallToolRequest("sandbox_compare", map[string]interface{}{
+		"container_id_or_name": name,
+		"container_path":       "/work/proj",
+		"local_path":           project,
+		"show_content_diff":    "main.py",
+	}))
+	require.NoError(t, err)
+
+	var comparison Comparison
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &comparison), resultText(t, result))
+	assert.Equal(t, []FileChange{{Path: "new.txt", Size: 4}}, comparison.Added)
+	require.Len(t, comparison.Removed, 1)
+	assert.Equal(t, "pkg/sub/run.sh", comparison.Removed[0].Path)
+	require.Len(t, comparison.Changed, 1)
+	assert.Equal(t, "main.py", comparison.Changed[0].Path)
+	assert.Contains(t, comparison.Diff, "+print('bye')\n")
+}
+
+func TestExecSanitizesOutput(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-exec-sanitize")
+
+	run := func(keepANSI bool) *mcp.CallToolResult {
+		result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+			"container_id_or_name": name,
+			"commands":             []interface{}{`printf '\033[31mred\033[0m \377\n'`},
+			"keep_ansi":            keepANSI,
+		}))
+		require.NoError(t, err)
+		return result
+	}
+
+	result := run(false)
+	require.Len(t, result.Content, 2)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "red �\n")
+	var summary execSummary
+	require.NoError(t, json.Unmarshal([]byte(result.Content[1].(mcp.TextContent).Text), &summary))
+	require.NotNil(t, summary.Sanitized)
+	assert.Equal(t, OutputSanitization{ANSISequences: 2, InvalidBytes: 1}, *summary.Sanitized)
+
+	result = run(true)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "\x1b[31mred\x1b[0m �\n")
+}
+
+func TestInitializeWithPackages(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandboxWithArgs(t, map[string]interface{}{
+		"image":    "python:3.12-slim-bookworm",
+		"name":     "mcp-test-init-packages",
+		"packages": []interface{}{"requests"},
+	})
+
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{`python -c "import requests; print('ok')"`},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, execResult), "ok\n")
+}
+
+func TestInitializePackageFailureRemovesContainer(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := "mcp-test-init-packages-fail"
+
+	result, err := InitializeEnvironment(ctx, newMockCallToolRequest("sandbox_initialize", map[string]interface{}{
+		"image":    "python:3.12-slim-bookworm",
+		"name":     name,
+		"packages": []interface{}{"this-package-does-not-exist-3f9a1c"},
+	}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	assert.Contains(t, text, "package installation failed, container removed")
+	assert.Contains(t, text, "pip install exited with code 1")
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	require.NoError(t, err)
+	defer cli.Close()
+	_, err = cli.ContainerInspect(ctx, name)
+	assert.True(t, client.IsErrNotFound(err), "container should have been removed")
+}
+
+func TestInitializeChecksRuntime(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := "mcp-test-init-runtime-missing"
+
+	result, err := InitializeEnvironment(ctx, newMockCallToolRequest("sandbox_initialize", map[string]interface{}{
+		"image":    dockertest.Image,
+		"name":     name,
+		"language": "python",
+	}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	assert.Contains(t, text, "RUNTIME_MISSING: image "+dockertest.Image+" has no python runtime for python")
+	assert.Contains(t, text, "such as "+DefaultImage)
+	assert.Contains(t, text, "container removed")
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	require.NoError(t, err)
+	defer cli.Close()
+	_, err = cli.ContainerInspect(ctx, name)
+	assert.True(t, client.IsErrNotFound(err), "container should have been removed")
+
+	// The check can be skipped, and an image that has the runtime reports its version
+	startSandboxWithArgs(t, map[string]interface{}{"image": dockertest.Image, "name": "mcp-test-init-runtime-skipped", "language": "python", "skip_validation": true})
+	result, err = InitializeEnvironment(ctx, newMockCallToolRequest("sandbox_initialize", map[string]interface{}{
+		"image":    "python:3.12-slim-bookworm",
+		"name":     "mcp-test-init-runtime-python",
+		"language": "python",
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		StopContainer(ctx, newMockCallToolRequest("sandbox_stop", map[string]interface{}{"container_id_or_name": "mcp-test-init-runtime-python"}))
+	})
+	assert.Contains(t, resultText(t, result), "\nRuntime: Python 3.12.")
+}
+
+func TestInitializeMountsInputFiles(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	csv := filepath.Join(t.TempDir(), "rows.csv")
+	require.NoError(t, os.WriteFile(csv, []byte("id,name\n1,a\n2,b\n3,c\n"), 0644))
+
+	name := "mcp-test-init-input-files"
+	result, err := InitializeEnvironment(ctx, newMockCallToolRequest("sandbox_initialize", map[string]interface{}{
+		"image":       DefaultImage,
+		"name":        name,
+		"input_files": []interface{}{csv},
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		StopContainer(ctx, newMockCallToolRequest("sandbox_stop", map[string]interface{}{"container_id_or_name": name}))
+	})
+	require.Contains(t, resultText(t, result), "\nInput file: /app/inputs/rows.csv (read-only, from ")
+
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands": []interface{}{
+			`python3 -c "import csv; print(len(list(csv.reader(open('/app/inputs/rows.csv')))) - 1)"`,
+			"touch /app/inputs/rows.csv",
+		},
+	}))
+	require.NoError(t, err)
+	text := resultText(t, execResult)
+	assert.Contains(t, text, "\n3\n")
+	assert.Contains(t, text, "Read-only file system")
+}
+
+func TestExecExpectJSON(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-exec-json")
+
+	result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands": []interface{}{
+			map[string]interface{}{"command": `echo '{"rows": 3}'; echo progress >&2`, "expect_json": true},
+			map[string]interface{}{"command": `echo '{"rows": 3}'; echo done`, "expect_json": true},
+			"echo plain",
+		},
+	}))
+	require.NoError(t, err)
+	require.Len(t, result.Content, 4)
+	assert.Contains(t, resultText(t, result), "$ echo '{\"rows\": 3}'; echo progress >&2\n{\"rows\": 3}\nstderr: progress\n")
+	assert.Contains(t, result.Content[1].(mcp.TextContent).Text, `JSON_PARSE_FAILED: unexpected "done" after the JSON value at line 2, column 1 (byte 12); stdout is shown as text above`)
+
+	structured, err := json.Marshal(result.StructuredContent)
+	require.NoError(t, err)
+	var summary struct {
+		ExitCodes []int      `json:"exit_codes"`
+		JSON      []execJSON `json:"json"`
+	}
+	require.NoError(t, json.Unmarshal(structured, &summary))
+	assert.Equal(t, []int{0, 0, 0}, summary.ExitCodes)
+	require.Len(t, summary.JSON, 1)
+	assert.Equal(t, 0, summary.JSON[0].Command)
+	assert.Equal(t, map[string]any{"rows": float64(3)}, summary.JSON[0].Value)
+}
+
+func TestStopRunsOnStopCommands(t *testing.T) {
+	cli := dockertest.Require(t)
+	ctx := context.Background()
+	stop := func(name string, onStop []interface{}, args map[string]interface{}) string {
+		result, err := InitializeEnvironment(ctx, newMockCallToolRequest("sandbox_initialize", map[string]interface{}{
+			"image":            dockertest.Image,
+			"name":             name,
+			"on_stop_commands": onStop,
+		}))
+		require.NoError(t, err)
+		require.Contains(t, resultText(t, result), "sandbox_stop runs")
+		args["container_id_or_name"] = name
+		result, err = StopContainer(ctx, newMockCallToolRequest("sandbox_stop", args))
+		require.NoError(t, err)
+		_, err = cli.ContainerInspect(ctx, name)
+		assert.True(t, client.IsErrNotFound(err), "the container is removed even when a hook fails")
+		return resultText(t, result)
+	}
+
+	text := stop("mcp-test-stop-hooks", []interface{}{"echo flushed > /tmp/marker && cat /tmp/marker", "echo failing >&2; exit 4", "echo never"}, map[string]interface{}{})
+	assert.Contains(t, text, "Successfully stopped and removed container: mcp-test-stop-hooks\nOn-stop commands:\n$ echo flushed > /tmp/marker && cat /tmp/marker\nflushed\n")
+	assert.Contains(t, text, "$ echo failing >&2; exit 4\nError: failing\nCommand exited with code 4")
+	assert.Contains(t, text, `Warning: on-stop command "echo failing >&2; exit 4" exited with code 4; stopping anyway`)
+	assert.NotContains(t, text, "never")
+
+	text = stop("mcp-test-stop-skip-hooks", []interface{}{"echo flushed"}, map[string]interface{}{"skip_hooks": true})
+	assert.NotContains(t, text, "On-stop commands")
+	assert.NotContains(t, text, "flushed")
+}
+
+func TestScheduleExec(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-schedule")
+
+	result, err := ScheduleExec(ctx, newMockCallToolRequest("schedule_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"command":              "echo scheduled; hostname >/dev/null",
+		"delay_seconds":        float64(2),
+	}))
+	require.NoError(t, err)
+	var job ScheduledJob
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &job), resultText(t, result))
+	assert.Equal(t, name, job.Container)
+
+	record := waitForScheduledRun(t, job.ID, 15*time.Second)
+	assert.Equal(t, 0, record.ExitCode)
+	assert.Contains(t, record.Output, "$ echo scheduled; hostname >/dev/null\nscheduled\n")
+}
+
+func TestExportLogs(t *testing.T) {
+	cli := dockertest.Require(t)
+	ctx := context.Background()
+	dockertest.Run(t, cli, "mcp-test-logs-export", nil, "sh", "-c", "yes 0123456789abcdef | head -c 5000000; echo failed >&2")
+	dest := filepath.Join(t.TempDir(), "server.log")
+
+	result, err := ExportLogs(ctx, newMockCallToolRequest("sandbox_logs_export", map[string]interface{}{
+		"container_id_or_name": "mcp-test-logs-export",
+		"local_dest_path":      dest,
+		"split_streams":        true,
+	}))
+	require.NoError(t, err)
+	text := resultText(t, result)
+	stdoutPath := filepath.Join(filepath.Dir(dest), "server.stdout.log")
+	assert.Contains(t, text, fmt.Sprintf("- stdout: %s, 5000000 bytes", stdoutPath))
+	info, err := os.Stat(stdoutPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000000), info.Size())
+	stderr, err := os.ReadFile(filepath.Join(filepath.Dir(dest), "server.stderr.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "failed\n", string(stderr))
+
+	// tail keeps the last lines only
+	result, err = ExportLogs(ctx, newMockCallToolRequest("sandbox_logs_export", map[string]interface{}{
+		"container_id_or_name": "mcp-test-logs-export",
+		"local_dest_path":      dest,
+		"tail":                 float64(1),
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), fmt.Sprintf("- stdout and stderr: %s, ", dest))
+	content, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Less(t, len(content), 100)
+}
+
+func TestCheckCode(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	check := func(code string) codeCheckResult {
+		t.Helper()
+		result, err := CheckCode(ctx, newMockCallToolRequest("check_code", map[string]interface{}{
+			"language": "python",
+			"code":     code,
+		}))
+		require.NoError(t, err)
+		var checked codeCheckResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &checked), resultText(t, result))
+		return checked
+	}
+
+	// The code is compiled, not run
+	checked := check("import sys\nsys.exit(3)\n")
+	assert.True(t, checked.OK)
+	assert.Empty(t, checked.Diagnostics)
+
+	checked = check("print('ok')\nx = (\n")
+	assert.False(t, checked.OK)
+	assert.Equal(t, []Diagnostic{{File: "main.py", Line: 2, Code: "SyntaxError", Message: "'(' was never closed"}}, checked.Diagnostics)
+}
+
+func TestInstallSystemPackages(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-system-packages")
+
+	result, err := InstallSystemPackages(ctx, newMockCallToolRequest("install_system_packages", map[string]interface{}{
+		"container_id_or_name": name,
+		"packages":             []interface{}{"jq"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Installed 1 system packages with apk: jq", resultText(t, result))
+
+	execResult, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{`echo '{"a": 1}' | jq .a`},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, execResult), "1\n")
+}
+
+func TestShellSessionEcho(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-shell")
+
+	result, err := ShellOpen(ctx, newMockCallToolRequest("sandbox_shell_open", map[string]interface{}{
+		"container_id_or_name": name,
+		"shell":                "cat",
+	}))
+	require.NoError(t, err)
+	var opened struct {
+		SessionID string `json:"session_id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &opened))
+
+	_, err = ShellInput(ctx, newMockCallToolRequest("sandbox_shell_input", map[string]interface{}{
+		"session_id": opened.SessionID,
+		"input":      "hello shell\n",
+	}))
+	require.NoError(t, err)
+
+	// The TTY echoes the input and cat writes it back, both with CRLF line endings
+	var output string
+	for i := 0; i < 10 && strings.Count(output, "hello shell") < 2; i++ {
+		result, err = ShellRead(ctx, newMockCallToolRequest("sandbox_shell_read", map[string]interface{}{
+			"session_id": opened.SessionID,
+			"wait_ms":    1000,
+		}))
+		require.NoError(t, err)
+		var read shellOutput
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &read))
+		output += read.Output
+	}
+	assert.Equal(t, "hello shell\r\nhello shell\r\n", output)
+
+	// Stopping the sandbox closes its sessions
+	_, err = StopContainer(ctx, newMockCallToolRequest("sandbox_stop", map[string]interface{}{
+		"container_id_or_name": name,
+	}))
+	require.NoError(t, err)
+	_, err = lookupShellSession(opened.SessionID)
+	assert.Error(t, err)
+}
+
+func TestCancelledRunCommandLeavesNoGoroutines(t *testing.T) {
+	dockertest.Require(t)
+	// Pull first so the run reaches the container before it is cancelled
+	_, err := RunCommand(context.Background(), newMockCallToolRequest("run_command", map[string]interface{}{
+		"image": dockertest.Image, "command": []interface{}{"true"}, "collect_stats": false,
+	}))
+	require.NoError(t, err)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	started := time.Now()
+	result, err := RunCommand(ctx, newMockCallToolRequest("run_command", map[string]interface{}{
+		"image": dockertest.Image, "command": []interface{}{"sleep", "60"},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "Error:")
+	assert.Less(t, time.Since(started), 30*time.Second)
+}
+
+func TestRunCommandSpans(t *testing.T) {
+	dockertest.Require(t)
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.UseTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { tracing.UseTracerProvider(noop.NewTracerProvider()) })
+
+	handler := tracing.Middleware(RunCommand)
+	_, err := handler(context.Background(), newMockCallToolRequest("run_command", map[string]interface{}{
+		"image": dockertest.Image, "command": "exit 3", "collect_stats": false,
+	}))
+	require.NoError(t, err)
+
+	// Every Docker step is a child of the call's span
+	spans := exporter.GetSpans()
+	require.NotEmpty(t, spans)
+	call := spans[len(spans)-1]
+	assert.Equal(t, "tools/call run_command", call.Name)
+	var children []string
+	for _, span := range spans[:len(spans)-1] {
+		assert.Equal(t, call.SpanContext.SpanID(), span.Parent.SpanID(), span.Name)
+		children = append(children, span.Name)
+		if span.Name == "container.wait" {
+			assert.Contains(t, span.Attributes, tracing.ExitCodeKey.Int(3))
+		}
+	}
+	assert.Equal(t, []string{"image.pull", "container.create", "container.start", "container.wait", "logs.collect"}, children)
+}
+
+func TestRunCommand(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+
+	run := func(args map[string]interface{}) commandResult {
+		result, err := RunCommand(ctx, newMockCallToolRequest("run_command", args))
+		require.NoError(t, err)
+		var out commandResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &out), resultText(t, result))
+		return out
+	}
 
-	execTextContent, ok := execResult.Content[0].(mcp.TextContent)
+	out := run(map[string]interface{}{"image": dockertest.Image, "command": []interface{}{"uname", "-a"}})
+	assert.Equal(t, 0, out.ExitCode)
+	assert.Contains(t, out.Stdout, "Linux")
+	for _, key := range []string{"image_pull_ms", "container_create_ms", "exec_ms", "log_collect_ms"} {
+		assert.Contains(t, out.Timing, key)
+	}
+	assert.NotContains(t, out.Timing, "install_ms")
+
+	// The run is recorded with a manifest that pins the image digest
+	assert.Contains(t, out.ImageDigest, "alpine@sha256:")
+	record, ok := LookupExecution(out.ExecutionID)
 	require.True(t, ok)
-	assert.Contains(t, execTextContent.Text, "hello world")
+	require.NotNil(t, record.Manifest)
+	assert.Equal(t, out.ImageDigest, record.Manifest.ImageDigest)
+	assert.Equal(t, []string{"uname", "-a"}, record.Manifest.Command)
+
+	rerun, err := RunFromManifest(ctx, newMockCallToolRequest("run_from_manifest", map[string]interface{}{"execution_id": out.ExecutionID}))
+	require.NoError(t, err)
+	var again commandResult
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, rerun)), &again), resultText(t, rerun))
+	assert.Equal(t, 0, again.ExitCode)
+	assert.Equal(t, out.Stdout, again.Stdout)
+	assert.Equal(t, out.ImageDigest, again.ImageDigest)
+
+	out = run(map[string]interface{}{
+		"image":   dockertest.Image,
+		"command": "echo $GREETING; pwd; echo oops >&2; exit 3",
+		"env":     map[string]interface{}{"GREETING": "hi"},
+		"workdir": "/tmp",
+		"network": "none",
+	})
+	assert.Equal(t, 3, out.ExitCode)
+	assert.Equal(t, "hi\n/tmp\n", out.Stdout)
+	assert.Equal(t, "oops\n", out.Stderr)
+
+	// A CPU-bound command reports its usage; one that opts out doesn't
+	out = run(map[string]interface{}{"image": dockertest.Image, "command": "i=0; while [ $i -lt 3000000 ]; do i=$((i+1)); done"})
+	require.NotNil(t, out.Usage)
+	assert.Greater(t, out.Usage.WallSeconds, 0.0)
+	assert.Greater(t, out.Usage.CPUSeconds, 0.0)
+	assert.Greater(t, out.Usage.PeakMemoryBytes, uint64(0))
+	out = run(map[string]interface{}{"image": dockertest.Image, "command": "true", "collect_stats": false})
+	assert.Nil(t, out.Usage)
+
+	out = run(map[string]interface{}{"image": dockertest.Image, "command": "echo started; sleep 30", "timeout_seconds": 1})
+	assert.True(t, out.TimedOut)
+	assert.Equal(t, "started\n", out.Stdout)
+
+	// Nothing is left behind
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	require.NoError(t, err)
+	defer cli.Close()
+	containers, err := ListSandboxContainers(ctx, cli, true, false)
+	require.NoError(t, err)
+	for _, c := range containers {
+		assert.NotEqual(t, dockertest.Image, c.Image, "run_command left container %s behind", c.ID)
+	}
+}
+
+func TestExecPastDeadlineReturnsPartialOutput(t *testing.T) {
+	dockertest.Require(t)
+	name := startSandbox(t, dockertest.Image, "mcp-test-exec-deadline")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"echo started; sleep 5; echo finished"},
+	}))
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	section := result.Content[0].(mcp.TextContent).Text
+	assert.Contains(t, section, "started\n")
+	assert.NotContains(t, section, "finished")
+	assert.Contains(t, section, "Watchdog: the tool call reached its deadline")
+
+	var summary execSummary
+	require.NoError(t, json.Unmarshal([]byte(result.Content[1].(mcp.TextContent).Text), &summary))
+	assert.True(t, summary.Running)
+	assert.Equal(t, []int{-1}, summary.ExitCodes)
+
+	// The record is completed once the command exits
+	require.Eventually(t, func() bool {
+		record, ok := LookupExecution(summary.ExecutionID)
+		return ok && !record.Running
+	}, 10*time.Second, 100*time.Millisecond)
+	record, _ := LookupExecution(summary.ExecutionID)
+	assert.Equal(t, 0, record.ExitCode)
+	assert.Contains(t, record.Output, "started\nfinished\n")
+}
+
+func TestWriteFileCustomWorkDir(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandboxWithArgs(t, map[string]interface{}{
+		"image":   dockertest.Image,
+		"name":    "mcp-test-workdir",
+		"workdir": "/usr/src/app",
+	})
+
+	result, err := WriteFile(ctx, newMockCallToolRequest("write_file_sandbox", map[string]interface{}{
+		"container_id_or_name": name,
+		"file_name":            "hello.txt",
+		"file_contents":        "hello from the workdir\n",
+		"dest_dir":             "notes",
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "/usr/src/app/notes/hello.txt")
+	assert.Contains(t, resultText(t, result), "(working directory /usr/src/app)")
+
+	result, err = Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"cat /usr/src/app/notes/hello.txt"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "$ cat /usr/src/app/notes/hello.txt\nhello from the workdir\n", resultText(t, result))
+}
+
+func TestInitializeLimitsSetRuntimeHints(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandboxWithArgs(t, map[string]interface{}{
+		"image":        dockertest.Image,
+		"name":         "mcp-test-limits",
+		"cpu_limit":    1.5,
+		"memory_limit": 256,
+	})
+
+	result, err := Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{`echo "$GOMAXPROCS $OMP_NUM_THREADS $NODE_OPTIONS"`},
+	}))
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, "$ echo \"$GOMAXPROCS $OMP_NUM_THREADS $NODE_OPTIONS\"\n2 2 --max-old-space-size=192\n", result.Content[0].(mcp.TextContent).Text)
+
+	var summary execSummary
+	require.NoError(t, json.Unmarshal([]byte(result.Content[1].(mcp.TextContent).Text), &summary))
+	require.NotNil(t, summary.Limits)
+	assert.Equal(t, SandboxLimits{CPUs: 1.5, MemoryBytes: 256 * 1024 * 1024}, *summary.Limits)
+}
+
+func TestWriteFilesBatch(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-write-files")
+
+	result, err := WriteFiles(ctx, newMockCallToolRequest("write_files_sandbox", map[string]interface{}{
+		"container_id_or_name": name,
+		"files": []interface{}{
+			map[string]interface{}{"path": "README.md", "contents": "# demo\n"},
+			map[string]interface{}{"path": "src/app/main.py", "contents": "print('hi')\n"},
+			map[string]interface{}{"path": "src/app/__init__.py", "contents": ""},
+			map[string]interface{}{"path": "data/blob.bin", "contents": "AAEC", "encoding": "base64"},
+			map[string]interface{}{"path": "run.sh", "contents": "#!/bin/sh\necho running\n", "mode": "0755"},
+		},
+	}))
+	require.NoError(t, err)
+	var written struct {
+		WorkingDirectory string        `json:"working_directory"`
+		Files            []writtenFile `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &written))
+	assert.Equal(t, "/app", written.WorkingDirectory)
+	assert.Equal(t, []writtenFile{
+		{Path: "/app/README.md", Bytes: 7},
+		{Path: "/app/src/app/main.py", Bytes: 12},
+		{Path: "/app/src/app/__init__.py", Bytes: 0},
+		{Path: "/app/data/blob.bin", Bytes: 3},
+		{Path: "/app/run.sh", Bytes: 23},
+	}, written.Files)
+
+	result, err = Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"cat /app/src/app/main.py", "wc -c < /app/data/blob.bin", "/app/run.sh"},
+	}))
+	require.NoError(t, err)
+	require.Len(t, result.Content, 4)
+	assert.Equal(t, "$ cat /app/src/app/main.py\nprint('hi')\n", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, "$ wc -c < /app/data/blob.bin\n3\n", result.Content[1].(mcp.TextContent).Text)
+	assert.Equal(t, "$ /app/run.sh\nrunning\n", result.Content[2].(mcp.TextContent).Text)
+
+	// A bad entry rejects the whole batch before anything is written
+	result, err = WriteFiles(ctx, newMockCallToolRequest("write_files_sandbox", map[string]interface{}{
+		"container_id_or_name": name,
+		"files": []interface{}{
+			map[string]interface{}{"path": "never.txt", "contents": "x"},
+			map[string]interface{}{"path": "bad.txt", "contents": "x", "mode": "rwx"},
+		},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, resultText(t, result), "files[1].mode")
+	result, err = Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"test ! -e /app/never.txt"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "$ test ! -e /app/never.txt\n", result.Content[0].(mcp.TextContent).Text)
+}
+
+func TestScaffoldSandbox(t *testing.T) {
+	dockertest.Require(t)
+	ctx := context.Background()
+	name := startSandbox(t, dockertest.Image, "mcp-test-scaffold")
+
+	result, err := ScaffoldSandbox(ctx, newMockCallToolRequest("scaffold_sandbox", map[string]interface{}{
+		"container_id_or_name": name,
+		"template":             "python/fastapi-app",
+		"dest_dir":             "api",
+		"variables":            map[string]interface{}{"project_name": "orders"},
+	}))
+	require.NoError(t, err)
+	var created struct {
+		DestDir string        `json:"dest_dir"`
+		Files   []writtenFile `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &created))
+	assert.Equal(t, "/app/api", created.DestDir)
+	var paths []string
+	for _, f := range created.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Contains(t, paths, "/app/api/main.py")
+	assert.Contains(t, paths, "/app/api/tests/test_main.py")
+	assert.NotContains(t, paths, "/app/api/template.json")
+
+	result, err = Exec(ctx, newMockCallToolRequest("sandbox_exec", map[string]interface{}{
+		"container_id_or_name": name,
+		"commands":             []interface{}{"head -n 1 /app/api/README.md"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "$ head -n 1 /app/api/README.md\n# orders\n", result.Content[0].(mcp.TextContent).Text)
 }
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ulimitNames are the resource names Docker accepts for --ulimit
+var ulimitNames = []string{
+	"as", "core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice",
+	"nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack",
+}
+
+// defaultUlimitSpec keeps untrusted code from exhausting file descriptors and disables core dumps so
+// crashes can't fill the disk; override it with SANDBOX_DEFAULT_ULIMITS. Processes are capped with
+// defaultPidsLimit instead of nproc, which counts every process of the UID across the whole host.
+const defaultUlimitSpec = "nofile=1024,core=0"
+
+// defaultUlimits apply to every sandbox unless a call overrides them by name
+var defaultUlimits = mustParseUlimitSpec(defaultUlimitSpec)
+
+// LoadUlimitDefaultsFromEnv replaces the default ulimits with SANDBOX_DEFAULT_ULIMITS, a comma-separated
+// list of name=soft[:hard] entries; an empty value disables the defaults
+func LoadUlimitDefaultsFromEnv() error {
+	spec, ok := os.LookupEnv("SANDBOX_DEFAULT_ULIMITS")
+	if !ok {
+		return nil
+	}
+	ulimits, err := parseUlimitSpec(spec)
+	if err != nil {
+		return fmt.Errorf("invalid SANDBOX_DEFAULT_ULIMITS: %w", err)
+	}
+	defaultUlimits = ulimits
+	return nil
+}
+
+func mustParseUlimitSpec(spec string) []*container.Ulimit {
+	ulimits, err := parseUlimitSpec(spec)
+	if err != nil {
+		panic(err)
+	}
+	return ulimits
+}
+
+// parseUlimitSpec parses "nofile=1024:2048,core=0"; a missing hard limit equals the soft one
+func parseUlimitSpec(spec string) ([]*container.Ulimit, error) {
+	var ulimits []*container.Ulimit
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limits, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("expected name=soft[:hard], got %q", entry)
+		}
+		softStr, hardStr, hasHard := strings.Cut(limits, ":")
+		soft, err := strconv.ParseInt(strings.TrimSpace(softStr), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid soft limit in %q", entry)
+		}
+		hard := soft
+		if hasHard {
+			if hard, err = strconv.ParseInt(strings.TrimSpace(hardStr), 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid hard limit in %q", entry)
+			}
+		}
+		ulimit, err := newUlimit(strings.TrimSpace(name), soft, hard)
+		if err != nil {
+			return nil, err
+		}
+		ulimits = append(ulimits, ulimit)
+	}
+	return ulimits, nil
+}
+
+// parseUlimitsArgument parses the ulimits tool argument, an array of {name, soft, hard} objects
+func parseUlimitsArgument(value any) ([]*container.Ulimit, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("ulimits must be an array of {name, soft, hard} objects")
+	}
+
+	var ulimits []*container.Ulimit
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("ulimits[%d] must be an object with name, soft and optional hard", i)
+		}
+		name, _ := obj["name"].(string)
+		soft, ok := ulimitValue(obj["soft"])
+		if !ok {
+			return nil, fmt.Errorf("ulimits[%d].soft must be a number", i)
+		}
+		hard := soft
+		if h, present := obj["hard"]; present {
+			if hard, ok = ulimitValue(h); !ok {
+				return nil, fmt.Errorf("ulimits[%d].hard must be a number", i)
+			}
+		}
+		ulimit, err := newUlimit(name, soft, hard)
+		if err != nil {
+			return nil, fmt.Errorf("ulimits[%d]: %w", i, err)
+		}
+		ulimits = append(ulimits, ulimit)
+	}
+	return ulimits, nil
+}
+
+// ulimitValue accepts JSON numbers and the integers YAML templates decode to
+func ulimitValue(v any) (int64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), n == float64(int64(n))
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// newUlimit validates a ulimit; -1 means unlimited
+func newUlimit(name string, soft, hard int64) (*container.Ulimit, error) {
+	if !isUlimitName(name) {
+		return nil, fmt.Errorf("unknown ulimit %q: recognized names are %s", name, strings.Join(ulimitNames, ", "))
+	}
+	if soft < -1 || hard < -1 {
+		return nil, fmt.Errorf("ulimit %s must not be below -1 (unlimited)", name)
+	}
+	if hard != -1 && (soft == -1 || soft > hard) {
+		return nil, fmt.Errorf("ulimit %s: soft limit %d exceeds hard limit %d", name, soft, hard)
+	}
+	return &container.Ulimit{Name: name, Soft: soft, Hard: hard}, nil
+}
+
+func isUlimitName(name string) bool {
+	for _, known := range ulimitNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeUlimits overlays requested ulimits on the defaults by name, sorted by name
+func mergeUlimits(defaults, requested []*container.Ulimit) []*container.Ulimit {
+	byName := map[string]*container.Ulimit{}
+	for _, u := range defaults {
+		byName[u.Name] = u
+	}
+	for _, u := range requested {
+		byName[u.Name] = u
+	}
+
+	merged := make([]*container.Ulimit, 0, len(byName))
+	for _, u := range byName {
+		merged = append(merged, u)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUlimitSpec(t *testing.T) {
+	ulimits, err := parseUlimitSpec("nofile=1024:2048, core=0,stack=-1")
+	require.NoError(t, err)
+	assert.Equal(t, []*container.Ulimit{
+		{Name: "nofile", Soft: 1024, Hard: 2048},
+		{Name: "core", Soft: 0, Hard: 0},
+		{Name: "stack", Soft: -1, Hard: -1},
+	}, ulimits)
+
+	ulimits, err = parseUlimitSpec("")
+	require.NoError(t, err)
+	assert.Empty(t, ulimits)
+
+	_, err = parseUlimitSpec("nofile")
+	assert.ErrorContains(t, err, "expected name=soft[:hard]")
+	_, err = parseUlimitSpec("nofile=lots")
+	assert.ErrorContains(t, err, "invalid soft limit")
+}
+
+func TestParseUlimitsArgument(t *testing.T) {
+	ulimits, err := parseUlimitsArgument([]any{
+		map[string]any{"name": "nofile", "soft": float64(4096), "hard": float64(8192)},
+		map[string]any{"name": "stack", "soft": -1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*container.Ulimit{
+		{Name: "nofile", Soft: 4096, Hard: 8192},
+		{Name: "stack", Soft: -1, Hard: -1},
+	}, ulimits)
+
+	ulimits, err = parseUlimitsArgument(nil)
+	require.NoError(t, err)
+	assert.Nil(t, ulimits)
+}
+
+func TestParseUlimitsArgumentErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"not an array", "nofile=1", "must be an array"},
+		{"not an object", []any{"nofile"}, "ulimits[0] must be an object"},
+		{"unknown name", []any{map[string]any{"name": "files", "soft": float64(1)}}, "unknown ulimit \"files\": recognized names are as, core"},
+		{"missing soft", []any{map[string]any{"name": "nofile"}}, "ulimits[0].soft must be a number"},
+		{"soft above hard", []any{map[string]any{"name": "nofile", "soft": float64(10), "hard": float64(5)}}, "soft limit 10 exceeds hard limit 5"},
+		{"below unlimited", []any{map[string]any{"name": "core", "soft": float64(-2)}}, "must not be below -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseUlimitsArgument(tt.value)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestMergeUlimits(t *testing.T) {
+	merged := mergeUlimits(defaultUlimits, []*container.Ulimit{{Name: "nofile", Soft: 4096, Hard: 4096}, {Name: "stack", Soft: -1, Hard: -1}})
+	assert.Equal(t, []*container.Ulimit{
+		{Name: "core", Soft: 0, Hard: 0},
+		{Name: "nofile", Soft: 4096, Hard: 4096},
+		{Name: "stack", Soft: -1, Hard: -1},
+	}, merged)
+}
+
+func TestSandboxHostConfigLimitsProcesses(t *testing.T) {
+	// Processes are capped by the sandbox's own PID cgroup rather than nproc, which counts the whole UID
+	hostConfig := sandboxHostConfig(0)
+	require.NotNil(t, hostConfig.PidsLimit)
+	assert.Equal(t, int64(defaultPidsLimit), *hostConfig.PidsLimit)
+	for _, ulimit := range defaultUlimits {
+		assert.NotEqual(t, "nproc", ulimit.Name)
+	}
+}
+
+func TestLoadUlimitDefaultsFromEnv(t *testing.T) {
+	previous := defaultUlimits
+	t.Cleanup(func() { defaultUlimits = previous })
+
+	t.Setenv("SANDBOX_DEFAULT_ULIMITS", "nofile=64")
+	require.NoError(t, LoadUlimitDefaultsFromEnv())
+	assert.Equal(t, []*container.Ulimit{{Name: "nofile", Soft: 64, Hard: 64}}, defaultUlimits)
+
+	t.Setenv("SANDBOX_DEFAULT_ULIMITS", "bogus=1")
+	assert.ErrorContains(t, LoadUlimitDefaultsFromEnv(), "invalid SANDBOX_DEFAULT_ULIMITS")
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// OrUnitString lets a number property of a duration or size also take a string with units, such as "5m" or
+// "512m", so a model doesn't have to guess the unit of a bare number. Its schema type becomes ["number", "string"].
+func OrUnitString() mcp.PropertyOption {
+	return func(schema map[string]any) {
+		schema["type"] = []string{"number", "string"}
+	}
+}
+
+// durationUnitNames and sizeUnitNames name the units of plain numbers in error messages
+var (
+	durationUnitNames = map[time.Duration]string{time.Millisecond: "milliseconds", time.Second: "seconds"}
+	sizeUnitNames     = map[int64]string{1: "bytes", 1 << 10: "kilobytes", 1 << 20: "megabytes"}
+)
+
+// sizeUnits are the multipliers of size suffixes. As in Docker, k, m and g are powers of 1024 with or without
+// a trailing b.
+var sizeUnits = map[string]int64{
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+// sizeString is a number with an optional unit, e.g. "512m", "1.5 GB" or "100kb"
+var sizeString = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-z]*)$`)
+
+// numberValue reads a number as JSON (float64) or YAML (int, int64) decodes it
+func numberValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// parseDurationArgument reads a duration argument, given as a number of unit or as a Go duration string such
+// as "90s", "5m" or "1h30m". A number in a string counts as a number; an omitted argument is 0.
+func parseDurationArgument(field string, value any, unit time.Duration) (time.Duration, error) {
+	invalid := &InvalidArgumentError{Field: field, Expected: fmt.Sprintf("a number of %s or a duration such as 90s, 5m or 2h", durationUnitNames[unit]), Got: value}
+	n, isNumber := numberValue(value)
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		s := strings.TrimSpace(v)
+		var err error
+		if n, err = strconv.ParseFloat(s, 64); err == nil {
+			isNumber = true
+			break
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, invalid
+		}
+		return d, nil
+	}
+	if !isNumber || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) || n*float64(unit) >= math.MaxInt64 {
+		return 0, invalid
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// parseSizeArgument reads a size argument, given as a number of unit bytes or as a string with a suffix such
+// as "100kb", "512m" or "2g". A number in a string counts as a number; an omitted argument is 0.
+func parseSizeArgument(field string, value any, unit int64) (int64, error) {
+	invalid := &InvalidArgumentError{Field: field, Expected: fmt.Sprintf("a number of %s or a size such as 100kb, 512m or 2g", sizeUnitNames[unit]), Got: value}
+	var n float64
+	multiplier := unit
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64, int, int64:
+		n, _ = numberValue(v)
+	case string:
+		m := sizeString.FindStringSubmatch(strings.ToLower(strings.TrimSpace(v)))
+		if m == nil {
+			return 0, invalid
+		}
+		n, _ = strconv.ParseFloat(m[1], 64)
+		if m[2] != "" {
+			var ok bool
+			if multiplier, ok = sizeUnits[m[2]]; !ok {
+				return 0, invalid
+			}
+		}
+	default:
+		return 0, invalid
+	}
+	bytes := n * float64(multiplier)
+	if n < 0 || math.IsInf(n, 0) || math.IsNaN(n) || bytes >= math.MaxInt64 {
+		return 0, invalid
+	}
+	return int64(bytes), nil
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDurationArgument(t *testing.T) {
+	valid := []struct {
+		value any
+		unit  time.Duration
+		want  time.Duration
+	}{
+		{nil, time.Second, 0},
+		{float64(0), time.Second, 0},
+		{float64(60), time.Second, time.Minute},
+		{int(60), time.Second, time.Minute},
+		{int64(60), time.Second, time.Minute},
+		{float64(1.5), time.Second, 1500 * time.Millisecond},
+		{float64(250), time.Millisecond, 250 * time.Millisecond},
+		{"60", time.Second, time.Minute},
+		{" 2.5 ", time.Second, 2500 * time.Millisecond},
+		{"90s", time.Second, 90 * time.Second},
+		{"5m", time.Second, 5 * time.Minute},
+		{"2h", time.Second, 2 * time.Hour},
+		{"1h30m", time.Second, 90 * time.Minute},
+		{"500ms", time.Second, 500 * time.Millisecond},
+		{"5s", time.Millisecond, 5 * time.Second},
+		{"0s", time.Second, 0},
+	}
+	for _, c := range valid {
+		got, err := parseDurationArgument("timeout_seconds", c.value, c.unit)
+		require.NoError(t, err, "%#v", c.value)
+		assert.Equal(t, c.want, got, "%#v", c.value)
+	}
+
+	for _, value := range []any{"", "soon", "5 minutes", "5M", "1d", "-5m", "-1", float64(-1), "NaN", "Inf", math.Inf(1), float64(1e12), true, []any{"5m"}, map[string]any{}} {
+		_, err := parseDurationArgument("timeout_seconds", value, time.Second)
+		var invalid *InvalidArgumentError
+		require.ErrorAs(t, err, &invalid, "%#v", value)
+		assert.Equal(t, "timeout_seconds", invalid.Field)
+	}
+
+	_, err := parseDurationArgument("wait_ms", "later", time.Millisecond)
+	assert.EqualError(t, err, `INVALID_ARGUMENT: wait_ms must be a number of milliseconds or a duration such as 90s, 5m or 2h, got the string "later"`)
+	_, err = parseDurationArgument("timeout_seconds", "-1", time.Second)
+	assert.EqualError(t, err, `INVALID_ARGUMENT: timeout_seconds must be a number of seconds or a duration such as 90s, 5m or 2h, got the string "-1"`)
+}
+
+func TestParseSizeArgument(t *testing.T) {
+	valid := []struct {
+		value any
+		unit  int64
+		want  int64
+	}{
+		{nil, 1 << 20, 0},
+		{float64(0), 1 << 20, 0},
+		{float64(512), 1 << 20, 512 << 20},
+		{int(512), 1 << 20, 512 << 20},
+		{int64(512), 1 << 20, 512 << 20},
+		{float64(0.5), 1 << 20, 512 << 10},
+		{float64(100), 1, 100},
+		{"512", 1 << 20, 512 << 20},
+		{"512m", 1 << 20, 512 << 20},
+		{"512M", 1, 512 << 20},
+		{"512mb", 1 << 20, 512 << 20},
+		{"512 MiB", 1 << 20, 512 << 20},
+		{"2g", 1 << 20, 2 << 30},
+		{"1.5GB", 1 << 20, 3 << 29},
+		{"100kb", 1 << 20, 100 << 10},
+		{"100k", 1, 100 << 10},
+		{"4096b", 1 << 20, 4096},
+		{"1t", 1, 1 << 40},
+		{" 64m ", 1 << 20, 64 << 20},
+	}
+	for _, c := range valid {
+		got, err := parseSizeArgument("memory_limit", c.value, c.unit)
+		require.NoError(t, err, "%#v", c.value)
+		assert.Equal(t, c.want, got, "%#v", c.value)
+	}
+
+	for _, value := range []any{"", "lots", "m", "512x", "512 m b", "-512m", "0x10", "1e3m", "9999999999t", float64(-1), math.Inf(1), float64(1e30), true, []any{"512m"}} {
+		_, err := parseSizeArgument("memory_limit", value, 1<<20)
+		var invalid *InvalidArgumentError
+		require.ErrorAs(t, err, &invalid, "%#v", value)
+		assert.Equal(t, "memory_limit", invalid.Field)
+	}
+
+	_, err := parseSizeArgument("memory_limit", "lots", 1<<20)
+	assert.EqualError(t, err, `INVALID_ARGUMENT: memory_limit must be a number of megabytes or a size such as 100kb, 512m or 2g, got the string "lots"`)
+}
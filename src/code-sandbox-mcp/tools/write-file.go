@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"path/filepath"
+	"path"
 	"strings"
-	"time"
 
+	"github.com/Automata-Labs-team/code-sandbox-mcp/dockerdebug"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -20,6 +20,10 @@ func WriteFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolR
 	if err != nil {
 		return mcp.NewToolResultText("container_id_or_name is required"), nil
 	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 
 	fileName, err := request.RequireString("file_name")
 	if err != nil {
@@ -30,21 +34,32 @@ func WriteFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolR
 	if err != nil {
 		return mcp.NewToolResultText("file_contents is required"), nil
 	}
+	data, err := decodeContents("file_contents", "encoding", fileContents, request.GetString("encoding", ""))
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 
-	// Get the destination path (optional parameter)
-	destDir := request.GetString("dest_dir", "")
-	if destDir == "" {
-		// Default: write to the working directory
-		destDir = "/app"
-	} else {
-		// If provided but doesn't start with /, prepend /app/
-		if !strings.HasPrefix(destDir, "/") {
-			destDir = filepath.Join("/app", destDir)
-		}
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
 	}
 
+	// Get the destination path (optional parameter), defaulting to the working directory
+	destDir := inWorkDir(workDir, request.GetString("dest_dir", ""))
+
 	// Full path to the file
-	fullPath := filepath.Join(destDir, fileName)
+	fullPath := inWorkDir(destDir, fileName)
+	destDir = path.Dir(fullPath)
+
+	// Writing goes through `sh -c "cat > file"`, so fail early on images that lack either
+	hint := "use a base image that ships a shell (e.g. a -slim or alpine variant), or copy_file/copy_project which work without one"
+	if err := requireCommand(ctx, containerIDOrName, "sh", hint); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+	if err := requireCommand(ctx, containerIDOrName, "cat", hint); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
 
 	// Create the directory if it doesn't exist
 	if err := ensureDirectoryExists(ctx, containerIDOrName, destDir); err != nil {
@@ -52,11 +67,11 @@ func WriteFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolR
 	}
 
 	// Write the file
-	if err := writeFileToContainer(ctx, containerIDOrName, fullPath, fileContents); err != nil {
+	if err := writeFileToContainer(ctx, containerIDOrName, fullPath, string(data)); err != nil {
 		return mcp.NewToolResultText(fmt.Sprintf("Error writing file: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully wrote file %s to container %s", fullPath, containerIDOrName)), nil
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully wrote file %s to container %s (working directory %s)", fullPath, containerIDOrName, workDir)), nil
 }
 
 // ensureDirectoryExists creates a directory in the container if it doesn't already exist
@@ -64,6 +79,7 @@ func ensureDirectoryExists(ctx context.Context, containerIDOrName, dirPath strin
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -91,6 +107,7 @@ func writeFileToContainer(ctx context.Context, containerIDOrName, filePath, cont
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
+		dockerdebug.ClientOpt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
@@ -109,15 +126,17 @@ func writeFileToContainer(ctx context.Context, containerIDOrName, filePath, cont
 	}
 
 	// Create the exec instance
-	execIDResp, err := cli.ContainerExecCreate(ctx, containerIDOrName, execConfig)
+	attachCtx, cancel := withDockerTimeout(ctx, execAttachTimeout)
+	defer cancel()
+	execIDResp, err := cli.ContainerExecCreate(attachCtx, containerIDOrName, execConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create exec: %w", err)
 	}
 
 	// Attach to the exec instance
-	resp, err := cli.ContainerExecAttach(ctx, execIDResp.ID, container.ExecAttachOptions{})
+	resp, err := cli.ContainerExecAttach(attachCtx, execIDResp.ID, container.ExecAttachOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to attach to exec: %w", err)
+		return fmt.Errorf("failed to attach to exec: %w", dockerTimeoutError(ctx, "attaching to the exec", execAttachTimeout, err))
 	}
 	defer resp.Close()
 
@@ -129,20 +148,5 @@ func writeFileToContainer(ctx context.Context, containerIDOrName, filePath, cont
 	resp.CloseWrite()
 
 	// Wait for the command to complete
-	for {
-		inspect, err := cli.ContainerExecInspect(ctx, execIDResp.ID)
-		if err != nil {
-			return fmt.Errorf("failed to inspect exec: %w", err)
-		}
-		if !inspect.Running {
-			if inspect.ExitCode != 0 {
-				return fmt.Errorf("command exited with code %d", inspect.ExitCode)
-			}
-			break
-		}
-		// Small sleep to avoid hammering the Docker API
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	return nil
+	return waitForExec(ctx, cli, execIDResp.ID)
 }
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// batchFile is one validated entry of write_files_sandbox
+type batchFile struct {
+	Path string // absolute path in the container
+	Data []byte
+	Mode int64
+}
+
+// writtenFile reports one file written by write_files_sandbox
+type writtenFile struct {
+	Path  string `json:"path"`
+	Bytes int    `json:"bytes"`
+}
+
+// WriteFiles writes several files to the container's filesystem with a single archive upload
+func WriteFiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters using new API
+	containerIDOrName, err := request.RequireString("container_id_or_name")
+	if err != nil {
+		return mcp.NewToolResultText("container_id_or_name is required"), nil
+	}
+	containerIDOrName, err = ResolveContainer(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Relative paths are resolved against the container's working directory
+	workDir, err := lookupWorkDir(ctx, containerIDOrName)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Every entry is validated before anything is written, so a bad entry leaves the container untouched
+	files, err := parseFilesArgument(request.GetArguments()["files"], workDir)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// OS, cache and editor junk is left out unless asked for
+	var skipped int
+	if !request.GetBool("include_junk", false) {
+		files, skipped = withoutJunkFiles(files)
+	}
+
+	if len(files) > 0 {
+		archive, err := filesToTar(files, time.Now())
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		}
+		if err := copyArchiveToContainer(ctx, containerIDOrName, archive); err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Error copying to container: %v", err)), nil
+		}
+	}
+
+	written := make([]writtenFile, 0, len(files))
+	for _, f := range files {
+		written = append(written, writtenFile{Path: f.Path, Bytes: len(f.Data)})
+	}
+	result := map[string]interface{}{"working_directory": workDir, "files": written}
+	if skipped > 0 {
+		result["skipped_junk"] = skipped
+	}
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("JSON_SERIALIZE_ERROR: failed to serialize written files: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// parseFilesArgument parses the files tool argument, an array of {path, contents, encoding, mode} objects
+func parseFilesArgument(value any, workDir string) ([]batchFile, error) {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("files must be a non-empty array of {path, contents, encoding, mode} objects")
+	}
+
+	files := make([]batchFile, 0, len(items))
+	seen := map[string]bool{}
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("files[%d] must be an object with path and contents", i)
+		}
+
+		p, _ := obj["path"].(string)
+		if p == "" || strings.HasSuffix(p, "/") {
+			return nil, fmt.Errorf("files[%d].path must name a file", i)
+		}
+		fullPath := inWorkDir(workDir, p)
+		if fullPath == "/" {
+			return nil, fmt.Errorf("files[%d].path must name a file", i)
+		}
+		if seen[fullPath] {
+			return nil, fmt.Errorf("files[%d]: %s is listed more than once", i, fullPath)
+		}
+		seen[fullPath] = true
+
+		contents, ok := obj["contents"].(string)
+		if !ok {
+			return nil, fmt.Errorf("files[%d].contents must be a string", i)
+		}
+		encoding, _ := obj["encoding"].(string)
+		data, err := decodeContents(fmt.Sprintf("files[%d].contents", i), fmt.Sprintf("files[%d].encoding", i), contents, encoding)
+		if err != nil {
+			return nil, err
+		}
+
+		mode, err := fileModeValue(obj["mode"])
+		if err != nil {
+			return nil, fmt.Errorf("files[%d].mode %v", i, err)
+		}
+
+		files = append(files, batchFile{Path: fullPath, Data: data, Mode: mode})
+	}
+	return files, nil
+}
+
+// withoutJunkFiles drops the files with a path segment in junkNames and returns how many it dropped
+func withoutJunkFiles(files []batchFile) ([]batchFile, int) {
+	kept := files[:0:0]
+	for _, f := range files {
+		if !isJunkPath(f.Path) {
+			kept = append(kept, f)
+		}
+	}
+	return kept, len(files) - len(kept)
+}
+
+// fileModeValue reads a permission mode written in octal, as a string ("0755") or as the number 755;
+// a missing mode is 0644
+func fileModeValue(v any) (int64, error) {
+	var digits string
+	switch m := v.(type) {
+	case nil:
+		return 0644, nil
+	case string:
+		digits = m
+	case float64:
+		if m != float64(int64(m)) {
+			return 0, fmt.Errorf("must be an octal permission such as \"0755\"")
+		}
+		digits = strconv.FormatInt(int64(m), 10)
+	default:
+		return 0, fmt.Errorf("must be an octal permission such as \"0755\"")
+	}
+	mode, err := strconv.ParseInt(digits, 8, 64)
+	if err != nil || mode < 0 || mode > 07777 {
+		return 0, fmt.Errorf("must be an octal permission such as \"0755\", got %v", v)
+	}
+	return mode, nil
+}
+
+// filesToTar packs the files into a tar stream rooted at the container's filesystem root. Missing parent
+// directories are created by the Docker API while extracting, and existing ones keep their permissions.
+func filesToTar(files []batchFile, modTime time.Time) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    strings.TrimPrefix(f.Path, "/"),
+			Mode:    f.Mode,
+			Size:    int64(len(f.Data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to write tar header for %s: %w", f.Path, err)
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return nil, fmt.Errorf("failed to write tar content for %s: %w", f.Path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilesArgument(t *testing.T) {
+	files, err := parseFilesArgument([]any{
+		map[string]any{"path": "main.py", "contents": "print('hi')\n"},
+		map[string]any{"path": "src/pkg/util.py", "contents": ""},
+		map[string]any{"path": "/tmp/data.bin", "contents": base64.StdEncoding.EncodeToString([]byte{0, 1, 2}), "encoding": "base64"},
+		map[string]any{"path": "run.sh", "contents": "#!/bin/sh\n", "mode": "0755"},
+		map[string]any{"path": "bin/tool", "contents": "x", "mode": float64(700)},
+	}, "/workspace")
+	require.NoError(t, err)
+	assert.Equal(t, []batchFile{
+		{Path: "/workspace/main.py", Data: []byte("print('hi')\n"), Mode: 0644},
+		{Path: "/workspace/src/pkg/util.py", Data: []byte{}, Mode: 0644},
+		{Path: "/tmp/data.bin", Data: []byte{0, 1, 2}, Mode: 0644},
+		{Path: "/workspace/run.sh", Data: []byte("#!/bin/sh\n"), Mode: 0755},
+		{Path: "/workspace/bin/tool", Data: []byte("x"), Mode: 0700},
+	}, files)
+}
+
+func TestParseFilesArgumentRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"missing", nil, "files must be a non-empty array"},
+		{"empty", []any{}, "files must be a non-empty array"},
+		{"not an object", []any{"main.py"}, "files[0] must be an object"},
+		{"no path", []any{map[string]any{"contents": "x"}}, "files[0].path must name a file"},
+		{"directory path", []any{map[string]any{"path": "src/", "contents": "x"}}, "files[0].path must name a file"},
+		{"root", []any{map[string]any{"path": "/app/../", "contents": "x"}}, "files[0].path must name a file"},
+		{"no contents", []any{map[string]any{"path": "a.txt"}}, "files[0].contents must be a string"},
+		{"duplicate", []any{
+			map[string]any{"path": "a.txt", "contents": "x"},
+			map[string]any{"path": "/app/a.txt", "contents": "y"},
+		}, "files[1]: /app/a.txt is listed more than once"},
+		{"bad base64", []any{map[string]any{"path": "a.bin", "contents": "!!", "encoding": "base64"}}, "files[0].contents is not valid base64"},
+		{"bad encoding", []any{map[string]any{"path": "a.txt", "contents": "x", "encoding": "hex"}}, `files[0].encoding must be utf8, base64 or gzip+base64, got "hex"`},
+		{"corrupt gzip", []any{map[string]any{"path": "a.txt", "contents": "aGVsbG8=", "encoding": "gzip+base64"}}, "files[0].contents is not a gzip stream"},
+		{"bad mode", []any{map[string]any{"path": "a.txt", "contents": "x", "mode": "rwx"}}, "files[0].mode must be an octal permission"},
+		{"mode out of range", []any{map[string]any{"path": "a.txt", "contents": "x", "mode": "17777"}}, "files[0].mode must be an octal permission"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFilesArgument(tt.value, "/app")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestFilesToTar(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	archive, err := filesToTar([]batchFile{
+		{Path: "/app/src/main.go", Data: []byte("package main\n"), Mode: 0644},
+		{Path: "/app/run.sh", Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	}, modTime)
+	require.NoError(t, err)
+
+	tr := tar.NewReader(archive)
+	header, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "app/src/main.go", header.Name)
+	assert.Equal(t, int64(0644), header.Mode)
+	assert.True(t, header.ModTime.Equal(modTime))
+	data, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(data))
+
+	header, err = tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "app/run.sh", header.Name)
+	assert.Equal(t, int64(0755), header.Mode)
+
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWithoutJunkFiles(t *testing.T) {
+	files := []batchFile{
+		{Path: "/app/main.py"},
+		{Path: "/app/.DS_Store"},
+		{Path: "/app/pkg/__pycache__/util.cpython-312.pyc"},
+		{Path: "/app/pkg/util.py"},
+		{Path: "/app/.main.py.swp"},
+	}
+	kept, skipped := withoutJunkFiles(files)
+	assert.Equal(t, []batchFile{{Path: "/app/main.py"}, {Path: "/app/pkg/util.py"}}, kept)
+	assert.Equal(t, 3, skipped)
+	assert.Len(t, files, 5, "the input is left as it was")
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// timingKeys name the timing entry each span adds its duration to; starting a container counts as part of
+// creating it
+var timingKeys = map[string]string{
+	"image.pull":           "image_pull_ms",
+	"container.create":     "container_create_ms",
+	"container.start":      "container_create_ms",
+	"dependencies.install": "install_ms",
+	"container.exec":       "exec_ms",
+	"container.wait":       "exec_ms",
+	"logs.collect":         "log_collect_ms",
+}
+
+// Timing adds up how long the phases of a tool call took, from the spans started under it. A span inside
+// another timed span, such as the execs of dependencies.install, counts only toward the outer one.
+type Timing struct {
+	mu       sync.Mutex
+	phases   map[string]time.Duration
+	recorded bool
+}
+
+type timingKey struct{}
+
+// timedPhaseKey marks a context inside a timed span
+type timedPhaseKey struct{}
+
+// WithTiming returns ctx with a Timing that the spans started under it report to, or the one ctx already has
+func WithTiming(ctx context.Context) (context.Context, *Timing) {
+	if timing, ok := ctx.Value(timingKey{}).(*Timing); ok {
+		return ctx, timing
+	}
+	timing := &Timing{phases: map[string]time.Duration{}}
+	return context.WithValue(ctx, timingKey{}, timing), timing
+}
+
+// Milliseconds returns the duration of each phase so far in milliseconds, e.g. {"image_pull_ms": 850}, or nil
+// when no timed span has ended
+func (t *Timing) Milliseconds() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.recorded {
+		return nil
+	}
+	ms := make(map[string]int64, len(t.phases))
+	for key, d := range t.phases {
+		ms[key] = d.Milliseconds()
+	}
+	return ms
+}
+
+func (t *Timing) add(key string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases[key] += d
+	t.recorded = true
+}
+
+// timedSpan reports its duration to a Timing when it ends
+type timedSpan struct {
+	trace.Span
+	timing *Timing
+	key    string
+	start  time.Time
+	once   sync.Once
+}
+
+func (s *timedSpan) End(options ...trace.SpanEndOption) {
+	s.once.Do(func() { s.timing.add(s.key, time.Since(s.start)) })
+	s.Span.End(options...)
+}
+
+// timed wraps a span just started under ctx so its duration reaches the Timing of ctx
+func timed(ctx context.Context, name string, span trace.Span) (context.Context, trace.Span) {
+	timing, ok := ctx.Value(timingKey{}).(*Timing)
+	key, timedName := timingKeys[name]
+	if !ok || !timedName || ctx.Value(timedPhaseKey{}) != nil {
+		return ctx, span
+	}
+	return context.WithValue(ctx, timedPhaseKey{}, true), &timedSpan{Span: span, timing: timing, key: key, start: time.Now()}
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName names the tracer of the server's spans
+const instrumentationName = "github.com/Automata-Labs-team/code-sandbox-mcp"
+
+// Attribute keys of the server's spans
+const (
+	ToolKey      = attribute.Key("mcp.tool.name")
+	ContainerKey = attribute.Key("container.id")
+	ImageKey     = attribute.Key("container.image.name")
+	LanguageKey  = attribute.Key("sandbox.language")
+	ExitCodeKey  = attribute.Key("process.exit.code")
+)
+
+// tracer starts every span; until Setup or UseTracerProvider it is a no-op, so spans cost next to nothing
+var tracer trace.Tracer = noop.NewTracerProvider().Tracer(instrumentationName)
+
+// Setup exports traces over OTLP/HTTP to endpoint, a URL such as http://localhost:4318. The returned function
+// flushes the spans still buffered and stops the export.
+func Setup(ctx context.Context, endpoint string, version string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --otel-endpoint %q: %w", endpoint, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName("code-sandbox-mcp"),
+			semconv.ServiceVersion(version),
+		)),
+	)
+	UseTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// UseTracerProvider starts the server's spans with provider, e.g. one with an in-memory exporter in tests
+func UseTracerProvider(provider trace.TracerProvider) {
+	tracer = provider.Tracer(instrumentationName)
+}
+
+// Start starts a child span of the span in ctx; when ctx has a Timing, the span's duration is added to it
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return timed(ctx, name, span)
+}
+
+// End ends a span, marking it failed when err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware wraps each tool call in a span named after the tool, so the spans of the Docker calls it makes
+// become its children. The sandbox, image and language arguments are recorded when a tool has them.
+func Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		attrs := []attribute.KeyValue{ToolKey.String(tool)}
+		for key, arg := range map[attribute.Key]string{
+			ContainerKey: "container_id_or_name",
+			ImageKey:     "image",
+			LanguageKey:  "language",
+		} {
+			if value := request.GetString(arg, ""); value != "" {
+				attrs = append(attrs, key.String(value))
+			}
+		}
+		ctx, span := tracer.Start(ctx, "tools/call "+tool, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		result, err := next(ctx, request)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if message, failed := failure(result); failed {
+			span.SetStatus(codes.Error, message)
+		}
+		return result, err
+	}
+}
+
+// failure returns the message of a failed call; tools report most failures as "Error: ..." text rather than as
+// an error result
+func failure(result *mcp.CallToolResult) (string, bool) {
+	if result == nil || len(result.Content) == 0 {
+		return "", result != nil && result.IsError
+	}
+	text, _ := result.Content[0].(mcp.TextContent)
+	message, _, _ := strings.Cut(text.Text, "\n")
+	return message, result.IsError || strings.HasPrefix(text.Text, "Error")
+}
@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans sends the spans of the test to an in-memory exporter
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	saved := tracer
+	UseTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { tracer = saved })
+	return exporter
+}
+
+func request(tool string, args map[string]any) mcp.CallToolRequest {
+	return mcp.CallToolRequest{Params: mcp.CallToolParams{Name: tool, Arguments: args}}
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestNoopByDefault(t *testing.T) {
+	_, span := Start(context.Background(), "container.create")
+	assert.False(t, span.IsRecording())
+	assert.False(t, span.SpanContext().IsValid())
+	End(span, errors.New("ignored"))
+}
+
+func TestMiddlewareSpans(t *testing.T) {
+	exporter := recordSpans(t)
+	handler := Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		_, pull := Start(ctx, "image.pull", ImageKey.String("alpine:3.20"))
+		End(pull, nil)
+		_, wait := Start(ctx, "container.wait", ContainerKey.String("abc123"))
+		wait.SetAttributes(ExitCodeKey.Int(3))
+		End(wait, nil)
+		return mcp.NewToolResultText("{}"), nil
+	})
+	_, err := handler(context.Background(), request("run_command", map[string]any{"image": "alpine:3.20", "command": "exit 3"}))
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	pull, wait, call := spans[0], spans[1], spans[2]
+	assert.Equal(t, "tools/call run_command", call.Name)
+	assert.False(t, call.Parent.IsValid())
+	assert.Equal(t, codes.Unset, call.Status.Code)
+	assert.Equal(t, "run_command", attributes(call)[ToolKey].AsString())
+	assert.Equal(t, "alpine:3.20", attributes(call)[ImageKey].AsString())
+	for _, child := range []tracetest.SpanStub{pull, wait} {
+		assert.Equal(t, call.SpanContext.SpanID(), child.Parent.SpanID(), child.Name)
+		assert.Equal(t, call.SpanContext.TraceID(), child.SpanContext.TraceID(), child.Name)
+	}
+	assert.Equal(t, int64(3), attributes(wait)[ExitCodeKey].AsInt64())
+}
+
+func TestMiddlewareFailures(t *testing.T) {
+	exporter := recordSpans(t)
+	results := []struct {
+		result *mcp.CallToolResult
+		err    error
+		want   string
+	}{
+		{mcp.NewToolResultText("Error: no such container\nmore"), nil, "Error: no such container"},
+		{mcp.NewToolResultError("INVALID_ARGUMENT: image must be a string, got true"), nil, "INVALID_ARGUMENT: image must be a string, got true"},
+		{nil, errors.New("JSON_SERIALIZE_ERROR: boom"), "JSON_SERIALIZE_ERROR: boom"},
+	}
+	for _, r := range results {
+		handler := Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return r.result, r.err
+		})
+		_, _ = handler(context.Background(), request("sandbox_exec", map[string]any{"container_id_or_name": "box"}))
+	}
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, len(results))
+	for i, span := range spans {
+		assert.Equal(t, codes.Error, span.Status.Code)
+		assert.Equal(t, results[i].want, span.Status.Description)
+		assert.Equal(t, "box", attributes(span)[ContainerKey].AsString())
+	}
+}
+
+func TestTiming(t *testing.T) {
+	ctx, timing := WithTiming(context.Background())
+	shared, same := WithTiming(ctx)
+	assert.Same(t, timing, same, "a nested WithTiming reports to the outer Timing")
+	assert.Nil(t, timing.Milliseconds())
+
+	phase := func(ctx context.Context, name string, d time.Duration) context.Context {
+		ctx, span := Start(ctx, name)
+		time.Sleep(d)
+		End(span, nil)
+		return ctx
+	}
+	phase(shared, "image.pull", 10*time.Millisecond)
+	phase(ctx, "container.create", 5*time.Millisecond)
+	phase(ctx, "container.start", 5*time.Millisecond)
+	phase(ctx, "tools/call other", 5*time.Millisecond)
+
+	// The execs of an install count toward install_ms only
+	installCtx, install := Start(ctx, "dependencies.install")
+	phase(installCtx, "container.exec", 10*time.Millisecond)
+	End(install, nil)
+
+	ms := timing.Milliseconds()
+	assert.ElementsMatch(t, []string{"image_pull_ms", "container_create_ms", "install_ms"}, keys(ms))
+	assert.GreaterOrEqual(t, ms["image_pull_ms"], int64(10))
+	assert.GreaterOrEqual(t, ms["container_create_ms"], int64(10))
+	assert.GreaterOrEqual(t, ms["install_ms"], int64(10))
+
+	// Without a Timing, spans are not wrapped
+	_, span := Start(context.Background(), "image.pull")
+	_, wrapped := span.(*timedSpan)
+	assert.False(t, wrapped)
+}
+
+func keys(m map[string]int64) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
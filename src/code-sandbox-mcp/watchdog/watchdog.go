@@ -0,0 +1,115 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultTimeout is the deadline of a tool call unless SANDBOX_CALL_TIMEOUT is set. It stays just under the
+// 60 seconds common MCP clients wait before abandoning a call.
+const DefaultTimeout = 55 * time.Second
+
+// MetaTimeoutKey is the _meta field that overrides the deadline of a single call, in seconds; 0 disables it
+const MetaTimeoutKey = "timeoutSeconds"
+
+// Slack is added to the limit of a tool that enforces one of its own, so the tool ends a call that runs out of
+// time and returns what it has before the watchdog steps in
+const Slack = 15 * time.Second
+
+// ToolTimeouts gives the tools with a limit of their own, such as the timeout_seconds of run_command, the longest
+// a call may run by that limit; 0 means the call has no limit
+type ToolTimeouts map[string]func(request mcp.CallToolRequest) time.Duration
+
+// grace is how long a handler may take to return its partial result once its deadline has passed
+var grace = 2 * time.Second
+
+// Middleware gives each tool call a deadline through its context. Handlers that watch the context return what
+// they have when it expires; a handler that is still running after a short grace period is abandoned and the
+// client gets a WATCHDOG_TIMEOUT error instead of no answer at all. A tool in own gets its own limit plus Slack
+// when that is longer than timeout.
+func Middleware(timeout time.Duration, own ToolTimeouts) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			timeout := callTimeout(request, timeout, own)
+			if timeout <= 0 {
+				return next(ctx, request)
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type reply struct {
+				result *mcp.CallToolResult
+				err    error
+			}
+			replies := make(chan reply, 1)
+			go func() {
+				result, err := next(ctx, request)
+				replies <- reply{result, err}
+			}()
+
+			var r reply
+			select {
+			case r = <-replies:
+			case <-ctx.Done():
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					// The client cancelled the call; nobody is waiting for a partial result
+					r = <-replies
+					break
+				}
+				select {
+				case r = <-replies:
+				case <-time.After(grace):
+					return timeoutResult(request, timeout), nil
+				}
+			}
+			if r.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return timeoutResult(request, timeout), nil
+			}
+			return r.result, r.err
+		}
+	}
+}
+
+// callTimeout returns the deadline requested in the call's _meta, or fallback, extended to the tool's own limit
+func callTimeout(request mcp.CallToolRequest, fallback time.Duration, own ToolTimeouts) time.Duration {
+	if request.Params.Meta != nil {
+		if seconds, ok := request.Params.Meta.AdditionalFields[MetaTimeoutKey].(float64); ok && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+	limit, ok := own[request.Params.Name]
+	if !ok || fallback <= 0 {
+		return fallback
+	}
+	l := limit(request)
+	if l <= 0 {
+		return 0
+	}
+	return max(fallback, l+Slack)
+}
+
+func timeoutResult(request mcp.CallToolRequest, timeout time.Duration) *mcp.CallToolResult {
+	return mcp.NewToolResultError(fmt.Sprintf(
+		"WATCHDOG_TIMEOUT: %s did not finish within %s; it may still be running. Check executions_list or the "+
+			"containers://{id}/logs resource for its output, or retry with a longer _meta.%s.",
+		request.Params.Name, timeout, MetaTimeoutKey))
+}
+
+// TimeoutFromEnv reads SANDBOX_CALL_TIMEOUT, a duration such as 55s or 5m; 0 disables the watchdog
+func TimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("SANDBOX_CALL_TIMEOUT")
+	if value == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("SANDBOX_CALL_TIMEOUT: invalid duration %q", value)
+	}
+	return timeout, nil
+}
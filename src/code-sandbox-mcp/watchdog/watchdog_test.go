@@ -0,0 +1,131 @@
+package watchdog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func call(t *testing.T, timeout time.Duration, meta map[string]any, handler func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)) (string, bool, time.Duration) {
+	t.Helper()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "sandbox_exec"
+	if meta != nil {
+		request.Params.Meta = &mcp.Meta{AdditionalFields: meta}
+	}
+	start := time.Now()
+	result, err := Middleware(timeout, nil)(handler)(context.Background(), request)
+	require.NoError(t, err)
+	return result.Content[0].(mcp.TextContent).Text, result.IsError, time.Since(start)
+}
+
+// sleepUntilDone returns partial output when its context ends, like sandbox_exec does
+func sleepUntilDone(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	select {
+	case <-ctx.Done():
+		return mcp.NewToolResultText("partial output"), nil
+	case <-time.After(10 * time.Second):
+		return mcp.NewToolResultText("full output"), nil
+	}
+}
+
+func TestMiddlewareReturnsPartialResult(t *testing.T) {
+	text, isError, elapsed := call(t, 100*time.Millisecond, nil, sleepUntilDone)
+	assert.Equal(t, "partial output", text)
+	assert.False(t, isError)
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestMiddlewareAbandonsStuckHandler(t *testing.T) {
+	defer func(g time.Duration) { grace = g }(grace)
+	grace = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	text, isError, elapsed := call(t, 50*time.Millisecond, nil, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-release
+		return mcp.NewToolResultText("too late"), nil
+	})
+	assert.True(t, isError)
+	assert.Contains(t, text, "WATCHDOG_TIMEOUT: sandbox_exec did not finish within 50ms")
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestMiddlewareReportsDeadlineErrors(t *testing.T) {
+	text, isError, _ := call(t, 50*time.Millisecond, nil, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.True(t, isError)
+	assert.Contains(t, text, "WATCHDOG_TIMEOUT: ")
+}
+
+func TestMiddlewareMetaOverride(t *testing.T) {
+	quick := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		select {
+		case <-ctx.Done():
+			return mcp.NewToolResultText("partial output"), nil
+		case <-time.After(200 * time.Millisecond):
+			return mcp.NewToolResultText("full output"), nil
+		}
+	}
+
+	// The call's own deadline replaces the configured one, and 0 disables the watchdog
+	text, _, _ := call(t, 50*time.Millisecond, map[string]any{MetaTimeoutKey: float64(5)}, quick)
+	assert.Equal(t, "full output", text)
+	text, _, _ = call(t, 50*time.Millisecond, map[string]any{MetaTimeoutKey: float64(0)}, quick)
+	assert.Equal(t, "full output", text)
+	text, _, _ = call(t, 5*time.Second, map[string]any{MetaTimeoutKey: 0.05}, quick)
+	assert.Equal(t, "partial output", text)
+
+	text, _, _ = call(t, 0, nil, quick)
+	assert.Equal(t, "full output", text)
+}
+
+func TestCallTimeoutToolLimits(t *testing.T) {
+	own := ToolTimeouts{
+		"run_command": func(request mcp.CallToolRequest) time.Duration {
+			seconds, _ := request.GetArguments()["timeout_seconds"].(float64)
+			return time.Duration(seconds) * time.Second
+		},
+	}
+	request := func(name string, args map[string]any, meta map[string]any) mcp.CallToolRequest {
+		var r mcp.CallToolRequest
+		r.Params.Name = name
+		r.Params.Arguments = args
+		if meta != nil {
+			r.Params.Meta = &mcp.Meta{AdditionalFields: meta}
+		}
+		return r
+	}
+
+	// A tool's own longer limit extends the deadline by Slack; a shorter one leaves it alone
+	assert.Equal(t, 600*time.Second+Slack, callTimeout(request("run_command", map[string]any{"timeout_seconds": float64(600)}, nil), DefaultTimeout, own))
+	assert.Equal(t, DefaultTimeout, callTimeout(request("run_command", map[string]any{"timeout_seconds": float64(5)}, nil), DefaultTimeout, own))
+	// A tool without a limit of its own has none from the watchdog either
+	assert.Zero(t, callTimeout(request("run_command", nil, nil), DefaultTimeout, own))
+	// Other tools, _meta and a disabled watchdog are unaffected
+	assert.Equal(t, DefaultTimeout, callTimeout(request("sandbox_exec", nil, nil), DefaultTimeout, own))
+	assert.Equal(t, 2*time.Second, callTimeout(request("run_command", map[string]any{"timeout_seconds": float64(600)}, map[string]any{MetaTimeoutKey: float64(2)}), DefaultTimeout, own))
+	assert.Zero(t, callTimeout(request("run_command", map[string]any{"timeout_seconds": float64(600)}, nil), 0, own))
+}
+
+func TestTimeoutFromEnv(t *testing.T) {
+	t.Setenv("SANDBOX_CALL_TIMEOUT", "")
+	timeout, err := TimeoutFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, timeout)
+
+	t.Setenv("SANDBOX_CALL_TIMEOUT", "2m")
+	timeout, err = TimeoutFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, timeout)
+
+	t.Setenv("SANDBOX_CALL_TIMEOUT", "soon")
+	_, err = TimeoutFromEnv()
+	assert.ErrorContains(t, err, `invalid duration "soon"`)
+}